@@ -1,6 +1,8 @@
 import openModal from "./Modal.js";
 import Button from "./Button.js";
 import CalendarSvc from "../services/calendar.js";
+import DigestSvc from "../services/digest.js";
+import PushSvc from "../services/push.js";
 import { Calendar as CalendarSVG } from "../Icons/Calendar.js";
 import { Copy as CopySVG } from "../Icons/Copy.js";
 
@@ -12,6 +14,19 @@ function svgFromString(svgStr) {
   const doc = new DOMParser().parseFromString(svgStr, "image/svg+xml");
   return doc.documentElement; // <svg>
 }
+function select(id, options, value) {
+  const el = document.createElement("select");
+  el.id = id;
+  el.className = "input-field cal-sub__input";
+  for (const [v, text] of options) {
+    const opt = document.createElement("option");
+    opt.value = String(v);
+    opt.textContent = text;
+    el.append(opt);
+  }
+  el.value = String(value);
+  return el;
+}
 function normalizeIconColors(svgEl) {
   // use currentColor so CSS controls light/dark
   const all = [svgEl, ...svgEl.querySelectorAll("*")];
@@ -81,6 +96,12 @@ export function mountCalendarSubscribe() {
 
     body.append(label, row, actions);
     modal.setBody(body);
+    // Slots keep the sections in order whichever loads first.
+    const digestSlot = document.createElement("div");
+    const pushSlot = document.createElement("div");
+    body.append(digestSlot, pushSlot);
+    mountDigestSettings(digestSlot);
+    mountPushSettings(pushSlot);
 
     // data wiring …
     let tokenData;
@@ -92,8 +113,17 @@ export function mountCalendarSubscribe() {
       rotateBtn.disabled = true;
       return;
     }
-    const setUrl = (p) => { input.value = CalendarSvc.toAbsoluteUrl(p); };
-    setUrl(tokenData.urlPath);
+    const setUrl = (p) => {
+      input.value = CalendarSvc.toAbsoluteUrl(p);
+      copyBtn.disabled = false;
+    };
+    if (tokenData.urlPath) {
+      setUrl(tokenData.urlPath);
+    } else {
+      // Links are stored hashed, so an existing one can't be shown again.
+      input.value = "Your link is hidden. Use “New URL” to create a new one.";
+      copyBtn.disabled = true;
+    }
 
     copyBtn.addEventListener("click", async () => {
       try {
@@ -124,6 +154,176 @@ export function mountCalendarSubscribe() {
   });
 }
 
+// Email digest settings, appended below the subscription URL once loaded.
+async function mountDigestSettings(body) {
+  let settings;
+  try {
+    settings = await DigestSvc.getSettings();
+  } catch {
+    return;
+  }
+  if (!settings || !settings.mailEnabled) return;
+
+  const section = document.createElement("div");
+  section.className = "cal-sub__digest";
+  const label = document.createElement("label");
+  label.className = "cal-sub__label";
+  label.setAttribute("for", "cal-sub-digest");
+  label.textContent = "Email reminders";
+
+  const frequency = select("cal-sub-digest", [
+    ["off", "Off"],
+    ["daily", "Daily digest"],
+    ["weekly", "Weekly digest"],
+  ], settings.frequency);
+  const days = ["Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"];
+  const weekday = select("cal-sub-digest-day", days.map((d, i) => [i, d]), settings.weekday);
+  const hour = select(
+    "cal-sub-digest-hour",
+    Array.from({ length: 24 }, (_, h) => [h, `${String(h).padStart(2, "0")}:00`]),
+    settings.hour
+  );
+  const row = document.createElement("div");
+  row.className = "cal-sub__schedule";
+  row.append(weekday, hour);
+
+  const actions = document.createElement("div");
+  actions.className = "cal-sub__actions";
+  const saveBtn = Button({ label: "Save", type: "default" });
+  actions.append(saveBtn);
+
+  const sync = () => {
+    row.hidden = frequency.value === "off";
+    weekday.hidden = frequency.value !== "weekly";
+  };
+  frequency.addEventListener("change", sync);
+  sync();
+
+  saveBtn.addEventListener("click", async () => {
+    saveBtn.disabled = true;
+    try {
+      await DigestSvc.saveSettings({
+        frequency: frequency.value,
+        hour: Number(hour.value),
+        weekday: Number(weekday.value),
+        timezone: DigestSvc.localTimezone(),
+      });
+      saveBtn.textContent = "Saved";
+    } catch {
+      saveBtn.textContent = "Failed. Try again";
+    } finally {
+      saveBtn.disabled = false;
+      setTimeout(() => (saveBtn.textContent = "Save"), 1100);
+    }
+  });
+
+  section.append(label, frequency, row, actions);
+  body.append(section);
+}
+
+// Push notification settings for this browser.
+async function mountPushSettings(body) {
+  if (!PushSvc.supported()) return;
+  let settings;
+  try {
+    settings = await PushSvc.getSettings();
+  } catch {
+    return;
+  }
+  if (!settings || !settings.enabled) return;
+
+  const section = document.createElement("div");
+  section.className = "cal-sub__digest";
+  const label = document.createElement("label");
+  label.className = "cal-sub__label";
+  label.setAttribute("for", "cal-sub-push-lead");
+  label.textContent = "Notifications on this device";
+
+  const lead = select(
+    "cal-sub-push-lead",
+    [...new Set([1, 3, 6, 12, 24, 48, 72, 168, settings.leadHours])]
+      .sort((a, b) => a - b)
+      .map((h) => [h, h >= 48 && h % 24 === 0 ? `${h / 24} days before` : `${h} hour${h === 1 ? "" : "s"} before`]),
+    settings.leadHours
+  );
+  const kinds = document.createElement("div");
+  kinds.className = "cal-sub__schedule";
+  const boxes = {};
+  for (const [key, text] of [["chapters", "Chapters"], ["articles", "Articles"], ["assignments", "Assignments"]]) {
+    const wrap = document.createElement("label");
+    const box = document.createElement("input");
+    box.type = "checkbox";
+    box.checked = settings[key];
+    boxes[key] = box;
+    wrap.append(box, ` ${text}`);
+    kinds.append(wrap);
+  }
+
+  const actions = document.createElement("div");
+  actions.className = "cal-sub__actions";
+  const toggleBtn = Button({ label: "Turn on", type: "default" });
+  const testBtn = Button({ label: "Test", type: "default" });
+  const saveBtn = Button({ label: "Save", type: "default" });
+  actions.append(testBtn, toggleBtn, saveBtn);
+
+  const flash = (btn, text, back) => {
+    btn.textContent = text;
+    setTimeout(() => (btn.textContent = back), 1100);
+  };
+  const sync = async () => {
+    const on = !!(await PushSvc.current().catch(() => null));
+    toggleBtn.textContent = on ? "Turn off" : "Turn on";
+    testBtn.hidden = !on;
+    return on;
+  };
+  await sync();
+
+  toggleBtn.addEventListener("click", async () => {
+    toggleBtn.disabled = true;
+    try {
+      if (await PushSvc.current()) await PushSvc.unsubscribe();
+      else await PushSvc.subscribe(settings.publicKey);
+      await sync();
+    } catch (err) {
+      flash(toggleBtn, err.message.startsWith("HTTP") ? "Failed. Try again" : err.message, toggleBtn.textContent);
+    } finally {
+      toggleBtn.disabled = false;
+    }
+  });
+
+  testBtn.addEventListener("click", async () => {
+    testBtn.disabled = true;
+    try {
+      await PushSvc.sendTest();
+      flash(testBtn, "Sent", "Test");
+    } catch {
+      flash(testBtn, "Failed", "Test");
+    } finally {
+      testBtn.disabled = false;
+    }
+  });
+
+  saveBtn.addEventListener("click", async () => {
+    saveBtn.disabled = true;
+    try {
+      settings = await PushSvc.saveSettings({
+        leadHours: Number(lead.value),
+        chapters: boxes.chapters.checked,
+        articles: boxes.articles.checked,
+        assignments: boxes.assignments.checked,
+      });
+      flash(saveBtn, "Saved", "Save");
+    } catch {
+      flash(saveBtn, "Failed. Try again", "Save");
+    } finally {
+      saveBtn.disabled = false;
+    }
+  });
+
+  section.append(label, lead, kinds, actions);
+  body.append(section);
+}
+
 export function unmountCalendarSubscribe() {
   const fab = document.getElementById("calendar-subscribe-fab");
   if (fab) fab.remove();
@@ -1,5 +1,6 @@
 import { router, initRoutes } from "./router.js";
 import auth from "./services/auth.js";
+import events from "./services/events.js";
 import { mountBanner, updateBanner } from "./components/Banner.js";
 import { Toast } from "./components/Toast.js";
 import { mountCalendarSubscribe, unmountCalendarSubscribe } from "./components/CalendarSubscribe.js";
@@ -31,6 +32,7 @@ async function boot() {
 
   // ensure correct initial state
   if (user) mountCalendarSubscribe(); else unmountCalendarSubscribe();
+  if (user) events.connect(); else events.disconnect();
 
   // react to login/register/logout everywhere
   window.addEventListener("auth:changed", async (e) => {
@@ -38,6 +40,7 @@ async function boot() {
     updateBanner({ user: nextUser });
 
     if (nextUser) mountCalendarSubscribe(); else unmountCalendarSubscribe();
+    if (nextUser) events.connect(); else events.disconnect();
 
     router.updatePageLinks();
   });
@@ -58,6 +58,26 @@ export default function CourseSection({
   slot.append(list, footer);
   window.router?.updatePageLinks?.();
 
+  // live updates: re-render a course's body when something in it changes
+  // (see services/events.js); stop listening once this section is replaced
+  const pending = new Map(); // courseId -> timer, to coalesce bursts
+  function onCourseChanged(e) {
+    if (!list.isConnected) {
+      window.removeEventListener("course:changed", onCourseChanged);
+      return;
+    }
+    const courseId = e.detail?.courseId ?? null;
+    const targets = courseId == null ? my : my.filter((c) => c.id === courseId);
+    for (const c of targets) {
+      clearTimeout(pending.get(c.id));
+      pending.set(c.id, setTimeout(() => {
+        pending.delete(c.id);
+        refreshCourseCard(c);
+      }, 250));
+    }
+  }
+  window.addEventListener("course:changed", onCourseChanged);
+
   // ---------- helpers ----------
 
   function makeCourseCard(c) {
@@ -74,6 +94,13 @@ export default function CourseSection({
     return card;
   }
 
+  function refreshCourseCard(c) {
+    const card = list.querySelector(`.exp-card[data-course-id="${c.id}"]`);
+    if (!card) return;
+    const node = renderBody(c);
+    card.setContent(node instanceof Node ? node : null);
+  }
+
   function addCourseToUI(c) {
     if (emptyCard) {
       emptyCard.remove();
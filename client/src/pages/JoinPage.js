@@ -0,0 +1,18 @@
+import { getMe } from "../util/index.js";
+import universityService from "../services/universities.js";
+import { Toast } from "../components/Toast.js";
+
+// #/join/:code — accepts an invite link, then goes to the universities list.
+export default async function JoinPage(code) {
+  const me = await getMe();
+  if (!me) return;
+
+  try {
+    await universityService.acceptInvite(code);
+    Toast("success", "Invite accepted");
+  } catch (err) {
+    console.error(err);
+    Toast("error", err?.message || "Failed to accept invite");
+  }
+  window.router.navigate("/universities");
+}
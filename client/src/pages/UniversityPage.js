@@ -106,7 +106,11 @@ export default async function UniversityPage() {
       actionLabel: "Join",
       onPick: async (u) => {
         try {
-          await universityService.join(u.id);
+          const res = await universityService.join(u.id);
+          if (res.status === "pending") {
+            Toast("success", `Request to join ${u.name} sent`);
+            return;
+          }
           Toast("success", `Joined ${u.name}`);
           await refresh();
         } catch (e) {
@@ -136,6 +140,11 @@ export default async function UniversityPage() {
       onSubmit: async (name) => {
         try {
           const created = await universityService.create(name.trim());
+          if (created.status === "pending") {
+            // Can't join until an admin approves it
+            Toast("success", `Submitted ${created.name} for review`);
+            return;
+          }
           // Join right after create (endpoint is idempotent)
           await universityService.join(created.id);
 
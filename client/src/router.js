@@ -2,6 +2,7 @@ import HomePage from "./pages/HomePage.js";
 import LoginPage from "./pages/LoginPage.js";
 import UniversityPage from "./pages/UniversityPage.js";
 import UniversityHomePage from "./pages/UniversityHomePage.js";
+import JoinPage from "./pages/JoinPage.js";
 
 export const router = new Navigo("/", { hash: true });
 
@@ -12,6 +13,7 @@ export function initRoutes() {
     .on("/", () => HomePage())
     .on("/login", () => LoginPage())
     .on("/universities", () => UniversityPage())
+    .on("/join/:code", ({ data }) => JoinPage(data.code))
 
     // base (no tab)
     .on("/universities/:slug", ({ data }) => UniversityHomePage(data.slug, null))
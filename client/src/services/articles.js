@@ -1,3 +1,5 @@
+import { readAllPages } from "../util/pages.js";
+
 class ArticlesService {
   constructor(apiBase) {
     this.API_BASE = apiBase;
@@ -8,10 +10,11 @@ class ArticlesService {
     const cid = Number(courseId);
     if (!Number.isInteger(cid) || cid <= 0) throw new Error("Invalid courseId");
 
-    const url = `${this.API_BASE}/articles?courseId=${encodeURIComponent(cid)}`;
-    const res = await fetch(url, { headers: { Accept: "application/json" } });
+    const url = `${this.API_BASE}/articles?courseId=${encodeURIComponent(cid)}&limit=200`;
+    const init = { headers: { Accept: "application/json" } };
+    const res = await fetch(url, init);
     if (!res.ok) throw new Error(`HTTP ${res.status}`);
-    return res.json(); // [{ id, title, completed, ... }]
+    return readAllPages(res, url, init); // [{ id, title, completed, ... }]
   }
 
   /* POST /api/articles */
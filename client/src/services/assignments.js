@@ -1,3 +1,5 @@
+import { readAllPages } from "../util/pages.js";
+
 class AssignmentsService {
   constructor(apiBase) {
     this.API_BASE = apiBase;
@@ -8,10 +10,11 @@ class AssignmentsService {
     const cid = Number(courseId);
     if (!Number.isInteger(cid) || cid <= 0) throw new Error("Invalid courseId");
 
-    const url = `${this.API_BASE}/assignments?courseId=${encodeURIComponent(cid)}`;
-    const res = await fetch(url, { headers: { Accept: "application/json" } });
+    const url = `${this.API_BASE}/assignments?courseId=${encodeURIComponent(cid)}&limit=200`;
+    const init = { headers: { Accept: "application/json" } };
+    const res = await fetch(url, init);
     if (!res.ok) throw new Error(`HTTP ${res.status}`);
-    return res.json(); // [{ id, title, completed, deadline? }, ...]
+    return readAllPages(res, url, init); // [{ id, title, completed, deadline? }, ...]
   }
 
   /* POST /api/assignments */
@@ -1,3 +1,5 @@
+import { readAllPages } from "../util/pages.js";
+
 class BooksService {
   constructor(apiBase = "/api") {
     this.API_BASE = apiBase;
@@ -12,10 +14,9 @@ class BooksService {
     if (!Number.isInteger(cid) || cid <= 0) throw new Error("Invalid courseId");
 
     try {
-      const res = await fetch(
-        `${this.API_BASE}/books?courseId=${encodeURIComponent(String(cid))}`,
-        { headers: { Accept: "application/json" } }
-      );
+      const url = `${this.API_BASE}/books?courseId=${encodeURIComponent(String(cid))}&limit=200`;
+      const init = { headers: { Accept: "application/json" } };
+      const res = await fetch(url, init);
 
       if (!res.ok) {
         if (res.status === 401) throw new Error("Unauthorized");
@@ -23,7 +24,7 @@ class BooksService {
         if (res.status === 404) throw new Error("Course not found");
         throw new Error(`HTTP ${res.status}`);
       }
-      return await res.json(); // Array<Book>
+      return await readAllPages(res, url, init); // Array<Book>
     } catch (err) {
       console.error("Books getByCourse failed:", err);
       throw err;
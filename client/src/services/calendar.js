@@ -11,7 +11,7 @@ class CalendarService {
     });
     if (res.status === 401) return null;
     if (!res.ok) throw new Error(`HTTP ${res.status}`);
-    return res.json(); // { token, urlPath }
+    return res.json(); // { token, urlPath } on first call, then { exists: true }
   }
 
   // Rotate the token (invalidates the old public URL).
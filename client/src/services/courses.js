@@ -1,3 +1,5 @@
+import { readAllPages } from "../util/pages.js";
+
 class CourseService {
   constructor(apiBase) {
     this.API_BASE = apiBase;
@@ -36,15 +38,15 @@ class CourseService {
     if (!uid) throw new Error("universityId is required");
 
     try {
-      const res = await fetch(`${this.API_BASE}/course-catalog?universityId=${encodeURIComponent(uid)}`, {
-        headers: { Accept: "application/json" },
-      });
+      const url = `${this.API_BASE}/course-catalog?universityId=${encodeURIComponent(uid)}&limit=200`;
+      const init = { headers: { Accept: "application/json" } };
+      const res = await fetch(url, init);
       if (!res.ok) {
         if (res.status === 401) throw new Error("Unauthorized");
         if (res.status === 403) throw new Error("Membership required");
         throw new Error(`HTTP ${res.status}`);
       }
-      return await res.json();
+      return await readAllPages(res, url, init);
     } catch (err) {
       console.error("Courses getCatalog failed:", err);
       throw err;
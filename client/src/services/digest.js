@@ -0,0 +1,37 @@
+class DigestService {
+  constructor(apiBase) {
+    this.API_BASE = apiBase;
+  }
+
+  // { frequency, hour, weekday, timezone, last_sent_at?, mailEnabled }
+  async getSettings() {
+    const res = await fetch(`${this.API_BASE}/digest/settings`, {
+      headers: { Accept: "application/json" },
+      credentials: "include",
+    });
+    if (res.status === 401) return null;
+    if (!res.ok) throw new Error(`HTTP ${res.status}`);
+    return res.json();
+  }
+
+  // settings: { frequency: "off" | "daily" | "weekly", hour?, weekday?, timezone? }
+  async saveSettings(settings) {
+    const res = await fetch(`${this.API_BASE}/digest/settings`, {
+      method: "PUT",
+      headers: { Accept: "application/json", "Content-Type": "application/json" },
+      credentials: "include",
+      body: JSON.stringify(settings),
+    });
+    if (res.status === 401) throw new Error("Unauthorized");
+    if (!res.ok) throw new Error(`HTTP ${res.status}`);
+    return res.json();
+  }
+
+  // The browser's IANA timezone, e.g. "Europe/Amsterdam".
+  localTimezone() {
+    return Intl.DateTimeFormat().resolvedOptions().timeZone || "UTC";
+  }
+}
+
+const API_BASE = "/api";
+export default new DigestService(API_BASE);
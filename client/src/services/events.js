@@ -0,0 +1,33 @@
+class EventsService {
+  constructor(apiBase) {
+    this.API_BASE = apiBase;
+    this.source = null;
+  }
+
+  // Open GET /api/events (auth required). Every change in one of my courses is
+  // re-dispatched on window as "course:changed" with the event as detail
+  // ({ type, kind, itemId, courseId, ... }). When the server can't replay what
+  // was missed while disconnected, detail.courseId is null: refresh everything.
+  // EventSource reconnects by itself and resumes via Last-Event-ID.
+  connect() {
+    if (this.source) return;
+    const source = new EventSource(`${this.API_BASE}/events`, { withCredentials: true });
+    source.onmessage = (e) => {
+      let detail;
+      try { detail = JSON.parse(e.data); } catch { return; }
+      window.dispatchEvent(new CustomEvent("course:changed", { detail }));
+    };
+    source.addEventListener("reset", () => {
+      window.dispatchEvent(new CustomEvent("course:changed", { detail: { courseId: null } }));
+    });
+    this.source = source;
+  }
+
+  disconnect() {
+    this.source?.close();
+    this.source = null;
+  }
+}
+
+const API_BASE = "/api";
+export default new EventsService(API_BASE);
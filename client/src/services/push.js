@@ -0,0 +1,93 @@
+class PushService {
+  constructor(apiBase) {
+    this.API_BASE = apiBase;
+  }
+
+  supported() {
+    return "serviceWorker" in navigator && "PushManager" in window && "Notification" in window;
+  }
+
+  // { leadHours, chapters, articles, assignments, enabled, publicKey? }
+  async getSettings() {
+    const res = await fetch(`${this.API_BASE}/push/settings`, {
+      headers: { Accept: "application/json" },
+      credentials: "include",
+    });
+    if (res.status === 401) return null;
+    if (!res.ok) throw new Error(`HTTP ${res.status}`);
+    return res.json();
+  }
+
+  // settings: any of { leadHours, chapters, articles, assignments }
+  async saveSettings(settings) {
+    const res = await fetch(`${this.API_BASE}/push/settings`, {
+      method: "PUT",
+      headers: { Accept: "application/json", "Content-Type": "application/json" },
+      credentials: "include",
+      body: JSON.stringify(settings),
+    });
+    if (res.status === 401) throw new Error("Unauthorized");
+    if (!res.ok) throw new Error(`HTTP ${res.status}`);
+    return res.json();
+  }
+
+  // This browser's current subscription, or null.
+  async current() {
+    const reg = await navigator.serviceWorker.getRegistration("/");
+    return reg ? reg.pushManager.getSubscription() : null;
+  }
+
+  // Ask for permission, subscribe this browser and register it with the server.
+  async subscribe(publicKey) {
+    if ((await Notification.requestPermission()) !== "granted") {
+      throw new Error("Notifications are blocked for this site");
+    }
+    const reg = await navigator.serviceWorker.register("/sw.js");
+    await navigator.serviceWorker.ready;
+    const sub =
+      (await reg.pushManager.getSubscription()) ||
+      (await reg.pushManager.subscribe({
+        userVisibleOnly: true,
+        applicationServerKey: base64UrlToBytes(publicKey),
+      }));
+    const res = await fetch(`${this.API_BASE}/push/subscriptions`, {
+      method: "POST",
+      headers: { Accept: "application/json", "Content-Type": "application/json" },
+      credentials: "include",
+      body: JSON.stringify(sub),
+    });
+    if (!res.ok) throw new Error(`HTTP ${res.status}`);
+    return res.json();
+  }
+
+  // Unsubscribe this browser, here and on the server.
+  async unsubscribe() {
+    const sub = await this.current();
+    if (!sub) return;
+    await fetch(`${this.API_BASE}/push/subscriptions`, {
+      method: "DELETE",
+      headers: { "Content-Type": "application/json" },
+      credentials: "include",
+      body: JSON.stringify({ endpoint: sub.endpoint }),
+    });
+    await sub.unsubscribe();
+  }
+
+  async sendTest() {
+    const res = await fetch(`${this.API_BASE}/push/test`, {
+      method: "POST",
+      headers: { Accept: "application/json" },
+      credentials: "include",
+    });
+    if (!res.ok) throw new Error(`HTTP ${res.status}`);
+    return res.json(); // { sent }
+  }
+}
+
+function base64UrlToBytes(s) {
+  const b64 = (s + "=".repeat((4 - (s.length % 4)) % 4)).replace(/-/g, "+").replace(/_/g, "/");
+  return Uint8Array.from(atob(b64), (c) => c.charCodeAt(0));
+}
+
+const API_BASE = "/api";
+export default new PushService(API_BASE);
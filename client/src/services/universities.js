@@ -1,3 +1,6 @@
+import { readAllPages } from "../util/pages.js";
+import { problemError } from "../util/problem.js";
+
 class UniversityService {
   constructor(apiBase) {
     this.API_BASE = apiBase;
@@ -12,11 +15,11 @@ class UniversityService {
   }
 
   async getAll() {
-    const res = await fetch(`${this.API_BASE}/universities`, {
-      headers: { Accept: "application/json" },
-    });
+    const url = `${this.API_BASE}/universities?limit=200`;
+    const init = { headers: { Accept: "application/json" } };
+    const res = await fetch(url, init);
     if (!res.ok) throw new Error(`HTTP ${res.status}`);
-    return res.json(); // [{ id, name }]
+    return readAllPages(res, url, init); // [{ id, name }]
   }
 
   async create(name) {
@@ -27,10 +30,10 @@ class UniversityService {
     });
     if (!res.ok) {
       // 409 is common for duplicate names; surface a clear message
-      if (res.status === 409) throw new Error("University name already exists");
+      if (res.status === 409) throw await problemError(res, "University name already exists");
       throw new Error(`HTTP ${res.status}`);
     }
-    return res.json(); // { id, name, created_at? }
+    return res.json(); // { id, name, created_at, status }
   }
 
   async join(universityId) {
@@ -39,8 +42,26 @@ class UniversityService {
       headers: { "Content-Type": "application/json", Accept: "application/json" },
       body: JSON.stringify({ universityId }),
     });
-    if (!res.ok) throw new Error(`HTTP ${res.status}`);
-    return res.json(); // { userId, universityId, role }
+    if (!res.ok) {
+      // 403 (invite required) and 409 (awaiting approval) carry a readable reason
+      if (res.status === 403 || res.status === 409) throw await problemError(res);
+      throw new Error(`HTTP ${res.status}`);
+    }
+    return res.json(); // { userId, universityId, role }, or { status: "pending", requestId } (202)
+  }
+
+  async acceptInvite(code) {
+    const res = await fetch(`${this.API_BASE}/invites/accept`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json", Accept: "application/json" },
+      body: JSON.stringify({ code }),
+    });
+    if (!res.ok) {
+      if (res.status === 404) throw new Error("This invite link is not valid");
+      if (res.status === 409 || res.status === 410) throw await problemError(res);
+      throw new Error(`HTTP ${res.status}`);
+    }
+    return res.json(); // { universityId, courseId? }
   }
 
   async leave(universityId) {
@@ -1,2 +1,4 @@
 export { getMe as default } from "./getMe.js";
 export * from "./getMe.js";
+export * from "./pages.js";
+export * from "./problem.js";
@@ -0,0 +1,17 @@
+/**
+ * Reads a paginated list response ({ items, nextCursor }) to the end.
+ * `res` is the (already checked) first page; later pages are fetched from
+ * `url` with ?cursor= appended, using the same `init`.
+ */
+export async function readAllPages(res, url, init) {
+  let page = await res.json();
+  const items = [...page.items];
+  while (page.nextCursor) {
+    const sep = url.includes("?") ? "&" : "?";
+    const next = await fetch(`${url}${sep}cursor=${encodeURIComponent(page.nextCursor)}`, init);
+    if (!next.ok) throw new Error(`HTTP ${next.status}`);
+    page = await next.json();
+    items.push(...page.items);
+  }
+  return items;
+}
@@ -0,0 +1,17 @@
+/**
+ * Reads an API error response (application/problem+json) into an Error whose
+ * message is the problem's `detail` and whose `code` is its stable code.
+ * Falls back to `fallback`, or "HTTP <status>", when the body has no detail.
+ */
+export async function problemError(res, fallback) {
+  let problem = {};
+  try {
+    problem = await res.json();
+  } catch {
+    // not JSON (e.g. a proxy error page)
+  }
+  const err = new Error(problem.detail || fallback || `HTTP ${res.status}`);
+  err.status = res.status;
+  err.code = problem.code;
+  return err;
+}
@@ -2,9 +2,15 @@ package admin
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"example.com/sqlite-server/auth"
 	"example.com/sqlite-server/session"
+	"example.com/sqlite-server/university"
 	"example.com/sqlite-server/util"
 )
 
@@ -12,26 +18,59 @@ type countResp struct {
 	Count int64 `json:"count"`
 }
 
-func RegisterAdminRoutes(mux *http.ServeMux, db *sql.DB) {
+func RegisterAdminRoutes(mux util.Router, db *sql.DB) {
 	mux.HandleFunc("/admin/users/count",
 		session.RequireAuth(db, adminOnly(db, usersCountHandler(db))),
 	)
+	mux.HandleFunc("/admin/users/2fa",
+		session.RequireAuth(db, adminOnly(db, resetTOTPHandler(db))),
+	)
+	mux.HandleFunc("/admin/users",
+		session.RequireAuth(db, adminOnly(db, listUsersHandler(db))),
+	)
+	mux.HandleFunc("/admin/users/logout",
+		session.RequireAuth(db, adminOnly(db, forceLogoutHandler(db))),
+	)
+	mux.HandleFunc("/admin/users/disable",
+		session.RequireAuth(db, adminOnly(db, setDisabledHandler(db, true))),
+	)
+	mux.HandleFunc("/admin/users/enable",
+		session.RequireAuth(db, adminOnly(db, setDisabledHandler(db, false))),
+	)
+	mux.HandleFunc("/admin/admins",
+		session.RequireAuth(db, adminOnly(db, adminsHandler(db))),
+	)
+	mux.HandleFunc("/admin/universities",
+		session.RequireAuth(db, adminOnly(db, reviewQueueHandler(db))),
+	)
+	mux.HandleFunc("/admin/universities/approve",
+		session.RequireAuth(db, adminOnly(db, reviewUniversityHandler(db, true))),
+	)
+	mux.HandleFunc("/admin/universities/reject",
+		session.RequireAuth(db, adminOnly(db, reviewUniversityHandler(db, false))),
+	)
+	mux.HandleFunc("/admin/universities/merge",
+		session.RequireAuth(db, adminOnly(db, mergeUniversitiesHandler(db))),
+	)
+	mux.HandleFunc("/admin/stats",
+		session.RequireAuth(db, adminOnly(db, statsHandler(db))),
+	)
 }
 
 func adminOnly(db *sql.DB, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid, ok := session.UserIDFromCtx(r.Context())
 		if !ok || uid == "" {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 		okAdmin, err := IsAdmin(db, uid)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		if !okAdmin {
-			http.Error(w, "forbidden", http.StatusForbidden)
+			util.HTTPError(w, "forbidden", http.StatusForbidden)
 			return
 		}
 		next.ServeHTTP(w, r)
@@ -41,14 +80,330 @@ func adminOnly(db *sql.DB, next http.HandlerFunc) http.HandlerFunc {
 func usersCountHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 		n, err := CountUsers(db)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		util.WriteJSON(w, countResp{Count: n}, http.StatusOK)
 	}
 }
+
+// DELETE /admin/users/2fa
+// Body: { "userId": "uuid" }
+// Turns off a locked-out user's 2FA (secret, recovery codes and pending logins).
+// 204 on success; 404 if the user has no 2FA.
+func resetTOTPHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		uid, ok := decodeUserID(r)
+		if !ok {
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if err := auth.DisableTOTP(db, uid); err != nil {
+			if err == sql.ErrNoRows {
+				util.HTTPError(w, "not found", http.StatusNotFound)
+				return
+			}
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// decodeUserID reads { "userId": "uuid" } from the body.
+func decodeUserID(r *http.Request) (string, bool) {
+	var p struct {
+		UserID string `json:"userId"`
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return "", false
+	}
+	id := strings.TrimSpace(p.UserID)
+	return id, id != ""
+}
+
+// queryInt parses a non-negative integer query parameter, or returns def.
+func queryInt(r *http.Request, key string, def int) (int, bool) {
+	raw := r.URL.Query().Get(key)
+	if raw == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(raw)
+	return n, err == nil && n >= 0
+}
+
+// GET /admin/users?q=&limit=50&offset=0
+// Searches users by email. limit defaults to 50 (max 200).
+// Returns: { "users": [...], "total": n }
+func listUsersHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		limit, ok1 := queryInt(r, "limit", 50)
+		offset, ok2 := queryInt(r, "offset", 0)
+		if !ok1 || !ok2 || limit == 0 || limit > 200 {
+			util.HTTPError(w, "invalid limit/offset", http.StatusBadRequest)
+			return
+		}
+		page, err := ListUsers(db, r.URL.Query().Get("q"), limit, offset)
+		if err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		util.WriteJSON(w, page, http.StatusOK)
+	}
+}
+
+// POST /admin/users/logout
+// Body: { "userId": "uuid" }
+// Ends all of the user's sessions. Returns: 200 OK with { "revoked": n }
+func forceLogoutHandler(db *sql.DB) http.HandlerFunc {
+	type resp struct {
+		Revoked int64 `json:"revoked"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		uid, ok := decodeUserID(r)
+		if !ok {
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		n, err := ForceLogout(db, uid)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				util.HTTPError(w, "not found", http.StatusNotFound)
+				return
+			}
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		util.WriteJSON(w, resp{Revoked: n}, http.StatusOK)
+	}
+}
+
+// POST /admin/users/disable, POST /admin/users/enable
+// Body: { "userId": "uuid" }
+// Disabling blocks sign-in and tokens and ends the user's sessions. Admins
+// can't disable themselves. 204 on success; 404 if no such user.
+func setDisabledHandler(db *sql.DB, disabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		uid, ok := decodeUserID(r)
+		if !ok {
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if me, _ := session.UserIDFromCtx(r.Context()); disabled && me == uid {
+			util.HTTPError(w, "cannot disable yourself", http.StatusBadRequest)
+			return
+		}
+		if err := SetUserDisabled(db, uid, disabled); err != nil {
+			if err == sql.ErrNoRows {
+				util.HTTPError(w, "not found", http.StatusNotFound)
+				return
+			}
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Dispatcher for /admin/admins
+func adminsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			grantAdminHandler(db)(w, r)
+		case http.MethodDelete:
+			revokeAdminHandler(db)(w, r)
+		default:
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// POST /admin/admins
+// Body: { "userId": "uuid" }
+// 201 if promoted; 200 if already an admin; 404 if no such user.
+func grantAdminHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := decodeUserID(r)
+		if !ok {
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		created, err := GrantAdmin(db, uid)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				util.HTTPError(w, "not found", http.StatusNotFound)
+				return
+			}
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if created {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// DELETE /admin/admins
+// Body: { "userId": "uuid" }
+// 204 if demoted; 404 if not an admin; 409 if they are the last admin.
+func revokeAdminHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := decodeUserID(r)
+		if !ok {
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if err := RevokeAdmin(db, uid); err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// POST /admin/universities/merge
+// Body: { "sourceId": "uuid", "targetId": "uuid" }
+// Moves the source's courses, members, terms, invites and join requests into the
+// target and deletes the source.
+// Returns: 200 OK with { "coursesMoved": n, "membersMoved": n, "termsMoved": n, ... }
+// 409 if both have the same course (code/year/term), or the same term with
+// different dates; the body lists them.
+func mergeUniversitiesHandler(db *sql.DB) http.HandlerFunc {
+	type payload struct {
+		SourceID string `json:"sourceId"`
+		TargetID string `json:"targetId"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var p payload
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil {
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		src, dst := strings.TrimSpace(p.SourceID), strings.TrimSpace(p.TargetID)
+		if src == "" || dst == "" {
+			util.HTTPError(w, "sourceId and targetId are required", http.StatusBadRequest)
+			return
+		}
+
+		res, conflicts, err := MergeUniversities(db, src, dst)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrConflictingCourses):
+				err = ErrConflictingCourses.WithMessage("conflicting courses: " + strings.Join(conflicts, ", "))
+			case errors.Is(err, ErrConflictingTerms):
+				err = ErrConflictingTerms.WithMessage("conflicting terms: " + strings.Join(conflicts, ", "))
+			}
+			util.WriteError(w, err)
+			return
+		}
+		util.WriteJSON(w, res, http.StatusOK)
+	}
+}
+
+// GET /admin/stats
+// System-wide counts of users, courses, materials and progress.
+func statsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s, err := GetStats(db)
+		if err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		util.WriteJSON(w, s, http.StatusOK)
+	}
+}
+
+// GET /admin/universities?status=pending
+// The moderation queue; status is pending (default), approved or rejected.
+func reviewQueueHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		status := r.URL.Query().Get("status")
+		if status == "" {
+			status = university.StatusPending
+		}
+		list, err := university.ListForReview(db, status)
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		util.WriteJSON(w, list, http.StatusOK)
+	}
+}
+
+// POST /admin/universities/approve, POST /admin/universities/reject
+// Body: { "universityId": "uuid", "reason": "optional note shown to the creator" }
+// 204 on success; 404 if no such university; 409 if it isn't pending.
+func reviewUniversityHandler(db *sql.DB, approve bool) http.HandlerFunc {
+	type payload struct {
+		UniversityID string `json:"universityId"`
+		Reason       string `json:"reason,omitempty"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		uid, ok := session.UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var p payload
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil || strings.TrimSpace(p.UniversityID) == "" {
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if len(p.Reason) > 500 {
+			util.HTTPError(w, "reason too long (max 500)", http.StatusBadRequest)
+			return
+		}
+
+		if err := university.ReviewUniversity(db, strings.TrimSpace(p.UniversityID), uid, approve, p.Reason); err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -2,6 +2,18 @@ package admin
 
 import (
 	"database/sql"
+	"net/http"
+	"strings"
+
+	"example.com/sqlite-server/util"
+)
+
+// Errors returned by this package.
+var (
+	ErrLastAdmin          = util.NewError(http.StatusConflict, "last_admin", "cannot remove the last admin")
+	ErrSameUniversity     = util.NewError(http.StatusBadRequest, "same_university", "sourceId and targetId must differ")
+	ErrConflictingCourses = util.NewError(http.StatusConflict, "conflicting_courses", "conflicting courses")
+	ErrConflictingTerms   = util.NewError(http.StatusConflict, "conflicting_terms", "conflicting terms")
 )
 
 func IsAdmin(db *sql.DB, userID string) (bool, error) {
@@ -18,3 +30,358 @@ func CountUsers(db *sql.DB) (int64, error) {
 	err := db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&n)
 	return n, err
 }
+
+// UserRow is a user as seen in the admin console.
+type UserRow struct {
+	ID           string `json:"id"`
+	Email        string `json:"email"`
+	CreatedAt    int64  `json:"created_at"`
+	IsAdmin      bool   `json:"isAdmin"`
+	DisabledAt   *int64 `json:"disabled_at,omitempty"`
+	TwoFactor    bool   `json:"twoFactor"`
+	SessionCount int64  `json:"sessionCount"`
+}
+
+// UserPage is one page of ListUsers.
+type UserPage struct {
+	Users []UserRow `json:"users"`
+	Total int64     `json:"total"`
+}
+
+// ListUsers returns users whose email contains q (case-insensitive; empty = all),
+// oldest first.
+func ListUsers(db *sql.DB, q string, limit, offset int) (UserPage, error) {
+	pattern := "%" + escapeLike(strings.ToLower(strings.TrimSpace(q))) + "%"
+
+	var page UserPage
+	if err := db.QueryRow(`
+		SELECT COUNT(1) FROM users WHERE lower(email) LIKE ? ESCAPE '\'
+	`, pattern).Scan(&page.Total); err != nil {
+		return page, err
+	}
+
+	rows, err := db.Query(`
+		SELECT u.id, u.email, u.created_at, u.disabled_at,
+		       EXISTS (SELECT 1 FROM admins a WHERE a.user_id = u.id),
+		       EXISTS (SELECT 1 FROM user_totp t WHERE t.user_id = u.id AND t.enabled = 1),
+		       (SELECT COUNT(1) FROM sessions s
+		         WHERE s.user_id = u.id AND s.expires_at > strftime('%s','now'))
+		  FROM users u
+		 WHERE lower(u.email) LIKE ? ESCAPE '\'
+		 ORDER BY u.created_at ASC, u.id ASC
+		 LIMIT ? OFFSET ?
+	`, pattern, limit, offset)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+
+	page.Users = make([]UserRow, 0, limit)
+	for rows.Next() {
+		var u UserRow
+		var disabled sql.NullInt64
+		if err := rows.Scan(&u.ID, &u.Email, &u.CreatedAt, &disabled, &u.IsAdmin, &u.TwoFactor, &u.SessionCount); err != nil {
+			return page, err
+		}
+		if disabled.Valid {
+			v := disabled.Int64
+			u.DisabledAt = &v
+		}
+		page.Users = append(page.Users, u)
+	}
+	return page, rows.Err()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func userExists(db *sql.DB, userID string) error {
+	var x int
+	return db.QueryRow(`SELECT 1 FROM users WHERE id = ?`, userID).Scan(&x)
+}
+
+// GrantAdmin makes userID an admin. Returns sql.ErrNoRows if the user doesn't exist.
+// created is false if they already were one.
+func GrantAdmin(db *sql.DB, userID string) (bool, error) {
+	if err := userExists(db, userID); err != nil {
+		return false, err
+	}
+	res, err := db.Exec(`INSERT OR IGNORE INTO admins (user_id) VALUES (?)`, userID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// RevokeAdmin removes admin rights. Refuses to remove the last admin (ErrLastAdmin).
+// Returns sql.ErrNoRows if userID isn't an admin.
+func RevokeAdmin(db *sql.DB, userID string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var n int64
+	if err := tx.QueryRow(`SELECT COUNT(1) FROM admins`).Scan(&n); err != nil {
+		return err
+	}
+	res, err := tx.Exec(`DELETE FROM admins WHERE user_id = ?`, userID)
+	if err != nil {
+		return err
+	}
+	if k, _ := res.RowsAffected(); k == 0 {
+		return sql.ErrNoRows
+	}
+	if n <= 1 {
+		return ErrLastAdmin
+	}
+	return tx.Commit()
+}
+
+// ForceLogout ends every session of userID and returns how many were removed.
+// Personal access tokens are left alone (they're revoked separately, or by disabling).
+func ForceLogout(db *sql.DB, userID string) (int64, error) {
+	if err := userExists(db, userID); err != nil {
+		return 0, err
+	}
+	res, err := db.Exec(`DELETE FROM sessions WHERE user_id = ?`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// SetUserDisabled disables or re-enables an account. Disabling also ends its
+// sessions; its tokens stop working until it is re-enabled.
+// Returns sql.ErrNoRows if the user doesn't exist.
+func SetUserDisabled(db *sql.DB, userID string, disabled bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var res sql.Result
+	if disabled {
+		res, err = tx.Exec(`
+			UPDATE users SET disabled_at = COALESCE(disabled_at, strftime('%s','now')) WHERE id = ?
+		`, userID)
+	} else {
+		res, err = tx.Exec(`UPDATE users SET disabled_at = NULL WHERE id = ?`, userID)
+	}
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if disabled {
+		if _, err := tx.Exec(`DELETE FROM sessions WHERE user_id = ?`, userID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// MergeResult reports what MergeUniversities moved.
+type MergeResult struct {
+	CoursesMoved  int64 `json:"coursesMoved"`
+	MembersMoved  int64 `json:"membersMoved"`
+	TermsMoved    int64 `json:"termsMoved"`
+	InvitesMoved  int64 `json:"invitesMoved"`
+	RequestsMoved int64 `json:"requestsMoved"`
+}
+
+// MergeUniversities folds sourceID into targetID: courses, memberships, terms,
+// invites and join requests move to the target, then the source is deleted.
+// A course that exists in both (same year, term and code) blocks the merge,
+// since its materials and progress would have to be reconciled by hand; those
+// are returned as conflicts with the error ErrConflictingCourses. So does a
+// term both define with different dates or time zone (ErrConflictingTerms);
+// where they agree, the target's term (and its breaks) is kept. Pending join
+// requests already settled by the target's membership or its own pending
+// requests are dropped. Returns sql.ErrNoRows if either university is missing.
+func MergeUniversities(db *sql.DB, sourceID, targetID string) (MergeResult, []string, error) {
+	var out MergeResult
+	if sourceID == targetID {
+		return out, nil, ErrSameUniversity
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return out, nil, err
+	}
+	defer tx.Rollback()
+
+	var n int64
+	if err := tx.QueryRow(`
+		SELECT COUNT(1) FROM universities WHERE id IN (?, ?)
+	`, sourceID, targetID).Scan(&n); err != nil {
+		return out, nil, err
+	}
+	if n != 2 {
+		return out, nil, sql.ErrNoRows
+	}
+
+	conflicts, err := mergeConflicts(tx, `
+		SELECT s.code || ' (' || s.year || '/' || s.term || ')'
+		  FROM courses s
+		  JOIN courses t
+		    ON t.university_id = ? AND t.year = s.year AND t.term = s.term AND t.code = s.code
+		 WHERE s.university_id = ?
+		 ORDER BY s.year, s.term, s.code
+	`, targetID, sourceID)
+	if err != nil {
+		return out, nil, err
+	}
+	if len(conflicts) > 0 {
+		return out, conflicts, ErrConflictingCourses
+	}
+	conflicts, err = mergeConflicts(tx, `
+		SELECT s.year || '/' || s.term
+		  FROM terms s
+		  JOIN terms t ON t.university_id = ? AND t.year = s.year AND t.term = s.term
+		 WHERE s.university_id = ?
+		   AND (t.starts_on <> s.starts_on OR t.ends_on <> s.ends_on OR t.timezone <> s.timezone)
+		 ORDER BY s.year, s.term
+	`, targetID, sourceID)
+	if err != nil {
+		return out, nil, err
+	}
+	if len(conflicts) > 0 {
+		return out, conflicts, ErrConflictingTerms
+	}
+
+	res, err := tx.Exec(`UPDATE courses SET university_id = ? WHERE university_id = ?`, targetID, sourceID)
+	if err != nil {
+		return out, nil, err
+	}
+	out.CoursesMoved, _ = res.RowsAffected()
+
+	// Members of both keep the stronger of their two roles.
+	if err := tx.QueryRow(`
+		SELECT COUNT(*) FROM user_universities s
+		 WHERE s.university_id = ?
+		   AND NOT EXISTS (SELECT 1 FROM user_universities t
+		                    WHERE t.university_id = ? AND t.user_id = s.user_id)
+	`, sourceID, targetID).Scan(&out.MembersMoved); err != nil {
+		return out, nil, err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO user_universities (user_id, university_id, role)
+		SELECT user_id, ?, role FROM user_universities WHERE university_id = ?
+		ON CONFLICT (user_id, university_id) DO UPDATE SET role = excluded.role
+		 WHERE `+roleRank("excluded.role")+` > `+roleRank("user_universities.role")+`
+	`, targetID, sourceID); err != nil {
+		return out, nil, err
+	}
+
+	// Terms the target already has (with the same dates) stay the target's;
+	// the source's copies go with the source.
+	res, err = tx.Exec(`
+		UPDATE terms SET university_id = ?
+		 WHERE university_id = ?
+		   AND NOT EXISTS (SELECT 1 FROM terms t
+		                    WHERE t.university_id = ? AND t.year = terms.year AND t.term = terms.term)
+	`, targetID, sourceID, targetID)
+	if err != nil {
+		return out, nil, err
+	}
+	out.TermsMoved, _ = res.RowsAffected()
+
+	res, err = tx.Exec(`UPDATE invites SET university_id = ? WHERE university_id = ?`, targetID, sourceID)
+	if err != nil {
+		return out, nil, err
+	}
+	out.InvitesMoved, _ = res.RowsAffected()
+
+	if _, err := tx.Exec(`
+		DELETE FROM join_requests
+		 WHERE university_id = ? AND status = 'pending'
+		   AND (EXISTS (SELECT 1 FROM user_universities m
+		                 WHERE m.university_id = ? AND m.user_id = join_requests.user_id)
+		        OR EXISTS (SELECT 1 FROM join_requests t
+		                    WHERE t.university_id = ? AND t.status = 'pending'
+		                      AND t.user_id = join_requests.user_id))
+	`, sourceID, targetID, targetID); err != nil {
+		return out, nil, err
+	}
+	res, err = tx.Exec(`UPDATE join_requests SET university_id = ? WHERE university_id = ?`, targetID, sourceID)
+	if err != nil {
+		return out, nil, err
+	}
+	out.RequestsMoved, _ = res.RowsAffected()
+
+	if _, err := tx.Exec(`DELETE FROM universities WHERE id = ?`, sourceID); err != nil {
+		return out, nil, err
+	}
+	return out, nil, tx.Commit()
+}
+
+// mergeConflicts collects the labels a conflict query returns.
+func mergeConflicts(tx *sql.Tx, query string, args ...any) ([]string, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var conflicts []string
+	for rows.Next() {
+		var label string
+		if err := rows.Scan(&label); err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, label)
+	}
+	return conflicts, rows.Err()
+}
+
+// Stats are system-wide counts for the admin dashboard.
+type Stats struct {
+	Users             int64 `json:"users"`
+	DisabledUsers     int64 `json:"disabledUsers"`
+	Admins            int64 `json:"admins"`
+	ActiveSessions    int64 `json:"activeSessions"`
+	Universities      int64 `json:"universities"`
+	PendingReview     int64 `json:"universitiesPendingReview"`
+	Courses           int64 `json:"courses"`
+	Enrollments       int64 `json:"enrollments"`
+	Books             int64 `json:"books"`
+	Chapters          int64 `json:"chapters"`
+	Articles          int64 `json:"articles"`
+	Assignments       int64 `json:"assignments"`
+	ProgressEntries   int64 `json:"progressEntries"`
+	ProgressCompleted int64 `json:"progressCompleted"`
+}
+
+func GetStats(db *sql.DB) (Stats, error) {
+	var s Stats
+	err := db.QueryRow(`
+		SELECT
+		  (SELECT COUNT(1) FROM users),
+		  (SELECT COUNT(1) FROM users WHERE disabled_at IS NOT NULL),
+		  (SELECT COUNT(1) FROM admins),
+		  (SELECT COUNT(1) FROM sessions WHERE expires_at > strftime('%s','now')),
+		  (SELECT COUNT(1) FROM universities),
+		  (SELECT COUNT(1) FROM universities WHERE status = 'pending'),
+		  (SELECT COUNT(1) FROM courses),
+		  (SELECT COUNT(1) FROM user_courses),
+		  (SELECT COUNT(1) FROM books),
+		  (SELECT COUNT(1) FROM chapters),
+		  (SELECT COUNT(1) FROM articles),
+		  (SELECT COUNT(1) FROM assignments),
+		  (SELECT COUNT(1) FROM progress),
+		  (SELECT COUNT(1) FROM progress WHERE completed = 1)
+	`).Scan(&s.Users, &s.DisabledUsers, &s.Admins, &s.ActiveSessions, &s.Universities, &s.PendingReview,
+		&s.Courses, &s.Enrollments, &s.Books, &s.Chapters, &s.Articles, &s.Assignments,
+		&s.ProgressEntries, &s.ProgressCompleted)
+	return s, err
+}
+
+// roleRank is the SQL ranking a university role column: owner > curator > member.
+func roleRank(col string) string {
+	return "CASE " + col + " WHEN 'owner' THEN 2 WHEN 'curator' THEN 1 ELSE 0 END"
+}
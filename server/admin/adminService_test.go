@@ -0,0 +1,195 @@
+package admin
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"example.com/sqlite-server/store"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func exec(t *testing.T, db *sql.DB, query string, args ...any) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+func count(t *testing.T, db *sql.DB, query string, args ...any) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return n
+}
+
+func TestMergeUniversitiesMovesCoursesAndMembers(t *testing.T) {
+	db := openTestDB(t)
+	exec(t, db, `INSERT INTO users (id, email, password) VALUES ('u1', 'a@x.io', ''), ('u2', 'b@x.io', '')`)
+	exec(t, db, `INSERT INTO universities (id, name) VALUES ('src', 'Uni A'), ('dst', 'Uni B')`)
+	exec(t, db, `INSERT INTO user_universities (user_id, university_id) VALUES ('u1', 'src'), ('u2', 'src'), ('u1', 'dst')`)
+	exec(t, db, `
+		INSERT INTO courses (university_id, year, term, code, name) VALUES
+		  ('src', 2026, 1, 'CS101', 'Intro'),
+		  ('src', 2026, 1, 'CS102', 'Data structures'),
+		  ('dst', 2026, 1, 'CS101', 'Introduction')`)
+
+	_, conflicts, err := MergeUniversities(db, "src", "dst")
+	if err == nil || len(conflicts) != 1 || conflicts[0] != "CS101 (2026/1)" {
+		t.Fatalf("merge = %v, %v; want the CS101 conflict", conflicts, err)
+	}
+	if n := count(t, db, `SELECT COUNT(1) FROM courses WHERE university_id = 'src'`); n != 2 {
+		t.Fatalf("a refused merge moved courses: %d left", n)
+	}
+
+	exec(t, db, `DELETE FROM courses WHERE university_id = 'dst'`)
+	res, _, err := MergeUniversities(db, "src", "dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.CoursesMoved != 2 || res.MembersMoved != 1 {
+		t.Errorf("result = %+v, want 2 courses and 1 member (u2)", res)
+	}
+	if n := count(t, db, `SELECT COUNT(1) FROM user_universities WHERE university_id = 'dst'`); n != 2 {
+		t.Errorf("target has %d members, want 2", n)
+	}
+	if n := count(t, db, `SELECT COUNT(1) FROM universities WHERE id = 'src'`); n != 0 {
+		t.Error("source university still exists")
+	}
+}
+
+func TestRevokeAdminKeepsTheLastOne(t *testing.T) {
+	db := openTestDB(t)
+	exec(t, db, `INSERT INTO users (id, email, password) VALUES ('u1', 'a@x.io', ''), ('u2', 'b@x.io', '')`)
+	for _, id := range []string{"u1", "u2"} {
+		if created, err := GrantAdmin(db, id); err != nil || !created {
+			t.Fatalf("GrantAdmin(%s) = %v, %v", id, created, err)
+		}
+	}
+	if created, err := GrantAdmin(db, "u1"); err != nil || created {
+		t.Fatalf("granting again = %v, %v", created, err)
+	}
+	if err := RevokeAdmin(db, "u1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RevokeAdmin(db, "u2"); err == nil {
+		t.Fatal("revoked the last admin")
+	}
+	if ok, err := IsAdmin(db, "u2"); err != nil || !ok {
+		t.Fatalf("u2 admin = %v, %v", ok, err)
+	}
+}
+
+func TestMergeUniversitiesKeepsStrongerRole(t *testing.T) {
+	db := openTestDB(t)
+	exec(t, db, `INSERT INTO users (id, email, password) VALUES ('u1', 'a@x.io', ''), ('u2', 'b@x.io', ''), ('u3', 'c@x.io', '')`)
+	exec(t, db, `INSERT INTO universities (id, name) VALUES ('src', 'Uni A'), ('dst', 'Uni B')`)
+	exec(t, db, `
+		INSERT INTO user_universities (user_id, university_id, role) VALUES
+		  ('u1', 'src', 'owner'),   ('u1', 'dst', 'member'),
+		  ('u2', 'src', 'member'),  ('u2', 'dst', 'curator'),
+		  ('u3', 'src', 'curator')`)
+
+	res, _, err := MergeUniversities(db, "src", "dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.MembersMoved != 1 {
+		t.Errorf("moved %d members, want 1 (u3)", res.MembersMoved)
+	}
+	for user, want := range map[string]string{"u1": "owner", "u2": "curator", "u3": "curator"} {
+		var role string
+		if err := db.QueryRow(`SELECT role FROM user_universities WHERE user_id = ? AND university_id = 'dst'`, user).Scan(&role); err != nil {
+			t.Fatal(err)
+		}
+		if role != want {
+			t.Errorf("%s is %s of the target, want %s", user, role, want)
+		}
+	}
+}
+
+func TestMergeUniversitiesMovesInvitesAndRequests(t *testing.T) {
+	db := openTestDB(t)
+	exec(t, db, `INSERT INTO users (id, email, password) VALUES ('u1', 'a@x.io', ''), ('u2', 'b@x.io', ''), ('u3', 'c@x.io', '')`)
+	exec(t, db, `INSERT INTO universities (id, name) VALUES ('src', 'Uni A'), ('dst', 'Uni B')`)
+	exec(t, db, `INSERT INTO user_universities (user_id, university_id) VALUES ('u1', 'dst')`)
+	exec(t, db, `INSERT INTO invites (id, code_hash, university_id) VALUES ('i1', 'h1', 'src')`)
+	exec(t, db, `
+		INSERT INTO join_requests (user_id, university_id) VALUES
+		  ('u1', 'src'), -- already a member of dst
+		  ('u2', 'src'), -- also waiting on dst
+		  ('u2', 'dst'),
+		  ('u3', 'src')`)
+
+	res, _, err := MergeUniversities(db, "src", "dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := MergeResult{InvitesMoved: 1, RequestsMoved: 1}
+	if res != want {
+		t.Errorf("result = %+v, want %+v", res, want)
+	}
+	if n := count(t, db, `SELECT COUNT(1) FROM invites WHERE university_id = 'dst'`); n != 1 {
+		t.Errorf("target has %d invites, want 1", n)
+	}
+	if n := count(t, db, `SELECT COUNT(1) FROM join_requests WHERE university_id = 'dst' AND status = 'pending'`); n != 2 {
+		t.Errorf("target has %d pending requests, want 2 (u2's own and u3's)", n)
+	}
+}
+
+func TestMergeUniversitiesMovesTerms(t *testing.T) {
+	db := openTestDB(t)
+	exec(t, db, `INSERT INTO universities (id, name) VALUES ('src', 'Uni A'), ('dst', 'Uni B')`)
+	exec(t, db, `
+		INSERT INTO terms (university_id, year, term, name, starts_on, ends_on) VALUES
+		  ('src', 2026, 1, 'Spring', '2026-01-12', '2026-05-01'),
+		  ('src', 2026, 2, 'Autumn', '2026-08-24', '2026-12-11'),
+		  ('dst', 2026, 1, 'Spring term', '2026-01-12', '2026-05-01')`)
+	exec(t, db, `INSERT INTO term_breaks (term_id, name, starts_on, ends_on)
+		SELECT id, 'Reading week', '2026-10-05', '2026-10-09' FROM terms WHERE university_id = 'src' AND term = 2`)
+	exec(t, db, `INSERT INTO courses (university_id, year, term, code, name) VALUES ('src', 2026, 2, 'CS101', 'Intro')`)
+
+	res, _, err := MergeUniversities(db, "src", "dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := MergeResult{CoursesMoved: 1, TermsMoved: 1}
+	if res != want {
+		t.Errorf("result = %+v, want %+v", res, want)
+	}
+	if n := count(t, db, `SELECT COUNT(1) FROM terms WHERE university_id = 'dst'`); n != 2 {
+		t.Errorf("target has %d terms, want 2", n)
+	}
+	if n := count(t, db, `SELECT COUNT(1) FROM term_breaks b JOIN terms t ON t.id = b.term_id WHERE t.university_id = 'dst'`); n != 1 {
+		t.Errorf("moved term has %d breaks, want 1", n)
+	}
+}
+
+func TestMergeUniversitiesRejectsDifferingTerms(t *testing.T) {
+	db := openTestDB(t)
+	exec(t, db, `INSERT INTO universities (id, name) VALUES ('src', 'Uni A'), ('dst', 'Uni B')`)
+	exec(t, db, `
+		INSERT INTO terms (university_id, year, term, name, starts_on, ends_on) VALUES
+		  ('src', 2026, 1, 'Spring', '2026-01-12', '2026-05-01'),
+		  ('dst', 2026, 1, 'Spring', '2026-01-19', '2026-05-08')`)
+
+	_, conflicts, err := MergeUniversities(db, "src", "dst")
+	if !errors.Is(err, ErrConflictingTerms) || len(conflicts) != 1 || conflicts[0] != "2026/1" {
+		t.Fatalf("merge = %v, %v; want the 2026/1 conflict", conflicts, err)
+	}
+	if n := count(t, db, `SELECT COUNT(1) FROM universities`); n != 2 {
+		t.Errorf("%d universities left, want both", n)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+// checkScenario walks a fresh database through every operation in apiSpec:
+// alice is an admin, bob and carol are regular users. Steps run in order and
+// may use values saved by earlier ones.
+
+type obj = map[string]any
+
+const (
+	checkPassword = "correct horse battery"
+	checkDeadline = 1794830400 // 2026-11-16T12:00:00Z, inside the check's autumn term
+)
+
+func credentials(email string) obj { return obj{"email": email, "password": checkPassword} }
+
+var checkScenario = []checkStep{
+	// Meta
+	{route: "GET /", status: 200},
+	{route: "GET /csrf", status: 200},
+	{route: "GET /openapi.json", status: 200},
+
+	// Accounts
+	{as: "alice", route: "POST /register", body: credentials("alice@example.com"), status: 201,
+		save: map[string]string{"aliceId": "userId"}, after: grantAdmin("aliceId")},
+	{as: "bob", route: "POST /register", body: credentials("bob@example.com"), status: 201,
+		save: map[string]string{"bobId": "userId"}},
+	{as: "carol", route: "POST /register", body: credentials("carol@example.com"), status: 201,
+		save: map[string]string{"carolId": "userId"}},
+	{as: "alice", route: "GET /me", status: 200},
+	{as: "bob", route: "POST /logout", status: 204},
+	{as: "bob", route: "POST /login", body: credentials("bob@example.com"), status: 200},
+	{as: "bob2", route: "POST /login", body: obj{"email": "bob@example.com", "password": checkPassword, "remember": true}, status: 200},
+
+	// Two-factor authentication
+	{as: "carol", route: "GET /auth/2fa", status: 200},
+	{as: "carol", route: "POST /auth/2fa/setup", status: 200, save: map[string]string{"totpSecret": "secret"}},
+	{as: "carol", route: "POST /auth/2fa/enable", body: obj{"code": "{totp}"}, status: 200,
+		save: map[string]string{"recovery": "recoveryCodes.0"}},
+	{as: "carol", route: "POST /auth/2fa/recovery-codes", body: obj{"code": "{recovery}"}, status: 200,
+		save: map[string]string{"recovery1": "recoveryCodes.0", "recovery2": "recoveryCodes.1"}},
+	{as: "carol", route: "POST /logout", status: 204},
+	{as: "carol", route: "POST /login", body: credentials("carol@example.com"), status: 200,
+		save: map[string]string{"mfaToken": "mfaToken"}},
+	{as: "carol", route: "POST /login/2fa", body: obj{"mfaToken": "{mfaToken}", "code": "{recovery1}"}, status: 200},
+	{as: "carol", route: "DELETE /auth/2fa", body: obj{"code": "{recovery2}"}, status: 204},
+	{as: "carol", route: "POST /auth/2fa/setup", status: 200, save: map[string]string{"totpSecret": "secret"}},
+	{as: "carol", route: "POST /auth/2fa/enable", body: obj{"code": "{totp}"}, status: 200},
+
+	// Single sign-on (not configured here)
+	{route: "GET /auth/oidc/login?returnTo=/", status: 404},
+	{route: "GET /auth/oidc/callback?code=x&state=y", status: 404},
+	{as: "alice", route: "GET /auth/identities", status: 200},
+	{as: "alice", route: "DELETE /auth/identities", body: obj{"identityId": 1}, status: 404},
+
+	// Sessions and tokens
+	{as: "bob", route: "GET /sessions", status: 200, save: map[string]string{"otherSession": "[current=false].id"}},
+	{as: "bob", route: "DELETE /sessions", body: obj{"sessionId": "{otherSession}"}, status: 204},
+	{as: "bob", route: "DELETE /sessions/others", status: 200},
+	{as: "alice", route: "POST /tokens", body: obj{"name": "script", "scopes": []string{"read"}}, status: 201,
+		save: map[string]string{"tokenId": "id"}},
+	{as: "alice", route: "GET /tokens", status: 200},
+	{as: "alice", route: "DELETE /tokens", body: obj{"tokenId": "{tokenId}"}, status: 204},
+
+	// Universities
+	{as: "alice", route: "POST /universities", body: obj{"name": "Alpha University"}, status: 201,
+		save: map[string]string{"uni": "id"}},
+	{as: "bob", route: "POST /universities", body: obj{"name": "Beta College"}, status: 201,
+		save: map[string]string{"uni2": "id"}},
+	{as: "bob", route: "POST /universities", body: obj{"name": "Gamma Institute"}, status: 201,
+		save: map[string]string{"uni3": "id"}},
+	{as: "bob", route: "GET /universities/mine", status: 200},
+	{route: "GET /universities?sort=-name&limit=10", status: 200},
+	{as: "alice", route: "GET /admin/universities", status: 200},
+	{as: "alice", route: "POST /admin/universities/approve", body: obj{"universityId": "{uni2}"}, status: 204},
+	{as: "alice", route: "POST /admin/universities/reject", body: obj{"universityId": "{uni3}", "reason": "duplicate"}, status: 204},
+
+	// Memberships
+	{as: "bob", route: "POST /user-universities", body: obj{"universityId": "{uni}"}, status: 201},
+	{as: "carol", route: "POST /user-universities", body: obj{"universityId": "{uni}"}, status: 201},
+	{as: "alice", route: "GET /user-universities", status: 200},
+	{as: "alice", route: "PATCH /user-universities/role", body: obj{"universityId": "{uni}", "userId": "{bobId}", "role": "curator"}, status: 204},
+	{as: "carol", route: "DELETE /user-universities", body: obj{"universityId": "{uni}"}, status: 204},
+
+	// Terms
+	{as: "alice", route: "POST /terms", body: obj{
+		"universityId": "{uni}", "year": 2026, "term": 4, "name": "Autumn 2026",
+		"startsOn": "2026-09-01", "endsOn": "2026-12-20", "timezone": "Europe/Amsterdam",
+		"breaks": []obj{{"name": "Autumn break", "startsOn": "2026-10-26", "endsOn": "2026-10-30"}},
+	}, status: 201, save: map[string]string{"term": "id"}},
+	{as: "alice", route: "POST /terms", body: obj{
+		"universityId": "{uni}", "year": 2027, "term": 1, "name": "Winter 2027",
+		"startsOn": "2027-01-04", "endsOn": "2027-03-31", "timezone": "Europe/Amsterdam",
+	}, status: 201, save: map[string]string{"term2": "id"}},
+	{as: "alice", route: "GET /terms?universityId={uni}", status: 200},
+	{as: "alice", route: "GET /terms/{term}", status: 200},
+	{as: "alice", route: "PUT /terms/{term}", body: obj{
+		"name": "Autumn 2026", "startsOn": "2026-08-31", "endsOn": "2026-12-20", "timezone": "Europe/Amsterdam",
+		"breaks": []obj{{"name": "Autumn break", "startsOn": "2026-10-26", "endsOn": "2026-10-30"}},
+	}, status: 200},
+
+	// Courses and enrollments
+	{as: "alice", route: "POST /courses", body: obj{"universityId": "{uni}", "year": 2026, "term": 4, "code": "CS101", "name": "Intro to Computing"}, status: 201,
+		save: map[string]string{"course": "id"}},
+	{as: "bob", route: "POST /courses", body: obj{"universityId": "{uni}", "year": 2026, "term": 4, "code": "CS102", "name": "Data Structures"}, status: 201,
+		save: map[string]string{"course2": "id"}},
+	{as: "bob", route: "DELETE /courses", body: obj{"courseId": "{course2}"}, status: 204},
+	{as: "alice", route: "POST /user-courses", body: obj{"courseId": "{course}"}, status: 201},
+	{as: "bob", route: "POST /user-courses", body: obj{"courseId": "{course}"}, status: 201},
+	{as: "alice", route: "GET /courses?universityId={uni}", status: 200},
+	{as: "alice", route: "GET /course-catalog?universityId={uni}&sort=code&limit=5", status: 200},
+
+	// Books and chapters
+	{as: "alice", route: "POST /books", body: obj{"courseId": "{course}", "title": "Structure and Interpretation", "author": "Abelson", "numChapters": 3}, status: 201,
+		save: map[string]string{"book": "id", "chapter": "chapters.0.id"}},
+	{as: "alice", route: "PATCH /chapters/{chapter}/deadline", body: obj{"deadline": checkDeadline}, status: 200},
+	{as: "bob", route: "PATCH /chapters/{chapter}/progress", body: obj{"completed": true}, status: 200},
+	{as: "alice", route: "GET /books?courseId={course}&sort=-title&hasDeadline=true", status: 200},
+	{as: "alice", route: "POST /books", body: obj{"courseId": "{course}", "title": "Scratch", "author": "Nobody"}, status: 201,
+		save: map[string]string{"book2": "id"}},
+	{as: "alice", route: "DELETE /books", body: obj{"bookId": "{book2}"}, status: 204},
+
+	// Articles
+	{as: "alice", route: "POST /articles", body: obj{"courseId": "{course}", "title": "Go To Statement Considered Harmful", "author": "Dijkstra"}, status: 201,
+		save: map[string]string{"article": "id"}},
+	{as: "alice", route: "PATCH /articles/{article}/deadline", body: obj{"deadline": checkDeadline}, status: 200},
+	{as: "bob", route: "PATCH /articles/{article}/progress", body: obj{"completed": true}, status: 200},
+	{as: "alice", route: "GET /articles?courseId={course}&sort=deadline", status: 200},
+	{as: "alice", route: "POST /articles", body: obj{"courseId": "{course}", "title": "Scratch", "author": "Nobody"}, status: 201,
+		save: map[string]string{"article2": "id"}},
+	{as: "alice", route: "DELETE /articles", body: obj{"articleId": "{article2}"}, status: 204},
+
+	// Assignments
+	{as: "alice", route: "POST /assignments", body: obj{"courseId": "{course}", "title": "Problem set 1", "description": "Exercises 1.1-1.8"}, status: 201,
+		save: map[string]string{"assignment": "id"}},
+	{as: "alice", route: "PATCH /assignments/{assignment}/deadline", body: obj{"deadline": checkDeadline}, status: 200},
+	{as: "bob", route: "PATCH /assignments/{assignment}/progress", body: obj{"completed": true}, status: 200},
+	{as: "alice", route: "GET /assignments?courseId={course}&completed=false", status: 200},
+	{as: "alice", route: "POST /assignments", body: obj{"courseId": "{course}", "title": "Scratch"}, status: 201,
+		save: map[string]string{"assignment2": "id"}},
+	{as: "alice", route: "DELETE /assignments", body: obj{"assignmentId": "{assignment2}"}, status: 204},
+
+	// Search and calendar
+	{as: "bob", route: "GET /search?q=intro", status: 200},
+	{as: "alice", route: "GET /calendar.ics", status: 200},
+	{as: "alice", route: "GET /calendar/token", status: 200, save: map[string]string{"calendarToken": "token"}},
+	{route: "GET /calendar/{calendarToken}.ics", status: 200},
+	{as: "alice", route: "POST /calendar/token/rotate", status: 200},
+	{as: "bob", route: "GET /events?lastEventId=1", status: 200, stream: true},
+
+	// Digests
+	{as: "bob", route: "GET /digest/settings", status: 200},
+	{as: "bob", route: "PUT /digest/settings", body: obj{"frequency": "weekly", "hour": 7, "weekday": 1, "timezone": "Europe/Amsterdam"}, status: 200,
+		after: saveDigestToken("bobId", "digestToken")},
+	{as: "bob", route: "PUT /digest/settings", body: obj{"frequency": "daily", "hour": 25}, status: 400},
+	{route: "GET /digest/unsubscribe?token={digestToken}", status: 200},
+	{route: "POST /digest/unsubscribe?token={digestToken}", status: 200},
+	{route: "POST /digest/unsubscribe?token=nope", status: 404},
+
+	// Push ({pushSubscription} is a stand-in browser's, at the hook receiver)
+	{as: "bob", route: "GET /push/settings", status: 200},
+	{as: "bob", route: "PUT /push/settings", body: obj{"leadHours": 48, "articles": false}, status: 200},
+	{as: "bob", route: "PUT /push/settings", body: obj{"leadHours": 500}, status: 400},
+	{as: "bob", route: "POST /push/subscriptions", body: "{pushSubscription}", status: 201},
+	{as: "bob", route: "POST /push/subscriptions", body: obj{"endpoint": "ftp://push.example.com/x", "keys": obj{"p256dh": "x", "auth": "y"}}, status: 400},
+	{as: "bob", route: "GET /push/subscriptions", status: 200},
+	{as: "bob", route: "POST /push/test", status: 200, after: verifyPush("Notifications are on")},
+	{as: "bob", route: "DELETE /push/subscriptions", body: obj{"endpoint": "{pushEndpoint}"}, status: 204},
+	{as: "bob", route: "DELETE /push/subscriptions", body: obj{"endpoint": "{pushEndpoint}"}, status: 404},
+	{as: "carol", route: "POST /push/test", status: 200},
+
+	// Webhooks ({hookURL} is the check's stand-in receiver)
+	{as: "alice", route: "POST /webhooks", body: obj{"courseId": "{course}", "url": "{hookURL}"}, status: 201,
+		save: map[string]string{"hook": "id", "hookSecret": "secret"}},
+	{as: "carol", route: "POST /webhooks", body: obj{"courseId": "{course}", "url": "{hookURL}"}, status: 403},
+	{as: "alice", route: "GET /webhooks?courseId={course}", status: 200},
+	{as: "alice", route: "POST /webhooks/{hook}/ping", status: 200, after: verifyWebhook("hookSecret")},
+	{as: "alice", route: "GET /webhooks/{hook}/deliveries?limit=10", status: 200},
+	{as: "alice", route: "DELETE /webhooks", body: obj{"webhookId": "{hook}"}, status: 204},
+
+	// Current courses, cloning and archiving
+	{as: "alice", route: "GET /courses/current", status: 200},
+	{as: "alice", route: "POST /courses/{course}/clone", body: obj{"year": 2027, "term": 1, "shiftDeadlines": true}, status: 201,
+		save: map[string]string{"clone": "course.id"}},
+	{as: "alice", route: "POST /courses/{clone}/archive", body: obj{"scope": "course"}, status: 204},
+	{as: "alice", route: "POST /courses/{clone}/restore", body: obj{"scope": "course"}, status: 204},
+
+	// Join policies, invites and requests
+	{as: "alice", route: "PATCH /join-policy", body: obj{"universityId": "{uni}", "joinPolicy": "invite"}, status: 200},
+	{as: "carol", route: "POST /user-universities", body: obj{"universityId": "{uni}"}, status: 403},
+	{as: "alice", route: "POST /invites", body: obj{"universityId": "{uni}", "expiresInDays": 7, "maxUses": 5}, status: 201,
+		save: map[string]string{"invite": "id", "inviteCode": "code"}},
+	{as: "alice", route: "GET /invites?universityId={uni}", status: 200},
+	{as: "carol", route: "POST /invites/accept", body: obj{"code": "{inviteCode}"}, status: 200},
+	{as: "alice", route: "DELETE /invites", body: obj{"inviteId": "{invite}"}, status: 204},
+	{as: "alice", route: "PATCH /join-policy", body: obj{"courseId": "{course}", "joinPolicy": "approval"}, status: 200},
+	{as: "carol", route: "POST /user-courses", body: obj{"courseId": "{course}"}, status: 202,
+		save: map[string]string{"request": "requestId"}},
+	{as: "carol", route: "GET /join-requests", status: 200},
+	{as: "alice", route: "GET /join-requests?courseId={course}", status: 200},
+	{as: "alice", route: "POST /join-requests/approve", body: obj{"requestId": "{request}"}, status: 204},
+	{as: "carol", route: "DELETE /user-courses", body: obj{"courseId": "{course}"}, status: 204},
+	{as: "carol", route: "POST /user-courses", body: obj{"courseId": "{course}"}, status: 202,
+		save: map[string]string{"request2": "requestId"}},
+	{as: "alice", route: "POST /join-requests/reject", body: obj{"requestId": "{request2}"}, status: 204},
+	{as: "carol", route: "POST /user-courses", body: obj{"courseId": "{course}"}, status: 202,
+		save: map[string]string{"request3": "requestId"}},
+	{as: "carol", route: "DELETE /join-requests", body: obj{"requestId": "{request3}"}, status: 204},
+
+	// Administration
+	{as: "alice", route: "GET /admin/users/count", status: 200},
+	{as: "alice", route: "GET /admin/users?q=example&limit=10", status: 200},
+	{as: "alice", route: "POST /admin/users/logout", body: obj{"userId": "{bobId}"}, status: 200},
+	{as: "alice", route: "POST /admin/users/disable", body: obj{"userId": "{carolId}"}, status: 204},
+	{as: "alice", route: "POST /admin/users/enable", body: obj{"userId": "{carolId}"}, status: 204},
+	{as: "alice", route: "DELETE /admin/users/2fa", body: obj{"userId": "{carolId}"}, status: 204},
+	{as: "alice", route: "POST /admin/admins", body: obj{"userId": "{bobId}"}, status: 201},
+	{as: "alice", route: "DELETE /admin/admins", body: obj{"userId": "{bobId}"}, status: 204},
+	{as: "alice", route: "POST /admin/universities/merge", body: obj{"sourceId": "{uni2}", "targetId": "{uni}"}, status: 200},
+	{as: "alice", route: "GET /admin/stats", status: 200},
+
+	// Cleanup
+	{as: "alice", route: "DELETE /terms/{term2}", status: 204},
+	{as: "alice", route: "DELETE /universities", body: obj{"universityId": "{uni3}"}, status: 204},
+}
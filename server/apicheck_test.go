@@ -0,0 +1,552 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/sqlite-server/admin"
+	"example.com/sqlite-server/digest"
+	"example.com/sqlite-server/openapi"
+	"example.com/sqlite-server/push"
+	"example.com/sqlite-server/webhook"
+)
+
+// -----------------------------------------------------------
+// TestOpenAPIContract: the contract test for apiSpec
+// -----------------------------------------------------------
+//
+// The test serves registerRoutes from a scratch database and
+//   - requires every registered pattern to be documented, and every documented
+//     path to reach a handler other than the "/" fallback;
+//   - runs checkScenario, validating each request and response against the
+//     document (status, content type, schema), and requires every operation
+//     to have been exercised.
+func TestOpenAPIContract(t *testing.T) {
+	problems, err := checkOpenAPI(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range problems {
+		t.Error(p)
+	}
+}
+
+// routeRecorder registers on a ServeMux and remembers the patterns.
+type routeRecorder struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (r *routeRecorder) HandleFunc(pattern string, h func(http.ResponseWriter, *http.Request)) {
+	r.patterns = append(r.patterns, pattern)
+	r.ServeMux.HandleFunc(pattern, h)
+}
+
+// checkOpenAPI runs the check with its database in dir.
+func checkOpenAPI(dir string) ([]string, error) {
+	db, err := openDatabaseAt(filepath.Join(dir, "check.db"))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rec := &routeRecorder{ServeMux: http.NewServeMux()}
+	registerRoutes(rec, db)
+	spec := apiSpec()
+
+	problems := checkRoutes(spec, rec)
+
+	top := http.NewServeMux()
+	top.Handle("/api/", apiHandler(rec.ServeMux))
+	srv := httptest.NewServer(top)
+	defer srv.Close()
+
+	hooks := &hookReceiver{}
+	hookSrv := httptest.NewServer(hooks)
+	defer hookSrv.Close()
+	webhook.AllowPrivate = true
+	push.AllowPrivate = true
+	if push.Default == nil {
+		key, err := push.GenerateVAPID()
+		if err != nil {
+			return nil, err
+		}
+		if push.Default, err = push.NewVAPID(key, "mailto:check@localhost"); err != nil {
+			return nil, err
+		}
+	}
+
+	run := &checkRun{db: db, spec: spec, base: srv.URL + "/api", vars: map[string]string{}, clients: map[string]*http.Client{}, covered: map[*openapi.Op]bool{}, hooks: hooks}
+	run.vars["hookURL"] = strconv.Quote(hookSrv.URL + "/hook")
+	if err := run.newBrowser(hookSrv.URL + "/push"); err != nil {
+		return nil, err
+	}
+	for i, st := range checkScenario {
+		if err := run.step(st); err != nil {
+			problems = append(problems, fmt.Sprintf("step %d (%s as %s): %v", i+1, st.route, userOrAnon(st.as), err))
+		}
+	}
+	for _, o := range spec.Ops() {
+		if !run.covered[o] {
+			problems = append(problems, fmt.Sprintf("%s %s (%s) is never exercised", o.Method, o.Path, o.ID()))
+		}
+	}
+	return problems, nil
+}
+
+// checkRoutes compares the registered patterns with the documented paths.
+func checkRoutes(spec *openapi.Spec, rec *routeRecorder) []string {
+	var problems []string
+	documented := map[string]bool{}
+	for _, o := range spec.Ops() {
+		req := httptest.NewRequest(o.Method, o.SamplePath(), nil)
+		_, pattern := rec.Handler(req)
+		if pattern == "/" && o.Path != "/" {
+			problems = append(problems, fmt.Sprintf("%s %s is documented but no route serves it", o.Method, o.Path))
+			continue
+		}
+		documented[pattern] = true
+	}
+	for _, p := range rec.patterns {
+		if !documented[p] {
+			problems = append(problems, fmt.Sprintf("route %q is registered but not documented", p))
+		}
+	}
+	return problems
+}
+
+func userOrAnon(as string) string {
+	if as == "" {
+		return "anonymous"
+	}
+	return as
+}
+
+// checkStep is one request of the scenario. {name} in route and body is
+// replaced by a value saved by an earlier step; a body string that is exactly
+// "{name}" takes the saved JSON value (so numbers stay numbers). {totp} is
+// the current code for the saved totpSecret.
+type checkStep struct {
+	as     string // client (each has its own cookies); "" is anonymous
+	route  string // "METHOD /path?query"
+	body   any
+	status int
+	stream bool              // an event stream: check the response head and hang up
+	save   map[string]string // name -> path into the response, e.g. "items.0.id", "[current=false].id"
+	after  func(*checkRun) error
+}
+
+type checkRun struct {
+	db      *sql.DB
+	spec    *openapi.Spec
+	base    string
+	vars    map[string]string // raw JSON values
+	clients map[string]*http.Client
+	covered map[*openapi.Op]bool
+	hooks   *hookReceiver
+	browser *ecdh.PrivateKey // the push subscription's keys, as a browser would hold them
+	authKey []byte
+}
+
+var varRef = regexp.MustCompile(`\{([A-Za-z0-9]+)\}`)
+
+func (c *checkRun) client(as string) *http.Client {
+	if cl, ok := c.clients[as]; ok {
+		return cl
+	}
+	jar, _ := cookiejar.New(nil)
+	cl := &http.Client{
+		Jar:           jar,
+		Timeout:       10 * time.Second,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	c.clients[as] = cl
+	return cl
+}
+
+// text returns a saved value for use inside a string.
+func (c *checkRun) text(name string) (string, error) {
+	if name == "totp" {
+		return c.totp()
+	}
+	raw, ok := c.vars[name]
+	if !ok {
+		return "", fmt.Errorf("nothing saved as %q", name)
+	}
+	var s string
+	if json.Unmarshal([]byte(raw), &s) == nil {
+		return s, nil
+	}
+	return raw, nil
+}
+
+func (c *checkRun) expand(s string) (string, error) {
+	var err error
+	out := varRef.ReplaceAllStringFunc(s, func(m string) string {
+		v, e := c.text(m[1 : len(m)-1])
+		if e != nil {
+			err = e
+		}
+		return v
+	})
+	return out, err
+}
+
+func (c *checkRun) step(st checkStep) error {
+	method, target, _ := strings.Cut(st.route, " ")
+	target, err := c.expand(target)
+	if err != nil {
+		return err
+	}
+	path, _, _ := strings.Cut(target, "?")
+	op := c.spec.Find(method, path)
+	if op == nil {
+		return fmt.Errorf("no operation documents %s %s", method, path)
+	}
+	c.covered[op] = true
+
+	var body []byte
+	if st.body != nil {
+		if body, err = json.Marshal(st.body); err != nil {
+			return err
+		}
+		// Whole-string references keep the saved JSON type.
+		for name, raw := range c.vars {
+			body = bytes.ReplaceAll(body, []byte(`"{`+name+`}"`), []byte(raw))
+		}
+		s, err := c.expand(string(body))
+		if err != nil {
+			return err
+		}
+		body = []byte(s)
+		if err := op.CheckRequest(body); err != nil {
+			return fmt.Errorf("request: %v", err)
+		}
+	}
+
+	req, err := http.NewRequest(method, c.base+target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Sec-Fetch-Site", "same-origin")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := c.client(st.as).Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	var resBody []byte
+	if !st.stream {
+		if resBody, err = io.ReadAll(res.Body); err != nil {
+			return err
+		}
+	}
+
+	if res.StatusCode != st.status {
+		return fmt.Errorf("got %d, want %d: %s", res.StatusCode, st.status, bytes.TrimSpace(resBody))
+	}
+	if err := op.CheckResponse(res.StatusCode, res.Header.Get("Content-Type"), resBody); err != nil {
+		return fmt.Errorf("response: %v", err)
+	}
+
+	if len(st.save) > 0 {
+		var v any
+		dec := json.NewDecoder(bytes.NewReader(resBody))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return fmt.Errorf("response: %v", err)
+		}
+		for name, p := range st.save {
+			got, err := lookup(v, p)
+			if err != nil {
+				return fmt.Errorf("save %s: %v", name, err)
+			}
+			raw, _ := json.Marshal(got)
+			c.vars[name] = string(raw)
+		}
+	}
+	if st.after != nil {
+		return st.after(c)
+	}
+	return nil
+}
+
+// lookup follows a dotted path: object keys, array indexes, and [field=value]
+// for the first array element whose field has that value.
+func lookup(v any, p string) (any, error) {
+	for _, seg := range strings.Split(p, ".") {
+		switch cur := v.(type) {
+		case map[string]any:
+			next, ok := cur[seg]
+			if !ok {
+				return nil, fmt.Errorf("no %q", seg)
+			}
+			v = next
+		case []any:
+			if strings.HasPrefix(seg, "[") {
+				field, want, _ := strings.Cut(strings.Trim(seg, "[]"), "=")
+				found := false
+				for _, item := range cur {
+					if m, ok := item.(map[string]any); ok && fmt.Sprint(m[field]) == want {
+						v, found = item, true
+						break
+					}
+				}
+				if !found {
+					return nil, fmt.Errorf("no element with %s", seg)
+				}
+				continue
+			}
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(cur) {
+				return nil, fmt.Errorf("no element %q", seg)
+			}
+			v = cur[i]
+		default:
+			return nil, fmt.Errorf("cannot index %q", seg)
+		}
+	}
+	return v, nil
+}
+
+// totp computes the current code for the saved totpSecret (RFC 6238, as the
+// authenticator app would).
+func (c *checkRun) totp() (string, error) {
+	secret, err := c.text("totpSecret")
+	if err != nil {
+		return "", err
+	}
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return "", err
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(time.Now().Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	off := sum[len(sum)-1] & 0x0f
+	v := binary.BigEndian.Uint32(sum[off:off+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", v%1_000_000), nil
+}
+
+// hookReceiver stands in for a webhook endpoint: it answers 204 and keeps the
+// last request.
+type hookReceiver struct {
+	mu     sync.Mutex
+	header http.Header
+	body   []byte
+}
+
+func (h *hookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	h.mu.Lock()
+	h.header, h.body = r.Header.Clone(), body
+	h.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verifyWebhook checks the receiver's last request was signed with the saved
+// secret, as a receiver would.
+func verifyWebhook(secretVar string) func(*checkRun) error {
+	return func(c *checkRun) error {
+		secret, err := c.text(secretVar)
+		if err != nil {
+			return err
+		}
+		c.hooks.mu.Lock()
+		defer c.hooks.mu.Unlock()
+		if c.hooks.body == nil {
+			return fmt.Errorf("the webhook received nothing")
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(c.hooks.header.Get("X-Webhook-Timestamp") + "."))
+		mac.Write(c.hooks.body)
+		want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		if got := c.hooks.header.Get("X-Webhook-Signature"); !hmac.Equal([]byte(got), []byte(want)) {
+			return fmt.Errorf("webhook signature %q, want %q", got, want)
+		}
+		var p webhook.Payload
+		if err := json.Unmarshal(c.hooks.body, &p); err != nil || p.Type != "ping" {
+			return fmt.Errorf("webhook payload %s is not a ping", c.hooks.body)
+		}
+		return nil
+	}
+}
+
+// newBrowser makes the keys of a stand-in browser's push subscription and
+// saves it as pushSubscription, with endpoint at the hook receiver.
+func (c *checkRun) newBrowser(endpoint string) error {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	c.browser, c.authKey = key, make([]byte, 16)
+	if _, err := rand.Read(c.authKey); err != nil {
+		return err
+	}
+	sub, err := json.Marshal(map[string]any{
+		"endpoint":       endpoint,
+		"expirationTime": nil,
+		"keys": map[string]string{
+			"p256dh": base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+			"auth":   base64.RawURLEncoding.EncodeToString(c.authKey),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	c.vars["pushSubscription"] = string(sub)
+	c.vars["pushEndpoint"] = strconv.Quote(endpoint)
+	return nil
+}
+
+// verifyPush checks the receiver's last request as a push service and the
+// browser would: a valid VAPID signature for the receiver's origin, and a
+// body that decrypts (RFC 8291) to a notification titled title.
+func verifyPush(title string) func(*checkRun) error {
+	return func(c *checkRun) error {
+		c.hooks.mu.Lock()
+		header, body := c.hooks.header, c.hooks.body
+		c.hooks.mu.Unlock()
+		if body == nil || header.Get("Content-Encoding") != "aes128gcm" {
+			return fmt.Errorf("the push endpoint received no aes128gcm body")
+		}
+		if err := verifyVAPID(header.Get("Authorization")); err != nil {
+			return err
+		}
+
+		// Header: salt(16) | record size(4) | key length(1) | sender key.
+		if len(body) < 21 || len(body) < 21+int(body[20]) {
+			return fmt.Errorf("push body too short")
+		}
+		salt, senderKey, record := body[:16], body[21:21+int(body[20])], body[21+int(body[20]):]
+		sender, err := ecdh.P256().NewPublicKey(senderKey)
+		if err != nil {
+			return err
+		}
+		shared, err := c.browser.ECDH(sender)
+		if err != nil {
+			return err
+		}
+		info := append(append([]byte("WebPush: info\x00"), c.browser.PublicKey().Bytes()...), senderKey...)
+		ikm, _ := hkdf.Key(sha256.New, shared, c.authKey, string(info), 32)
+		prk, _ := hkdf.Extract(sha256.New, ikm, salt)
+		cek, _ := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+		nonce, _ := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+		block, err := aes.NewCipher(cek)
+		if err != nil {
+			return err
+		}
+		gcm, _ := cipher.NewGCM(block)
+		plain, err := gcm.Open(nil, nonce, record, nil)
+		if err != nil {
+			return fmt.Errorf("push body does not decrypt: %v", err)
+		}
+		plain = bytes.TrimRight(plain, "\x00")
+		if len(plain) == 0 || plain[len(plain)-1] != 0x02 {
+			return fmt.Errorf("push record is not marked last")
+		}
+		var n push.Notification
+		if err := json.Unmarshal(plain[:len(plain)-1], &n); err != nil || n.Title != title {
+			return fmt.Errorf("push notification %s, want title %q", plain[:len(plain)-1], title)
+		}
+		return nil
+	}
+}
+
+// verifyVAPID checks an Authorization header carries a JWT signed with the
+// server's VAPID key.
+func verifyVAPID(authorization string) error {
+	var token, key string
+	for _, part := range strings.Split(strings.TrimPrefix(authorization, "vapid "), ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			token = v
+		case "k":
+			key = v
+		}
+	}
+	if key != push.Default.PublicKey {
+		return fmt.Errorf("VAPID key %q, want %q", key, push.Default.PublicKey)
+	}
+	dot := strings.LastIndex(token, ".")
+	if dot < 0 {
+		return fmt.Errorf("malformed VAPID token %q", token)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(token[dot+1:])
+	if err != nil || len(sig) != 64 {
+		return fmt.Errorf("malformed VAPID signature")
+	}
+	raw, _ := base64.RawURLEncoding.DecodeString(key)
+	pub, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), raw)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256([]byte(token[:dot]))
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	if !ecdsa.Verify(pub, digest[:], r, s) {
+		return fmt.Errorf("VAPID signature does not verify")
+	}
+	return nil
+}
+
+// saveDigestToken mints and saves an unsubscribe token for the saved user,
+// as a digest to them would carry (it only reaches them by email).
+func saveDigestToken(userVar, name string) func(*checkRun) error {
+	return func(c *checkRun) error {
+		id, err := c.text(userVar)
+		if err != nil {
+			return err
+		}
+		token, err := digest.NewUnsubscribeToken(c.db, id)
+		if err != nil {
+			return err
+		}
+		c.vars[name] = strconv.Quote(token)
+		return nil
+	}
+}
+
+// grantAdmin makes the saved user an admin, as `server admin grant` would.
+func grantAdmin(userVar string) func(*checkRun) error {
+	return func(c *checkRun) error {
+		id, err := c.text(userVar)
+		if err != nil {
+			return err
+		}
+		_, err = admin.GrantAdmin(c.db, id)
+		return err
+	}
+}
@@ -0,0 +1,78 @@
+// Command example shows the apiclient package in use: it signs in and lists
+// the caller's open assignments with deadlines, per course.
+//
+//	go run ./apiclient/example -url http://localhost:8080/api -email me@example.com
+//	READING_TOKEN=... go run ./apiclient/example
+//
+// The password is read from READING_PASSWORD; with READING_TOKEN set no
+// sign-in happens and the token is sent instead.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"example.com/sqlite-server/apiclient"
+)
+
+func main() {
+	base := flag.String("url", "http://localhost:8080/api", "API base URL")
+	email := flag.String("email", "", "sign in with this email (password from READING_PASSWORD)")
+	flag.Parse()
+	ctx := context.Background()
+
+	var c *apiclient.Client
+	if token := os.Getenv("READING_TOKEN"); token != "" {
+		c = apiclient.New(*base, apiclient.WithToken(token))
+	} else {
+		c = apiclient.New(*base)
+		res, err := c.Login(ctx, apiclient.LoginBody{Email: *email, Password: os.Getenv("READING_PASSWORD")})
+		if err != nil {
+			log.Fatal(err)
+		}
+		if res.MFARequired {
+			log.Fatal("this account uses two-factor authentication; use READING_TOKEN instead")
+		}
+	}
+
+	me, err := c.GetMe(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("signed in as %s\n", me.Email)
+
+	unis, err := c.ListMemberships(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, u := range unis {
+		courses, err := c.ListMyCourses(ctx, apiclient.ListMyCoursesParams{UniversityID: u.UniversityID})
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, course := range courses {
+			page, err := c.ListAssignments(ctx, apiclient.ListAssignmentsParams{
+				CourseID:    course.ID,
+				Completed:   ptr(false),
+				HasDeadline: ptr(true),
+				Sort:        "deadline",
+			})
+			if err != nil {
+				log.Fatal(err)
+			}
+			if len(page.Items) == 0 {
+				continue
+			}
+			fmt.Printf("\n%s %s (%s)\n", course.Code, course.Name, u.Name)
+			for _, a := range page.Items {
+				fmt.Printf("  %s  %s\n", time.Unix(*a.Deadline, 0).Format("Mon 2 Jan 15:04"), a.Title)
+			}
+		}
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -0,0 +1,646 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+
+	"example.com/sqlite-server/admin"
+	"example.com/sqlite-server/article"
+	"example.com/sqlite-server/assignment"
+	"example.com/sqlite-server/auth"
+	"example.com/sqlite-server/book"
+	"example.com/sqlite-server/chapter"
+	"example.com/sqlite-server/course"
+	"example.com/sqlite-server/digest"
+	"example.com/sqlite-server/enrollment"
+	"example.com/sqlite-server/events"
+	"example.com/sqlite-server/invite"
+	"example.com/sqlite-server/membership"
+	"example.com/sqlite-server/openapi"
+	"example.com/sqlite-server/push"
+	"example.com/sqlite-server/search"
+	"example.com/sqlite-server/session"
+	"example.com/sqlite-server/term"
+	"example.com/sqlite-server/university"
+	"example.com/sqlite-server/util"
+	"example.com/sqlite-server/webhook"
+)
+
+// -----------------------------------------------------------
+// OpenAPI document
+// -----------------------------------------------------------
+//
+// apiSpec describes every route registerRoutes wires up. Response schemas are
+// reflected from the types the handlers encode; request bodies and small
+// handler-local responses are mirrored below. TestOpenAPIContract keeps
+// the two honest by exercising each route against the document.
+
+// Request bodies.
+type (
+	emailPasswordBody struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	loginBody struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+		Remember bool   `json:"remember,omitempty" doc:"longer-lived, persistent cookie"`
+	}
+	loginTOTPBody struct {
+		MFAToken string `json:"mfaToken"`
+		Code     string `json:"code" doc:"current TOTP code or a recovery code"`
+	}
+	codeBody struct {
+		Code string `json:"code"`
+	}
+	identityIDBody struct {
+		IdentityID int64 `json:"identityId"`
+	}
+	sessionIDBody struct {
+		SessionID string `json:"sessionId" doc:"the id from GET /sessions"`
+	}
+	createTokenBody struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes" doc:"read, progress:write, admin"`
+		ExpiresInDays *int64   `json:"expiresInDays,omitempty" doc:"1-365, default 90"`
+	}
+	tokenIDBody struct {
+		TokenID string `json:"tokenId"`
+	}
+	nameBody struct {
+		Name string `json:"name"`
+	}
+	universityIDBody struct {
+		UniversityID string `json:"universityId"`
+	}
+	setRoleBody struct {
+		UniversityID string `json:"universityId"`
+		UserID       string `json:"userId"`
+		Role         string `json:"role" enum:"member|curator"`
+	}
+	termBreakBody struct {
+		Name     string `json:"name"`
+		StartsOn string `json:"startsOn" doc:"YYYY-MM-DD"`
+		EndsOn   string `json:"endsOn" doc:"YYYY-MM-DD"`
+	}
+	termBody struct {
+		UniversityID string          `json:"universityId,omitempty" doc:"required on create, ignored on update"`
+		Year         int64           `json:"year,omitempty" doc:"required on create, ignored on update"`
+		Term         int64           `json:"term,omitempty" doc:"1-4; required on create, ignored on update"`
+		Name         string          `json:"name"`
+		StartsOn     string          `json:"startsOn" doc:"YYYY-MM-DD, inclusive"`
+		EndsOn       string          `json:"endsOn" doc:"YYYY-MM-DD, inclusive"`
+		Timezone     string          `json:"timezone" doc:"IANA name, e.g. Europe/Amsterdam"`
+		Breaks       []termBreakBody `json:"breaks,omitempty"`
+	}
+	createCourseBody struct {
+		UniversityID string `json:"universityId"`
+		Year         int64  `json:"year"`
+		Term         int64  `json:"term" doc:"1-4"`
+		Code         string `json:"code"`
+		Name         string `json:"name"`
+	}
+	courseIDBody struct {
+		CourseID int64 `json:"courseId"`
+	}
+	cloneCourseBody struct {
+		Year           int64  `json:"year"`
+		Term           int64  `json:"term" doc:"1-4"`
+		Code           string `json:"code,omitempty" doc:"defaults to the source's"`
+		Name           string `json:"name,omitempty" doc:"defaults to the source's"`
+		ShiftDeadlines bool   `json:"shiftDeadlines,omitempty"`
+	}
+	archiveBody struct {
+		Scope string `json:"scope,omitempty" enum:"me|course" doc:"default me"`
+	}
+	joinPolicyBody struct {
+		UniversityID string `json:"universityId,omitempty"`
+		CourseID     int64  `json:"courseId,omitempty"`
+		JoinPolicy   string `json:"joinPolicy" enum:"open|invite|approval"`
+	}
+	createInviteBody struct {
+		UniversityID  string `json:"universityId,omitempty"`
+		CourseID      int64  `json:"courseId,omitempty"`
+		ExpiresInDays int    `json:"expiresInDays,omitempty"`
+		MaxUses       int64  `json:"maxUses,omitempty"`
+	}
+	inviteIDBody struct {
+		InviteID string `json:"inviteId"`
+	}
+	requestIDBody struct {
+		RequestID int64 `json:"requestId"`
+	}
+	createWebhookBody struct {
+		CourseID int64  `json:"courseId"`
+		URL      string `json:"url"`
+	}
+	createdWebhookResult struct {
+		webhook.Webhook
+		Secret string `json:"secret" doc:"shown once; signs every delivery"`
+	}
+	webhookIDBody struct {
+		WebhookID int64 `json:"webhookId"`
+	}
+	digestSettingsBody struct {
+		Frequency string `json:"frequency" enum:"off|daily|weekly"`
+		Hour      int    `json:"hour,omitempty" doc:"0-23; omitted fields keep their values"`
+		Weekday   int    `json:"weekday,omitempty" doc:"0 = Sunday ... 6 = Saturday"`
+		Timezone  string `json:"timezone,omitempty" doc:"IANA name, e.g. Europe/Amsterdam"`
+	}
+	digestSettingsResult struct {
+		digest.Settings
+		MailEnabled bool `json:"mailEnabled" doc:"false when the server has no mailer configured"`
+	}
+	pushSettingsBody struct {
+		LeadHours   int  `json:"leadHours,omitempty" doc:"1-168; omitted fields keep their values"`
+		Chapters    bool `json:"chapters,omitempty"`
+		Articles    bool `json:"articles,omitempty"`
+		Assignments bool `json:"assignments,omitempty"`
+	}
+	pushSettingsResult struct {
+		push.Settings
+		Enabled   bool   `json:"enabled" doc:"false when the server has no VAPID key configured"`
+		PublicKey string `json:"publicKey,omitempty" doc:"the VAPID key, for PushManager.subscribe's applicationServerKey"`
+	}
+	pushKeys struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	}
+	pushSubscriptionBody struct {
+		Endpoint       string   `json:"endpoint"`
+		ExpirationTime any      `json:"expirationTime,omitempty" doc:"as the browser sends it; ignored"`
+		Keys           pushKeys `json:"keys"`
+	}
+	pushEndpointBody struct {
+		Endpoint string `json:"endpoint"`
+	}
+	pushTestResult struct {
+		Sent int `json:"sent" doc:"browsers that accepted the notification"`
+	}
+	createBookBody struct {
+		CourseID    int64   `json:"courseId"`
+		Title       string  `json:"title"`
+		Author      string  `json:"author"`
+		NumChapters *int64  `json:"numChapters,omitempty"`
+		Location    *string `json:"location,omitempty"`
+	}
+	bookIDBody struct {
+		BookID int64 `json:"bookId"`
+	}
+	deadlineBody struct {
+		Deadline *int64 `json:"deadline" doc:"unix seconds; null clears"`
+	}
+	progressBody struct {
+		Completed bool `json:"completed"`
+	}
+	createArticleBody struct {
+		CourseID int64   `json:"courseId"`
+		Title    string  `json:"title"`
+		Author   string  `json:"author"`
+		Location *string `json:"location,omitempty"`
+	}
+	articleIDBody struct {
+		ArticleID int64 `json:"articleId"`
+	}
+	createAssignmentBody struct {
+		CourseID    int64   `json:"courseId"`
+		Title       string  `json:"title"`
+		Description *string `json:"description,omitempty"`
+	}
+	assignmentIDBody struct {
+		AssignmentID int64 `json:"assignmentId"`
+	}
+	userIDBody struct {
+		UserID string `json:"userId"`
+	}
+	reviewBody struct {
+		UniversityID string `json:"universityId"`
+		Reason       string `json:"reason,omitempty" doc:"note shown to the creator"`
+	}
+	mergeBody struct {
+		SourceID string `json:"sourceId"`
+		TargetID string `json:"targetId"`
+	}
+)
+
+// Responses the handlers build from local types.
+type (
+	tokenResult struct {
+		Token string `json:"token"`
+	}
+	userIDResult struct {
+		UserID string `json:"userId"`
+	}
+	loginResult struct {
+		UserID      string `json:"userId,omitempty" doc:"set when signed in"`
+		MFARequired bool   `json:"mfaRequired,omitempty" doc:"true when a second factor is needed"`
+		MFAToken    string `json:"mfaToken,omitempty" doc:"pass to POST /login/2fa"`
+	}
+	meResult struct {
+		UserID string `json:"userId"`
+		Email  string `json:"email"`
+	}
+	totpSetupResult struct {
+		Secret     string `json:"secret"`
+		OtpauthURI string `json:"otpauthUri"`
+	}
+	recoveryCodesResult struct {
+		RecoveryCodes []string `json:"recoveryCodes" doc:"shown once"`
+	}
+	revokedResult struct {
+		Revoked int64 `json:"revoked"`
+	}
+	createdTokenResult struct {
+		session.APIToken
+		Token string `json:"token" doc:"the secret, shown once"`
+	}
+	pendingResult struct {
+		Status    string `json:"status" enum:"pending"`
+		RequestID int64  `json:"requestId"`
+	}
+	joinPolicyResult struct {
+		JoinPolicy string `json:"joinPolicy" enum:"open|invite|approval"`
+	}
+	createdInviteResult struct {
+		invite.Invite
+		Code string `json:"code" doc:"shown once"`
+		Link string `json:"link"`
+	}
+	completedResult struct {
+		Completed bool `json:"completed"`
+	}
+	calendarTokenResult struct {
+		Token   string `json:"token,omitempty" doc:"only when just created"`
+		URLPath string `json:"urlPath,omitempty" doc:"only when just created"`
+		Exists  bool   `json:"exists"`
+	}
+	rotatedCalendarTokenResult struct {
+		Token   string `json:"token"`
+		URLPath string `json:"urlPath"`
+	}
+	countResult struct {
+		Count int64 `json:"count"`
+	}
+)
+
+const apiDescription = `Reading tracker API. Errors are application/problem+json documents with a
+stable "code". Mutating requests authenticated by the session cookie must pass
+the CSRF check (Sec-Fetch-Site, Origin, or the X-CSRF-Token header from GET /csrf).`
+
+func apiSpec() *openapi.Spec {
+	s := openapi.New("Reading API", "1.0.0", apiDescription, util.Problem{})
+	s.Server("/api")
+	s.Security("session", openapi.SecurityScheme{
+		Type: "apiKey", In: "cookie", Name: "session",
+		Description: "Set by /register and /login (named __Host-session in production).",
+	})
+	s.Security("token", openapi.SecurityScheme{
+		Type: "http", Scheme: "bearer",
+		Description: "Personal access token; scopes limit what it can do.",
+	})
+
+	paged := func(o *openapi.Op, sorts ...string) *openapi.Op {
+		values := make([]string, 0, 2*len(sorts))
+		for _, v := range sorts {
+			values = append(values, v, "-"+v)
+		}
+		return o.
+			Query("limit", "integer", false, "page size, default 50, max 200").
+			Query("cursor", "string", false, "nextCursor from the previous page").
+			QueryEnum("sort", false, "prefix with - for descending", values...).
+			Errors(400)
+	}
+	filtered := func(o *openapi.Op) *openapi.Op {
+		return o.
+			Query("completed", "boolean", false, "done by the caller").
+			Query("hasDeadline", "boolean", false, "has a deadline").
+			Query("dueBefore", "integer", false, "deadline before this unix time")
+	}
+	authed := func(o *openapi.Op) *openapi.Op { return o.Errors(401) }
+
+	s.Tag("Meta")
+	s.Op("health", "GET /", "Liveness check").Public().ReturnsText(200, "text/plain")
+	s.Op("getCSRFToken", "GET /csrf", "Get a CSRF token").Public().
+		Describe("Sets the csrf cookie; send the token back in X-CSRF-Token.").
+		Returns(200, tokenResult{})
+	s.Op("getOpenAPI", "GET /openapi.json", "This document").Public().Returns(200, map[string]any{})
+
+	s.Tag("Auth")
+	s.Op("register", "POST /register", "Create an account and sign in").Public().
+		Body(emailPasswordBody{}).Returns(201, userIDResult{}).Errors(400, 409)
+	s.Op("login", "POST /login", "Sign in").Public().
+		Describe("With 2FA enabled no session is created; finish with POST /login/2fa.").
+		Body(loginBody{}).Returns(200, loginResult{}).Errors(400, 401, 403, 429)
+	s.Op("loginTOTP", "POST /login/2fa", "Finish signing in with a second factor").Public().
+		Body(loginTOTPBody{}).Returns(200, userIDResult{}).Errors(400, 401, 403, 429)
+	s.Op("logout", "POST /logout", "Sign out").Public().Empty(204)
+	authed(s.Op("getMe", "GET /me", "The signed-in user").Returns(200, meResult{}))
+
+	s.Tag("Two-factor authentication")
+	authed(s.Op("getTOTPStatus", "GET /auth/2fa", "2FA status").Returns(200, auth.TOTPStatus{}))
+	authed(s.Op("setupTOTP", "POST /auth/2fa/setup", "Start 2FA enrolment").
+		Returns(200, totpSetupResult{}).Errors(409))
+	authed(s.Op("enableTOTP", "POST /auth/2fa/enable", "Confirm enrolment with a code").
+		Body(codeBody{}).Returns(200, recoveryCodesResult{}).Errors(400, 409))
+	authed(s.Op("regenerateRecoveryCodes", "POST /auth/2fa/recovery-codes", "Replace the recovery codes").
+		Body(codeBody{}).Returns(200, recoveryCodesResult{}).Errors(400, 409, 429))
+	authed(s.Op("disableTOTP", "DELETE /auth/2fa", "Turn 2FA off").
+		Body(codeBody{}).Empty(204).Errors(400, 404, 409, 429))
+
+	s.Tag("Single sign-on")
+	s.Op("oidcLogin", "GET /auth/oidc/login", "Redirect to the identity provider").Public().
+		Query("returnTo", "string", false, "app path to return to").
+		Query("link", "string", false, "1 to link the identity to the signed-in account").
+		Empty(302).Errors(401, 403, 404, 502)
+	s.Op("oidcCallback", "GET /auth/oidc/callback", "Provider redirect target").Public().
+		Describe("Signs in and redirects to returnTo; with 2FA on, redirects to "+
+			"/login/2fa#mfaToken=...&returnTo=... instead, to finish at POST /login/2fa.").
+		Query("code", "string", false, "").Query("state", "string", false, "").
+		Empty(302).Errors(400, 401, 403, 404, 409, 429)
+	authed(s.Op("listIdentities", "GET /auth/identities", "Linked external identities").
+		Returns(200, []auth.Identity{}))
+	authed(s.Op("unlinkIdentity", "DELETE /auth/identities", "Unlink an external identity").
+		Body(identityIDBody{}).Empty(204).Errors(400, 404, 409))
+
+	s.Tag("Sessions")
+	authed(s.Op("listSessions", "GET /sessions", "Active sessions").Returns(200, []session.SessionView{}))
+	authed(s.Op("revokeSession", "DELETE /sessions", "Sign out one session").
+		Body(sessionIDBody{}).Empty(204).Errors(400, 404))
+	authed(s.Op("revokeOtherSessions", "DELETE /sessions/others", "Sign out all other sessions").
+		Returns(200, revokedResult{}))
+
+	s.Tag("Personal access tokens")
+	authed(s.Op("listTokens", "GET /tokens", "My tokens").Only("session").
+		Returns(200, []session.APIToken{}).Errors(403))
+	authed(s.Op("createToken", "POST /tokens", "Create a token").Only("session").
+		Body(createTokenBody{}).Returns(201, createdTokenResult{}).Errors(400, 403))
+	authed(s.Op("revokeToken", "DELETE /tokens", "Revoke a token").Only("session").
+		Body(tokenIDBody{}).Empty(204).Errors(400, 403, 404))
+
+	s.Tag("Universities")
+	paged(s.Op("listUniversities", "GET /universities", "Approved universities").Public().
+		Query("q", "string", false, "name search").
+		Returns(200, util.Page[university.University]{}), "name", "created")
+	authed(s.Op("listMyUniversities", "GET /universities/mine", "Universities I created").
+		Returns(200, []university.University{}))
+	authed(s.Op("createUniversity", "POST /universities", "Propose a university").
+		Body(nameBody{}).Returns(201, university.University{}).Errors(400, 409))
+	authed(s.Op("deleteUniversity", "DELETE /universities", "Delete an empty university").
+		Body(universityIDBody{}).Empty(204).Errors(400, 403, 404, 409))
+
+	s.Tag("Memberships")
+	authed(s.Op("listMemberships", "GET /user-universities", "My universities").
+		Returns(200, []membership.MembershipView{}))
+	authed(s.Op("joinUniversity", "POST /user-universities", "Join a university").
+		Describe("200 when already a member; 202 when the join policy files a request.").
+		Body(universityIDBody{}).Returns(201, membership.Membership{}).Returns(200, membership.Membership{}).
+		Returns(202, pendingResult{}).Errors(400, 403, 404, 409))
+	authed(s.Op("leaveUniversity", "DELETE /user-universities", "Leave a university").
+		Body(universityIDBody{}).Empty(204).Errors(400, 404, 409))
+	authed(s.Op("setMemberRole", "PATCH /user-universities/role", "Change a member's role").
+		Body(setRoleBody{}).Empty(204).Errors(400, 403, 404, 409))
+
+	s.Tag("Terms")
+	authed(s.Op("listTerms", "GET /terms", "A university's terms").
+		Query("universityId", "string", true, "").Returns(200, []term.Term{}).Errors(400))
+	authed(s.Op("createTerm", "POST /terms", "Define a term").
+		Body(termBody{}).Returns(201, term.Term{}).Errors(400, 403, 409))
+	authed(s.Op("getTerm", "GET /terms/{id}", "One term").PathParam("id", "integer", "").
+		Returns(200, term.Term{}).Errors(400, 404))
+	authed(s.Op("updateTerm", "PUT /terms/{id}", "Change a term's dates and breaks").PathParam("id", "integer", "").
+		Body(termBody{}).Returns(200, term.Term{}).Errors(400, 403, 404))
+	authed(s.Op("deleteTerm", "DELETE /terms/{id}", "Delete a term").PathParam("id", "integer", "").
+		Empty(204).Errors(400, 403, 404))
+
+	s.Tag("Courses")
+	authed(s.Op("listMyCourses", "GET /courses", "My courses at a university").
+		Query("universityId", "string", true, "").
+		QueryEnum("include", false, "also list archived courses", "archived").
+		Returns(200, []course.Course{}).Errors(400, 403))
+	authed(paged(s.Op("listCourseCatalog", "GET /course-catalog", "All courses at a university").
+		Query("universityId", "string", true, "").
+		Query("year", "integer", false, "").Query("term", "integer", false, "1-4").
+		QueryEnum("include", false, "also list archived courses", "archived").
+		Returns(200, util.Page[course.Course]{}).Errors(403), "recent", "code", "name"))
+	authed(s.Op("listCurrentCourses", "GET /courses/current", "My courses running today, by term").
+		Returns(200, []course.TermCourses{}))
+	authed(s.Op("createCourse", "POST /courses", "Create a course").
+		Body(createCourseBody{}).Returns(201, course.Course{}).Errors(400, 403, 409))
+	authed(s.Op("deleteCourse", "DELETE /courses", "Delete an empty course").
+		Body(courseIDBody{}).Empty(204).Errors(400, 403, 404, 409))
+	authed(s.Op("cloneCourse", "POST /courses/{id}/clone", "Copy a course into another year and term").
+		Describe("The caller must be a curator of the course or enrolled in it.").
+		PathParam("id", "integer", "").
+		Body(cloneCourseBody{}).Returns(201, course.CloneResult{}).Errors(400, 403, 404, 409))
+	authed(s.Op("archiveCourse", "POST /courses/{id}/archive", "Archive a course for me or everyone").
+		PathParam("id", "integer", "").
+		OptionalBody(archiveBody{}).Empty(204).Errors(400, 403, 404))
+	authed(s.Op("restoreCourse", "POST /courses/{id}/restore", "Undo archiving").
+		PathParam("id", "integer", "").
+		OptionalBody(archiveBody{}).Empty(204).Errors(400, 403, 404))
+
+	s.Tag("Enrollments")
+	authed(s.Op("enroll", "POST /user-courses", "Enroll in a course").
+		Describe("200 when already enrolled; 202 when the join policy files a request. courseId may also be a numeric string.").
+		Body(courseIDBody{}).Returns(201, enrollment.Enrollment{}).Returns(200, enrollment.Enrollment{}).
+		Returns(202, pendingResult{}).Errors(400, 403, 409))
+	authed(s.Op("unenroll", "DELETE /user-courses", "Leave a course").
+		Body(courseIDBody{}).Empty(204).Errors(400, 404))
+
+	s.Tag("Invites")
+	authed(s.Op("setJoinPolicy", "PATCH /join-policy", "Set how people join a university or course").
+		Body(joinPolicyBody{}).Returns(200, joinPolicyResult{}).Errors(400, 403, 404))
+	authed(s.Op("listInvites", "GET /invites", "Invites for a university or course").
+		Query("universityId", "string", false, "").Query("courseId", "integer", false, "").
+		Returns(200, []invite.Invite{}).Errors(400, 403, 404))
+	authed(s.Op("createInvite", "POST /invites", "Create an invite link").
+		Body(createInviteBody{}).Returns(201, createdInviteResult{}).Errors(400, 403, 404, 409))
+	authed(s.Op("revokeInvite", "DELETE /invites", "Revoke an invite").
+		Body(inviteIDBody{}).Empty(204).Errors(400, 403, 404))
+	authed(s.Op("acceptInvite", "POST /invites/accept", "Join with an invite code").
+		Body(codeBody{}).Returns(200, invite.Accepted{}).Errors(400, 404, 409, 410))
+	authed(s.Op("listJoinRequests", "GET /join-requests", "Join requests").
+		Describe("Without universityId or courseId: my own requests. With one: that target's requests (curators).").
+		Query("universityId", "string", false, "").Query("courseId", "integer", false, "").
+		QueryEnum("status", false, "default pending", "pending", "approved", "rejected").
+		Returns(200, []invite.JoinRequest{}).Errors(400, 403, 404))
+	authed(s.Op("withdrawJoinRequest", "DELETE /join-requests", "Withdraw my pending request").
+		Body(requestIDBody{}).Empty(204).Errors(400, 404, 409))
+	authed(s.Op("approveJoinRequest", "POST /join-requests/approve", "Approve a join request").
+		Body(requestIDBody{}).Empty(204).Errors(400, 403, 404, 409))
+	authed(s.Op("rejectJoinRequest", "POST /join-requests/reject", "Reject a join request").
+		Body(requestIDBody{}).Empty(204).Errors(400, 403, 404, 409))
+
+	s.Tag("Books")
+	authed(paged(filtered(s.Op("listBooks", "GET /books", "A course's books with my chapter progress").
+		Query("courseId", "integer", true, "").
+		Returns(200, util.Page[book.Book]{}).Errors(403)), "created", "title", "author"))
+	authed(s.Op("createBook", "POST /books", "Add a book").
+		Body(createBookBody{}).Returns(201, book.Book{}).Errors(400, 403))
+	authed(s.Op("deleteBook", "DELETE /books", "Delete a book nobody has progress on").
+		Body(bookIDBody{}).Empty(204).Errors(400, 403, 404, 409))
+
+	s.Tag("Chapters")
+	authed(s.Op("setChapterDeadline", "PATCH /chapters/{id}/deadline", "Set or clear a chapter deadline").
+		PathParam("id", "integer", "").
+		Body(deadlineBody{}).Returns(200, chapter.Chapter{}).Errors(400, 403, 404))
+	authed(s.Op("setChapterProgress", "PATCH /chapters/{id}/progress", "Mark a chapter done or not").
+		PathParam("id", "integer", "").
+		Body(progressBody{}).Returns(200, completedResult{}).Errors(400, 403, 404))
+
+	s.Tag("Articles")
+	authed(paged(filtered(s.Op("listArticles", "GET /articles", "A course's articles with my progress").
+		Query("courseId", "integer", true, "").
+		Returns(200, util.Page[article.ArticleWithStatus]{}).Errors(403)), "created", "title", "author", "deadline"))
+	authed(s.Op("createArticle", "POST /articles", "Add an article").
+		Body(createArticleBody{}).Returns(201, article.Article{}).Errors(400, 403))
+	authed(s.Op("deleteArticle", "DELETE /articles", "Delete an article nobody has progress on").
+		Body(articleIDBody{}).Empty(204).Errors(400, 403, 404, 409))
+	authed(s.Op("setArticleDeadline", "PATCH /articles/{id}/deadline", "Set or clear an article deadline").
+		PathParam("id", "integer", "").
+		Body(deadlineBody{}).Returns(200, article.Article{}).Errors(400, 403, 404))
+	authed(s.Op("setArticleProgress", "PATCH /articles/{id}/progress", "Mark an article done or not").
+		PathParam("id", "integer", "").
+		Body(progressBody{}).Returns(200, completedResult{}).Errors(400, 403, 404))
+
+	s.Tag("Assignments")
+	authed(paged(filtered(s.Op("listAssignments", "GET /assignments", "A course's assignments with my progress").
+		Query("courseId", "integer", true, "").
+		Returns(200, util.Page[assignment.AssignmentWithStatus]{}).Errors(403)), "created", "title", "deadline"))
+	authed(s.Op("createAssignment", "POST /assignments", "Add an assignment").
+		Body(createAssignmentBody{}).Returns(201, assignment.Assignment{}).Errors(400, 403))
+	authed(s.Op("deleteAssignment", "DELETE /assignments", "Delete an assignment nobody has progress on").
+		Body(assignmentIDBody{}).Empty(204).Errors(400, 403, 404, 409))
+	authed(s.Op("setAssignmentDeadline", "PATCH /assignments/{id}/deadline", "Set or clear an assignment deadline").
+		PathParam("id", "integer", "").
+		Body(deadlineBody{}).Returns(200, assignment.Assignment{}).Errors(400, 403, 404))
+	authed(s.Op("setAssignmentProgress", "PATCH /assignments/{id}/progress", "Mark an assignment done or not").
+		PathParam("id", "integer", "").
+		Body(progressBody{}).Returns(200, completedResult{}).Errors(400, 403, 404))
+
+	s.Tag("Search")
+	authed(s.Op("search", "GET /search", "Search courses and materials").
+		Query("q", "string", true, "words; the last one matches as a prefix").
+		QueryEnum("kind", false, "", search.KindCourse, search.KindBook, search.KindArticle, search.KindAssignment).
+		Query("limit", "integer", false, "default 20, max 100").
+		QueryEnum("include", false, "also search archived courses", "archived").
+		Returns(200, []search.Hit{}).Errors(400))
+
+	s.Tag("Calendar")
+	authed(s.Op("getCalendar", "GET /calendar.ics", "My deadlines as iCalendar").
+		ReturnsText(200, "text/calendar").Empty(304))
+	authed(s.Op("getCalendarToken", "GET /calendar/token", "Create my feed token if there is none").
+		Returns(200, calendarTokenResult{}))
+	authed(s.Op("rotateCalendarToken", "POST /calendar/token/rotate", "Replace my feed token").
+		Returns(200, rotatedCalendarTokenResult{}))
+	s.Op("getCalendarFeed", "GET /calendar/{token}.ics", "Subscribable feed (token in the URL)").Public().
+		ReturnsText(200, "text/calendar").Empty(304).Errors(404)
+
+	s.Tag("Digests")
+	authed(s.Op("getDigestSettings", "GET /digest/settings", "My deadline digest settings").
+		Returns(200, digestSettingsResult{}))
+	authed(s.Op("saveDigestSettings", "PUT /digest/settings", "Choose daily, weekly or no deadline digests").
+		Describe("Digests list incomplete items that are overdue (up to 14 days) or due in the next 3 days "+
+			"(daily) or 14 days (weekly), and are only sent when there is something to list. Saving "+
+			"restarts the schedule: the first digest goes out at the next slot.").
+		Body(digestSettingsBody{}).Returns(200, digestSettingsResult{}).Errors(400))
+	s.Op("digestUnsubscribePage", "GET /digest/unsubscribe", "Confirm unsubscribing (link in each digest)").Public().
+		Query("token", "string", true, "").ReturnsText(200, "text/html")
+	s.Op("digestUnsubscribe", "POST /digest/unsubscribe", "Stop digests (also RFC 8058 one-click)").Public().
+		Query("token", "string", true, "").ReturnsText(200, "text/html").ReturnsText(404, "text/html")
+
+	s.Tag("Push")
+	authed(s.Op("getPushSettings", "GET /push/settings", "My push notification settings, and the server's VAPID key").
+		Returns(200, pushSettingsResult{}))
+	authed(s.Op("savePushSettings", "PUT /push/settings", "Choose how early, and for what, to be notified").
+		Describe("Each browser subscribed below is notified once per deadline, leadHours before it, about "+
+			"incomplete items of the enabled kinds.").
+		Body(pushSettingsBody{}).Returns(200, pushSettingsResult{}).Errors(400))
+	authed(s.Op("listPushSubscriptions", "GET /push/subscriptions", "My subscribed browsers").
+		Returns(200, []push.Subscription{}))
+	authed(s.Op("createPushSubscription", "POST /push/subscriptions", "Subscribe this browser").
+		Describe("The body is the browser's PushSubscription as JSON. An endpoint already subscribed "+
+			"by another user moves to the caller.").
+		Body(pushSubscriptionBody{}).Returns(201, push.Subscription{}).Errors(400))
+	authed(s.Op("deletePushSubscription", "DELETE /push/subscriptions", "Unsubscribe a browser").
+		Body(pushEndpointBody{}).Empty(204).Errors(400, 404))
+	authed(s.Op("testPush", "POST /push/test", "Send a test notification to my browsers").
+		Returns(200, pushTestResult{}).Errors(502, 503))
+
+	s.Tag("Events")
+	s.Model(events.Event{})
+	authed(s.Op("streamEvents", "GET /events", "Live changes in my courses").
+		Describe("A text/event-stream. Each message's data is an Event and its id can be sent back as "+
+			"Last-Event-ID (or lastEventId) to receive what was missed; a \"reset\" event means that is no "+
+			"longer possible and the client should reload. Progress events only reach their own user.").
+		Query("lastEventId", "integer", false, "resume after this event id").
+		ReturnsText(200, "text/event-stream"))
+
+	s.Tag("Webhooks")
+	s.Model(webhook.Payload{})
+	authed(s.Op("listWebhooks", "GET /webhooks", "A course's webhooks (curators)").
+		Query("courseId", "integer", true, "").Returns(200, []webhook.Webhook{}).Errors(400, 403, 404))
+	authed(s.Op("createWebhook", "POST /webhooks", "Register a webhook (curators)").
+		Describe("The URL receives a POST with a Payload whenever material is added to or deleted from the "+
+			"course or a deadline changes. X-Webhook-Signature is \"sha256=\" and the hex HMAC-SHA256, keyed "+
+			"with the secret, of X-Webhook-Timestamp, \".\" and the body. Failed deliveries are retried with "+
+			"backoff for about 15 hours.").
+		Body(createWebhookBody{}).Returns(201, createdWebhookResult{}).Errors(400, 403, 404, 409))
+	authed(s.Op("deleteWebhook", "DELETE /webhooks", "Remove a webhook (curators)").
+		Body(webhookIDBody{}).Empty(204).Errors(400, 403, 404))
+	authed(s.Op("listWebhookDeliveries", "GET /webhooks/{id}/deliveries", "A webhook's delivery log (curators)").
+		PathParam("id", "integer", "").Query("limit", "integer", false, "default 50, at most 200").
+		Returns(200, []webhook.Delivery{}).Errors(400, 403, 404))
+	authed(s.Op("pingWebhook", "POST /webhooks/{id}/ping", "Send a test ping (curators)").
+		Describe("Delivers a \"ping\" payload now, without retries, and returns the delivery whatever the outcome.").
+		PathParam("id", "integer", "").Returns(200, webhook.Delivery{}).Errors(400, 403, 404))
+
+	s.Tag("Admin")
+	adminOnly := func(o *openapi.Op) *openapi.Op { return o.Errors(401, 403) }
+	adminOnly(s.Op("adminCountUsers", "GET /admin/users/count", "Number of users").Returns(200, countResult{}))
+	adminOnly(s.Op("adminListUsers", "GET /admin/users", "Users").
+		Query("q", "string", false, "email search").
+		Query("limit", "integer", false, "default 50").Query("offset", "integer", false, "").
+		Returns(200, admin.UserPage{}))
+	adminOnly(s.Op("adminLogoutUser", "POST /admin/users/logout", "Sign a user out everywhere").
+		Body(userIDBody{}).Returns(200, revokedResult{}).Errors(400, 404))
+	adminOnly(s.Op("adminDisableUser", "POST /admin/users/disable", "Disable an account").
+		Body(userIDBody{}).Empty(204).Errors(400, 404, 409))
+	adminOnly(s.Op("adminEnableUser", "POST /admin/users/enable", "Re-enable an account").
+		Body(userIDBody{}).Empty(204).Errors(400, 404))
+	adminOnly(s.Op("adminResetTOTP", "DELETE /admin/users/2fa", "Turn off a user's 2FA").
+		Body(userIDBody{}).Empty(204).Errors(400, 404))
+	adminOnly(s.Op("adminGrant", "POST /admin/admins", "Make a user an admin").
+		Describe("201 when granted, 200 when already an admin.").
+		Body(userIDBody{}).Empty(201, 200).Errors(400, 404))
+	adminOnly(s.Op("adminRevoke", "DELETE /admin/admins", "Remove admin rights").
+		Body(userIDBody{}).Empty(204).Errors(400, 404, 409))
+	adminOnly(s.Op("adminReviewQueue", "GET /admin/universities", "Universities by review status").
+		QueryEnum("status", false, "default pending", university.StatusPending, university.StatusApproved, university.StatusRejected).
+		Returns(200, []university.ReviewItem{}).Errors(400))
+	adminOnly(s.Op("adminApproveUniversity", "POST /admin/universities/approve", "Approve a university").
+		Body(reviewBody{}).Empty(204).Errors(400, 404, 409))
+	adminOnly(s.Op("adminRejectUniversity", "POST /admin/universities/reject", "Reject a university").
+		Body(reviewBody{}).Empty(204).Errors(400, 404, 409))
+	adminOnly(s.Op("adminMergeUniversities", "POST /admin/universities/merge", "Merge a duplicate university into another").
+		Body(mergeBody{}).Returns(200, admin.MergeResult{}).Errors(400, 404, 409))
+	adminOnly(s.Op("adminStats", "GET /admin/stats", "Counts for the dashboard").Returns(200, admin.Stats{}))
+
+	return s
+}
+
+var (
+	specOnce sync.Once
+	specJSON []byte
+)
+
+// GET /openapi.json
+func openapiHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	specOnce.Do(func() { specJSON = apiSpec().JSON() })
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, _ = w.Write(specJSON)
+}
@@ -10,11 +10,12 @@ import (
 	"example.com/sqlite-server/enrollment"
 	"example.com/sqlite-server/membership"
 	"example.com/sqlite-server/session"
+	"example.com/sqlite-server/term"
 	"example.com/sqlite-server/util"
 )
 
 // RegisterArticleRoutes wires the /articles endpoints behind auth.
-func RegisterArticleRoutes(mux *http.ServeMux, db *sql.DB) {
+func RegisterArticleRoutes(mux util.Router, db *sql.DB) {
 	mux.HandleFunc("/articles", session.RequireAuth(db, articlesHandler(db)))
 	mux.HandleFunc("/articles/", session.RequireAuth(db, articlesDispatcher(db)))
 }
@@ -30,7 +31,7 @@ func articlesHandler(db *sql.DB) http.HandlerFunc {
 		case http.MethodDelete:
 			deleteArticleHandler(db)(w, r)
 		default:
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
 		}
 	}
 }
@@ -42,12 +43,12 @@ func articlesDispatcher(db *sql.DB) http.HandlerFunc {
 		path := strings.TrimPrefix(r.URL.Path, "/articles/")
 		parts := strings.Split(path, "/")
 		if len(parts) != 2 || parts[0] == "" {
-			http.Error(w, "not found", http.StatusNotFound)
+			util.HTTPError(w, "not found", http.StatusNotFound)
 			return
 		}
 		id, err := strconv.ParseInt(parts[0], 10, 64)
 		if err != nil || id <= 0 {
-			http.Error(w, "invalid id", http.StatusBadRequest)
+			util.HTTPError(w, "invalid id", http.StatusBadRequest)
 			return
 		}
 
@@ -57,17 +58,17 @@ func articlesDispatcher(db *sql.DB) http.HandlerFunc {
 			case http.MethodPatch:
 				patchArticleDeadlineHandler(db, id)(w, r)
 			default:
-				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
 			}
 		case "progress":
 			switch r.Method {
 			case http.MethodPatch:
 				patchArticleProgressHandler(db, id)(w, r)
 			default:
-				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
 			}
 		default:
-			http.Error(w, "not found", http.StatusNotFound)
+			util.HTTPError(w, "not found", http.StatusNotFound)
 		}
 	}
 }
@@ -86,7 +87,7 @@ func postArticleHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid, ok := session.UserIDFromCtx(r.Context())
 		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 
@@ -94,7 +95,7 @@ func postArticleHandler(db *sql.DB) http.HandlerFunc {
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
 		if err := dec.Decode(&p); err != nil {
-			http.Error(w, "bad request", http.StatusBadRequest)
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
 			return
 		}
 
@@ -109,7 +110,7 @@ func postArticleHandler(db *sql.DB) http.HandlerFunc {
 			}
 		}
 		if p.CourseID <= 0 || p.Title == "" || p.Author == "" {
-			http.Error(w, "invalid input", http.StatusBadRequest)
+			util.HTTPError(w, "invalid input", http.StatusBadRequest)
 			return
 		}
 
@@ -117,35 +118,26 @@ func postArticleHandler(db *sql.DB) http.HandlerFunc {
 		uniID, err := enrollment.CourseUniversity(db, p.CourseID)
 		if err != nil {
 			if err == sql.ErrNoRows {
-				http.Error(w, "course not found", http.StatusBadRequest)
+				util.HTTPError(w, "course not found", http.StatusBadRequest)
 				return
 			}
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		isMember, err := membership.IsMember(db, uid, uniID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		if !isMember {
-			http.Error(w, "forbidden", http.StatusForbidden)
+			util.HTTPError(w, "forbidden", http.StatusForbidden)
 			return
 		}
 
 		a, err := AddArticle(db, p.CourseID, p.Title, p.Author, p.Location)
 		if err != nil {
-			switch strings.ToLower(err.Error()) {
-			case "invalid input":
-				http.Error(w, "invalid input", http.StatusBadRequest)
-				return
-			case "course not found":
-				http.Error(w, "course not found", http.StatusBadRequest)
-				return
-			default:
-				http.Error(w, "internal error", http.StatusInternalServerError)
-				return
-			}
+			util.WriteError(w, err)
+			return
 		}
 
 		util.WriteJSON(w, a, http.StatusCreated)
@@ -163,90 +155,117 @@ func patchArticleDeadlineHandler(db *sql.DB, articleID int64) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid, ok := session.UserIDFromCtx(r.Context())
 		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		var p payload
 		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
-			http.Error(w, "bad request", http.StatusBadRequest)
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
 			return
 		}
 		if p.Deadline != nil && (*p.Deadline < 0 || *p.Deadline > maxDeadline) {
-			http.Error(w, "invalid deadline", http.StatusBadRequest)
+			util.HTTPError(w, "invalid deadline", http.StatusBadRequest)
 			return
 		}
 
 		// Access checks
 		if _, err := ArticleUniversityID(db, articleID); err != nil {
 			if err == sql.ErrNoRows {
-				http.Error(w, "not found", http.StatusNotFound)
+				util.HTTPError(w, "not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		canEdit, err := UserEnrolledInArticleCourse(db, uid, articleID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		if !canEdit {
-			http.Error(w, "forbidden", http.StatusForbidden)
+			util.HTTPError(w, "forbidden", http.StatusForbidden)
 			return
 		}
 
+		// Deadlines must fall within the course's term, outside its breaks.
+		if p.Deadline != nil {
+			courseID, err := ArticleCourseID(db, articleID)
+			if err != nil {
+				util.HTTPError(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+			if err := term.CheckDeadline(db, courseID, *p.Deadline); err != nil {
+				util.WriteError(w, err)
+				return
+			}
+		}
+
 		// Update deadline
 		if err := SetArticleDeadline(db, articleID, p.Deadline); err != nil {
 			if err == sql.ErrNoRows {
-				http.Error(w, "not found", http.StatusNotFound)
+				util.HTTPError(w, "not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 
 		// Fetch and return updated article
 		a, err := GetArticle(db, articleID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		util.WriteJSON(w, a, http.StatusOK)
 	}
 }
 
-// GET /articles?courseId=123
+// GET /articles?courseId=123[&completed=bool][&hasDeadline=bool][&dueBefore=unix]
+//
+//	[&sort=created|title|author|deadline][&limit=N][&cursor=...]
+//
 // Auth: caller must be ENROLLED in the course (not just a uni member).
-// Returns: []ArticleWithStatus (includes "completed" per user)
+// Returns: util.Page[ArticleWithStatus] (includes "completed" per user)
 func getArticlesForCourseHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid, ok := session.UserIDFromCtx(r.Context())
 		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 		courseID, err := util.ParseInt64Query(r, "courseId")
 		if err != nil || courseID <= 0 {
-			http.Error(w, "courseId is required", http.StatusBadRequest)
+			util.HTTPError(w, "courseId is required", http.StatusBadRequest)
 			return
 		}
 
 		// Must be enrolled in this course.
 		enrolled, err := enrollment.UserEnrolledInCourse(db, uid, courseID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		if !enrolled {
-			http.Error(w, "forbidden", http.StatusForbidden)
+			util.HTTPError(w, "forbidden", http.StatusForbidden)
 			return
 		}
 
 		// Fetch with per-user progress
-		list, err := ListArticlesByCourseWithProgress(db, courseID, uid)
+		pq, err := util.ParsePageQuery(r, articleSorts...)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.WriteError(w, err)
+			return
+		}
+		f, err := util.ParseItemFilter(r)
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+
+		list, err := ListArticlesByCourseWithProgress(db, courseID, uid, f, pq)
+		if err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		util.WriteJSON(w, list, http.StatusOK)
@@ -268,7 +287,7 @@ func patchArticleProgressHandler(db *sql.DB, articleID int64) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid, ok := session.UserIDFromCtx(r.Context())
 		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 
@@ -276,38 +295,38 @@ func patchArticleProgressHandler(db *sql.DB, articleID int64) http.HandlerFunc {
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
 		if err := dec.Decode(&p); err != nil || p.Completed == nil {
-			http.Error(w, "bad request", http.StatusBadRequest)
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
 			return
 		}
 
 		// Ensure it exists (404 semantics).
 		if _, err := ArticleUniversityID(db, articleID); err != nil {
 			if err == sql.ErrNoRows {
-				http.Error(w, "not found", http.StatusNotFound)
+				util.HTTPError(w, "not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 
 		// Must be enrolled in owning course.
 		canEdit, err := UserEnrolledInArticleCourse(db, uid, articleID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		if !canEdit {
-			http.Error(w, "forbidden", http.StatusForbidden)
+			util.HTTPError(w, "forbidden", http.StatusForbidden)
 			return
 		}
 
 		// Apply change (service layer).
 		if err := SetArticleProgress(db, uid, articleID, *p.Completed); err != nil {
 			if err == sql.ErrNoRows {
-				http.Error(w, "not found", http.StatusNotFound)
+				util.HTTPError(w, "not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 
@@ -315,7 +334,6 @@ func patchArticleProgressHandler(db *sql.DB, articleID int64) http.HandlerFunc {
 	}
 }
 
-
 // DELETE /articles
 // Body: { "articleId": number }
 // Auth: must be enrolled in the article's course.
@@ -329,57 +347,45 @@ func deleteArticleHandler(db *sql.DB) http.HandlerFunc {
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
 		if err := dec.Decode(&p); err != nil || p.ArticleID <= 0 {
-			http.Error(w, "bad request", http.StatusBadRequest)
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
 			return
 		}
 
 		// 1) Existence check -> 404 if missing
 		if _, err := GetArticle(db, p.ArticleID); err != nil {
 			if err == sql.ErrNoRows {
-				http.Error(w, "not found", http.StatusNotFound)
+				util.HTTPError(w, "not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 
 		uid, ok := session.UserIDFromCtx(r.Context())
 		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		// 2) Enrollment check
 		enrolled, err := UserEnrolledInArticleCourse(db, uid, p.ArticleID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		if !enrolled {
-			http.Error(w, "forbidden", http.StatusForbidden)
+			util.HTTPError(w, "forbidden", http.StatusForbidden)
 			return
 		}
 
 		// 3) Delete with progress guard
 		deleted, derr := DeleteArticleIfNoProgress(db, p.ArticleID)
 		if derr != nil {
-			switch {
-			case derr == sql.ErrNoRows:
-				http.Error(w, "not found", http.StatusNotFound)
-				return
-			case strings.Contains(strings.ToLower(derr.Error()), "invalid input"):
-				http.Error(w, "invalid input", http.StatusBadRequest)
-				return
-			case strings.Contains(strings.ToLower(derr.Error()), "has progress"):
-				http.Error(w, "conflict: article has progress", http.StatusConflict)
-				return
-			default:
-				http.Error(w, "internal error", http.StatusInternalServerError)
-				return
-			}
+			util.WriteError(w, derr)
+			return
 		}
 		if !deleted {
-			http.Error(w, "not found", http.StatusNotFound)
+			util.HTTPError(w, "not found", http.StatusNotFound)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
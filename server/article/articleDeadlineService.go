@@ -2,14 +2,16 @@ package article
 
 import (
 	"database/sql"
-	"errors"
+
+	"example.com/sqlite-server/events"
+	"example.com/sqlite-server/util"
 )
 
 // ArticleBelongsToUniversity returns (true,nil) if the article exists and its course's
 // university_id matches uniID. If the article doesn't exist, returns (false, sql.ErrNoRows).
 func ArticleBelongsToUniversity(db *sql.DB, articleID int64, uniID string) (bool, error) {
 	if articleID <= 0 || uniID == "" {
-		return false, errors.New("invalid input")
+		return false, util.ErrInvalidInput
 	}
 	var got string
 	err := db.QueryRow(`
@@ -31,7 +33,7 @@ func ArticleBelongsToUniversity(db *sql.DB, articleID int64, uniID string) (bool
 // If the article doesn't exist, returns sql.ErrNoRows.
 func ArticleUniversityID(db *sql.DB, articleID int64) (string, error) {
 	if articleID <= 0 {
-		return "", errors.New("invalid input")
+		return "", util.ErrInvalidInput
 	}
 	var uniID string
 	err := db.QueryRow(`
@@ -46,11 +48,21 @@ func ArticleUniversityID(db *sql.DB, articleID int64) (string, error) {
 	return uniID, nil
 }
 
+// ArticleCourseID returns the id of the article's course.
+// If the article doesn't exist, returns sql.ErrNoRows.
+func ArticleCourseID(db *sql.DB, articleID int64) (int64, error) {
+	var courseID int64
+	err := db.QueryRow(`
+		SELECT course_id FROM articles WHERE id = ?;
+	`, articleID).Scan(&courseID)
+	return courseID, err
+}
+
 // UserEnrolledInArticleCourse returns true if the given user has an enrollment
 // (user_courses) in the course to which the article belongs.
 func UserEnrolledInArticleCourse(db *sql.DB, userID string, articleID int64) (bool, error) {
 	if userID == "" || articleID <= 0 {
-		return false, errors.New("invalid input")
+		return false, util.ErrInvalidInput
 	}
 	var exists int
 	err := db.QueryRow(`
@@ -74,15 +86,13 @@ func UserEnrolledInArticleCourse(db *sql.DB, userID string, articleID int64) (bo
 // or clears it when deadline == nil. Returns sql.ErrNoRows if article not found.
 func SetArticleDeadline(db *sql.DB, articleID int64, deadline *int64) error {
 	if articleID <= 0 {
-		return errors.New("invalid input")
+		return util.ErrInvalidInput
 	}
-	res, err := db.Exec(`UPDATE articles SET deadline = ? WHERE id = ?;`, deadline, articleID)
+	var courseID int64
+	err := db.QueryRow(`UPDATE articles SET deadline = ? WHERE id = ? RETURNING course_id;`, deadline, articleID).Scan(&courseID)
 	if err != nil {
-		return err
-	}
-	n, _ := res.RowsAffected()
-	if n == 0 {
-		return sql.ErrNoRows
+		return err // may be sql.ErrNoRows
 	}
+	events.Publish(events.Event{Type: events.TypeDeadline, Kind: events.KindArticle, ItemID: articleID, CourseID: courseID, Deadline: deadline})
 	return nil
 }
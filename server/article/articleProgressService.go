@@ -2,36 +2,45 @@ package article
 
 import (
 	"database/sql"
-	"errors"
+
+	"example.com/sqlite-server/events"
+	"example.com/sqlite-server/util"
 )
 
 // SetArticleProgress marks an article as completed (true) or not completed (false) for userID.
 // Returns sql.ErrNoRows if the article doesn't exist.
 func SetArticleProgress(db *sql.DB, userID string, articleID int64, completed bool) error {
 	if userID == "" || articleID <= 0 {
-		return errors.New("invalid input")
+		return util.ErrInvalidInput
 	}
 
 	// Ensure article exists (404 semantics for callers).
-	var exists int64
-	if err := db.QueryRow(`SELECT id FROM articles WHERE id = ?`, articleID).Scan(&exists); err != nil {
+	var courseID int64
+	if err := db.QueryRow(`SELECT course_id FROM articles WHERE id = ?`, articleID).Scan(&courseID); err != nil {
 		return err // may be sql.ErrNoRows
 	}
 
+	var err error
 	if completed {
 		// Upsert: create or set completed=1
-		_, err := db.Exec(`
+		_, err = db.Exec(`
 			INSERT INTO progress (user_id, article_id, completed)
 			VALUES (?, ?, 1)
 			ON CONFLICT(user_id, article_id) DO UPDATE SET completed = 1
 		`, userID, articleID)
+	} else {
+		// Not completed → delete the row
+		_, err = db.Exec(`
+			DELETE FROM progress
+			 WHERE user_id = ? AND article_id IS NOT NULL AND article_id = ?
+		`, userID, articleID)
+	}
+	if err != nil {
 		return err
 	}
-
-	// Not completed → delete the row
-	_, err := db.Exec(`
-		DELETE FROM progress
-		 WHERE user_id = ? AND article_id IS NOT NULL AND article_id = ?
-	`, userID, articleID)
-	return err
+	events.Publish(events.Event{
+		Type: events.TypeProgress, Kind: events.KindArticle, ItemID: articleID, CourseID: courseID,
+		Completed: &completed, UserID: userID,
+	})
+	return nil
 }
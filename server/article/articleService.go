@@ -2,17 +2,26 @@ package article
 
 import (
 	"database/sql"
-	"errors"
+	"net/http"
 	"strings"
+
+	"example.com/sqlite-server/events"
+	"example.com/sqlite-server/util"
+)
+
+// Errors returned by this package.
+var (
+	ErrCourseNotFound     = util.NewError(http.StatusBadRequest, "course_not_found", "course not found")
+	ErrArticleHasProgress = util.NewError(http.StatusConflict, "article_has_progress", "article has progress")
 )
 
 type Article struct {
-	ID        int64   `json:"id"`
-	CourseID  int64   `json:"courseId"`
-	Title     string  `json:"title"`
-	Author    string  `json:"author"`
-	Location  *string `json:"location,omitempty"`
-	Deadline  *int64  `json:"deadline,omitempty"` // always nil on create
+	ID       int64   `json:"id"`
+	CourseID int64   `json:"courseId"`
+	Title    string  `json:"title"`
+	Author   string  `json:"author"`
+	Location *string `json:"location,omitempty"`
+	Deadline *int64  `json:"deadline,omitempty"` // always nil on create
 }
 
 // AddArticle inserts a new article for a course and initializes deadline = NULL.
@@ -21,14 +30,14 @@ func AddArticle(db *sql.DB, courseID int64, title, author string, location *stri
 	title = strings.TrimSpace(title)
 	author = strings.TrimSpace(author)
 	if courseID <= 0 || title == "" || author == "" {
-		return Article{}, errors.New("invalid input")
+		return Article{}, util.ErrInvalidInput
 	}
 
 	// Ensure the course exists.
 	var cid int64
 	if err := db.QueryRow(`SELECT id FROM courses WHERE id = ?`, courseID).Scan(&cid); err != nil {
 		if err == sql.ErrNoRows {
-			return Article{}, errors.New("course not found")
+			return Article{}, ErrCourseNotFound
 		}
 		return Article{}, err
 	}
@@ -61,10 +70,10 @@ func AddArticle(db *sql.DB, courseID int64, title, author string, location *stri
 		a.Location = &val
 	}
 	// a.Deadline remains nil
+	events.Publish(events.Event{Type: events.TypeCreated, Kind: events.KindArticle, ItemID: a.ID, CourseID: a.CourseID, Item: a})
 	return a, nil
 }
 
-
 type ArticleWithStatus struct {
 	ID        int64   `json:"id"`
 	CourseID  int64   `json:"courseId"`
@@ -75,13 +84,22 @@ type ArticleWithStatus struct {
 	Completed bool    `json:"completed"`
 }
 
-// ListArticlesByCourseWithProgress returns all articles for a course and
-// includes a per-user "completed" flag from the progress table.
-func ListArticlesByCourseWithProgress(db *sql.DB, courseID int64, userID string) ([]ArticleWithStatus, error) {
+// articleSorts are the orders GET /articles accepts; the first is the default.
+var articleSorts = []util.Sort{
+	{Name: "created", Keys: []string{"a.id"}},
+	{Name: "title", Keys: []string{"a.title", "a.id"}},
+	{Name: "author", Keys: []string{"a.author", "a.id"}},
+	{Name: "deadline", Keys: []string{"COALESCE(a.deadline, 9223372036854775807)", "a.id"}},
+}
+
+// ListArticlesByCourseWithProgress returns a page of a course's articles
+// matching f, with a per-user "completed" flag from the progress table.
+func ListArticlesByCourseWithProgress(db *sql.DB, courseID int64, userID string, f util.ItemFilter, q util.PageQuery) (util.Page[ArticleWithStatus], error) {
 	if courseID <= 0 || strings.TrimSpace(userID) == "" {
-		return []ArticleWithStatus{}, nil
+		return util.NewPage[ArticleWithStatus](nil, q, nil), nil
 	}
 
+	after, args := q.After()
 	rows, err := db.Query(`
 		SELECT a.id, a.course_id, a.title, a.author, a.location, a.deadline,
 		       COALESCE(p.completed, 0)
@@ -90,21 +108,27 @@ func ListArticlesByCourseWithProgress(db *sql.DB, courseID int64, userID string)
 		         ON p.article_id = a.id
 		        AND p.user_id   = ?
 		 WHERE a.course_id = ?
-		 ORDER BY a.id ASC
-	`, userID, courseID)
+		   AND (? IS NULL OR COALESCE(p.completed, 0) = ?)
+		   AND (? IS NULL OR (a.deadline IS NOT NULL) = ?)
+		   AND (? IS NULL OR a.deadline < ?)
+		   AND `+after+`
+		 ORDER BY `+q.OrderBy()+`
+		 LIMIT ?
+	`, append(append([]any{userID, courseID, f.Completed, f.Completed, f.HasDeadline, f.HasDeadline,
+		f.DueBefore, f.DueBefore}, args...), q.FetchLimit())...)
 	if err != nil {
-		return nil, err
+		return util.Page[ArticleWithStatus]{}, err
 	}
 	defer rows.Close()
 
-	out := make([]ArticleWithStatus, 0, 32)
+	out := make([]ArticleWithStatus, 0, q.FetchLimit())
 	for rows.Next() {
 		var a ArticleWithStatus
 		var loc sql.NullString
 		var dl sql.NullInt64
 		var compInt int64
 		if err := rows.Scan(&a.ID, &a.CourseID, &a.Title, &a.Author, &loc, &dl, &compInt); err != nil {
-			return nil, err
+			return util.Page[ArticleWithStatus]{}, err
 		}
 		if loc.Valid {
 			v := loc.String
@@ -117,10 +141,22 @@ func ListArticlesByCourseWithProgress(db *sql.DB, courseID int64, userID string)
 		a.Completed = compInt == 1
 		out = append(out, a)
 	}
-	return out, rows.Err()
+	if err := rows.Err(); err != nil {
+		return util.Page[ArticleWithStatus]{}, err
+	}
+	return util.NewPage(out, q, func(a ArticleWithStatus) []any {
+		switch q.Sort.Name {
+		case "title":
+			return []any{a.Title, a.ID}
+		case "author":
+			return []any{a.Author, a.ID}
+		case "deadline":
+			return []any{util.DeadlineKey(a.Deadline), a.ID}
+		}
+		return []any{a.ID}
+	}), nil
 }
 
-
 // GetArticle returns the article by ID, including nullable location and deadline.
 func GetArticle(db *sql.DB, id int64) (Article, error) {
 	var a Article
@@ -145,17 +181,16 @@ func GetArticle(db *sql.DB, id int64) (Article, error) {
 	return a, nil
 }
 
-
 // DeleteArticleIfNoProgress deletes the article iff it exists and
 // no user has a completion row for it. Returns (false, sql.ErrNoRows) if missing.
 func DeleteArticleIfNoProgress(db *sql.DB, articleID int64) (bool, error) {
 	if articleID <= 0 {
-		return false, errors.New("invalid input")
+		return false, util.ErrInvalidInput
 	}
 
 	// Ensure it exists
-	var exists int64
-	if err := db.QueryRow(`SELECT id FROM articles WHERE id = ?`, articleID).Scan(&exists); err != nil {
+	var courseID int64
+	if err := db.QueryRow(`SELECT course_id FROM articles WHERE id = ?`, articleID).Scan(&courseID); err != nil {
 		if err == sql.ErrNoRows {
 			return false, sql.ErrNoRows
 		}
@@ -168,7 +203,7 @@ func DeleteArticleIfNoProgress(db *sql.DB, articleID int64) (bool, error) {
 		return false, err
 	}
 	if cnt > 0 {
-		return false, errors.New("article has progress")
+		return false, ErrArticleHasProgress
 	}
 
 	// Delete
@@ -177,5 +212,8 @@ func DeleteArticleIfNoProgress(db *sql.DB, articleID int64) (bool, error) {
 		return false, err
 	}
 	n, _ := res.RowsAffected()
+	if n > 0 {
+		events.Publish(events.Event{Type: events.TypeDeleted, Kind: events.KindArticle, ItemID: articleID, CourseID: courseID})
+	}
 	return n > 0, nil
 }
@@ -10,11 +10,12 @@ import (
 	"example.com/sqlite-server/enrollment"
 	"example.com/sqlite-server/membership"
 	"example.com/sqlite-server/session"
+	"example.com/sqlite-server/term"
 	"example.com/sqlite-server/util"
 )
 
 // RegisterAssignmentRoutes wires the /assignments endpoints behind auth.
-func RegisterAssignmentRoutes(mux *http.ServeMux, db *sql.DB) {
+func RegisterAssignmentRoutes(mux util.Router, db *sql.DB) {
 	// Collection endpoints
 	mux.HandleFunc("/assignments", session.RequireAuth(db, assignmentsHandler(db)))
 	// Item subroutes (e.g., /assignments/{id}/deadline)
@@ -32,7 +33,7 @@ func assignmentsHandler(db *sql.DB) http.HandlerFunc {
 		case http.MethodDelete:
 			deleteAssignmentHandler(db)(w, r)
 		default:
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
 		}
 	}
 }
@@ -44,12 +45,12 @@ func assignmentsDispatcher(db *sql.DB) http.HandlerFunc {
 		path := strings.TrimPrefix(r.URL.Path, "/assignments/")
 		parts := strings.Split(path, "/")
 		if len(parts) != 2 || parts[0] == "" {
-			http.Error(w, "not found", http.StatusNotFound)
+			util.HTTPError(w, "not found", http.StatusNotFound)
 			return
 		}
 		id, err := strconv.ParseInt(parts[0], 10, 64)
 		if err != nil || id <= 0 {
-			http.Error(w, "invalid id", http.StatusBadRequest)
+			util.HTTPError(w, "invalid id", http.StatusBadRequest)
 			return
 		}
 
@@ -59,17 +60,17 @@ func assignmentsDispatcher(db *sql.DB) http.HandlerFunc {
 			case http.MethodPatch:
 				patchAssignmentDeadlineHandler(db, id)(w, r)
 			default:
-				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
 			}
 		case "progress":
 			switch r.Method {
 			case http.MethodPatch:
 				patchAssignmentProgressHandler(db, id)(w, r)
 			default:
-				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
 			}
 		default:
-			http.Error(w, "not found", http.StatusNotFound)
+			util.HTTPError(w, "not found", http.StatusNotFound)
 		}
 	}
 }
@@ -87,7 +88,7 @@ func postAssignmentHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid, ok := session.UserIDFromCtx(r.Context())
 		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 
@@ -95,7 +96,7 @@ func postAssignmentHandler(db *sql.DB) http.HandlerFunc {
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
 		if err := dec.Decode(&p); err != nil {
-			http.Error(w, "bad request", http.StatusBadRequest)
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
 			return
 		}
 
@@ -109,7 +110,7 @@ func postAssignmentHandler(db *sql.DB) http.HandlerFunc {
 			}
 		}
 		if p.CourseID <= 0 || p.Title == "" {
-			http.Error(w, "invalid input", http.StatusBadRequest)
+			util.HTTPError(w, "invalid input", http.StatusBadRequest)
 			return
 		}
 
@@ -117,71 +118,75 @@ func postAssignmentHandler(db *sql.DB) http.HandlerFunc {
 		uniID, err := enrollment.CourseUniversity(db, p.CourseID)
 		if err != nil {
 			if err == sql.ErrNoRows {
-				http.Error(w, "course not found", http.StatusBadRequest)
+				util.HTTPError(w, "course not found", http.StatusBadRequest)
 				return
 			}
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		isMember, err := membership.IsMember(db, uid, uniID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		if !isMember {
-			http.Error(w, "forbidden", http.StatusForbidden)
+			util.HTTPError(w, "forbidden", http.StatusForbidden)
 			return
 		}
 
 		a, err := AddAssignment(db, p.CourseID, p.Title, p.Description)
 		if err != nil {
-			switch strings.ToLower(err.Error()) {
-			case "invalid input":
-				http.Error(w, "invalid input", http.StatusBadRequest)
-				return
-			case "course not found":
-				http.Error(w, "course not found", http.StatusBadRequest)
-				return
-			default:
-				http.Error(w, "internal error", http.StatusInternalServerError)
-				return
-			}
+			util.WriteError(w, err)
+			return
 		}
 
 		util.WriteJSON(w, a, http.StatusCreated)
 	}
 }
 
-
-// GET /assignments?courseId=123
+// GET /assignments?courseId=123[&completed=bool][&hasDeadline=bool][&dueBefore=unix]
+//
+//	[&sort=created|title|deadline][&limit=N][&cursor=...]
+//
 // Auth: caller must be ENROLLED in the course.
-// Returns: []AssignmentWithStatus including per-user "completed" flag.
+// Returns: util.Page[AssignmentWithStatus] including per-user "completed" flag.
 func getAssignmentsForCourseHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid, ok := session.UserIDFromCtx(r.Context())
 		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 		courseID, err := util.ParseInt64Query(r, "courseId")
 		if err != nil || courseID <= 0 {
-			http.Error(w, "courseId is required", http.StatusBadRequest)
+			util.HTTPError(w, "courseId is required", http.StatusBadRequest)
 			return
 		}
 
 		enrolled, err := enrollment.UserEnrolledInCourse(db, uid, courseID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		if !enrolled {
-			http.Error(w, "forbidden", http.StatusForbidden)
+			util.HTTPError(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		pq, err := util.ParsePageQuery(r, assignmentSorts...)
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		f, err := util.ParseItemFilter(r)
+		if err != nil {
+			util.WriteError(w, err)
 			return
 		}
 
-		list, err := ListAssignmentsByCourseWithProgress(db, courseID, uid)
+		list, err := ListAssignmentsByCourseWithProgress(db, courseID, uid, f, pq)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		util.WriteJSON(w, list, http.StatusOK)
@@ -201,7 +206,7 @@ func patchAssignmentDeadlineHandler(db *sql.DB, assignmentID int64) http.Handler
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid, ok := session.UserIDFromCtx(r.Context())
 		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 
@@ -209,12 +214,12 @@ func patchAssignmentDeadlineHandler(db *sql.DB, assignmentID int64) http.Handler
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
 		if err := dec.Decode(&p); err != nil {
-			http.Error(w, "bad request", http.StatusBadRequest)
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
 			return
 		}
 		if p.Deadline != nil {
 			if *p.Deadline < 0 || *p.Deadline > maxDeadline {
-				http.Error(w, "invalid deadline", http.StatusBadRequest)
+				util.HTTPError(w, "invalid deadline", http.StatusBadRequest)
 				return
 			}
 		}
@@ -222,45 +227,57 @@ func patchAssignmentDeadlineHandler(db *sql.DB, assignmentID int64) http.Handler
 		// Ensure it exists (404 semantics).
 		if _, err := AssignmentUniversityID(db, assignmentID); err != nil {
 			if err == sql.ErrNoRows {
-				http.Error(w, "not found", http.StatusNotFound)
+				util.HTTPError(w, "not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 
 		// Must be enrolled in owning course.
 		canEdit, err := UserEnrolledInAssignmentCourse(db, uid, assignmentID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		if !canEdit {
-			http.Error(w, "forbidden", http.StatusForbidden)
+			util.HTTPError(w, "forbidden", http.StatusForbidden)
 			return
 		}
 
+		// Deadlines must fall within the course's term, outside its breaks.
+		if p.Deadline != nil {
+			courseID, err := AssignmentCourseID(db, assignmentID)
+			if err != nil {
+				util.HTTPError(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+			if err := term.CheckDeadline(db, courseID, *p.Deadline); err != nil {
+				util.WriteError(w, err)
+				return
+			}
+		}
+
 		// Update
 		if err := SetAssignmentDeadline(db, assignmentID, p.Deadline); err != nil {
 			if err == sql.ErrNoRows {
-				http.Error(w, "not found", http.StatusNotFound)
+				util.HTTPError(w, "not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 
 		// Return updated resource
 		a, err := GetAssignment(db, assignmentID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		util.WriteJSON(w, a, http.StatusOK)
 	}
 }
 
-
 // PATCH /assignments/{id}/progress
 // Body: { "completed": boolean }
 // Auth: caller must be enrolled in the assignment's course.
@@ -276,7 +293,7 @@ func patchAssignmentProgressHandler(db *sql.DB, assignmentID int64) http.Handler
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid, ok := session.UserIDFromCtx(r.Context())
 		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 
@@ -284,38 +301,38 @@ func patchAssignmentProgressHandler(db *sql.DB, assignmentID int64) http.Handler
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
 		if err := dec.Decode(&p); err != nil || p.Completed == nil {
-			http.Error(w, "bad request", http.StatusBadRequest)
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
 			return
 		}
 
 		// Ensure it exists (404 semantics).
 		if _, err := AssignmentUniversityID(db, assignmentID); err != nil {
 			if err == sql.ErrNoRows {
-				http.Error(w, "not found", http.StatusNotFound)
+				util.HTTPError(w, "not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 
 		// Must be enrolled in owning course.
 		canEdit, err := UserEnrolledInAssignmentCourse(db, uid, assignmentID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		if !canEdit {
-			http.Error(w, "forbidden", http.StatusForbidden)
+			util.HTTPError(w, "forbidden", http.StatusForbidden)
 			return
 		}
 
 		// Apply change (service layer).
 		if err := SetAssignmentProgress(db, uid, assignmentID, *p.Completed); err != nil {
 			if err == sql.ErrNoRows {
-				http.Error(w, "not found", http.StatusNotFound)
+				util.HTTPError(w, "not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 
@@ -323,7 +340,6 @@ func patchAssignmentProgressHandler(db *sql.DB, assignmentID int64) http.Handler
 	}
 }
 
-
 // DELETE /assignments
 // Body: { "assignmentId": number }
 // Auth: must be enrolled in the assignment's course.
@@ -337,57 +353,45 @@ func deleteAssignmentHandler(db *sql.DB) http.HandlerFunc {
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
 		if err := dec.Decode(&p); err != nil || p.AssignmentID <= 0 {
-			http.Error(w, "bad request", http.StatusBadRequest)
-		 return
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
+			return
 		}
 
 		// 1) Existence check -> 404
 		if _, err := GetAssignment(db, p.AssignmentID); err != nil {
 			if err == sql.ErrNoRows {
-				http.Error(w, "not found", http.StatusNotFound)
+				util.HTTPError(w, "not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 
 		uid, ok := session.UserIDFromCtx(r.Context())
 		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		// 2) Enrollment check
 		enrolled, err := UserEnrolledInAssignmentCourse(db, uid, p.AssignmentID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		if !enrolled {
-			http.Error(w, "forbidden", http.StatusForbidden)
+			util.HTTPError(w, "forbidden", http.StatusForbidden)
 			return
 		}
 
 		// 3) Delete with progress guard
 		deleted, derr := DeleteAssignmentIfNoProgress(db, p.AssignmentID)
 		if derr != nil {
-			switch {
-			case derr == sql.ErrNoRows:
-				http.Error(w, "not found", http.StatusNotFound)
-				return
-			case strings.Contains(strings.ToLower(derr.Error()), "invalid input"):
-				http.Error(w, "invalid input", http.StatusBadRequest)
-				return
-			case strings.Contains(strings.ToLower(derr.Error()), "has progress"):
-				http.Error(w, "conflict: assignment has progress", http.StatusConflict)
-				return
-			default:
-				http.Error(w, "internal error", http.StatusInternalServerError)
-				return
-			}
+			util.WriteError(w, derr)
+			return
 		}
 		if !deleted {
-			http.Error(w, "not found", http.StatusNotFound)
+			util.HTTPError(w, "not found", http.StatusNotFound)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
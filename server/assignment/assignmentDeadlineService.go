@@ -2,14 +2,16 @@ package assignment
 
 import (
 	"database/sql"
-	"errors"
+
+	"example.com/sqlite-server/events"
+	"example.com/sqlite-server/util"
 )
 
 // AssignmentUniversityID returns the university_id for the assignment's course.
 // If the assignment doesn't exist, returns sql.ErrNoRows.
 func AssignmentUniversityID(db *sql.DB, assignmentID int64) (string, error) {
 	if assignmentID <= 0 {
-		return "", errors.New("invalid input")
+		return "", util.ErrInvalidInput
 	}
 	var uniID string
 	err := db.QueryRow(`
@@ -24,11 +26,21 @@ func AssignmentUniversityID(db *sql.DB, assignmentID int64) (string, error) {
 	return uniID, nil
 }
 
+// AssignmentCourseID returns the id of the assignment's course.
+// If the assignment doesn't exist, returns sql.ErrNoRows.
+func AssignmentCourseID(db *sql.DB, assignmentID int64) (int64, error) {
+	var courseID int64
+	err := db.QueryRow(`
+		SELECT course_id FROM assignments WHERE id = ?;
+	`, assignmentID).Scan(&courseID)
+	return courseID, err
+}
+
 // UserEnrolledInAssignmentCourse returns true if the given user has an enrollment
 // (user_courses) in the course to which the assignment belongs.
 func UserEnrolledInAssignmentCourse(db *sql.DB, userID string, assignmentID int64) (bool, error) {
 	if userID == "" || assignmentID <= 0 {
-		return false, errors.New("invalid input")
+		return false, util.ErrInvalidInput
 	}
 	var exists int
 	err := db.QueryRow(`
@@ -52,15 +64,13 @@ func UserEnrolledInAssignmentCourse(db *sql.DB, userID string, assignmentID int6
 // or clears it when deadline == nil. Returns sql.ErrNoRows if assignment not found.
 func SetAssignmentDeadline(db *sql.DB, assignmentID int64, deadline *int64) error {
 	if assignmentID <= 0 {
-		return errors.New("invalid input")
+		return util.ErrInvalidInput
 	}
-	res, err := db.Exec(`UPDATE assignments SET deadline = ? WHERE id = ?;`, deadline, assignmentID)
+	var courseID int64
+	err := db.QueryRow(`UPDATE assignments SET deadline = ? WHERE id = ? RETURNING course_id;`, deadline, assignmentID).Scan(&courseID)
 	if err != nil {
-		return err
-	}
-	n, _ := res.RowsAffected()
-	if n == 0 {
-		return sql.ErrNoRows
+		return err // may be sql.ErrNoRows
 	}
+	events.Publish(events.Event{Type: events.TypeDeadline, Kind: events.KindAssignment, ItemID: assignmentID, CourseID: courseID, Deadline: deadline})
 	return nil
 }
@@ -2,8 +2,17 @@ package assignment
 
 import (
 	"database/sql"
-	"errors"
+	"net/http"
 	"strings"
+
+	"example.com/sqlite-server/events"
+	"example.com/sqlite-server/util"
+)
+
+// Errors returned by this package.
+var (
+	ErrCourseNotFound        = util.NewError(http.StatusBadRequest, "course_not_found", "course not found")
+	ErrAssignmentHasProgress = util.NewError(http.StatusConflict, "assignment_has_progress", "assignment has progress")
 )
 
 type Assignment struct {
@@ -18,7 +27,7 @@ type Assignment struct {
 func AddAssignment(db *sql.DB, courseID int64, title string, description *string) (Assignment, error) {
 	title = strings.TrimSpace(title)
 	if courseID <= 0 || title == "" {
-		return Assignment{}, errors.New("invalid input")
+		return Assignment{}, util.ErrInvalidInput
 	}
 	if description != nil {
 		s := strings.TrimSpace(*description)
@@ -33,7 +42,7 @@ func AddAssignment(db *sql.DB, courseID int64, title string, description *string
 	var cid int64
 	if err := db.QueryRow(`SELECT id FROM courses WHERE id = ?`, courseID).Scan(&cid); err != nil {
 		if err == sql.ErrNoRows {
-			return Assignment{}, errors.New("course not found")
+			return Assignment{}, ErrCourseNotFound
 		}
 		return Assignment{}, err
 	}
@@ -51,7 +60,12 @@ func AddAssignment(db *sql.DB, courseID int64, title string, description *string
 		return Assignment{}, err
 	}
 
-	return GetAssignment(db, id)
+	a, err := GetAssignment(db, id)
+	if err != nil {
+		return Assignment{}, err
+	}
+	events.Publish(events.Event{Type: events.TypeCreated, Kind: events.KindAssignment, ItemID: a.ID, CourseID: a.CourseID, Item: a})
+	return a, nil
 }
 
 // GetAssignment returns the assignment by ID.
@@ -116,7 +130,6 @@ func ListAssignmentsByCourse(db *sql.DB, courseID int64) ([]Assignment, error) {
 	return out, rows.Err()
 }
 
-
 type AssignmentWithStatus struct {
 	ID          int64   `json:"id"`
 	CourseID    int64   `json:"courseId"`
@@ -126,13 +139,21 @@ type AssignmentWithStatus struct {
 	Completed   bool    `json:"completed"`
 }
 
-// ListAssignmentsByCourseWithProgress returns all assignments for a course
-// and marks whether the given user has completed each one.
-func ListAssignmentsByCourseWithProgress(db *sql.DB, courseID int64, userID string) ([]AssignmentWithStatus, error) {
+// assignmentSorts are the orders GET /assignments accepts; the first is the default.
+var assignmentSorts = []util.Sort{
+	{Name: "created", Keys: []string{"a.id"}},
+	{Name: "title", Keys: []string{"a.title", "a.id"}},
+	{Name: "deadline", Keys: []string{"COALESCE(a.deadline, 9223372036854775807)", "a.id"}},
+}
+
+// ListAssignmentsByCourseWithProgress returns a page of a course's assignments
+// matching f and marks whether the given user has completed each one.
+func ListAssignmentsByCourseWithProgress(db *sql.DB, courseID int64, userID string, f util.ItemFilter, q util.PageQuery) (util.Page[AssignmentWithStatus], error) {
 	if courseID <= 0 || strings.TrimSpace(userID) == "" {
-		return []AssignmentWithStatus{}, nil
+		return util.NewPage[AssignmentWithStatus](nil, q, nil), nil
 	}
 
+	after, args := q.After()
 	rows, err := db.Query(`
 		SELECT a.id, a.course_id, a.title, a.description, a.deadline,
 		       COALESCE(p.completed, 0)
@@ -141,21 +162,27 @@ func ListAssignmentsByCourseWithProgress(db *sql.DB, courseID int64, userID stri
 		         ON p.assignment_id = a.id
 		        AND p.user_id = ?
 		 WHERE a.course_id = ?
-		 ORDER BY a.id ASC
-	`, userID, courseID)
+		   AND (? IS NULL OR COALESCE(p.completed, 0) = ?)
+		   AND (? IS NULL OR (a.deadline IS NOT NULL) = ?)
+		   AND (? IS NULL OR a.deadline < ?)
+		   AND `+after+`
+		 ORDER BY `+q.OrderBy()+`
+		 LIMIT ?
+	`, append(append([]any{userID, courseID, f.Completed, f.Completed, f.HasDeadline, f.HasDeadline,
+		f.DueBefore, f.DueBefore}, args...), q.FetchLimit())...)
 	if err != nil {
-		return nil, err
+		return util.Page[AssignmentWithStatus]{}, err
 	}
 	defer rows.Close()
 
-	out := make([]AssignmentWithStatus, 0, 32)
+	out := make([]AssignmentWithStatus, 0, q.FetchLimit())
 	for rows.Next() {
 		var a AssignmentWithStatus
 		var desc sql.NullString
 		var dl sql.NullInt64
 		var compInt int64
 		if err := rows.Scan(&a.ID, &a.CourseID, &a.Title, &desc, &dl, &compInt); err != nil {
-			return nil, err
+			return util.Page[AssignmentWithStatus]{}, err
 		}
 		if desc.Valid {
 			v := desc.String
@@ -168,20 +195,30 @@ func ListAssignmentsByCourseWithProgress(db *sql.DB, courseID int64, userID stri
 		a.Completed = compInt == 1
 		out = append(out, a)
 	}
-	return out, rows.Err()
+	if err := rows.Err(); err != nil {
+		return util.Page[AssignmentWithStatus]{}, err
+	}
+	return util.NewPage(out, q, func(a AssignmentWithStatus) []any {
+		switch q.Sort.Name {
+		case "title":
+			return []any{a.Title, a.ID}
+		case "deadline":
+			return []any{util.DeadlineKey(a.Deadline), a.ID}
+		}
+		return []any{a.ID}
+	}), nil
 }
 
-
 // DeleteAssignmentIfNoProgress deletes the assignment iff it exists and
 // no user has a completion row for it. Returns (false, sql.ErrNoRows) if missing.
 func DeleteAssignmentIfNoProgress(db *sql.DB, assignmentID int64) (bool, error) {
 	if assignmentID <= 0 {
-		return false, errors.New("invalid input")
+		return false, util.ErrInvalidInput
 	}
 
 	// Ensure it exists
-	var exists int64
-	if err := db.QueryRow(`SELECT id FROM assignments WHERE id = ?`, assignmentID).Scan(&exists); err != nil {
+	var courseID int64
+	if err := db.QueryRow(`SELECT course_id FROM assignments WHERE id = ?`, assignmentID).Scan(&courseID); err != nil {
 		if err == sql.ErrNoRows {
 			return false, sql.ErrNoRows
 		}
@@ -194,7 +231,7 @@ func DeleteAssignmentIfNoProgress(db *sql.DB, assignmentID int64) (bool, error)
 		return false, err
 	}
 	if cnt > 0 {
-		return false, errors.New("assignment has progress")
+		return false, ErrAssignmentHasProgress
 	}
 
 	// Safe to delete
@@ -203,5 +240,8 @@ func DeleteAssignmentIfNoProgress(db *sql.DB, assignmentID int64) (bool, error)
 		return false, err
 	}
 	n, _ := res.RowsAffected()
+	if n > 0 {
+		events.Publish(events.Event{Type: events.TypeDeleted, Kind: events.KindAssignment, ItemID: assignmentID, CourseID: courseID})
+	}
 	return n > 0, nil
 }
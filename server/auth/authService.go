@@ -2,44 +2,93 @@ package auth
 
 import (
 	"database/sql"
+	"net/http"
+
+	"example.com/sqlite-server/store"
+	"example.com/sqlite-server/util"
+)
+
+// Errors returned by this package.
+var (
+	ErrEmailTaken         = util.NewError(http.StatusConflict, "email_taken", "email already registered")
+	ErrInvalidCode        = util.NewError(http.StatusBadRequest, "invalid_code", "invalid code")
+	ErrTOTPNotEnrolled    = util.NewError(http.StatusConflict, "totp_not_enrolled", "call /auth/2fa/setup first")
+	ErrTOTPAlreadyEnabled = util.NewError(http.StatusConflict, "totp_enabled", "2fa already enabled")
+	ErrMFALocked          = util.NewError(http.StatusTooManyRequests, "mfa_locked", "too many wrong codes, try again later")
+	ErrIdentityLinked     = util.NewError(http.StatusConflict, "identity_linked", "identity already linked to another account")
+	ErrEmailNotVerified   = util.NewError(http.StatusForbidden, "email_not_verified", "provider did not verify the email")
+	ErrLastSignInMethod   = util.NewError(http.StatusConflict, "last_sign_in_method", "cannot remove the last sign-in method")
 )
 
 // User represents a user record in the database.
 type User struct {
-	ID        string `json:"id"`
-	Email     string `json:"email"`
-	Password  string `json:"-"` // never JSON expose
-	CreatedAt int64  `json:"created_at"`
+	ID         string `json:"id"`
+	Email      string `json:"email"`
+	Password   string `json:"-"` // never JSON expose
+	CreatedAt  int64  `json:"created_at"`
+	DisabledAt *int64 `json:"disabled_at,omitempty"`
 }
 
-// AddUser inserts a new user (already hashed password).
+// AddUser inserts a new user (already hashed password). Errors: ErrEmailTaken.
 func AddUser(db *sql.DB, id, email, password string) error {
 	_, err := db.Exec(`
 		INSERT INTO users (id, email, password)
 		VALUES (?, ?, ?);
 	`, id, email, password)
+	if err != nil && store.IsUniqueViolation(err) {
+		return ErrEmailTaken
+	}
 	return err
 }
 
 // GetUserByEmail returns a user by their email.
 func GetUserByEmail(db *sql.DB, email string) (User, error) {
 	var u User
+	var disabled sql.NullInt64
 	err := db.QueryRow(`
-		SELECT id, email, password, created_at
+		SELECT id, email, password, created_at, disabled_at
 		FROM users
 		WHERE email = ?;
-	`, email).Scan(&u.ID, &u.Email, &u.Password, &u.CreatedAt)
+	`, email).Scan(&u.ID, &u.Email, &u.Password, &u.CreatedAt, &disabled)
+	if disabled.Valid {
+		u.DisabledAt = &disabled.Int64
+	}
 	return u, err
 }
 
-
 // GetUserByID returns a user by their ID.
 func GetUserByID(db *sql.DB, id string) (User, error) {
 	var u User
+	var disabled sql.NullInt64
 	err := db.QueryRow(`
-		SELECT id, email, password, created_at
+		SELECT id, email, password, created_at, disabled_at
 		FROM users
 		WHERE id = ?;
-	`, id).Scan(&u.ID, &u.Email, &u.Password, &u.CreatedAt)
+	`, id).Scan(&u.ID, &u.Email, &u.Password, &u.CreatedAt, &disabled)
+	if disabled.Valid {
+		u.DisabledAt = &disabled.Int64
+	}
 	return u, err
 }
+
+// SetPassword replaces a user's password hash and signs them out everywhere.
+// Returns sql.ErrNoRows if the user doesn't exist.
+func SetPassword(db *sql.DB, id, password string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE users SET password = ? WHERE id = ?`, password, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE user_id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
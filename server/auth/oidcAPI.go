@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"example.com/sqlite-server/session"
+	"example.com/sqlite-server/util"
+)
+
+const oidcStateCookie = "oidc_state"
+
+// registerOIDCRoutes wires SSO login and identity management.
+// When OIDC isn't configured the login endpoints answer 404.
+func registerOIDCRoutes(mux util.Router, db *sql.DB) {
+	mux.HandleFunc("/auth/oidc/login", oidcLoginHandler(db))
+	mux.HandleFunc("/auth/oidc/callback", oidcCallbackHandler(db))
+	mux.HandleFunc("/auth/identities", session.RequireAuth(db, identitiesHandler(db)))
+}
+
+// safeReturnTo only accepts same-site relative paths ("/courses/1"), never
+// "//host" or absolute URLs, so the callback can't be used as an open redirect.
+func safeReturnTo(raw string) string {
+	if raw == "" || !strings.HasPrefix(raw, "/") || strings.HasPrefix(raw, "//") || strings.ContainsAny(raw, "\\\r\n") {
+		return "/"
+	}
+	return raw
+}
+
+// GET /auth/oidc/login?returnTo=/path[&link=1]
+// Redirects to the provider. With link=1 the caller must be logged in (with a
+// browser session, not a token), and the external identity is linked to their
+// account instead of signing in.
+func oidcLoginHandler(db *sql.DB) http.HandlerFunc {
+	begin := func(w http.ResponseWriter, r *http.Request, linkUserID string) {
+		target, state, err := BeginOIDCLogin(db, OIDC, safeReturnTo(r.URL.Query().Get("returnTo")), linkUserID)
+		if err != nil {
+			log.Printf("oidc login: %v", err)
+			util.HTTPError(w, "identity provider unavailable", http.StatusBadGateway)
+			return
+		}
+
+		// Lax so the cookie survives the top-level redirect back from the provider.
+		http.SetCookie(w, &http.Cookie{
+			Name:     oidcStateCookie,
+			Value:    state,
+			Path:     "/",
+			MaxAge:   int(oidcStateTTL.Seconds()),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+			Secure:   util.SecureCookies,
+		})
+		http.Redirect(w, r, target, http.StatusFound)
+	}
+	link := session.RequireAuth(db, session.RequireCookieSession(func(w http.ResponseWriter, r *http.Request) {
+		uid, _ := session.UserIDFromCtx(r.Context())
+		begin(w, r, uid)
+	}))
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !OIDC.Enabled() {
+			util.HTTPError(w, "not found", http.StatusNotFound)
+			return
+		}
+		if r.URL.Query().Get("link") == "1" {
+			link(w, r)
+			return
+		}
+		begin(w, r, "")
+	}
+}
+
+// GET /auth/oidc/callback?code=...&state=...
+// Completes the login: verifies state (cookie-bound, one-time), exchanges the
+// code with PKCE, verifies the ID token, then signs in (or links) and redirects
+// to returnTo.
+func oidcCallbackHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !OIDC.Enabled() {
+			util.HTTPError(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		q := r.URL.Query()
+		state := q.Get("state")
+		c, err := r.Cookie(oidcStateCookie)
+		if state == "" || err != nil || subtle.ConstantTimeCompare([]byte(c.Value), []byte(state)) != 1 {
+			util.HTTPError(w, "invalid state", http.StatusBadRequest)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteLaxMode, Secure: util.SecureCookies})
+
+		st, err := consumeOIDCState(db, state)
+		if err != nil {
+			util.HTTPError(w, "invalid state", http.StatusBadRequest)
+			return
+		}
+		if e := q.Get("error"); e != "" {
+			util.HTTPError(w, "login cancelled: "+e, http.StatusUnauthorized)
+			return
+		}
+		code := q.Get("code")
+		if code == "" {
+			util.HTTPError(w, "code is required", http.StatusBadRequest)
+			return
+		}
+
+		claims, err := exchangeCode(OIDC, code, st)
+		if err != nil {
+			log.Printf("oidc callback: %v", err)
+			util.HTTPError(w, "login failed", http.StatusUnauthorized)
+			return
+		}
+
+		uid, err := ResolveOIDCUser(db, claims, st.LinkUserID)
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+
+		// Linking keeps the existing session; a login starts a fresh one, after
+		// the second factor if the account has one: the provider vouches for the
+		// email, not for the user's authenticator.
+		if st.LinkUserID == "" {
+			mfa, err := TOTPEnabled(db, uid)
+			if err != nil {
+				util.HTTPError(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+			if mfa {
+				tok, err := CreateMFAChallenge(db, uid, false)
+				if err != nil {
+					writeMFAError(w, err)
+					return
+				}
+				// In the fragment, so the token stays out of server and proxy logs.
+				frag := url.Values{"mfaToken": {tok}, "returnTo": {st.ReturnTo}}
+				http.Redirect(w, r, OIDC.AppURL+"/login/2fa#"+frag.Encode(), http.StatusFound)
+				return
+			}
+			sess, err := session.CreateSession(db, uid, false, session.ClientInfoFromRequest(r))
+			if err != nil {
+				util.WriteError(w, err)
+				return
+			}
+			session.SetCookie(w, sess)
+		}
+
+		http.Redirect(w, r, OIDC.AppURL+st.ReturnTo, http.StatusFound)
+	}
+}
+
+// Dispatcher for /auth/identities
+func identitiesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			getIdentitiesHandler(db)(w, r)
+		case http.MethodDelete:
+			deleteIdentityHandler(db)(w, r)
+		default:
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// GET /auth/identities
+// Lists the external identities linked to the caller.
+func getIdentitiesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := session.UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		list, err := ListIdentities(db, uid)
+		if err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		util.WriteJSON(w, list, http.StatusOK)
+	}
+}
+
+// DELETE /auth/identities
+// Body: { "identityId": 3 }
+// 204 if unlinked; 404 if not the caller's; 409 if it is their last way to sign in.
+func deleteIdentityHandler(db *sql.DB) http.HandlerFunc {
+	type payload struct {
+		IdentityID int64 `json:"identityId"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := session.UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var p payload
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil || p.IdentityID <= 0 {
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		if err := UnlinkIdentity(db, uid, p.IdentityID); err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,362 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/sqlite-server/session"
+	"example.com/sqlite-server/store"
+)
+
+// mockProvider is a minimal OpenID Connect provider: discovery, JWKS and a
+// token endpoint that checks PKCE. authorize stands in for the user signing
+// in at the provider.
+type mockProvider struct {
+	*httptest.Server
+	t   *testing.T
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]pendingCode
+}
+
+type pendingCode struct {
+	challenge string
+	claims    map[string]any
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &mockProvider{t: t, key: key, codes: map[string]pendingCode{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "alg": "RS256",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		c, ok := p.codes[r.FormValue("code")]
+		delete(p.codes, r.FormValue("code"))
+		p.mu.Unlock()
+		verifier := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(verifier[:]) != c.challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": p.sign(c.claims)})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *mockProvider) sign(claims map[string]any) string {
+	enc := func(v any) string {
+		b, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	unsigned := enc(map[string]string{"alg": "RS256", "kid": "k1"}) + "." + enc(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// authorize signs in at the provider as sub, following the authorization
+// redirect; it returns the callback query the provider would send back.
+func (p *mockProvider) authorize(location, sub, email string, verified bool) url.Values {
+	u, err := url.Parse(location)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != OIDC.ClientID {
+		p.t.Fatalf("unexpected authorization request %s", location)
+	}
+	code := randomCode(p.t)
+	now := time.Now().Unix()
+	p.mu.Lock()
+	p.codes[code] = pendingCode{challenge: q.Get("code_challenge"), claims: map[string]any{
+		"iss": p.URL, "aud": OIDC.ClientID, "sub": sub, "nonce": q.Get("nonce"),
+		"iat": now, "exp": now + 300, "email": email, "email_verified": verified,
+	}}
+	p.mu.Unlock()
+	return url.Values{"code": {code}, "state": {q.Get("state")}}
+}
+
+func randomCode(t *testing.T) string {
+	s, err := randomString(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+type oidcTest struct {
+	t        *testing.T
+	db       *sql.DB
+	mux      *http.ServeMux
+	provider *mockProvider
+}
+
+func newOIDCTest(t *testing.T) *oidcTest {
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	p := newMockProvider(t)
+	saved := OIDC
+	OIDC = OIDCConfig{
+		Issuer:      p.URL,
+		ClientID:    "reading",
+		RedirectURL: "https://reading.example/api/auth/oidc/callback",
+		Scopes:      "openid email",
+	}
+	resetOIDCCache()
+	t.Cleanup(func() {
+		OIDC = saved
+		resetOIDCCache()
+	})
+
+	mux := http.NewServeMux()
+	registerOIDCRoutes(mux, db)
+	return &oidcTest{t: t, db: db, mux: mux, provider: p}
+}
+
+func resetOIDCCache() {
+	oidcCache.Lock()
+	oidcCache.provider, oidcCache.keys, oidcCache.keysFetch = nil, nil, time.Time{}
+	oidcCache.Unlock()
+}
+
+func (o *oidcTest) get(target string, cookies []*http.Cookie, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	o.mux.ServeHTTP(w, r)
+	return w
+}
+
+// signIn runs the whole redirect dance as sub and returns the callback response.
+func (o *oidcTest) signIn(query string, cookies []*http.Cookie, sub, email string, verified bool) *httptest.ResponseRecorder {
+	o.t.Helper()
+	w := o.get("/auth/oidc/login?"+query, cookies, nil)
+	if w.Code != http.StatusFound {
+		o.t.Fatalf("login: %d %s", w.Code, w.Body)
+	}
+	back := o.provider.authorize(w.Header().Get("Location"), sub, email, verified)
+	return o.get("/auth/oidc/callback?"+back.Encode(), append(cookies, w.Result().Cookies()...), nil)
+}
+
+// sessionUser returns who the session cookie set by w belongs to.
+func (o *oidcTest) sessionUser(w *httptest.ResponseRecorder) string {
+	o.t.Helper()
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "session" && c.Value != "" {
+			s, err := session.GetSessionByID(o.db, c.Value)
+			if err != nil {
+				o.t.Fatal(err)
+			}
+			return s.UserID
+		}
+	}
+	o.t.Fatalf("no session cookie (status %d: %s)", w.Code, w.Body)
+	return ""
+}
+
+func (o *oidcTest) newSession(userID string) *http.Cookie {
+	s, err := session.CreateSession(o.db, userID, false, session.ClientInfo{})
+	if err != nil {
+		o.t.Fatal(err)
+	}
+	return &http.Cookie{Name: "session", Value: s.ID}
+}
+
+func TestOIDCLoginCreatesUserOnce(t *testing.T) {
+	o := newOIDCTest(t)
+
+	w := o.signIn("returnTo=/courses/7", nil, "sub-1", "new@example.com", true)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/courses/7" {
+		t.Fatalf("callback: %d to %q", w.Code, w.Header().Get("Location"))
+	}
+	uid := o.sessionUser(w)
+	u, err := GetUserByEmail(o.db, "new@example.com")
+	if err != nil || u.ID != uid {
+		t.Fatalf("user %+v, %v; session for %s", u, err, uid)
+	}
+
+	again := o.sessionUser(o.signIn("", nil, "sub-1", "new@example.com", true))
+	if again != uid {
+		t.Errorf("second login signed in %s, want %s", again, uid)
+	}
+	if ids, _ := ListIdentities(o.db, uid); len(ids) != 1 {
+		t.Errorf("identities = %+v, want one", ids)
+	}
+}
+
+func TestOIDCAutoLinksVerifiedEmail(t *testing.T) {
+	o := newOIDCTest(t)
+	if err := AddUser(o.db, "u-1", "student@example.com", "x"); err != nil {
+		t.Fatal(err)
+	}
+
+	w := o.signIn("", nil, "sub-2", "Student@Example.com", false)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("unverified email: %d, want 403", w.Code)
+	}
+
+	if uid := o.sessionUser(o.signIn("", nil, "sub-2", "Student@Example.com", true)); uid != "u-1" {
+		t.Errorf("verified email signed in %s, want u-1", uid)
+	}
+}
+
+func TestOIDCLinkToSignedInUser(t *testing.T) {
+	o := newOIDCTest(t)
+	if err := AddUser(o.db, "u-1", "me@example.com", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddUser(o.db, "u-2", "other@example.com", "x"); err != nil {
+		t.Fatal(err)
+	}
+	cookie := o.newSession("u-1")
+
+	// A different email at the provider still links to the signed-in user.
+	w := o.signIn("link=1&returnTo=/settings", []*http.Cookie{cookie}, "sub-3", "me@work.example", false)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/settings" {
+		t.Fatalf("link: %d %s", w.Code, w.Body)
+	}
+	if len(w.Result().Cookies()) > 1 { // only the state cookie is cleared
+		t.Errorf("linking replaced the session: %v", w.Result().Cookies())
+	}
+	if ids, _ := ListIdentities(o.db, "u-1"); len(ids) != 1 || ids[0].Subject != "sub-3" {
+		t.Fatalf("identities = %+v", ids)
+	}
+
+	// The identity now signs in as u-1 and can't be linked to anyone else.
+	if uid := o.sessionUser(o.signIn("", nil, "sub-3", "", false)); uid != "u-1" {
+		t.Errorf("linked identity signed in %s, want u-1", uid)
+	}
+	if w := o.signIn("link=1", []*http.Cookie{o.newSession("u-2")}, "sub-3", "", false); w.Code != http.StatusConflict {
+		t.Errorf("relink to u-2: %d, want 409", w.Code)
+	}
+}
+
+func TestOIDCLinkNeedsValidBrowserSession(t *testing.T) {
+	o := newOIDCTest(t)
+	if err := AddUser(o.db, "u-1", "me@example.com", "x"); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := o.get("/auth/oidc/login?link=1", nil, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("no session: %d, want 401", w.Code)
+	}
+
+	expired := o.newSession("u-1")
+	if _, err := o.db.Exec(`UPDATE sessions SET expires_at = 1`); err != nil {
+		t.Fatal(err)
+	}
+	if w := o.get("/auth/oidc/login?link=1", []*http.Cookie{expired}, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("expired session: %d, want 401", w.Code)
+	}
+
+	_, tok, err := session.CreateAPIToken(o.db, "u-1", "script", []string{"read"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bearer := http.Header{"Authorization": {"Bearer " + tok}}
+	if w := o.get("/auth/oidc/login?link=1", nil, bearer); w.Code != http.StatusForbidden {
+		t.Errorf("token: %d, want 403", w.Code)
+	}
+}
+
+func TestOIDCCallbackRejectsBadState(t *testing.T) {
+	o := newOIDCTest(t)
+
+	w := o.get("/auth/oidc/login", nil, nil)
+	back := o.provider.authorize(w.Header().Get("Location"), "sub-4", "x@example.com", true)
+	if w := o.get("/auth/oidc/callback?"+back.Encode(), nil, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("no state cookie: %d, want 400", w.Code)
+	}
+
+	// The state is single-use: replaying the callback fails.
+	cookies := w.Result().Cookies()
+	if w := o.get("/auth/oidc/callback?"+back.Encode(), cookies, nil); w.Code != http.StatusFound {
+		t.Fatalf("callback: %d %s", w.Code, w.Body)
+	}
+	if w := o.get("/auth/oidc/callback?"+back.Encode(), cookies, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("replay: %d, want 400", w.Code)
+	}
+}
+
+func TestOIDCLoginAsksForSecondFactor(t *testing.T) {
+	o := newOIDCTest(t)
+	if err := AddUser(o.db, "u-1", "me@example.com", "x"); err != nil {
+		t.Fatal(err)
+	}
+	secret := b32.EncodeToString([]byte("12345678901234567890"))
+	if _, err := o.db.Exec(`INSERT INTO user_totp (user_id, secret, enabled) VALUES ('u-1', ?, 1)`, secret); err != nil {
+		t.Fatal(err)
+	}
+
+	// Auto-linked by verified email: the provider doesn't stand in for 2FA.
+	w := o.signIn("returnTo=/courses/7", nil, "sub-1", "me@example.com", true)
+	loc, err := url.Parse(w.Header().Get("Location"))
+	if w.Code != http.StatusFound || err != nil || loc.Path != "/login/2fa" {
+		t.Fatalf("callback: %d to %q", w.Code, w.Header().Get("Location"))
+	}
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "session" && c.Value != "" {
+			t.Fatalf("session cookie set before the second factor")
+		}
+	}
+	var n int
+	if err := o.db.QueryRow(`SELECT COUNT(1) FROM sessions WHERE user_id = 'u-1'`).Scan(&n); err != nil || n != 0 {
+		t.Fatalf("%d sessions, %v; want none", n, err)
+	}
+
+	frag, _ := url.ParseQuery(loc.Fragment)
+	if frag.Get("returnTo") != "/courses/7" {
+		t.Errorf("returnTo = %q", frag.Get("returnTo"))
+	}
+	raw, _ := b32.DecodeString(secret)
+	uid, _, err := CompleteMFAChallenge(o.db, frag.Get("mfaToken"), totpCode(raw, time.Now().Unix()/totpPeriod))
+	if err != nil || uid != "u-1" {
+		t.Errorf("completing the challenge: %q, %v", uid, err)
+	}
+}
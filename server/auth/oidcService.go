@@ -0,0 +1,554 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"example.com/sqlite-server/util"
+)
+
+// OIDCConfig configures login through an external OpenID Connect provider:
+// OIDC_ISSUER, OIDC_CLIENT_ID, OIDC_CLIENT_SECRET (optional for public clients;
+// PKCE is always used), OIDC_REDIRECT_URL, OIDC_SCOPES and OIDC_APP_URL.
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string // e.g. https://app.example.com/api/auth/oidc/callback
+	Scopes       string
+	AppURL       string // prefixed to returnTo after login; empty = same origin
+}
+
+// Enabled reports whether enough is configured to offer SSO login.
+func (c OIDCConfig) Enabled() bool {
+	return c.Issuer != "" && c.ClientID != "" && c.RedirectURL != ""
+}
+
+// OIDC is the provider login goes through; serve sets it from the OIDC_*
+// settings. Off until it is Enabled.
+var OIDC = OIDCConfig{Scopes: "openid email profile"}
+
+const (
+	oidcStateTTL = 10 * time.Minute
+	clockSkew    = 60 // seconds of leeway on exp/iat
+)
+
+var oidcHTTP = &http.Client{Timeout: 10 * time.Second}
+
+// ---------------------------------------------------------------------------
+// Provider metadata and keys (discovered lazily, cached in-process)
+// ---------------------------------------------------------------------------
+
+type oidcProvider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+var oidcCache struct {
+	sync.Mutex
+	provider  *oidcProvider
+	keys      map[string]crypto.PublicKey
+	keysFetch time.Time
+}
+
+func discoverProvider(cfg OIDCConfig) (oidcProvider, error) {
+	oidcCache.Lock()
+	defer oidcCache.Unlock()
+	if oidcCache.provider != nil {
+		return *oidcCache.provider, nil
+	}
+
+	var p oidcProvider
+	if err := getJSON(cfg.Issuer+"/.well-known/openid-configuration", &p); err != nil {
+		return oidcProvider{}, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if strings.TrimRight(p.Issuer, "/") != cfg.Issuer {
+		return oidcProvider{}, errors.New("oidc discovery: issuer mismatch")
+	}
+	if p.AuthorizationEndpoint == "" || p.TokenEndpoint == "" || p.JWKSURI == "" {
+		return oidcProvider{}, errors.New("oidc discovery: incomplete metadata")
+	}
+	oidcCache.provider = &p
+	return p, nil
+}
+
+// signingKey returns the provider key for kid, refetching the JWKS (at most once
+// a minute) when the kid is unknown, which is how providers roll keys.
+func signingKey(p oidcProvider, kid string) (crypto.PublicKey, error) {
+	oidcCache.Lock()
+	defer oidcCache.Unlock()
+	if k, ok := oidcCache.keys[kid]; ok {
+		return k, nil
+	}
+	if time.Since(oidcCache.keysFetch) < time.Minute && oidcCache.keys != nil {
+		return nil, errors.New("unknown signing key")
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := getJSON(p.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if pub, err := k.publicKey(); err == nil {
+			keys[k.Kid] = pub
+		}
+	}
+	oidcCache.keys = keys
+	oidcCache.keysFetch = time.Now()
+
+	if k, ok := keys[kid]; ok {
+		return k, nil
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, errors.New("unsupported curve")
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, errors.New("unsupported key type")
+	}
+}
+
+func getJSON(u string, v any) error {
+	res, err := oidcHTTP.Get(u)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", u, res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// ---------------------------------------------------------------------------
+// Authorization request (state + nonce + PKCE)
+// ---------------------------------------------------------------------------
+
+// BeginOIDCLogin records a one-time login attempt and returns the provider URL to
+// redirect to, plus the raw state (the caller binds it to the browser via cookie).
+// linkUserID, if set, links the external identity to that (logged-in) user.
+func BeginOIDCLogin(db *sql.DB, cfg OIDCConfig, returnTo, linkUserID string) (string, string, error) {
+	p, err := discoverProvider(cfg)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := randomString(48)
+	if err != nil {
+		return "", "", err
+	}
+
+	_, _ = db.Exec(`DELETE FROM oidc_states WHERE expires_at <= strftime('%s','now')`)
+	var link any
+	if linkUserID != "" {
+		link = linkUserID
+	}
+	if _, err := db.Exec(`
+		INSERT INTO oidc_states (state, code_verifier, nonce, return_to, link_user_id, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, util.HashToken(state), verifier, nonce, returnTo, link, time.Now().Add(oidcStateTTL).Unix()); err != nil {
+		return "", "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", cfg.ClientID)
+	q.Set("redirect_uri", cfg.RedirectURL)
+	q.Set("scope", cfg.Scopes)
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(p.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.AuthorizationEndpoint + sep + q.Encode(), state, nil
+}
+
+type oidcState struct {
+	Verifier   string
+	Nonce      string
+	ReturnTo   string
+	LinkUserID string
+}
+
+// consumeOIDCState loads and deletes a pending login. Returns sql.ErrNoRows if
+// the state is unknown, already used, or expired.
+func consumeOIDCState(db *sql.DB, state string) (oidcState, error) {
+	key := util.HashToken(state)
+	var st oidcState
+	var link sql.NullString
+	var exp int64
+	err := db.QueryRow(`
+		SELECT code_verifier, nonce, return_to, link_user_id, expires_at
+		  FROM oidc_states
+		 WHERE state = ?
+	`, key).Scan(&st.Verifier, &st.Nonce, &st.ReturnTo, &link, &exp)
+	if err != nil {
+		return oidcState{}, err
+	}
+	if _, err := db.Exec(`DELETE FROM oidc_states WHERE state = ?`, key); err != nil {
+		return oidcState{}, err
+	}
+	if time.Now().Unix() >= exp {
+		return oidcState{}, sql.ErrNoRows
+	}
+	st.LinkUserID = link.String
+	return st, nil
+}
+
+// ---------------------------------------------------------------------------
+// Code exchange and ID token verification
+// ---------------------------------------------------------------------------
+
+// IDClaims are the ID token claims we use.
+type IDClaims struct {
+	Issuer        string          `json:"iss"`
+	Subject       string          `json:"sub"`
+	Audience      json.RawMessage `json:"aud"`
+	Expiry        int64           `json:"exp"`
+	IssuedAt      int64           `json:"iat"`
+	Nonce         string          `json:"nonce"`
+	Email         string          `json:"email"`
+	EmailVerified *bool           `json:"email_verified"`
+}
+
+// exchangeCode redeems the authorization code and returns verified ID token claims.
+func exchangeCode(cfg OIDCConfig, code string, st oidcState) (IDClaims, error) {
+	p, err := discoverProvider(cfg)
+	if err != nil {
+		return IDClaims{}, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", cfg.RedirectURL)
+	form.Set("client_id", cfg.ClientID)
+	form.Set("code_verifier", st.Verifier)
+
+	req, err := http.NewRequest(http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return IDClaims{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+	}
+
+	res, err := oidcHTTP.Do(req)
+	if err != nil {
+		return IDClaims{}, fmt.Errorf("token endpoint: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return IDClaims{}, fmt.Errorf("token endpoint: %s", res.Status)
+	}
+	var tok struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&tok); err != nil {
+		return IDClaims{}, fmt.Errorf("token endpoint: %w", err)
+	}
+	if tok.IDToken == "" {
+		return IDClaims{}, errors.New("token endpoint: no id_token")
+	}
+	return verifyIDToken(cfg, p, tok.IDToken, st.Nonce)
+}
+
+// verifyIDToken checks the JWS signature (RS256/ES256) and the standard claims.
+func verifyIDToken(cfg OIDCConfig, p oidcProvider, raw, nonce string) (IDClaims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return IDClaims{}, errors.New("id_token: malformed")
+	}
+	var hdr struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &hdr); err != nil {
+		return IDClaims{}, fmt.Errorf("id_token header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return IDClaims{}, fmt.Errorf("id_token signature: %w", err)
+	}
+	key, err := signingKey(p, hdr.Kid)
+	if err != nil {
+		return IDClaims{}, err
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if hdr.Alg != "RS256" || rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) != nil {
+			return IDClaims{}, errors.New("id_token: bad signature")
+		}
+	case *ecdsa.PublicKey:
+		if hdr.Alg != "ES256" || len(sig) != 64 {
+			return IDClaims{}, errors.New("id_token: bad signature")
+		}
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(k, digest[:], r, s) {
+			return IDClaims{}, errors.New("id_token: bad signature")
+		}
+	default:
+		return IDClaims{}, errors.New("id_token: unsupported key")
+	}
+
+	var c IDClaims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return IDClaims{}, fmt.Errorf("id_token claims: %w", err)
+	}
+	now := time.Now().Unix()
+	switch {
+	case strings.TrimRight(c.Issuer, "/") != cfg.Issuer:
+		return IDClaims{}, errors.New("id_token: wrong issuer")
+	case !audienceContains(c.Audience, cfg.ClientID):
+		return IDClaims{}, errors.New("id_token: wrong audience")
+	case c.Expiry == 0 || now > c.Expiry+clockSkew:
+		return IDClaims{}, errors.New("id_token: expired")
+	case c.IssuedAt > now+clockSkew:
+		return IDClaims{}, errors.New("id_token: issued in the future")
+	case c.Nonce != nonce:
+		return IDClaims{}, errors.New("id_token: nonce mismatch")
+	case c.Subject == "":
+		return IDClaims{}, errors.New("id_token: missing sub")
+	}
+	return c, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// aud may be a single string or an array.
+func audienceContains(raw json.RawMessage, clientID string) bool {
+	var one string
+	if json.Unmarshal(raw, &one) == nil {
+		return one == clientID
+	}
+	var many []string
+	if json.Unmarshal(raw, &many) == nil {
+		for _, a := range many {
+			if a == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
+// Identity linking
+// ---------------------------------------------------------------------------
+
+// Identity is an external login linked to a user.
+type Identity struct {
+	ID          int64  `json:"id"`
+	Issuer      string `json:"issuer"`
+	Subject     string `json:"subject"`
+	Email       string `json:"email,omitempty"`
+	CreatedAt   int64  `json:"created_at"`
+	LastLoginAt *int64 `json:"last_login_at,omitempty"`
+}
+
+// ResolveOIDCUser maps verified claims to a local user, in order:
+//  1. an identity already linked to (iss, sub);
+//  2. linkUserID (explicit "link my account" flow);
+//  3. an existing user with the same, provider-verified email;
+//  4. a new user with no password (SSO-only).
+func ResolveOIDCUser(db *sql.DB, c IDClaims, linkUserID string) (string, error) {
+	var uid string
+	err := db.QueryRow(`
+		SELECT user_id FROM user_identities WHERE issuer = ? AND subject = ?
+	`, c.Issuer, c.Subject).Scan(&uid)
+	switch {
+	case err == nil:
+		if linkUserID != "" && linkUserID != uid {
+			return "", ErrIdentityLinked
+		}
+		_, _ = db.Exec(`
+			UPDATE user_identities SET last_login_at = strftime('%s','now'), email = COALESCE(?, email)
+			 WHERE issuer = ? AND subject = ?
+		`, nullString(c.Email), c.Issuer, c.Subject)
+		return uid, nil
+	case err != sql.ErrNoRows:
+		return "", err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(c.Email))
+	verified := c.EmailVerified != nil && *c.EmailVerified
+
+	switch {
+	case linkUserID != "":
+		uid = linkUserID
+	case email != "" && verified:
+		u, err := GetUserByEmail(db, email)
+		switch {
+		case err == nil:
+			uid = u.ID
+		case err == sql.ErrNoRows:
+			uid = uuid.NewString()
+			if err := AddUser(db, uid, email, ""); err != nil {
+				return "", err
+			}
+		default:
+			return "", err
+		}
+	default:
+		return "", ErrEmailNotVerified
+	}
+
+	if _, err := db.Exec(`
+		INSERT INTO user_identities (user_id, issuer, subject, email, last_login_at)
+		VALUES (?, ?, ?, ?, strftime('%s','now'))
+	`, uid, c.Issuer, c.Subject, nullString(email)); err != nil {
+		return "", err
+	}
+	return uid, nil
+}
+
+// ListIdentities returns the external identities linked to a user.
+func ListIdentities(db *sql.DB, userID string) ([]Identity, error) {
+	rows, err := db.Query(`
+		SELECT id, issuer, subject, COALESCE(email, ''), created_at, last_login_at
+		  FROM user_identities
+		 WHERE user_id = ?
+		 ORDER BY created_at ASC, id ASC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Identity, 0, 2)
+	for rows.Next() {
+		var i Identity
+		var last sql.NullInt64
+		if err := rows.Scan(&i.ID, &i.Issuer, &i.Subject, &i.Email, &i.CreatedAt, &last); err != nil {
+			return nil, err
+		}
+		if last.Valid {
+			v := last.Int64
+			i.LastLoginAt = &v
+		}
+		out = append(out, i)
+	}
+	return out, rows.Err()
+}
+
+// UnlinkIdentity removes one of the user's identities. It refuses to remove the
+// last way to sign in (no password and no other identity).
+// Returns sql.ErrNoRows if the user has no such identity.
+func UnlinkIdentity(db *sql.DB, userID string, identityID int64) error {
+	var x int
+	if err := db.QueryRow(`SELECT 1 FROM user_identities WHERE id = ? AND user_id = ?`, identityID, userID).Scan(&x); err != nil {
+		return err
+	}
+
+	var password string
+	var others int64
+	if err := db.QueryRow(`
+		SELECT u.password,
+		       (SELECT COUNT(1) FROM user_identities WHERE user_id = u.id AND id <> ?)
+		  FROM users u
+		 WHERE u.id = ?
+	`, identityID, userID).Scan(&password, &others); err != nil {
+		return err
+	}
+	if password == "" && others == 0 {
+		return ErrLastSignInMethod
+	}
+
+	_, err := db.Exec(`DELETE FROM user_identities WHERE id = ? AND user_id = ?`, identityID, userID)
+	return err
+}
+
+func randomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
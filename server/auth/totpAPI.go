@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"example.com/sqlite-server/session"
+	"example.com/sqlite-server/util"
+)
+
+// registerTOTPRoutes wires 2FA management for the logged-in user.
+func registerTOTPRoutes(mux util.Router, db *sql.DB) {
+	mux.HandleFunc("/auth/2fa", session.RequireAuth(db, totpHandler(db)))
+	mux.HandleFunc("/auth/2fa/setup", session.RequireAuth(db, totpSetupHandler(db)))
+	mux.HandleFunc("/auth/2fa/enable", session.RequireAuth(db, totpEnableHandler(db)))
+	mux.HandleFunc("/auth/2fa/recovery-codes", session.RequireAuth(db, totpRecoveryCodesHandler(db)))
+}
+
+type codePayload struct {
+	Code string `json:"code"`
+}
+
+type recoveryCodesResp struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// decodeCode reads { "code": "..." } from the body.
+func decodeCode(r *http.Request) (string, bool) {
+	var p codePayload
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil || p.Code == "" {
+		return "", false
+	}
+	return p.Code, true
+}
+
+// Dispatcher for /auth/2fa
+func totpHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			getTOTPStatusHandler(db)(w, r)
+		case http.MethodDelete:
+			disableTOTPHandler(db)(w, r)
+		default:
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// GET /auth/2fa
+// Returns { "enabled": true, "recoveryCodesLeft": 8 }
+func getTOTPStatusHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := session.UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		st, err := GetTOTPStatus(db, uid)
+		if err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		util.WriteJSON(w, st, http.StatusOK)
+	}
+}
+
+// DELETE /auth/2fa
+// Body: { "code": "123456" }  (current code or a recovery code)
+// 204 if 2FA was turned off; 401 on a wrong code; 404 if it wasn't on.
+func disableTOTPHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := session.UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		code, ok := decodeCode(r)
+		if !ok {
+			util.HTTPError(w, "code is required", http.StatusBadRequest)
+			return
+		}
+
+		enabled, err := TOTPEnabled(db, uid)
+		if err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if !enabled {
+			util.HTTPError(w, "not found", http.StatusNotFound)
+			return
+		}
+		if err := ConfirmSecondFactor(db, uid, code); err != nil {
+			writeMFAError(w, err)
+			return
+		}
+
+		if err := DisableTOTP(db, uid); err != nil && err != sql.ErrNoRows {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// POST /auth/2fa/setup
+// Starts (or restarts) enrolment. 2FA is not active until /auth/2fa/enable.
+// Returns: 200 OK with { "secret": "BASE32...", "otpauthUri": "otpauth://totp/..." }
+// 409 if 2FA is already enabled.
+func totpSetupHandler(db *sql.DB) http.HandlerFunc {
+	type resp struct {
+		Secret     string `json:"secret"`
+		OtpauthURI string `json:"otpauthUri"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		uid, ok := session.UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		u, err := GetUserByID(db, uid)
+		if err != nil {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		secret, uri, err := BeginTOTPEnrolment(db, uid, u.Email)
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		util.WriteJSON(w, resp{Secret: secret, OtpauthURI: uri}, http.StatusOK)
+	}
+}
+
+// POST /auth/2fa/enable
+// Body: { "code": "123456" }  (from the authenticator app)
+// Returns: 200 OK with { "recoveryCodes": [...] } (shown once)
+func totpEnableHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		uid, ok := session.UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		code, ok := decodeCode(r)
+		if !ok {
+			util.HTTPError(w, "code is required", http.StatusBadRequest)
+			return
+		}
+
+		codes, err := EnableTOTP(db, uid, code)
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		util.WriteJSON(w, recoveryCodesResp{RecoveryCodes: codes}, http.StatusOK)
+	}
+}
+
+// POST /auth/2fa/recovery-codes
+// Body: { "code": "123456" }
+// Replaces all recovery codes. Returns: 200 OK with { "recoveryCodes": [...] }
+func totpRecoveryCodesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		uid, ok := session.UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		code, ok := decodeCode(r)
+		if !ok {
+			util.HTTPError(w, "code is required", http.StatusBadRequest)
+			return
+		}
+
+		if err := ConfirmSecondFactor(db, uid, code); err != nil {
+			writeMFAError(w, err)
+			return
+		}
+
+		codes, err := RegenerateRecoveryCodes(db, uid)
+		if err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		util.WriteJSON(w, recoveryCodesResp{RecoveryCodes: codes}, http.StatusOK)
+	}
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/sqlite-server/session"
+	"example.com/sqlite-server/store"
+)
+
+// A stolen session can't be used to guess codes for the signed-in 2FA
+// actions any faster than for a login.
+func TestTOTPActionsCountWrongCodes(t *testing.T) {
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := AddUser(db, "u-1", "me@example.com", "x"); err != nil {
+		t.Fatal(err)
+	}
+	secret := b32.EncodeToString([]byte("12345678901234567890"))
+	if _, err := db.Exec(`INSERT INTO user_totp (user_id, secret, enabled) VALUES ('u-1', ?, 1)`, secret); err != nil {
+		t.Fatal(err)
+	}
+	wrong := "000000"
+	for n := 0; matchTOTP(secret, wrong, time.Now()) != 0; n++ {
+		wrong = fmt.Sprintf("%06d", n)
+	}
+	sess, err := session.CreateSession(db, "u-1", false, session.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	registerTOTPRoutes(mux, db)
+	call := func(method, path, code string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(`{"code":"`+code+`"}`))
+		r.AddCookie(&http.Cookie{Name: "session", Value: sess.ID})
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	for i := 1; i <= mfaLockAfter; i++ {
+		// Alternate between the two endpoints: they share one count.
+		method, path := http.MethodDelete, "/auth/2fa"
+		if i%2 == 0 {
+			method, path = http.MethodPost, "/auth/2fa/recovery-codes"
+		}
+		w := call(method, path, wrong)
+		want := http.StatusUnauthorized
+		if i == mfaLockAfter {
+			want = http.StatusTooManyRequests
+		}
+		if w.Code != want {
+			t.Fatalf("guess %d: %d %s, want %d", i, w.Code, w.Body, want)
+		}
+	}
+	if w := call(http.MethodDelete, "/auth/2fa", wrong); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("while locked: %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if on, _ := TOTPEnabled(db, "u-1"); !on {
+		t.Fatal("2FA turned off by a guesser")
+	}
+
+	if _, err := db.Exec(`UPDATE user_totp SET locked_until = 1`); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := b32.DecodeString(secret)
+	if w := call(http.MethodDelete, "/auth/2fa", totpCode(raw, time.Now().Unix()/totpPeriod)); w.Code != http.StatusNoContent {
+		t.Fatalf("right code after the lock: %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,393 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"example.com/sqlite-server/util"
+)
+
+// RFC 6238 parameters (the defaults every authenticator app supports).
+const (
+	totpPeriod     = 30
+	totpDigits     = 6
+	totpSkew       = 1 // accept one step either side for clock drift
+	recoveryCount  = 10
+	mfaTTL         = 5 * time.Minute
+	mfaMaxAttempts = 5  // per challenge
+	mfaLockAfter   = 10 // wrong codes in a row, across challenges
+	mfaLockout     = 15 * time.Minute
+)
+
+// TOTPIssuer names the service in authenticator apps; serve sets it from
+// TOTP_ISSUER.
+var TOTPIssuer = "Reading"
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPStatus is what a user sees about their own second factor.
+type TOTPStatus struct {
+	Enabled           bool  `json:"enabled"`
+	RecoveryCodesLeft int64 `json:"recoveryCodesLeft"`
+}
+
+// totpCode computes the code for a time step (HMAC-SHA1, dynamic truncation).
+func totpCode(secret []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	off := sum[len(sum)-1] & 0x0f
+	v := binary.BigEndian.Uint32(sum[off:off+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, v%1_000_000)
+}
+
+// matchTOTP returns the time step code matches, or 0.
+func matchTOTP(secretB32, code string, now time.Time) int64 {
+	secret, err := b32.DecodeString(secretB32)
+	if err != nil || len(code) != totpDigits {
+		return 0
+	}
+	cur := now.Unix() / totpPeriod
+	for d := int64(-totpSkew); d <= totpSkew; d++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(secret, cur+d)), []byte(code)) == 1 {
+			return cur + d
+		}
+	}
+	return 0
+}
+
+// BeginTOTPEnrolment (re)generates a pending secret for the user and returns it
+// together with an otpauth:// URI for QR codes. 2FA stays off until EnableTOTP
+// confirms a code. Fails with ErrTOTPAlreadyEnabled if 2FA is active.
+func BeginTOTPEnrolment(db *sql.DB, userID, email string) (string, string, error) {
+	var enabled bool
+	err := db.QueryRow(`SELECT enabled FROM user_totp WHERE user_id = ?`, userID).Scan(&enabled)
+	if err != nil && err != sql.ErrNoRows {
+		return "", "", err
+	}
+	if enabled {
+		return "", "", ErrTOTPAlreadyEnabled
+	}
+
+	raw := make([]byte, 20)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	secret := b32.EncodeToString(raw)
+
+	if _, err := db.Exec(`
+		INSERT INTO user_totp (user_id, secret, enabled) VALUES (?, ?, 0)
+		ON CONFLICT(user_id) DO UPDATE SET secret = excluded.secret, last_step = 0
+	`, userID, secret); err != nil {
+		return "", "", err
+	}
+
+	label := url.PathEscape(TOTPIssuer + ":" + email)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", TOTPIssuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(totpPeriod))
+	return secret, "otpauth://totp/" + label + "?" + q.Encode(), nil
+}
+
+// EnableTOTP confirms enrolment with a current code, turns 2FA on and returns a
+// fresh set of recovery codes (shown once).
+// Errors: ErrTOTPNotEnrolled, ErrTOTPAlreadyEnabled, ErrInvalidCode.
+func EnableTOTP(db *sql.DB, userID, code string) ([]string, error) {
+	var secret string
+	var enabled bool
+	err := db.QueryRow(`SELECT secret, enabled FROM user_totp WHERE user_id = ?`, userID).Scan(&secret, &enabled)
+	if err == sql.ErrNoRows {
+		return nil, ErrTOTPNotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	step := matchTOTP(secret, normalizeCode(code), time.Now())
+	if step == 0 {
+		return nil, ErrInvalidCode
+	}
+	if _, err := db.Exec(`UPDATE user_totp SET enabled = 1, last_step = ? WHERE user_id = ?`, step, userID); err != nil {
+		return nil, err
+	}
+	return RegenerateRecoveryCodes(db, userID)
+}
+
+// TOTPEnabled reports whether the user must pass a second factor at login.
+func TOTPEnabled(db *sql.DB, userID string) (bool, error) {
+	var enabled bool
+	err := db.QueryRow(`SELECT enabled FROM user_totp WHERE user_id = ?`, userID).Scan(&enabled)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return enabled, err
+}
+
+// GetTOTPStatus returns whether 2FA is on and how many recovery codes remain.
+func GetTOTPStatus(db *sql.DB, userID string) (TOTPStatus, error) {
+	var st TOTPStatus
+	enabled, err := TOTPEnabled(db, userID)
+	if err != nil {
+		return st, err
+	}
+	st.Enabled = enabled
+	if !enabled {
+		return st, nil
+	}
+	err = db.QueryRow(`
+		SELECT COUNT(1) FROM totp_recovery_codes WHERE user_id = ? AND used_at IS NULL
+	`, userID).Scan(&st.RecoveryCodesLeft)
+	return st, err
+}
+
+// VerifySecondFactor checks a TOTP code, or failing that a recovery code
+// (which is consumed). A TOTP step is never accepted twice.
+func VerifySecondFactor(db *sql.DB, userID, code string) (bool, error) {
+	code = normalizeCode(code)
+	if code == "" {
+		return false, nil
+	}
+
+	var secret string
+	var lastStep int64
+	err := db.QueryRow(`
+		SELECT secret, last_step FROM user_totp WHERE user_id = ? AND enabled = 1
+	`, userID).Scan(&secret, &lastStep)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if len(code) == totpDigits {
+		step := matchTOTP(secret, code, time.Now())
+		if step == 0 || step <= lastStep {
+			return false, nil
+		}
+		res, err := db.Exec(`
+			UPDATE user_totp SET last_step = ? WHERE user_id = ? AND last_step < ?
+		`, step, userID, step)
+		if err != nil {
+			return false, err
+		}
+		n, _ := res.RowsAffected()
+		return n == 1, nil
+	}
+
+	res, err := db.Exec(`
+		UPDATE totp_recovery_codes SET used_at = strftime('%s','now')
+		 WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+	`, userID, util.HashToken(code))
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes and returns the new
+// plaintext codes (formatted xxxxx-xxxxx). Only hashes are stored.
+func RegenerateRecoveryCodes(db *sql.DB, userID string) ([]string, error) {
+	codes := make([]string, 0, recoveryCount)
+	for len(codes) < recoveryCount {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		c := strings.ToLower(b32.EncodeToString(raw))[:10]
+		codes = append(codes, c[:5]+"-"+c[5:])
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM totp_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return nil, err
+	}
+	for _, c := range codes {
+		if _, err := tx.Exec(`
+			INSERT OR IGNORE INTO totp_recovery_codes (user_id, code_hash) VALUES (?, ?)
+		`, userID, util.HashToken(normalizeCode(c))); err != nil {
+			return nil, err
+		}
+	}
+	return codes, tx.Commit()
+}
+
+// DisableTOTP removes the user's second factor and recovery codes.
+// Returns sql.ErrNoRows if the user had none (used by the admin reset).
+func DisableTOTP(db *sql.DB, userID string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM user_totp WHERE user_id = ?`, userID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM totp_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM mfa_challenges WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// normalizeCode strips spaces/dashes and lowercases, so "123 456" and
+// "ABCDE-FGHIJ" are accepted as typed.
+func normalizeCode(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return strings.NewReplacer(" ", "", "-", "").Replace(s)
+}
+
+// ---------------------------------------------------------------------------
+// Login challenges (password OK, second factor pending)
+// ---------------------------------------------------------------------------
+
+// CreateMFAChallenge records a pending login and returns its one-time token.
+// Errors: ErrMFALocked while too many wrong codes lock the second factor.
+func CreateMFAChallenge(db *sql.DB, userID string, remember bool) (string, error) {
+	if err := checkMFALock(db, userID); err != nil {
+		return "", err
+	}
+	tok, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+	_, _ = db.Exec(`DELETE FROM mfa_challenges WHERE expires_at <= strftime('%s','now')`)
+	_, err = db.Exec(`
+		INSERT INTO mfa_challenges (token, user_id, remember, expires_at) VALUES (?, ?, ?, ?)
+	`, util.HashToken(tok), userID, remember, time.Now().Add(mfaTTL).Unix())
+	if err != nil {
+		return "", err
+	}
+	return tok, nil
+}
+
+// checkMFALock returns ErrMFALocked if userID's second factor is locked.
+func checkMFALock(db *sql.DB, userID string) error {
+	var locked bool
+	err := db.QueryRow(`
+		SELECT COALESCE(locked_until, 0) > ? FROM user_totp WHERE user_id = ?
+	`, time.Now().Unix(), userID).Scan(&locked)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if locked {
+		return ErrMFALocked
+	}
+	return nil
+}
+
+// recordMFAFailure counts a wrong code. The mfaLockAfter-th in a row locks the
+// second factor for mfaLockout and drops the user's pending challenges, so
+// signing in again for fresh attempts doesn't help a guesser.
+func recordMFAFailure(db *sql.DB, userID string) error {
+	var failed int
+	err := db.QueryRow(`
+		UPDATE user_totp SET failed_attempts = failed_attempts + 1 WHERE user_id = ?
+		RETURNING failed_attempts
+	`, userID).Scan(&failed)
+	switch {
+	case err == sql.ErrNoRows:
+		return ErrInvalidCode
+	case err != nil:
+		return err
+	case failed < mfaLockAfter:
+		return ErrInvalidCode
+	}
+	if _, err := db.Exec(`
+		UPDATE user_totp SET failed_attempts = 0, locked_until = ? WHERE user_id = ?
+	`, time.Now().Add(mfaLockout).Unix(), userID); err != nil {
+		return err
+	}
+	if _, err := db.Exec(`DELETE FROM mfa_challenges WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	return ErrMFALocked
+}
+
+// CompleteMFAChallenge verifies the second factor for a pending login.
+// On success the challenge is consumed and the user ID and remember flag returned.
+// Errors: sql.ErrNoRows (unknown/expired/exhausted challenge), ErrInvalidCode,
+// ErrMFALocked.
+func CompleteMFAChallenge(db *sql.DB, token, code string) (string, bool, error) {
+	key := util.HashToken(token)
+	var uid string
+	var remember bool
+	var attempts, exp int64
+	err := db.QueryRow(`
+		SELECT user_id, remember, attempts, expires_at FROM mfa_challenges WHERE token = ?
+	`, key).Scan(&uid, &remember, &attempts, &exp)
+	if err != nil {
+		return "", false, err
+	}
+	if time.Now().Unix() >= exp || attempts >= mfaMaxAttempts {
+		_, _ = db.Exec(`DELETE FROM mfa_challenges WHERE token = ?`, key)
+		return "", false, sql.ErrNoRows
+	}
+	if err := ConfirmSecondFactor(db, uid, code); err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			_, _ = db.Exec(`UPDATE mfa_challenges SET attempts = attempts + 1 WHERE token = ?`, key)
+		}
+		return "", false, err
+	}
+
+	res, err := db.Exec(`DELETE FROM mfa_challenges WHERE token = ?`, key)
+	if err != nil {
+		return "", false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return "", false, sql.ErrNoRows // raced with a concurrent completion
+	}
+	return uid, remember, nil
+}
+
+// ConfirmSecondFactor checks code (see VerifySecondFactor) for a signed-in
+// action such as turning 2FA off, counting a wrong one towards the lockout
+// like a login does. A right one clears the count.
+// Errors: ErrInvalidCode, ErrMFALocked.
+func ConfirmSecondFactor(db *sql.DB, userID, code string) error {
+	if err := checkMFALock(db, userID); err != nil {
+		return err
+	}
+	ok, err := VerifySecondFactor(db, userID, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return recordMFAFailure(db, userID)
+	}
+	_, err = db.Exec(`
+		UPDATE user_totp SET failed_attempts = 0, locked_until = NULL WHERE user_id = ?
+	`, userID)
+	return err
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"example.com/sqlite-server/store"
+)
+
+func TestMFALockoutSpansChallenges(t *testing.T) {
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	secret := b32.EncodeToString([]byte("12345678901234567890"))
+	if err := AddUser(db, "u-1", "me@example.com", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO user_totp (user_id, secret, enabled) VALUES ('u-1', ?, 1)`, secret); err != nil {
+		t.Fatal(err)
+	}
+	// A code that is wrong at every step the verifier accepts.
+	wrong := "000000"
+	for n := 0; matchTOTP(secret, wrong, time.Now()) != 0; n++ {
+		wrong = fmt.Sprintf("%06d", n)
+	}
+
+	// Each login allows mfaMaxAttempts guesses; logging in again resets that,
+	// but not the per-user count.
+	var tok string
+	for i := 1; i <= mfaLockAfter; i++ {
+		if (i-1)%mfaMaxAttempts == 0 {
+			if tok, err = CreateMFAChallenge(db, "u-1", false); err != nil {
+				t.Fatalf("challenge before guess %d: %v", i, err)
+			}
+		}
+		_, _, err := CompleteMFAChallenge(db, tok, wrong)
+		want := ErrInvalidCode
+		if i == mfaLockAfter {
+			want = ErrMFALocked
+		}
+		if !errors.Is(err, want) {
+			t.Fatalf("guess %d: %v, want %v", i, err, want)
+		}
+	}
+	if _, err := CreateMFAChallenge(db, "u-1", false); !errors.Is(err, ErrMFALocked) {
+		t.Fatalf("challenge while locked: %v", err)
+	}
+
+	// Once the lock expires the right code works and clears the count.
+	if _, err := db.Exec(`UPDATE user_totp SET locked_until = 1`); err != nil {
+		t.Fatal(err)
+	}
+	if tok, err = CreateMFAChallenge(db, "u-1", false); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := b32.DecodeString(secret)
+	uid, _, err := CompleteMFAChallenge(db, tok, totpCode(raw, time.Now().Unix()/totpPeriod))
+	if err != nil || uid != "u-1" {
+		t.Fatalf("right code: %q, %v", uid, err)
+	}
+	var failed int
+	if err := db.QueryRow(`SELECT failed_attempts FROM user_totp`).Scan(&failed); err != nil || failed != 0 {
+		t.Errorf("failed_attempts = %d, %v after a success", failed, err)
+	}
+}
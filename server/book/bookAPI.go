@@ -12,7 +12,7 @@ import (
 	"example.com/sqlite-server/util"
 )
 
-func RegisterBookRoutes(mux *http.ServeMux, db *sql.DB) {
+func RegisterBookRoutes(mux util.Router, db *sql.DB) {
 	// Both endpoints require auth and membership to the course's university.
 	mux.HandleFunc("/books", session.RequireAuth(db, booksHandler(db)))
 }
@@ -27,42 +27,55 @@ func booksHandler(db *sql.DB) http.HandlerFunc {
 		case http.MethodDelete:
 			deleteBookHandler(db)(w, r)
 		default:
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
 		}
 	}
 }
 
-
-// GET /books?courseId=123
-// Returns books for the course, each with embedded chapters and per-user "completed".
+// GET /books?courseId=123[&completed=bool][&hasDeadline=bool][&dueBefore=unix]
+//
+//	[&sort=created|title|author][&limit=N][&cursor=...]
+//
+// Returns a page of books for the course, each with embedded chapters and per-user "completed".
 // Access: caller must be ENROLLED in the course (not just university member).
 func getBooksForCourseHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid, ok := session.UserIDFromCtx(r.Context())
 		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 		courseID, err := util.ParseInt64Query(r, "courseId")
 		if err != nil || courseID <= 0 {
-			http.Error(w, "courseId is required", http.StatusBadRequest)
+			util.HTTPError(w, "courseId is required", http.StatusBadRequest)
 			return
 		}
 
 		// Tighten access: must be enrolled
 		enrolled, err := enrollment.UserEnrolledInCourse(db, uid, courseID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		if !enrolled {
-			http.Error(w, "forbidden", http.StatusForbidden)
+			util.HTTPError(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		pq, err := util.ParsePageQuery(r, bookSorts...)
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		f, err := util.ParseItemFilter(r)
+		if err != nil {
+			util.WriteError(w, err)
 			return
 		}
 
-		list, err := ListBooksByCourseWithProgress(db, courseID, uid)
+		list, err := ListBooksByCourseWithProgress(db, courseID, uid, f, pq)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		util.WriteJSON(w, list, http.StatusOK)
@@ -84,7 +97,7 @@ func postBookHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid, ok := session.UserIDFromCtx(r.Context())
 		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 
@@ -92,54 +105,44 @@ func postBookHandler(db *sql.DB) http.HandlerFunc {
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
 		if err := dec.Decode(&p); err != nil {
-			http.Error(w, "bad request", http.StatusBadRequest)
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
 			return
 		}
 		p.Title = strings.TrimSpace(p.Title)
 		p.Author = strings.TrimSpace(p.Author)
 		if p.CourseID <= 0 || p.Title == "" || p.Author == "" {
-			http.Error(w, "invalid input", http.StatusBadRequest)
+			util.HTTPError(w, "invalid input", http.StatusBadRequest)
 			return
 		}
 
 		uniID, err := enrollment.CourseUniversity(db, p.CourseID)
 		if err != nil {
 			if err == sql.ErrNoRows {
-				http.Error(w, "course not found", http.StatusBadRequest)
+				util.HTTPError(w, "course not found", http.StatusBadRequest)
 				return
 			}
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		isMember, err := membership.IsMember(db, uid, uniID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		if !isMember {
-			http.Error(w, "forbidden", http.StatusForbidden)
+			util.HTTPError(w, "forbidden", http.StatusForbidden)
 			return
 		}
 
 		b, err := AddBook(db, p.CourseID, p.Title, p.Author, p.NumChapters, p.Location)
 		if err != nil {
-			switch strings.ToLower(err.Error()) {
-			case "invalid input":
-				http.Error(w, "invalid input", http.StatusBadRequest)
-				return
-			case "course not found":
-				http.Error(w, "course not found", http.StatusBadRequest)
-				return
-			default:
-				http.Error(w, "internal error", http.StatusInternalServerError)
-				return
-			}
+			util.WriteError(w, err)
+			return
 		}
 		util.WriteJSON(w, b, http.StatusCreated)
 	}
 }
 
-
 // DELETE /books
 // Body: { "bookId": number }
 // Auth: must be enrolled in the book's course.
@@ -153,57 +156,45 @@ func deleteBookHandler(db *sql.DB) http.HandlerFunc {
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
 		if err := dec.Decode(&p); err != nil || p.BookID <= 0 {
-			http.Error(w, "bad request", http.StatusBadRequest)
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
 			return
 		}
 
 		// 1) Existence check -> 404 (use BookCourseID which 404s if missing)
 		if _, err := BookCourseID(db, p.BookID); err != nil {
 			if err == sql.ErrNoRows {
-				http.Error(w, "not found", http.StatusNotFound)
+				util.HTTPError(w, "not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 
 		uid, ok := session.UserIDFromCtx(r.Context())
 		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 
 		// 2) Enrollment check
 		enrolled, err := UserEnrolledInBookCourse(db, uid, p.BookID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		if !enrolled {
-			http.Error(w, "forbidden", http.StatusForbidden)
+			util.HTTPError(w, "forbidden", http.StatusForbidden)
 			return
 		}
 
 		// 3) Delete with chapter-progress guard
 		deleted, derr := DeleteBookIfNoChapterProgress(db, p.BookID)
 		if derr != nil {
-			switch {
-			case derr == sql.ErrNoRows:
-				http.Error(w, "not found", http.StatusNotFound)
-				return
-			case strings.Contains(strings.ToLower(derr.Error()), "invalid input"):
-				http.Error(w, "invalid input", http.StatusBadRequest)
-				return
-			case strings.Contains(strings.ToLower(derr.Error()), "chapter progress"):
-				http.Error(w, "conflict: book has chapter progress", http.StatusConflict)
-				return
-			default:
-				http.Error(w, "internal error", http.StatusInternalServerError)
-				return
-			}
+			util.WriteError(w, derr)
+			return
 		}
 		if !deleted {
-			http.Error(w, "not found", http.StatusNotFound)
+			util.HTTPError(w, "not found", http.StatusNotFound)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
@@ -1,23 +1,30 @@
-
 package book
 
 import (
 	"context"
 	"database/sql"
-	"errors"
+	"net/http"
 	"strings"
 
 	"example.com/sqlite-server/chapter"
+	"example.com/sqlite-server/events"
+	"example.com/sqlite-server/util"
+)
+
+// Errors returned by this package.
+var (
+	ErrCourseNotFound  = util.NewError(http.StatusBadRequest, "course_not_found", "course not found")
+	ErrBookHasProgress = util.NewError(http.StatusConflict, "book_has_progress", "book has chapter progress")
 )
 
 type Book struct {
-	ID          int64                             `json:"id"`
-	CourseID    int64                             `json:"courseId"`
-	Title       string                            `json:"title"`
-	Author      string                            `json:"author"`
-	NumChapters *int64                            `json:"numChapters,omitempty"`
-	Location    *string                           `json:"location,omitempty"`
-	Chapters    []chapter.ChapterWithStatus       `json:"chapters,omitempty"`
+	ID          int64                       `json:"id"`
+	CourseID    int64                       `json:"courseId"`
+	Title       string                      `json:"title"`
+	Author      string                      `json:"author"`
+	NumChapters *int64                      `json:"numChapters,omitempty"`
+	Location    *string                     `json:"location,omitempty"`
+	Chapters    []chapter.ChapterWithStatus `json:"chapters,omitempty"`
 }
 
 // AddBook inserts a new book and, if numChapters > 0, creates chapters [1..n] atomically.
@@ -26,7 +33,7 @@ func AddBook(db *sql.DB, courseID int64, title, author string, numChapters *int6
 	title = strings.TrimSpace(title)
 	author = strings.TrimSpace(author)
 	if courseID <= 0 || title == "" || author == "" {
-		return Book{}, errors.New("invalid input")
+		return Book{}, util.ErrInvalidInput
 	}
 
 	tx, err := db.BeginTx(context.Background(), nil)
@@ -39,7 +46,7 @@ func AddBook(db *sql.DB, courseID int64, title, author string, numChapters *int6
 	var cid int64
 	if err := tx.QueryRow(`SELECT id FROM courses WHERE id = ?`, courseID).Scan(&cid); err != nil {
 		if err == sql.ErrNoRows {
-			return Book{}, errors.New("course not found")
+			return Book{}, ErrCourseNotFound
 		}
 		return Book{}, err
 	}
@@ -95,10 +102,10 @@ func AddBook(db *sql.DB, courseID int64, title, author string, numChapters *int6
 	}
 	b.Chapters = ws
 
+	events.Publish(events.Event{Type: events.TypeCreated, Kind: events.KindBook, ItemID: b.ID, CourseID: b.CourseID, Item: b})
 	return b, nil
 }
 
-
 // ListBooksByCourse returns all books for a course including chapters.
 // Since Book.Chapters is []ChapterWithStatus, convert plain chapters to "with status" (Completed=false).
 func ListBooksByCourse(db *sql.DB, courseID int64) ([]Book, error) {
@@ -159,60 +166,86 @@ func ListBooksByCourse(db *sql.DB, courseID int64) ([]Book, error) {
 	return books, nil
 }
 
+// bookSorts are the orders GET /books accepts; the first is the default.
+var bookSorts = []util.Sort{
+	{Name: "created", Keys: []string{"b.id"}},
+	{Name: "title", Keys: []string{"b.title", "b.id"}},
+	{Name: "author", Keys: []string{"b.author", "b.id"}},
+}
 
-// ListBooksByCourseWithProgress returns all books for a course and attaches
-// chapters annotated with the caller's Completed status.
-func ListBooksByCourseWithProgress(db *sql.DB, courseID int64, userID string) ([]Book, error) {
+// ListBooksByCourseWithProgress returns a page of a course's books matching f
+// and attaches chapters annotated with the caller's Completed status. A book
+// counts as completed once it has chapters and all of them are done.
+func ListBooksByCourseWithProgress(db *sql.DB, courseID int64, userID string, f util.ItemFilter, q util.PageQuery) (util.Page[Book], error) {
 	if courseID <= 0 || strings.TrimSpace(userID) == "" {
-		return []Book{}, nil
+		return util.NewPage[Book](nil, q, nil), nil
 	}
 
 	// Load books
+	after, args := q.After()
 	rows, err := db.Query(`
-		SELECT id, course_id, title, author, numChapters, location
-		  FROM books
-		 WHERE course_id = ?
-		 ORDER BY id ASC
-	`, courseID)
+		SELECT b.id, b.course_id, b.title, b.author, b.numChapters, b.location
+		  FROM books b
+		 WHERE b.course_id = ?
+		   AND (? IS NULL OR (
+		         EXISTS (SELECT 1 FROM chapters ch WHERE ch.book_id = b.id)
+		         AND NOT EXISTS (
+		           SELECT 1 FROM chapters ch
+		             LEFT JOIN progress p ON p.chapter_id = ch.id AND p.user_id = ?
+		            WHERE ch.book_id = b.id AND COALESCE(p.completed, 0) = 0)
+		       ) = ?)
+		   AND (? IS NULL OR EXISTS (
+		         SELECT 1 FROM chapters ch WHERE ch.book_id = b.id AND ch.deadline IS NOT NULL) = ?)
+		   AND (? IS NULL OR EXISTS (
+		         SELECT 1 FROM chapters ch WHERE ch.book_id = b.id AND ch.deadline < ?))
+		   AND `+after+`
+		 ORDER BY `+q.OrderBy()+`
+		 LIMIT ?
+	`, append(append([]any{courseID, f.Completed, userID, f.Completed, f.HasDeadline, f.HasDeadline,
+		f.DueBefore, f.DueBefore}, args...), q.FetchLimit())...)
 	if err != nil {
-		return nil, err
+		return util.Page[Book]{}, err
 	}
 	defer rows.Close()
 
-	books := make([]Book, 0, 32)
-	ids := make([]int64, 0, 32)
+	books := make([]Book, 0, q.FetchLimit())
+	ids := make([]int64, 0, q.FetchLimit())
 	for rows.Next() {
 		var b Book
 		if err := rows.Scan(&b.ID, &b.CourseID, &b.Title, &b.Author, &b.NumChapters, &b.Location); err != nil {
-			return nil, err
+			return util.Page[Book]{}, err
 		}
 		ids = append(ids, b.ID)
 		books = append(books, b)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	if len(books) == 0 {
-		return books, nil
+		return util.Page[Book]{}, err
 	}
 
 	// Batch load chapters with per-user completion
 	chapMap, err := chapter.ListByBooksWithProgress(db, ids, userID)
 	if err != nil {
-		return nil, err
+		return util.Page[Book]{}, err
 	}
 
 	for i := range books {
 		books[i].Chapters = chapMap[books[i].ID]
 	}
-	return books, nil
+	return util.NewPage(books, q, func(b Book) []any {
+		switch q.Sort.Name {
+		case "title":
+			return []any{b.Title, b.ID}
+		case "author":
+			return []any{b.Author, b.ID}
+		}
+		return []any{b.ID}
+	}), nil
 }
 
-
 // BookCourseID returns the owning course_id for a book.
 func BookCourseID(db *sql.DB, bookID int64) (int64, error) {
 	if bookID <= 0 {
-		return 0, errors.New("invalid input")
+		return 0, util.ErrInvalidInput
 	}
 	var cid int64
 	if err := db.QueryRow(`SELECT course_id FROM books WHERE id = ?`, bookID).Scan(&cid); err != nil {
@@ -224,7 +257,7 @@ func BookCourseID(db *sql.DB, bookID int64) (int64, error) {
 // UserEnrolledInBookCourse reports whether the user is enrolled in the book's course.
 func UserEnrolledInBookCourse(db *sql.DB, userID string, bookID int64) (bool, error) {
 	if strings.TrimSpace(userID) == "" || bookID <= 0 {
-		return false, errors.New("invalid input")
+		return false, util.ErrInvalidInput
 	}
 	var exists int
 	err := db.QueryRow(`
@@ -248,12 +281,12 @@ func UserEnrolledInBookCourse(db *sql.DB, userID string, bookID int64) (bool, er
 // no user has completed ANY of its chapters. Returns (false, sql.ErrNoRows) if missing.
 func DeleteBookIfNoChapterProgress(db *sql.DB, bookID int64) (bool, error) {
 	if bookID <= 0 {
-		return false, errors.New("invalid input")
+		return false, util.ErrInvalidInput
 	}
 
 	// Ensure book exists
-	var exists int64
-	if err := db.QueryRow(`SELECT id FROM books WHERE id = ?`, bookID).Scan(&exists); err != nil {
+	var courseID int64
+	if err := db.QueryRow(`SELECT course_id FROM books WHERE id = ?`, bookID).Scan(&courseID); err != nil {
 		if err == sql.ErrNoRows {
 			return false, sql.ErrNoRows
 		}
@@ -271,7 +304,7 @@ func DeleteBookIfNoChapterProgress(db *sql.DB, bookID int64) (bool, error) {
 		return false, err
 	}
 	if cnt > 0 {
-		return false, errors.New("book has chapter progress")
+		return false, ErrBookHasProgress
 	}
 
 	// Safe to delete (chapters will cascade due to FK)
@@ -280,5 +313,8 @@ func DeleteBookIfNoChapterProgress(db *sql.DB, bookID int64) (bool, error) {
 		return false, err
 	}
 	n, _ := res.RowsAffected()
+	if n > 0 {
+		events.Publish(events.Event{Type: events.TypeDeleted, Kind: events.KindBook, ItemID: bookID, CourseID: courseID})
+	}
 	return n > 0, nil
 }
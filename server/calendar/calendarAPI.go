@@ -3,15 +3,15 @@ package calendar
 import (
 	"database/sql"
 	"net/http"
-	"strings"
 	"strconv"
+	"strings"
 	"time"
 
 	"example.com/sqlite-server/session"
 	"example.com/sqlite-server/util"
 )
 
-func RegisterCalendarRoutes(mux *http.ServeMux, db *sql.DB) {
+func RegisterCalendarRoutes(mux util.Router, db *sql.DB) {
 	mux.HandleFunc("/calendar.ics", session.RequireAuth(db, calendarHandler(db)))
 	mux.HandleFunc("/calendar/token", session.RequireAuth(db, tokenHandler(db)))
 	mux.HandleFunc("/calendar/", publicCalendarHandler(db))
@@ -22,7 +22,7 @@ func calendarHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, ok := session.UserIDFromCtx(r.Context())
 		if !ok || userID == "" {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 
@@ -34,7 +34,7 @@ func calendarHandler(db *sql.DB) http.HandlerFunc {
 			FROM calendar_index WHERE user_id = ?;
 		`, userID).Scan(&maxMod, &n)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		etag := `W/"` + strconv.FormatInt(maxMod, 10) + `-` + strconv.FormatInt(n, 10) + `"`
@@ -54,7 +54,7 @@ func calendarHandler(db *sql.DB) http.HandlerFunc {
 
 		events, err := GetUserEvents(db, userID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 
@@ -67,21 +67,30 @@ func calendarHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
+// GET /calendar/token
+// Mints the user's feed token on first use. Tokens are stored hashed, so later calls
+// can't reveal it again: they return { "exists": true } and the client must rotate
+// to get a fresh URL.
 func tokenHandler(db *sql.DB) http.HandlerFunc {
 	type resp struct {
-		Token string `json:"token"`
+		Token string `json:"token,omitempty"`
 		// Path only; constructing absolute URL is proxy-dependent.
-		UrlPath string `json:"urlPath"`
+		UrlPath string `json:"urlPath,omitempty"`
+		Exists  bool   `json:"exists"`
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, ok := session.UserIDFromCtx(r.Context())
 		if !ok || userID == "" {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
-		tok, err := GetOrCreateCalendarToken(db, userID)
+		tok, created, err := GetOrCreateCalendarToken(db, userID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if !created {
+			util.WriteJSON(w, resp{Exists: true}, http.StatusOK)
 			return
 		}
 		util.WriteJSON(w, resp{
@@ -91,7 +100,6 @@ func tokenHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-
 // /api/calendar/{token}.ics  (no auth; Apple/Google won't send cookies)
 func publicCalendarHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -101,27 +109,25 @@ func publicCalendarHandler(db *sql.DB) http.HandlerFunc {
 		// so path is "/calendar/<token>.ics" already.
 		const prefix = "/calendar/"
 		if len(path) <= len(prefix) || path[:len(prefix)] != prefix || !strings.HasSuffix(path, ".ics") {
-			http.NotFound(w, r)
+			util.HTTPError(w, "not found", http.StatusNotFound)
 			return
 		}
 		token := strings.TrimSuffix(path[len(prefix):], ".ics")
 		if token == "" {
-			http.NotFound(w, r)
+			util.HTTPError(w, "not found", http.StatusNotFound)
 			return
 		}
 
-		// Resolve token → user
-		var userID string
-		if err := db.QueryRow(`SELECT user_id FROM calendar_tokens WHERE token = ?`, token).Scan(&userID); err != nil {
+		// Resolve token → user (tokens are stored hashed)
+		userID, err := CalendarTokenUser(db, token)
+		if err != nil {
 			if err == sql.ErrNoRows {
-				http.NotFound(w, r)
+				util.HTTPError(w, "not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
-		// Update last_used_at (best effort)
-		_, _ = db.Exec(`UPDATE calendar_tokens SET last_used_at = strftime('%s','now') WHERE token = ?`, token)
 
 		// ETag/Last-Modified like the authed endpoint
 		var maxMod, n int64
@@ -129,7 +135,7 @@ func publicCalendarHandler(db *sql.DB) http.HandlerFunc {
 			SELECT COALESCE(MAX(last_modified_epoch),0), COUNT(*)
 			FROM calendar_index WHERE user_id = ?;
 		`, userID).Scan(&maxMod, &n); err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		etag := `W/"` + strconv.FormatInt(maxMod, 10) + `-` + strconv.FormatInt(n, 10) + `"`
@@ -149,7 +155,7 @@ func publicCalendarHandler(db *sql.DB) http.HandlerFunc {
 
 		events, err := GetUserEvents(db, userID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		ics := BuildICS(events)
@@ -163,24 +169,30 @@ func publicCalendarHandler(db *sql.DB) http.HandlerFunc {
 }
 
 func rotateTokenHandler(db *sql.DB) http.HandlerFunc {
-    type resp struct {
-        Token   string `json:"token"`
-        UrlPath string `json:"urlPath"`
-    }
-    return func(w http.ResponseWriter, r *http.Request) {
-        if r.Method != http.MethodPost {
-            http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-            return
-        }
-        userID, ok := session.UserIDFromCtx(r.Context())
-        if !ok || userID == "" { http.Error(w, "unauthorized", http.StatusUnauthorized); return }
-
-        tok, err := RotateCalendarToken(db, userID)
-        if err != nil { http.Error(w, "internal error", http.StatusInternalServerError); return }
-
-        util.WriteJSON(w, resp{
-            Token:   tok,
-            UrlPath: "/api/calendar/" + tok + ".ics",
-        }, http.StatusOK)
-    }
+	type resp struct {
+		Token   string `json:"token"`
+		UrlPath string `json:"urlPath"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		userID, ok := session.UserIDFromCtx(r.Context())
+		if !ok || userID == "" {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		tok, err := RotateCalendarToken(db, userID)
+		if err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		util.WriteJSON(w, resp{
+			Token:   tok,
+			UrlPath: "/api/calendar/" + tok + ".ics",
+		}, http.StatusOK)
+	}
 }
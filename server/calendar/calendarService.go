@@ -2,26 +2,29 @@ package calendar
 
 import (
 	"bytes"
+	"crypto/rand"
 	"database/sql"
 	"encoding/hex"
-	"crypto/rand"
-	"time"
-	"strings"
 	"strconv"
+	"strings"
+	"time"
+
+	"example.com/sqlite-server/store"
+	"example.com/sqlite-server/util"
 )
 
 // Event mirrors a calendar_index row.
 type Event struct {
-	UID              string
-	UserID           string
-	Kind             string
-	SourceID         int64
-	Summary          string
-	DeadlineEpoch    sql.NullInt64
-	Completed        bool
-	LastModified     int64
-	Seq              int
-	CancelledAt      sql.NullInt64
+	UID           string
+	UserID        string
+	Kind          string
+	SourceID      int64
+	Summary       string
+	DeadlineEpoch sql.NullInt64
+	Completed     bool
+	LastModified  int64
+	Seq           int
+	CancelledAt   sql.NullInt64
 }
 
 // GetUserEvents loads all events (even completed) for a user.
@@ -56,7 +59,6 @@ func GetUserEvents(db *sql.DB, userID string) ([]Event, error) {
 	return events, nil
 }
 
-
 func BuildICS(events []Event) string {
 	var buf bytes.Buffer
 	w := func(s string) { buf.WriteString(s + "\r\n") }
@@ -112,62 +114,87 @@ func BuildICS(events []Event) string {
 	return buf.String()
 }
 
-
-func GetOrCreateCalendarToken(db *sql.DB, userID string) (string, error) {
-	var tok string
+// GetOrCreateCalendarToken mints a feed token for the user if they don't have one.
+// Only the token's hash is stored, so an existing token can't be returned again:
+// created=false with an empty token means the user already has a (hidden) one.
+func GetOrCreateCalendarToken(db *sql.DB, userID string) (string, bool, error) {
+	var x int
 
 	// Fast path: already exists
-	if err := db.QueryRow(`SELECT token FROM calendar_tokens WHERE user_id = ?`, userID).Scan(&tok); err == nil {
-		return tok, nil
+	if err := db.QueryRow(`SELECT 1 FROM calendar_tokens WHERE user_id = ?`, userID).Scan(&x); err == nil {
+		return "", false, nil
 	} else if err != sql.ErrNoRows {
-		return "", err
+		return "", false, err
 	}
 
-	// Mint a new 256-bit token
-	buf := make([]byte, 32)
-	if _, err := rand.Read(buf); err != nil {
-		return "", err
+	tok, err := newCalendarToken()
+	if err != nil {
+		return "", false, err
 	}
-	tok = hex.EncodeToString(buf)
-
-	// Try insert; if a concurrent insert won, read it back
-	if _, err := db.Exec(`INSERT INTO calendar_tokens(token, user_id) VALUES(?, ?)`, tok, userID); err != nil {
-		// SQLite unique constraint text is portable enough to check
-		if strings.Contains(strings.ToLower(err.Error()), "unique") {
-			if err2 := db.QueryRow(`SELECT token FROM calendar_tokens WHERE user_id = ?`, userID).Scan(&tok); err2 == nil {
-				return tok, nil
-			}
+
+	// Try insert; if a concurrent insert won, the user has a token now
+	if _, err := db.Exec(`INSERT INTO calendar_tokens(token, user_id) VALUES(?, ?)`, util.HashToken(tok), userID); err != nil {
+		if store.IsUniqueViolation(err) {
+			return "", false, nil
 		}
+		return "", false, err
+	}
+	return tok, true, nil
+}
+
+// CalendarTokenUser resolves a feed token to its user and records the access.
+// Returns sql.ErrNoRows for unknown tokens.
+func CalendarTokenUser(db *sql.DB, token string) (string, error) {
+	key := util.HashToken(token)
+	var userID string
+	if err := db.QueryRow(`SELECT user_id FROM calendar_tokens WHERE token = ?`, key).Scan(&userID); err != nil {
 		return "", err
 	}
-	return tok, nil
+	// Update last_used_at (best effort)
+	_, _ = db.Exec(`UPDATE calendar_tokens SET last_used_at = strftime('%s','now') WHERE token = ?`, key)
+	return userID, nil
 }
 
+// newCalendarToken mints a 256-bit hex token.
+func newCalendarToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
 
 // RotateCalendarToken replaces the user's token and returns the new one.
 func RotateCalendarToken(db *sql.DB, userID string) (string, error) {
-    // mint new 256-bit token
-    buf := make([]byte, 32)
-    if _, err := rand.Read(buf); err != nil { return "", err }
-    tok := hex.EncodeToString(buf)
+	tok, err := newCalendarToken()
+	if err != nil {
+		return "", err
+	}
+	key := util.HashToken(tok)
 
-    tx, err := db.Begin()
-    if err != nil { return "", err }
-    defer func() { _ = tx.Rollback() }()
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = tx.Rollback() }()
 
-    // try update first
-    res, err := tx.Exec(`
+	// try update first
+	res, err := tx.Exec(`
         UPDATE calendar_tokens
         SET token = ?, created_at = strftime('%s','now'), last_used_at = NULL
-        WHERE user_id = ?`, tok, userID)
-    if err != nil { return "", err }
-
-    n, _ := res.RowsAffected()
-    if n == 0 {
-        if _, err := tx.Exec(`INSERT INTO calendar_tokens(token, user_id) VALUES(?, ?)`, tok, userID); err != nil {
-            return "", err
-        }
-    }
-    if err := tx.Commit(); err != nil { return "", err }
-    return tok, nil
+        WHERE user_id = ?`, key, userID)
+	if err != nil {
+		return "", err
+	}
+
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		if _, err := tx.Exec(`INSERT INTO calendar_tokens(token, user_id) VALUES(?, ?)`, key, userID); err != nil {
+			return "", err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return tok, nil
 }
@@ -8,11 +8,12 @@ import (
 	"strings"
 
 	"example.com/sqlite-server/session"
+	"example.com/sqlite-server/term"
 	"example.com/sqlite-server/util"
 )
 
 // RegisterChapterRoutes wires chapter endpoints.
-func RegisterChapterRoutes(mux *http.ServeMux, db *sql.DB) {
+func RegisterChapterRoutes(mux util.Router, db *sql.DB) {
 	// Only one endpoint for set/clear deadline. Auth required.
 	mux.HandleFunc("/chapters/", session.RequireAuth(db, chaptersDispatcher(db)))
 }
@@ -23,12 +24,12 @@ func chaptersDispatcher(db *sql.DB) http.HandlerFunc {
 		path := strings.TrimPrefix(r.URL.Path, "/chapters/")
 		parts := strings.Split(path, "/")
 		if len(parts) != 2 || parts[0] == "" {
-			http.Error(w, "not found", http.StatusNotFound)
+			util.HTTPError(w, "not found", http.StatusNotFound)
 			return
 		}
 		id, err := strconv.ParseInt(parts[0], 10, 64)
 		if err != nil || id <= 0 {
-			http.Error(w, "invalid id", http.StatusBadRequest)
+			util.HTTPError(w, "invalid id", http.StatusBadRequest)
 			return
 		}
 
@@ -38,22 +39,21 @@ func chaptersDispatcher(db *sql.DB) http.HandlerFunc {
 			case http.MethodPatch:
 				patchChapterDeadlineHandler(db, id)(w, r)
 			default:
-				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
 			}
 		case "progress":
 			switch r.Method {
 			case http.MethodPatch:
 				patchChapterProgressHandler(db, id)(w, r)
 			default:
-				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
 			}
 		default:
-			http.Error(w, "not found", http.StatusNotFound)
+			util.HTTPError(w, "not found", http.StatusNotFound)
 		}
 	}
 }
 
-
 // PATCH /chapters/{id}/deadline
 // Body: { "deadline": number|null }  (unix seconds; null clears)
 // Auth: caller must be enrolled in the chapter's course.
@@ -66,7 +66,7 @@ func patchChapterDeadlineHandler(db *sql.DB, chapterID int64) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid, ok := session.UserIDFromCtx(r.Context())
 		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 
@@ -74,12 +74,12 @@ func patchChapterDeadlineHandler(db *sql.DB, chapterID int64) http.HandlerFunc {
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
 		if err := dec.Decode(&p); err != nil {
-			http.Error(w, "bad request", http.StatusBadRequest)
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
 			return
 		}
 		if p.Deadline != nil {
 			if *p.Deadline < 0 || *p.Deadline > maxDeadline {
-				http.Error(w, "invalid deadline", http.StatusBadRequest)
+				util.HTTPError(w, "invalid deadline", http.StatusBadRequest)
 				return
 			}
 		}
@@ -87,45 +87,57 @@ func patchChapterDeadlineHandler(db *sql.DB, chapterID int64) http.HandlerFunc {
 		// Ensure the chapter exists (for 404 semantics).
 		if _, err := ChapterUniversityID(db, chapterID); err != nil {
 			if err == sql.ErrNoRows {
-				http.Error(w, "not found", http.StatusNotFound)
+				util.HTTPError(w, "not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 
 		// Must be enrolled in the owning course.
 		canEdit, err := UserEnrolledInChapterCourse(db, uid, chapterID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		if !canEdit {
-			http.Error(w, "forbidden", http.StatusForbidden)
+			util.HTTPError(w, "forbidden", http.StatusForbidden)
 			return
 		}
 
+		// Deadlines must fall within the course's term, outside its breaks.
+		if p.Deadline != nil {
+			courseID, err := ChapterCourseID(db, chapterID)
+			if err != nil {
+				util.HTTPError(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+			if err := term.CheckDeadline(db, courseID, *p.Deadline); err != nil {
+				util.WriteError(w, err)
+				return
+			}
+		}
+
 		// Apply update.
 		if err := SetChapterDeadline(db, chapterID, p.Deadline); err != nil {
 			if err == sql.ErrNoRows {
-				http.Error(w, "not found", http.StatusNotFound)
+				util.HTTPError(w, "not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 
 		// Reload and return updated resource from the service layer.
 		c, err := GetChapter(db, chapterID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		util.WriteJSON(w, c, http.StatusOK)
 	}
 }
 
-
 func patchChapterProgressHandler(db *sql.DB, chapterID int64) http.HandlerFunc {
 	type payload struct {
 		Completed *bool `json:"completed"`
@@ -137,7 +149,7 @@ func patchChapterProgressHandler(db *sql.DB, chapterID int64) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid, ok := session.UserIDFromCtx(r.Context())
 		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 
@@ -145,26 +157,26 @@ func patchChapterProgressHandler(db *sql.DB, chapterID int64) http.HandlerFunc {
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
 		if err := dec.Decode(&p); err != nil || p.Completed == nil {
-			http.Error(w, "bad request", http.StatusBadRequest)
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
 			return
 		}
 
 		canEdit, err := UserEnrolledInChapterCourse(db, uid, chapterID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		if !canEdit {
-			http.Error(w, "forbidden", http.StatusForbidden)
+			util.HTTPError(w, "forbidden", http.StatusForbidden)
 			return
 		}
 
 		if err := SetChapterProgress(db, uid, chapterID, *p.Completed); err != nil {
 			if err == sql.ErrNoRows {
-				http.Error(w, "not found", http.StatusNotFound)
+				util.HTTPError(w, "not found", http.StatusNotFound)
 				return
 			}
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 
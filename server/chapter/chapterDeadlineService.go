@@ -2,14 +2,16 @@ package chapter
 
 import (
 	"database/sql"
-	"errors"
+
+	"example.com/sqlite-server/events"
+	"example.com/sqlite-server/util"
 )
 
 // ChapterBelongsToUniversity returns (true, nil) if the chapter exists and its course's
 // university_id matches uniID. If the chapter doesn't exist, returns (false, sql.ErrNoRows).
 func ChapterBelongsToUniversity(db *sql.DB, chapterID int64, uniID string) (bool, error) {
 	if chapterID <= 0 || uniID == "" {
-		return false, errors.New("invalid input")
+		return false, util.ErrInvalidInput
 	}
 	var got string
 	err := db.QueryRow(`
@@ -32,7 +34,7 @@ func ChapterBelongsToUniversity(db *sql.DB, chapterID int64, uniID string) (bool
 // If the chapter doesn't exist, returns sql.ErrNoRows.
 func ChapterUniversityID(db *sql.DB, chapterID int64) (string, error) {
 	if chapterID <= 0 {
-		return "", errors.New("invalid input")
+		return "", util.ErrInvalidInput
 	}
 	var uniID string
 	err := db.QueryRow(`
@@ -48,11 +50,24 @@ func ChapterUniversityID(db *sql.DB, chapterID int64) (string, error) {
 	return uniID, nil
 }
 
+// ChapterCourseID returns the id of the chapter's course.
+// If the chapter doesn't exist, returns sql.ErrNoRows.
+func ChapterCourseID(db *sql.DB, chapterID int64) (int64, error) {
+	var courseID int64
+	err := db.QueryRow(`
+		SELECT b.course_id
+		  FROM chapters ch
+		  JOIN books b ON b.id = ch.book_id
+		 WHERE ch.id = ?;
+	`, chapterID).Scan(&courseID)
+	return courseID, err
+}
+
 // UserEnrolledInChapterCourse returns true if the given user has an enrollment
 // (user_courses) in the course to which the chapter belongs.
 func UserEnrolledInChapterCourse(db *sql.DB, userID string, chapterID int64) (bool, error) {
 	if userID == "" || chapterID <= 0 {
-		return false, errors.New("invalid input")
+		return false, util.ErrInvalidInput
 	}
 	var exists int
 	err := db.QueryRow(`
@@ -77,7 +92,7 @@ func UserEnrolledInChapterCourse(db *sql.DB, userID string, chapterID int64) (bo
 // or clears it when deadline == nil. Returns sql.ErrNoRows if chapter not found.
 func SetChapterDeadline(db *sql.DB, chapterID int64, deadline *int64) error {
 	if chapterID <= 0 {
-		return errors.New("invalid input")
+		return util.ErrInvalidInput
 	}
 	res, err := db.Exec(`UPDATE chapters SET deadline = ? WHERE id = ?;`, deadline, chapterID)
 	if err != nil {
@@ -87,5 +102,10 @@ func SetChapterDeadline(db *sql.DB, chapterID int64, deadline *int64) error {
 	if n == 0 {
 		return sql.ErrNoRows
 	}
+	courseID, err := ChapterCourseID(db, chapterID)
+	if err != nil {
+		return err
+	}
+	events.Publish(events.Event{Type: events.TypeDeadline, Kind: events.KindChapter, ItemID: chapterID, CourseID: courseID, Deadline: deadline})
 	return nil
 }
@@ -2,45 +2,58 @@ package chapter
 
 import (
 	"database/sql"
-	"errors"
+
+	"example.com/sqlite-server/events"
+	"example.com/sqlite-server/util"
 )
 
 // SetChapterProgress sets (completed=true) or clears (completed=false) the current user's
 // progress row for a chapter. Returns sql.ErrNoRows if the chapter doesn't exist.
 func SetChapterProgress(db *sql.DB, userID string, chapterID int64, completed bool) error {
 	if userID == "" || chapterID <= 0 {
-		return errors.New("invalid input")
+		return util.ErrInvalidInput
 	}
 
 	// Ensure chapter exists (404 semantics).
-	var exists int64
-	if err := db.QueryRow(`SELECT id FROM chapters WHERE id = ?`, chapterID).Scan(&exists); err != nil {
+	var courseID int64
+	if err := db.QueryRow(`
+		SELECT b.course_id
+		  FROM chapters ch
+		  JOIN books b ON b.id = ch.book_id
+		 WHERE ch.id = ?
+	`, chapterID).Scan(&courseID); err != nil {
 		return err // may be sql.ErrNoRows
 	}
 
+	var err error
 	if completed {
 		// Upsert: either create or set completed=1
-		_, err := db.Exec(`
+		_, err = db.Exec(`
 			INSERT INTO progress (user_id, chapter_id, completed)
 			VALUES (?, ?, 1)
 			ON CONFLICT(user_id, chapter_id) DO UPDATE SET completed = 1
 		`, userID, chapterID)
+	} else {
+		// Not completed → delete row (your stated preference)
+		_, err = db.Exec(`
+			DELETE FROM progress
+			 WHERE user_id = ? AND chapter_id IS NOT NULL AND chapter_id = ?
+		`, userID, chapterID)
+	}
+	if err != nil {
 		return err
 	}
-
-	// Not completed → delete row (your stated preference)
-	_, err := db.Exec(`
-		DELETE FROM progress
-		 WHERE user_id = ? AND chapter_id IS NOT NULL AND chapter_id = ?
-	`, userID, chapterID)
-	return err
+	events.Publish(events.Event{
+		Type: events.TypeProgress, Kind: events.KindChapter, ItemID: chapterID, CourseID: courseID,
+		Completed: &completed, UserID: userID,
+	})
+	return nil
 }
 
-
 // ChapterCompleted returns whether the current user has a progress row for this chapter.
 func ChapterCompleted(db *sql.DB, userID string, chapterID int64) (bool, error) {
 	if userID == "" || chapterID <= 0 {
-		return false, errors.New("invalid input")
+		return false, util.ErrInvalidInput
 	}
 	var cnt int64
 	if err := db.QueryRow(`
@@ -2,21 +2,22 @@ package chapter
 
 import (
 	"database/sql"
-	"errors"
 	"strings"
+
+	"example.com/sqlite-server/util"
 )
 
 type Chapter struct {
-	ID         int64   `json:"id"`
-	BookID     int64   `json:"bookId"`
-	ChapterNum int64   `json:"chapter_num"`
-	Deadline   *int64  `json:"deadline,omitempty"`
+	ID         int64  `json:"id"`
+	BookID     int64  `json:"bookId"`
+	ChapterNum int64  `json:"chapter_num"`
+	Deadline   *int64 `json:"deadline,omitempty"`
 }
 
 // CreateChaptersRangeTx inserts chapters 1..n for a book, inside the provided transaction.
 func CreateChaptersRangeTx(tx *sql.Tx, bookID int64, n int64) error {
 	if bookID <= 0 || n <= 0 {
-		return errors.New("invalid input")
+		return util.ErrInvalidInput
 	}
 	stmt, err := tx.Prepare(`INSERT INTO chapters (book_id, chapter_num) VALUES (?, ?)`)
 	if err != nil {
@@ -36,7 +37,7 @@ func CreateChaptersRangeTx(tx *sql.Tx, bookID int64, n int64) error {
 // deadline == nil clears it; otherwise sets to the provided unix seconds.
 func UpdateDeadline(db *sql.DB, chapterID int64, deadline *int64) error {
 	if chapterID <= 0 {
-		return errors.New("invalid input")
+		return util.ErrInvalidInput
 	}
 	res, err := db.Exec(`UPDATE chapters SET deadline = ? WHERE id = ?`, deadline, chapterID)
 	if err != nil {
@@ -143,7 +144,6 @@ func GetChapter(db *sql.DB, id int64) (Chapter, error) {
 	return c, nil
 }
 
-
 type ChapterWithStatus struct {
 	ID         int64  `json:"id"`
 	BookID     int64  `json:"bookId"`
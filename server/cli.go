@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"example.com/sqlite-server/admin"
+	"example.com/sqlite-server/auth"
+	"example.com/sqlite-server/digest"
+	"example.com/sqlite-server/mailer"
+	"example.com/sqlite-server/push"
+)
+
+const usage = `usage: server [command]
+
+commands:
+  serve [flags]                  run the HTTP server (default; serve -h lists
+                                 the flags and their environment variables)
+  migrate                        apply pending schema migrations and exit
+  admin grant <email>            make a user an admin
+  admin revoke <email>           remove a user's admin rights
+  admin list                     list admins
+  user reset-password <email>    set a new password (random, printed once)
+      [--stdin]                  read the new password from stdin instead
+  digest send <email>            email a user their deadline digest now
+  digest run                     send the digests that are due
+  push keygen                    print a new VAPID_PRIVATE_KEY
+  push run                       send the push notifications that are due
+  openapi                        print the OpenAPI document
+  openapi client [file]          generate the apiclient operations
+
+All other commands use DB_PATH (default data.db), from the environment or
+the CONFIG_FILE. Digests are sent with the mailer configured by MAIL_DIR or
+SMTP_ADDR; push notifications need VAPID_PRIVATE_KEY.
+`
+
+// runCommand executes an operator subcommand and returns the process exit code.
+func runCommand(args []string) int {
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Print(usage)
+		return 0
+	}
+	if args[0] == "openapi" {
+		return cmdOpenAPI(args[1:])
+	}
+	if len(args) == 2 && args[0] == "push" && args[1] == "keygen" {
+		key, err := push.GenerateVAPID()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("VAPID_PRIVATE_KEY=%s\n", key)
+		return 0
+	}
+
+	var run func(db *sql.DB, args []string) error
+	switch args[0] {
+	case "migrate":
+		run = cmdMigrate
+	case "admin":
+		run = cmdAdmin
+	case "user":
+		run = cmdUser
+	case "digest":
+		run = cmdDigest
+	case "push":
+		run = cmdPush
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	if err := run(db, args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return 1
+	}
+	return 0
+}
+
+// openDatabase already applied anything pending.
+func cmdMigrate(db *sql.DB, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("migrate takes no arguments")
+	}
+	fmt.Println("database is up to date")
+	return nil
+}
+
+func cmdAdmin(db *sql.DB, args []string) error {
+	if len(args) == 1 && args[0] == "list" {
+		rows, err := db.Query(`
+			SELECT u.email, u.id FROM admins a JOIN users u ON u.id = a.user_id ORDER BY u.email
+		`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var email, id string
+			if err := rows.Scan(&email, &id); err != nil {
+				return err
+			}
+			fmt.Printf("%s\t%s\n", email, id)
+		}
+		return rows.Err()
+	}
+
+	if len(args) != 2 || (args[0] != "grant" && args[0] != "revoke") {
+		return fmt.Errorf("usage: admin grant|revoke <email>, admin list")
+	}
+	u, err := userByEmail(db, args[1])
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "grant" {
+		created, err := admin.GrantAdmin(db, u.ID)
+		if err != nil {
+			return err
+		}
+		if !created {
+			fmt.Printf("%s is already an admin\n", u.Email)
+			return nil
+		}
+		fmt.Printf("%s is now an admin\n", u.Email)
+		return nil
+	}
+
+	switch err := admin.RevokeAdmin(db, u.ID); {
+	case err == sql.ErrNoRows:
+		return fmt.Errorf("%s is not an admin", u.Email)
+	case errors.Is(err, admin.ErrLastAdmin):
+		return fmt.Errorf("%s is the last admin; grant someone else first", u.Email)
+	case err != nil:
+		return err
+	}
+	fmt.Printf("%s is no longer an admin\n", u.Email)
+	return nil
+}
+
+func cmdUser(db *sql.DB, args []string) error {
+	if len(args) < 2 || args[0] != "reset-password" {
+		return fmt.Errorf("usage: user reset-password <email> [--stdin]")
+	}
+	fromStdin := len(args) == 3 && args[2] == "--stdin"
+	if len(args) > 3 || (len(args) == 3 && !fromStdin) {
+		return fmt.Errorf("usage: user reset-password <email> [--stdin]")
+	}
+
+	u, err := userByEmail(db, args[1])
+	if err != nil {
+		return err
+	}
+
+	var password string
+	if fromStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		password = strings.TrimRight(line, "\r\n")
+		if len(password) < 8 {
+			return fmt.Errorf("password too short (min 8)")
+		}
+	} else {
+		buf := make([]byte, 12)
+		if _, err := rand.Read(buf); err != nil {
+			return err
+		}
+		password = base64.RawURLEncoding.EncodeToString(buf)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := auth.SetPassword(db, u.ID, string(hash)); err != nil {
+		return err
+	}
+
+	if fromStdin {
+		fmt.Printf("password for %s updated; all sessions signed out\n", u.Email)
+	} else {
+		fmt.Printf("new password for %s: %s\n(all sessions signed out)\n", u.Email, password)
+	}
+	return nil
+}
+
+func cmdDigest(db *sql.DB, args []string) error {
+	if mailer.Default == nil {
+		return fmt.Errorf("no mailer configured; set MAIL_DIR or SMTP_ADDR")
+	}
+	switch {
+	case len(args) == 1 && args[0] == "run":
+		n, err := digest.SendDue(db, mailer.Default, time.Now())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("sent %d digests\n", n)
+		return nil
+	case len(args) == 2 && args[0] == "send":
+		u, err := userByEmail(db, args[1])
+		if err != nil {
+			return err
+		}
+		sent, err := digest.SendNow(db, mailer.Default, u.ID, time.Now())
+		if err != nil {
+			return err
+		}
+		if !sent {
+			fmt.Printf("%s has nothing due or overdue; no email sent\n", u.Email)
+			return nil
+		}
+		fmt.Printf("digest sent to %s\n", u.Email)
+		return nil
+	default:
+		return fmt.Errorf("usage: digest send <email>, digest run")
+	}
+}
+
+func cmdPush(db *sql.DB, args []string) error {
+	if len(args) != 1 || args[0] != "run" {
+		return fmt.Errorf("usage: push keygen, push run")
+	}
+	if push.Default == nil {
+		return fmt.Errorf("push notifications are off; set VAPID_PRIVATE_KEY (see push keygen)")
+	}
+	n, err := push.SendDue(context.Background(), db, push.Default, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("sent %d push notifications\n", n)
+	return nil
+}
+
+// cmdOpenAPI prints the document, or generates the client from it. It needs
+// no database, so runCommand calls it before opening DB_PATH. The document is
+// checked against the handlers by TestOpenAPIContract.
+func cmdOpenAPI(args []string) int {
+	switch {
+	case len(args) == 0:
+		_, _ = os.Stdout.Write(apiSpec().JSON())
+		return 0
+	case len(args) >= 1 && args[0] == "client":
+		return cmdOpenAPIClient(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "usage: server openapi [client [file]]\n")
+		return 2
+	}
+}
+
+// cmdOpenAPIClient generates the operations of the apiclient package, to
+// stdout or the named file (go generate passes operations.go).
+func cmdOpenAPIClient(args []string) int {
+	if len(args) > 1 {
+		fmt.Fprintf(os.Stderr, "usage: server openapi client [file]\n")
+		return 2
+	}
+	src, err := apiSpec().GoClient("apiclient", "`server openapi client`")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return 1
+	}
+	if len(args) == 0 {
+		_, _ = os.Stdout.Write(src)
+		return 0
+	}
+	if err := os.WriteFile(args[0], src, 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return 1
+	}
+	return 0
+}
+
+func userByEmail(db *sql.DB, email string) (auth.User, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	u, err := auth.GetUserByEmail(db, email)
+	if err == sql.ErrNoRows {
+		return u, fmt.Errorf("no user with email %s", email)
+	}
+	return u, err
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"example.com/sqlite-server/store"
+)
+
+func TestAdminAndUserCommands(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "cli.db")
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DB_PATH", dbPath)
+
+	db, err := store.Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`INSERT INTO users (id, email, password) VALUES ('u1', 'a@x.io', 'old'), ('u2', 'b@x.io', 'old')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO sessions (id, user_id, expires_at) VALUES ('s1', 'u1', strftime('%s','now') + 3600)`); err != nil {
+		t.Fatal(err)
+	}
+	admins := func() int {
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM admins`).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	for _, c := range []struct {
+		args   []string
+		code   int
+		admins int
+	}{
+		{[]string{"admin", "grant", " A@X.io "}, 0, 1},
+		{[]string{"admin", "grant", "a@x.io"}, 0, 1}, // already one
+		{[]string{"admin", "list"}, 0, 1},
+		{[]string{"admin", "revoke", "a@x.io"}, 1, 1}, // the last admin stays
+		{[]string{"admin", "grant", "nobody@x.io"}, 1, 1},
+		{[]string{"admin", "grant", "b@x.io"}, 0, 2},
+		{[]string{"admin", "revoke", "a@x.io"}, 0, 1},
+		{[]string{"admin", "promote", "a@x.io"}, 1, 1},
+		{[]string{"frobnicate"}, 2, 1},
+	} {
+		if code := runCommand(c.args); code != c.code {
+			t.Errorf("%q exited %d, want %d", c.args, code, c.code)
+		}
+		if n := admins(); n != c.admins {
+			t.Errorf("after %q: %d admins, want %d", c.args, n, c.admins)
+		}
+	}
+
+	// reset-password --stdin sets the given password and signs the user out.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString("correct horse\n")
+	w.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	code := runCommand([]string{"user", "reset-password", "a@x.io", "--stdin"})
+	os.Stdin = stdin
+	if code != 0 {
+		t.Fatalf("reset-password exited %d", code)
+	}
+	var hash string
+	var sessions int
+	if err := db.QueryRow(`SELECT password, (SELECT COUNT(*) FROM sessions WHERE user_id = 'u1') FROM users WHERE id = 'u1'`).Scan(&hash, &sessions); err != nil {
+		t.Fatal(err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte("correct horse")) != nil {
+		t.Error("password was not set")
+	}
+	if sessions != 0 {
+		t.Errorf("%d sessions survived the reset", sessions)
+	}
+}
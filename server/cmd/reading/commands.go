@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/sqlite-server/apiclient"
+)
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// prompt asks for one line on stderr and reads it from stdin.
+func prompt(label string) (string, error) {
+	fmt.Fprint(os.Stderr, label)
+	line, err := stdin.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func cmdLogin(ctx context.Context, args []string) error {
+	fset := flag.NewFlagSet("login", flag.ContinueOnError)
+	fset.SetOutput(io.Discard)
+	url := fset.String("url", "", "API base URL")
+	token := fset.String("token", "", "personal access token")
+	if err := fset.Parse(args); err != nil {
+		return usageError("login: " + err.Error())
+	}
+	if fset.NArg() > 1 {
+		return usageError("login takes at most one email")
+	}
+
+	cfg := &config{URL: *url}
+	if prev, err := loadConfig(); err == nil && cfg.URL == "" {
+		cfg.URL = prev.URL
+	}
+	if cfg.URL == "" {
+		cfg.URL = defaultURL
+	}
+
+	if *token != "" {
+		cfg.Token = *token
+		me, err := cfg.client().GetMe(ctx)
+		if err != nil {
+			return err
+		}
+		cfg.Email = me.Email
+		fmt.Printf("using a token for %s at %s\n", me.Email, cfg.URL)
+		return cfg.save()
+	}
+
+	email := fset.Arg(0)
+	var err error
+	if email == "" {
+		if email, err = prompt("Email: "); err != nil {
+			return err
+		}
+	}
+	password := os.Getenv("READING_PASSWORD")
+	if password == "" {
+		if password, err = prompt("Password: "); err != nil {
+			return err
+		}
+	}
+
+	c := cfg.client()
+	res, err := c.Login(ctx, apiclient.LoginBody{Email: email, Password: password, Remember: true})
+	if err != nil {
+		return err
+	}
+	if res.MFARequired {
+		code, err := prompt("Two-factor code (or a recovery code): ")
+		if err != nil {
+			return err
+		}
+		if _, err := c.LoginTOTP(ctx, apiclient.LoginTOTPBody{MFAToken: res.MFAToken, Code: code}); err != nil {
+			return err
+		}
+	}
+	cfg.Email = email
+	cfg.setCookies(c.Cookies())
+	if err := cfg.save(); err != nil {
+		return err
+	}
+	fmt.Printf("signed in as %s at %s\n", email, cfg.URL)
+	return nil
+}
+
+func cmdLogout(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return usageError("logout takes no arguments")
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.Token == "" {
+		// The saved cookies are forgotten either way.
+		if err := cfg.client().Logout(ctx); err != nil {
+			fmt.Fprintln(os.Stderr, "warning: server sign-out failed:", err)
+		}
+	}
+	return removeConfig()
+}
+
+func cmdWhoami(ctx context.Context, c *apiclient.Client, args []string) error {
+	if len(args) > 0 {
+		return usageError("whoami takes no arguments")
+	}
+	me, err := c.GetMe(ctx)
+	if err != nil {
+		return err
+	}
+	cfg, _ := loadConfig()
+	how := "session"
+	if cfg.Token != "" {
+		how = "access token"
+	}
+	fmt.Printf("%s at %s (%s)\n", me.Email, cfg.URL, how)
+	return nil
+}
+
+// parseItem reads "<kind> <id>" from the front of args.
+func parseItem(args []string) (string, int64, error) {
+	if len(args) < 2 {
+		return "", 0, usageError("expected <kind> <id>")
+	}
+	kind := args[0]
+	switch kind {
+	case "chapter", "article", "assignment":
+	default:
+		return "", 0, usageError(fmt.Sprintf("unknown kind %q (chapter, article or assignment)", kind))
+	}
+	id, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || id <= 0 {
+		return "", 0, usageError(fmt.Sprintf("invalid id %q", args[1]))
+	}
+	return kind, id, nil
+}
+
+func progress(completed bool) func(context.Context, *apiclient.Client, []string) error {
+	return func(ctx context.Context, c *apiclient.Client, args []string) error {
+		kind, id, err := parseItem(args)
+		if err != nil {
+			return err
+		}
+		if len(args) != 2 {
+			return usageError("expected <kind> <id>")
+		}
+		body := apiclient.ProgressBody{Completed: completed}
+		switch kind {
+		case "chapter":
+			_, err = c.SetChapterProgress(ctx, id, body)
+		case "article":
+			_, err = c.SetArticleProgress(ctx, id, body)
+		case "assignment":
+			_, err = c.SetAssignmentProgress(ctx, id, body)
+		}
+		if err != nil {
+			return err
+		}
+		state := "done"
+		if !completed {
+			state = "not done"
+		}
+		fmt.Printf("%s %d: %s\n", kind, id, state)
+		return nil
+	}
+}
+
+// deadlineLayouts are the accepted deadline formats, in local time. A bare
+// date means the end of that day.
+var deadlineLayouts = []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"}
+
+func parseDeadline(s string) (*int64, error) {
+	if s == "none" {
+		return nil, nil
+	}
+	for _, layout := range deadlineLayouts {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		if err != nil {
+			continue
+		}
+		if layout == "2006-01-02" {
+			t = t.Add(24*time.Hour - time.Minute)
+		}
+		epoch := t.Unix()
+		return &epoch, nil
+	}
+	return nil, usageError(fmt.Sprintf("invalid deadline %q (want 2026-11-16, \"2026-11-16 17:00\" or none)", s))
+}
+
+func cmdDeadline(ctx context.Context, c *apiclient.Client, args []string) error {
+	kind, id, err := parseItem(args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 3 {
+		return usageError("expected <kind> <id> <when|none>")
+	}
+	deadline, err := parseDeadline(strings.Join(args[2:], " "))
+	if err != nil {
+		return err
+	}
+	body := apiclient.DeadlineBody{Deadline: deadline}
+	switch kind {
+	case "chapter":
+		_, err = c.SetChapterDeadline(ctx, id, body)
+	case "article":
+		_, err = c.SetArticleDeadline(ctx, id, body)
+	case "assignment":
+		_, err = c.SetAssignmentDeadline(ctx, id, body)
+	}
+	if e := (*apiclient.Error)(nil); errors.As(err, &e) && strings.Contains(e.Detail, "token scope") {
+		return errors.New("setting deadlines needs a signed-in session, not an access token; run: reading login")
+	}
+	if err != nil {
+		return err
+	}
+	if deadline == nil {
+		fmt.Printf("%s %d: deadline cleared\n", kind, id)
+	} else {
+		fmt.Printf("%s %d: due %s\n", kind, id, formatTime(*deadline))
+	}
+	return nil
+}
+
+func formatTime(epoch int64) string {
+	return time.Unix(epoch, 0).Format("Mon 2 Jan 2006 15:04")
+}
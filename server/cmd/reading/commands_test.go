@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseItem(t *testing.T) {
+	tests := []struct {
+		args []string
+		kind string
+		id   int64
+		ok   bool
+	}{
+		{[]string{"chapter", "12"}, "chapter", 12, true},
+		{[]string{"assignment", "3", "extra"}, "assignment", 3, true},
+		{[]string{"chapter"}, "", 0, false},
+		{[]string{"book", "1"}, "", 0, false},
+		{[]string{"article", "0"}, "", 0, false},
+		{[]string{"article", "x"}, "", 0, false},
+	}
+	for _, tt := range tests {
+		kind, id, err := parseItem(tt.args)
+		if !tt.ok {
+			var u usageError
+			if !errors.As(err, &u) {
+				t.Errorf("parseItem(%q): err = %v, want a usage error", tt.args, err)
+			}
+			continue
+		}
+		if err != nil || kind != tt.kind || id != tt.id {
+			t.Errorf("parseItem(%q) = %q, %d, %v; want %q, %d", tt.args, kind, id, err, tt.kind, tt.id)
+		}
+	}
+}
+
+func TestParseDeadline(t *testing.T) {
+	local := func(s string) int64 {
+		tm, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm.Unix()
+	}
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"2026-11-16 17:00", local("2026-11-16 17:00")},
+		{"2026-11-16T17:00", local("2026-11-16 17:00")},
+		{"2026-11-16", local("2026-11-16 23:59")},
+	}
+	for _, tt := range tests {
+		got, err := parseDeadline(tt.in)
+		if err != nil || got == nil || *got != tt.want {
+			t.Errorf("parseDeadline(%q) = %v, %v; want %d", tt.in, got, err, tt.want)
+		}
+	}
+
+	if got, err := parseDeadline("none"); got != nil || err != nil {
+		t.Errorf("parseDeadline(none) = %v, %v; want nil, nil", got, err)
+	}
+	var u usageError
+	if _, err := parseDeadline("next friday"); !errors.As(err, &u) {
+		t.Errorf("parseDeadline(next friday): err = %v, want a usage error", err)
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	t.Setenv("READING_CONFIG", t.TempDir()+"/config.json")
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{nil, 0},
+		{[]string{"help"}, 0},
+		{[]string{"frobnicate"}, 2},
+		{[]string{"whoami"}, 1},
+	}
+	for _, tt := range tests {
+		if got := run(tt.args); got != tt.want {
+			t.Errorf("run(%q) = %d, want %d", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"example.com/sqlite-server/apiclient"
+)
+
+const defaultURL = "http://localhost:8080/api"
+
+// config is what login saves: where the API is and how to authenticate,
+// either a personal access token or the session cookies.
+type config struct {
+	URL     string        `json:"url"`
+	Email   string        `json:"email,omitempty"`
+	Token   string        `json:"token,omitempty"`
+	Cookies []savedCookie `json:"cookies,omitempty"`
+}
+
+type savedCookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func configPath() (string, error) {
+	if p := os.Getenv("READING_CONFIG"); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "reading", "config.json"), nil
+}
+
+func loadConfig() (*config, error) {
+	p, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errors.New("not signed in; run: reading login")
+	}
+	if err != nil {
+		return nil, err
+	}
+	cfg := &config{}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %v", p, err)
+	}
+	if cfg.URL == "" {
+		cfg.URL = defaultURL
+	}
+	return cfg, nil
+}
+
+// save writes the config readable only by the user: it holds credentials.
+func (cfg *config) save() error {
+	p, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p, append(b, '\n'), 0o600)
+}
+
+func removeConfig() error {
+	p, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (cfg *config) client() *apiclient.Client {
+	if cfg.Token != "" {
+		return apiclient.New(cfg.URL, apiclient.WithToken(cfg.Token))
+	}
+	cookies := make([]*http.Cookie, 0, len(cfg.Cookies))
+	for _, c := range cfg.Cookies {
+		cookies = append(cookies, &http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	return apiclient.New(cfg.URL, apiclient.WithCookies(cookies))
+}
+
+func (cfg *config) setCookies(cookies []*http.Cookie) {
+	cfg.Cookies = cfg.Cookies[:0]
+	for _, c := range cookies {
+		cfg.Cookies = append(cfg.Cookies, savedCookie{Name: c.Name, Value: c.Value})
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"example.com/sqlite-server/apiclient"
+	"example.com/sqlite-server/article"
+	"example.com/sqlite-server/assignment"
+	"example.com/sqlite-server/book"
+	"example.com/sqlite-server/util"
+)
+
+// item is a chapter, article or assignment in one of my courses.
+type item struct {
+	kind      string
+	id        int64
+	course    string
+	title     string
+	deadline  *int64
+	completed bool
+}
+
+// allPages follows nextCursor until the list is exhausted.
+func allPages[T any](fetch func(cursor string) (*util.Page[T], error)) ([]T, error) {
+	var all []T
+	cursor := ""
+	for {
+		page, err := fetch(cursor)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page.Items...)
+		if page.NextCursor == "" {
+			return all, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// myItems lists the materials of every course I'm enrolled in (archived
+// courses excluded), with my progress.
+func myItems(ctx context.Context, c *apiclient.Client) ([]item, error) {
+	unis, err := c.ListMemberships(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var items []item
+	for _, u := range unis {
+		courses, err := c.ListMyCourses(ctx, apiclient.ListMyCoursesParams{UniversityID: u.UniversityID})
+		if err != nil {
+			return nil, err
+		}
+		for _, co := range courses {
+			books, err := allPages(func(cursor string) (*util.Page[book.Book], error) {
+				return c.ListBooks(ctx, apiclient.ListBooksParams{CourseID: co.ID, Cursor: cursor, Limit: 200})
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, b := range books {
+				for _, ch := range b.Chapters {
+					items = append(items, item{"chapter", ch.ID, co.Code, fmt.Sprintf("%s, ch. %d", b.Title, ch.ChapterNum), ch.Deadline, ch.Completed})
+				}
+			}
+
+			articles, err := allPages(func(cursor string) (*util.Page[article.ArticleWithStatus], error) {
+				return c.ListArticles(ctx, apiclient.ListArticlesParams{CourseID: co.ID, Cursor: cursor, Limit: 200})
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, a := range articles {
+				items = append(items, item{"article", a.ID, co.Code, a.Title, a.Deadline, a.Completed})
+			}
+
+			assignments, err := allPages(func(cursor string) (*util.Page[assignment.AssignmentWithStatus], error) {
+				return c.ListAssignments(ctx, apiclient.ListAssignmentsParams{CourseID: co.ID, Cursor: cursor, Limit: 200})
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, a := range assignments {
+				items = append(items, item{"assignment", a.ID, co.Code, a.Title, a.Deadline, a.Completed})
+			}
+		}
+	}
+	return items, nil
+}
+
+func cmdDue(ctx context.Context, c *apiclient.Client, args []string) error {
+	fset := flag.NewFlagSet("due", flag.ContinueOnError)
+	fset.SetOutput(io.Discard)
+	days := fset.Int("days", 14, "look this many days ahead")
+	all := fset.Bool("all", false, "include completed items")
+	if err := fset.Parse(args); err != nil || fset.NArg() > 0 || *days < 0 {
+		return usageError("usage: reading due [-days N] [-all]")
+	}
+
+	items, err := myItems(ctx, c)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	until := now.AddDate(0, 0, *days).Unix()
+	var due []item
+	for _, it := range items {
+		if it.deadline == nil || *it.deadline > until || (it.completed && !*all) {
+			continue
+		}
+		due = append(due, it)
+	}
+	sort.SliceStable(due, func(i, j int) bool { return *due[i].deadline < *due[j].deadline })
+
+	if len(due) == 0 {
+		fmt.Printf("nothing due in the next %d days\n", *days)
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, it := range due {
+		state := ""
+		switch {
+		case it.completed:
+			state = "done"
+		case *it.deadline < now.Unix():
+			state = "OVERDUE"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s %d\t%s\t%s\n", state, formatTime(*it.deadline), it.kind, it.id, it.course, it.title)
+	}
+	return tw.Flush()
+}
+
+// event is a VEVENT from the calendar feed.
+type event struct {
+	end       time.Time
+	summary   string
+	cancelled bool
+}
+
+func cmdCalendar(ctx context.Context, c *apiclient.Client, args []string) error {
+	fset := flag.NewFlagSet("calendar", flag.ContinueOnError)
+	fset.SetOutput(io.Discard)
+	days := fset.Int("days", 7, "look this many days ahead")
+	if err := fset.Parse(args); err != nil || fset.NArg() > 0 || *days < 0 {
+		return usageError("usage: reading calendar [-days N]")
+	}
+
+	ics, err := c.GetCalendar(ctx)
+	if err != nil {
+		return err
+	}
+	events := parseICS(ics)
+	now := time.Now()
+	until := now.AddDate(0, 0, *days)
+
+	day := ""
+	shown := 0
+	for _, e := range events {
+		if e.cancelled || e.end.Before(now) || e.end.After(until) {
+			continue
+		}
+		local := e.end.Local()
+		if d := local.Format("Monday 2 January"); d != day {
+			if day != "" {
+				fmt.Println()
+			}
+			fmt.Println(d)
+			day = d
+		}
+		fmt.Printf("  %s  %s\n", local.Format("15:04"), e.summary)
+		shown++
+	}
+	if shown == 0 {
+		fmt.Printf("no deadlines in the next %d days\n", *days)
+	}
+	return nil
+}
+
+// parseICS reads the events of the server's feed, sorted by deadline (the
+// event's end). It understands only what BuildICS writes.
+func parseICS(data []byte) []event {
+	var events []event
+	var cur *event
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		name, value, _ := strings.Cut(strings.TrimRight(sc.Text(), "\r"), ":")
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			cur = &event{}
+		case name == "END" && value == "VEVENT" && cur != nil:
+			events = append(events, *cur)
+			cur = nil
+		case cur == nil:
+		case name == "DTEND":
+			cur.end, _ = time.Parse("20060102T150405Z", value)
+		case name == "SUMMARY":
+			cur.summary = unescapeICS(value)
+		case name == "STATUS":
+			cur.cancelled = value == "CANCELLED"
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].end.Before(events[j].end) })
+	return events
+}
+
+var icsUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, `;`, `\,`, `,`, `\n`, "\n")
+
+func unescapeICS(s string) string { return icsUnescaper.Replace(s) }
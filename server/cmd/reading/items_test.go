@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"example.com/sqlite-server/util"
+)
+
+func TestAllPages(t *testing.T) {
+	pages := map[string]*util.Page[int]{
+		"":  {Items: []int{1, 2}, NextCursor: "a"},
+		"a": {Items: []int{3}, NextCursor: "b"},
+		"b": {Items: []int{4}},
+	}
+	var cursors []string
+	got, err := allPages(func(cursor string) (*util.Page[int], error) {
+		cursors = append(cursors, cursor)
+		return pages[cursor], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 2, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("items = %v, want %v", got, want)
+	}
+	if want := []string{"", "a", "b"}; !reflect.DeepEqual(cursors, want) {
+		t.Errorf("cursors = %q, want %q", cursors, want)
+	}
+
+	boom := errors.New("boom")
+	if _, err := allPages(func(string) (*util.Page[int], error) { return nil, boom }); err != boom {
+		t.Errorf("err = %v, want %v", err, boom)
+	}
+}
+
+func TestParseICS(t *testing.T) {
+	data := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"SUMMARY:Essay\\, draft\\; part 2\r\n" +
+		"DTEND:20261120T170000Z\r\n" +
+		"STATUS:CANCELLED\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"SUMMARY:Chapter 1\\\\2\r\n" +
+		"DTEND:20261116T090000Z\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	want := []event{
+		{end: time.Date(2026, 11, 16, 9, 0, 0, 0, time.UTC), summary: `Chapter 1\2`},
+		{end: time.Date(2026, 11, 20, 17, 0, 0, 0, time.UTC), summary: "Essay, draft; part 2", cancelled: true},
+	}
+	if got := parseICS([]byte(data)); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseICS = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+// Command reading tracks reading progress from the terminal, talking to the
+// server's HTTP API through apiclient. `reading help` lists the commands.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"example.com/sqlite-server/apiclient"
+)
+
+const usage = `usage: reading <command> [arguments]
+
+commands:
+  login [-url URL] [email]            sign in (password from READING_PASSWORD or stdin)
+  login [-url URL] -token TOKEN       use a personal access token instead
+                                      (read-only plus progress; no deadlines)
+  logout                              sign out and forget the saved credentials
+  whoami                              show who is signed in, and where
+  due [-days N] [-all]                open items due within N days (default 14) and
+                                      overdue ones; -all includes completed items
+  done <kind> <id>                    mark a chapter, article or assignment complete
+  undo <kind> <id>                    mark it not complete
+  deadline <kind> <id> <when|none>    set a deadline ("2026-11-16" or "2026-11-16 17:00",
+                                      local time) or clear it
+  calendar [-days N]                  deadlines in the next N days (default 7)
+
+<kind> is chapter, article or assignment; ids are shown by "due".
+Credentials are kept in $READING_CONFIG (default: reading/config.json in the
+user config directory), readable only by you.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Print(usage)
+		return 0
+	}
+
+	var cmd func(ctx context.Context, args []string) error
+	switch args[0] {
+	case "login":
+		cmd = cmdLogin
+	case "logout":
+		cmd = cmdLogout
+	case "whoami":
+		cmd = signedIn(cmdWhoami)
+	case "due":
+		cmd = signedIn(cmdDue)
+	case "done":
+		cmd = signedIn(progress(true))
+	case "undo":
+		cmd = signedIn(progress(false))
+	case "deadline":
+		cmd = signedIn(cmdDeadline)
+	case "calendar":
+		cmd = signedIn(cmdCalendar)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	if err := cmd(context.Background(), args[1:]); err != nil {
+		var u usageError
+		if errors.As(err, &u) {
+			fmt.Fprintf(os.Stderr, "%v\n\n%s", err, usage)
+			return 2
+		}
+		if apiclient.IsCode(err, "unauthorized") {
+			err = errors.New("not signed in (or the session expired); run: reading login")
+		}
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return 1
+	}
+	return 0
+}
+
+// usageError is a bad command line, reported with the usage text.
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+// signedIn runs cmd with a client for the saved credentials and saves the
+// session cookie again afterwards, since the server may refresh it.
+func signedIn(cmd func(ctx context.Context, c *apiclient.Client, args []string) error) func(context.Context, []string) error {
+	return func(ctx context.Context, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		c := cfg.client()
+		if err := cmd(ctx, c, args); err != nil {
+			return err
+		}
+		if cfg.Token == "" {
+			cfg.setCookies(c.Cookies())
+			return cfg.save()
+		}
+		return nil
+	}
+}
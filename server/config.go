@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/sqlite-server/auth"
+	"example.com/sqlite-server/digest"
+	"example.com/sqlite-server/mailer"
+	"example.com/sqlite-server/push"
+	"example.com/sqlite-server/session"
+	"example.com/sqlite-server/term"
+	"example.com/sqlite-server/util"
+	"example.com/sqlite-server/webhook"
+)
+
+// Config is how `server serve` runs. Each setting comes from, in order of
+// precedence, a command-line flag, an environment variable, the config file
+// (-config or CONFIG_FILE: a JSON object keyed by the variable names), or the
+// default.
+type Config struct {
+	Addr      string // LISTEN_ADDR
+	DBPath    string // DB_PATH
+	StaticDir string // STATIC_DIR; "" serves no client
+
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration // event streams lift it for themselves
+	IdleTimeout       time.Duration
+	ShutdownTimeout   time.Duration // how long in-flight requests get to finish
+
+	TLSCert string // with TLSKey, serve HTTPS
+	TLSKey  string
+
+	CookieSecure   bool          // Secure cookies and the __Host- session cookie
+	CookieSameSite http.SameSite // of the session and CSRF cookies
+
+	TrustedProxies []*net.IPNet // whose X-Forwarded-For gives the client address
+
+	Sessions   session.Lifetimes
+	TOTPIssuer string // the name authenticator apps show
+	OIDC       auth.OIDCConfig
+
+	Mail   mailer.Config // digests are off when it picks no mailer
+	AppURL string        // base of links in emails
+
+	VAPIDSubject string      // contact given to push services
+	VAPID        *push.VAPID // nil: push notifications are off
+
+	WebhookAllowPrivate bool // webhooks may reach loopback and private addresses
+	PushAllowPrivate    bool // so may push endpoints, over plain http too
+
+	TermArchiveAfter time.Duration // after a term ends, its courses are archived
+}
+
+// setting is one Config field: its variable, flag, default and parser.
+type setting struct {
+	key, flag, def, usage string
+	set                   func(c *Config, v string) error
+}
+
+var settings = []setting{
+	{"LISTEN_ADDR", "addr", ":8080", "address to listen on", func(c *Config, v string) error {
+		c.Addr = v
+		return nil
+	}},
+	{"DB_PATH", "db", "data.db", "SQLite database file", func(c *Config, v string) error {
+		c.DBPath = v
+		return nil
+	}},
+	{"STATIC_DIR", "static", "./client", "directory of the web client (empty: none)", func(c *Config, v string) error {
+		c.StaticDir = v
+		return nil
+	}},
+	{"READ_HEADER_TIMEOUT", "read-header-timeout", "5s", "time to read request headers", durationSetting(func(c *Config) *time.Duration { return &c.ReadHeaderTimeout })},
+	{"READ_TIMEOUT", "read-timeout", "30s", "time to read a whole request", durationSetting(func(c *Config) *time.Duration { return &c.ReadTimeout })},
+	{"WRITE_TIMEOUT", "write-timeout", "60s", "time to write a response", durationSetting(func(c *Config) *time.Duration { return &c.WriteTimeout })},
+	{"IDLE_TIMEOUT", "idle-timeout", "120s", "keep-alive connection idle time", durationSetting(func(c *Config) *time.Duration { return &c.IdleTimeout })},
+	{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "10s", "time for in-flight requests to finish on SIGTERM", durationSetting(func(c *Config) *time.Duration { return &c.ShutdownTimeout })},
+	{"TLS_CERT", "tls-cert", "", "TLS certificate (PEM); with -tls-key, serve HTTPS", func(c *Config, v string) error {
+		c.TLSCert = v
+		return nil
+	}},
+	{"TLS_KEY", "tls-key", "", "TLS private key (PEM)", func(c *Config, v string) error {
+		c.TLSKey = v
+		return nil
+	}},
+	{"COOKIE_SECURE", "cookie-secure", "", "Secure cookies: true or false (default: true with ENV=prod or TLS)", func(c *Config, v string) error {
+		if v == "" {
+			c.CookieSecure = util.IsProd() || c.TLSCert != ""
+			return nil
+		}
+		b, err := strconv.ParseBool(v)
+		c.CookieSecure = b
+		return err
+	}},
+	{"COOKIE_SAMESITE", "cookie-samesite", "strict", "SameSite of the session cookie: strict or lax", func(c *Config, v string) error {
+		switch strings.ToLower(v) {
+		case "strict":
+			c.CookieSameSite = http.SameSiteStrictMode
+		case "lax":
+			c.CookieSameSite = http.SameSiteLaxMode
+		default:
+			return errors.New("must be strict or lax")
+		}
+		return nil
+	}},
+	{"SESSION_TTL", "session-ttl", "168h", "idle lifetime of a session", positiveDurationSetting(func(c *Config) *time.Duration { return &c.Sessions.TTL })},
+	{"SESSION_REMEMBER_TTL", "session-remember-ttl", "720h", "idle lifetime of a remember-me session", positiveDurationSetting(func(c *Config) *time.Duration { return &c.Sessions.RememberTTL })},
+	{"SESSION_MAX_LIFETIME", "session-max-lifetime", "2160h", "time from login after which a session ends regardless (0: no limit)", durationSetting(func(c *Config) *time.Duration { return &c.Sessions.MaxLifetime })},
+	{"TRUSTED_PROXIES", "trusted-proxies", "", "comma-separated proxy networks whose X-Forwarded-For is believed", func(c *Config, v string) error {
+		nets, err := util.ParseCIDRs(v)
+		c.TrustedProxies = nets
+		return err
+	}},
+	{"TOTP_ISSUER", "totp-issuer", "Reading", "service name shown in authenticator apps", stringSetting(func(c *Config) *string { return &c.TOTPIssuer })},
+	{"OIDC_ISSUER", "oidc-issuer", "", "OpenID Connect provider for single sign-on (empty: off)", func(c *Config, v string) error {
+		c.OIDC.Issuer = strings.TrimRight(v, "/")
+		return nil
+	}},
+	{"OIDC_CLIENT_ID", "oidc-client-id", "", "client id registered with the provider", stringSetting(func(c *Config) *string { return &c.OIDC.ClientID })},
+	{"OIDC_CLIENT_SECRET", "oidc-client-secret", "", "client secret (public clients have none)", stringSetting(func(c *Config) *string { return &c.OIDC.ClientSecret })},
+	{"OIDC_REDIRECT_URL", "oidc-redirect-url", "", "callback URL registered with the provider, ending in /api/auth/oidc/callback", stringSetting(func(c *Config) *string { return &c.OIDC.RedirectURL })},
+	{"OIDC_SCOPES", "oidc-scopes", "openid email profile", "scopes requested from the provider", stringSetting(func(c *Config) *string { return &c.OIDC.Scopes })},
+	{"OIDC_APP_URL", "oidc-app-url", "", "origin of the web client, where login returns (empty: this server)", func(c *Config, v string) error {
+		c.OIDC.AppURL = strings.TrimRight(v, "/")
+		return nil
+	}},
+	{"MAIL_DIR", "mail-dir", "", "write outgoing mail as .eml files here instead of sending it", stringSetting(func(c *Config) *string { return &c.Mail.Dir })},
+	{"SMTP_ADDR", "smtp-addr", "", "SMTP submission server, host:port (empty, and no MAIL_DIR: no mail)", stringSetting(func(c *Config) *string { return &c.Mail.SMTPAddr })},
+	{"SMTP_USERNAME", "smtp-username", "", "SMTP user name", stringSetting(func(c *Config) *string { return &c.Mail.SMTPUsername })},
+	{"SMTP_PASSWORD", "smtp-password", "", "SMTP password", stringSetting(func(c *Config) *string { return &c.Mail.SMTPPassword })},
+	{"MAIL_FROM", "mail-from", "reading@localhost", "sender of outgoing mail", stringSetting(func(c *Config) *string { return &c.Mail.From })},
+	{"APP_URL", "app-url", "http://localhost:8080", "base of links in emails", func(c *Config, v string) error {
+		c.AppURL = strings.TrimRight(v, "/")
+		return nil
+	}},
+	{"VAPID_SUBJECT", "vapid-subject", "mailto:reading@localhost", "contact (mailto: or https:) given to push services", stringSetting(func(c *Config) *string { return &c.VAPIDSubject })},
+	{"VAPID_PRIVATE_KEY", "vapid-private-key", "", "push notification key from `server push keygen` (empty: push is off)", func(c *Config, v string) error {
+		c.VAPID = nil
+		if v == "" {
+			return nil
+		}
+		var err error
+		if c.VAPID, err = push.NewVAPID(v, c.VAPIDSubject); err != nil {
+			return errors.New("not a key from `server push keygen`")
+		}
+		return nil
+	}},
+	{"WEBHOOK_ALLOW_PRIVATE", "webhook-allow-private", "false", "let webhooks reach loopback and private-network addresses", boolSetting(func(c *Config) *bool { return &c.WebhookAllowPrivate })},
+	{"PUSH_ALLOW_PRIVATE", "push-allow-private", "false", "let push endpoints be http, loopback or private (local testing)", boolSetting(func(c *Config) *bool { return &c.PushAllowPrivate })},
+	{"TERM_ARCHIVE_AFTER", "term-archive-after", "336h", "time after a term ends that its courses are archived", durationSetting(func(c *Config) *time.Duration { return &c.TermArchiveAfter })},
+}
+
+// secretSettings are never echoed in errors.
+var secretSettings = map[string]bool{"OIDC_CLIENT_SECRET": true, "SMTP_PASSWORD": true, "VAPID_PRIVATE_KEY": true}
+
+func stringSetting(field func(c *Config) *string) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		*field(c) = v
+		return nil
+	}
+}
+
+func boolSetting(field func(c *Config) *bool) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func durationSetting(field func(c *Config) *time.Duration) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return errors.New("must be a duration such as 30s or 2m")
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+func positiveDurationSetting(field func(c *Config) *time.Duration) func(c *Config, v string) error {
+	set := durationSetting(field)
+	return func(c *Config, v string) error {
+		if err := set(c, v); err != nil || *field(c) == 0 {
+			return errors.New("must be a positive duration such as 168h")
+		}
+		return nil
+	}
+}
+
+// loadConfig resolves the settings from args (the flags after `serve`), the
+// environment and the config file.
+func loadConfig(args []string) (Config, error) {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "JSON config file")
+	flags := map[string]*string{}
+	for _, s := range settings {
+		flags[s.flag] = fs.String(s.flag, "", s.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			printSettings(os.Stdout)
+		}
+		return Config{}, err
+	}
+	if fs.NArg() > 0 {
+		return Config{}, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	file := map[string]string{}
+	if *configFile != "" {
+		var err error
+		if file, err = readConfigFile(*configFile); err != nil {
+			return Config{}, err
+		}
+	}
+
+	var c Config
+	for _, s := range settings {
+		v, from := s.def, "the default"
+		if fv, ok := file[s.key]; ok {
+			v, from = fv, *configFile
+		}
+		if ev := strings.TrimSpace(os.Getenv(s.key)); ev != "" {
+			v, from = ev, "the environment"
+		}
+		if given[s.flag] {
+			v, from = *flags[s.flag], "-"+s.flag
+		}
+		if err := s.set(&c, v); err != nil {
+			if secretSettings[s.key] {
+				return Config{}, fmt.Errorf("%s (from %s): %v", s.key, from, err)
+			}
+			return Config{}, fmt.Errorf("%s=%q (from %s): %v", s.key, v, from, err)
+		}
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return Config{}, errors.New("TLS_CERT and TLS_KEY go together")
+	}
+	return c, nil
+}
+
+// apply hands c to the packages that keep their settings in package
+// variables. serve and the operator subcommands call it first.
+func (c Config) apply() {
+	util.SecureCookies, util.CookieSameSite = c.CookieSecure, c.CookieSameSite
+	util.TrustedProxies = c.TrustedProxies
+	session.Settings = c.Sessions
+	auth.TOTPIssuer, auth.OIDC = c.TOTPIssuer, c.OIDC
+	mailer.Default = c.Mail.Mailer()
+	digest.AppURL = c.AppURL
+	push.Default, push.AllowPrivate = c.VAPID, c.PushAllowPrivate
+	webhook.AllowPrivate = c.WebhookAllowPrivate
+	term.ArchiveAfter = c.TermArchiveAfter
+}
+
+// readConfigFile reads a JSON object of settings keyed by variable name.
+// Unknown keys are an error, so typos don't go unnoticed.
+func readConfigFile(path string) (map[string]string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]any
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	known := map[string]bool{}
+	for _, s := range settings {
+		known[s.key] = true
+	}
+	var unknown []string
+	out := map[string]string{}
+	for k, v := range values {
+		if !known[k] {
+			unknown = append(unknown, k)
+			continue
+		}
+		switch v := v.(type) {
+		case string:
+			out[k] = v
+		case bool, float64:
+			out[k] = fmt.Sprint(v)
+		default:
+			return nil, fmt.Errorf("%s: %s must be a string, number or boolean", path, k)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("%s: unknown settings %s", path, strings.Join(unknown, ", "))
+	}
+	return out, nil
+}
+
+// printSettings lists the flags with their variables and defaults.
+func printSettings(w io.Writer) {
+	fmt.Fprint(w, "usage: server [serve] [flags]\n\n"+
+		"Each flag can also be set by its environment variable or in the -config\n"+
+		"file (CONFIG_FILE), a JSON object keyed by variable name.\n\n")
+	fmt.Fprintf(w, "  -%-22s %-22s %s\n", "config", "CONFIG_FILE", "JSON config file")
+	for _, s := range settings {
+		usage := s.usage
+		if s.def != "" {
+			usage += " (default " + s.def + ")"
+		}
+		fmt.Fprintf(w, "  -%-22s %-22s %s\n", s.flag, s.key, usage)
+	}
+}
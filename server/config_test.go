@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/sqlite-server/mailer"
+)
+
+func TestLoadConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(file, []byte(`{
+		"MAIL_DIR": "/var/mail/reading",
+		"WEBHOOK_ALLOW_PRIVATE": true,
+		"TERM_ARCHIVE_AFTER": "48h",
+		"OIDC_CLIENT_ID": "from-file"
+	}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("OIDC_ISSUER", "https://id.example.com/")
+	t.Setenv("OIDC_CLIENT_ID", "from-env")
+	t.Setenv("APP_URL", "https://reading.example.com/")
+
+	c, err := loadConfig([]string{"-oidc-client-id", "from-flag", "-push-allow-private=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.OIDC.Issuer != "https://id.example.com" || c.OIDC.ClientID != "from-flag" || c.OIDC.Scopes != "openid email profile" {
+		t.Errorf("OIDC = %+v", c.OIDC)
+	}
+	if c.OIDC.Enabled() {
+		t.Errorf("OIDC enabled without a redirect URL")
+	}
+	if m, ok := c.Mail.Mailer().(mailer.FileSink); !ok || m.Dir != "/var/mail/reading" || m.From != "reading@localhost" {
+		t.Errorf("mailer = %#v", c.Mail.Mailer())
+	}
+	if c.AppURL != "https://reading.example.com" {
+		t.Errorf("AppURL = %q", c.AppURL)
+	}
+	if !c.WebhookAllowPrivate || !c.PushAllowPrivate || c.TermArchiveAfter != 48*time.Hour {
+		t.Errorf("webhook %v, push %v, archive after %s", c.WebhookAllowPrivate, c.PushAllowPrivate, c.TermArchiveAfter)
+	}
+	if c.VAPID != nil {
+		t.Errorf("VAPID set without a key")
+	}
+}
+
+func TestLoadConfigHidesSecrets(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("VAPID_PRIVATE_KEY", "not-so-secret-after-all")
+	_, err := loadConfig(nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "VAPID_PRIVATE_KEY (from the environment)") || strings.Contains(err.Error(), "not-so-secret") {
+		t.Errorf("err = %v", err)
+	}
+}
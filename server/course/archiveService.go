@@ -0,0 +1,51 @@
+package course
+
+import (
+	"database/sql"
+)
+
+// SetCourseArchived archives a course for everyone, or restores it. Archived
+// courses drop out of the default lists and calendar feeds but stay readable.
+// Returns sql.ErrNoRows if the course doesn't exist.
+func SetCourseArchived(db *sql.DB, courseID int64, archived bool) error {
+	var res sql.Result
+	var err error
+	if archived {
+		res, err = db.Exec(`
+			UPDATE courses SET archived_at = COALESCE(archived_at, strftime('%s','now')) WHERE id = ?
+		`, courseID)
+	} else {
+		res, err = db.Exec(`UPDATE courses SET archived_at = NULL WHERE id = ?`, courseID)
+	}
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetEnrollmentArchived archives a course for userID only, or restores it.
+// Returns sql.ErrNoRows if userID isn't enrolled.
+func SetEnrollmentArchived(db *sql.DB, userID string, courseID int64, archived bool) error {
+	var res sql.Result
+	var err error
+	if archived {
+		res, err = db.Exec(`
+			UPDATE user_courses SET archived_at = COALESCE(archived_at, strftime('%s','now'))
+			 WHERE user_id = ? AND course_id = ?
+		`, userID, courseID)
+	} else {
+		res, err = db.Exec(`
+			UPDATE user_courses SET archived_at = NULL WHERE user_id = ? AND course_id = ?
+		`, userID, courseID)
+	}
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
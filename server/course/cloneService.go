@@ -0,0 +1,292 @@
+package course
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+
+	"example.com/sqlite-server/article"
+	"example.com/sqlite-server/assignment"
+	"example.com/sqlite-server/book"
+	"example.com/sqlite-server/events"
+	"example.com/sqlite-server/store"
+	"example.com/sqlite-server/term"
+	"example.com/sqlite-server/util"
+)
+
+// CloneResult reports what CloneCourse copied.
+type CloneResult struct {
+	Course        Course `json:"course"`
+	Books         int64  `json:"books"`
+	Chapters      int64  `json:"chapters"`
+	Articles      int64  `json:"articles"`
+	Assignments   int64  `json:"assignments"`
+	ShiftedMonths int64  `json:"shiftedMonths,omitempty"`
+	ShiftedDays   int64  `json:"shiftedDays,omitempty"`
+	// ClearedDeadlines counts copied deadlines dropped because they fall
+	// outside the target term or in one of its breaks.
+	ClearedDeadlines int64 `json:"clearedDeadlines,omitempty"`
+}
+
+// CloneOptions describe the new offering. Empty Code/Name keep the source's.
+type CloneOptions struct {
+	Year           int64
+	Term           int64
+	Code           string
+	Name           string
+	ShiftDeadlines bool
+}
+
+// termOffsetMonths is the distance between two offerings, treating terms as
+// consecutive quarters of the year. Used when the terms have no dates.
+func termOffsetMonths(fromYear, fromTerm, toYear, toTerm int64) int64 {
+	return (toYear-fromYear)*12 + (toTerm-fromTerm)*3
+}
+
+// CloneCourse copies a course's books, chapters, articles and assignments into a
+// new course in the same university, created by createdBy. Progress, enrollments
+// and invites are not copied. With ShiftDeadlines, deadlines move by the term
+// offset: the days between the two terms' start dates when both are defined,
+// otherwise whole months (so dates land on the same day of the month).
+// Without it they are copied as-is. When the target term is defined, deadlines
+// that end up outside it or in one of its breaks are cleared, as they could not
+// be set by hand. Created events are published for the copied items.
+// Errors: sql.ErrNoRows (no source), util.ErrInvalidInput, term.ErrUnknownTerm,
+// ErrCourseExists.
+func CloneCourse(db *sql.DB, sourceID int64, opt CloneOptions, createdBy string) (CloneResult, error) {
+	var out CloneResult
+	opt.Code = strings.TrimSpace(opt.Code)
+	opt.Name = strings.TrimSpace(opt.Name)
+	if sourceID <= 0 || opt.Year <= 0 || opt.Term < 1 || opt.Term > 4 {
+		return out, util.ErrInvalidInput
+	}
+
+	var src Course
+	if err := db.QueryRow(`
+		SELECT id, university_id, year, term, code, name, join_policy
+		  FROM courses
+		 WHERE id = ?
+	`, sourceID).Scan(&src.ID, &src.UniversityID, &src.Year, &src.Term, &src.Code, &src.Name, &src.JoinPolicy); err != nil {
+		return out, err
+	}
+	if opt.Code == "" {
+		opt.Code = src.Code
+	}
+	if opt.Name == "" {
+		opt.Name = src.Name
+	}
+	if err := term.CheckCourseTerm(db, src.UniversityID, opt.Year, opt.Term); err != nil {
+		return out, err
+	}
+
+	to, terr := term.TermFor(db, src.UniversityID, opt.Year, opt.Term)
+	if terr != nil && terr != sql.ErrNoRows {
+		return out, terr
+	}
+
+	// deadline is the SQL expression for a copied deadline column.
+	deadline := "deadline"
+	if opt.ShiftDeadlines {
+		from, ferr := term.TermFor(db, src.UniversityID, src.Year, src.Term)
+		if ferr != nil && ferr != sql.ErrNoRows {
+			return out, ferr
+		}
+		unit, n := "months", termOffsetMonths(src.Year, src.Term, opt.Year, opt.Term)
+		if ferr == nil && terr == nil {
+			fs, _ := from.Window()
+			ts, _ := to.Window()
+			// Whole days, so times of day stay put.
+			unit, n = "days", int64(math.Round(ts.Sub(fs).Hours()/24))
+			out.ShiftedDays = n
+		} else {
+			out.ShiftedMonths = n
+		}
+		deadline = fmt.Sprintf(
+			"CASE WHEN deadline IS NULL THEN NULL ELSE CAST(strftime('%%s', deadline, 'unixepoch', '%+d %s') AS INTEGER) END",
+			n, unit)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return out, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		INSERT INTO courses (university_id, year, term, code, name, created_by, join_policy)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, src.UniversityID, opt.Year, opt.Term, opt.Code, opt.Name, createdBy, src.JoinPolicy)
+	if err != nil {
+		if store.IsUniqueViolation(err) {
+			return out, ErrCourseExists
+		}
+		return out, err
+	}
+	newID, err := res.LastInsertId()
+	if err != nil {
+		return out, err
+	}
+	out.Course = Course{ID: newID, UniversityID: src.UniversityID, Year: opt.Year, Term: opt.Term,
+		Code: opt.Code, Name: opt.Name, JoinPolicy: src.JoinPolicy}
+
+	// Books one at a time, to map old ids to new ones for their chapters.
+	rows, err := tx.Query(`SELECT id FROM books WHERE course_id = ? ORDER BY id`, sourceID)
+	if err != nil {
+		return out, err
+	}
+	var bookIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return out, err
+		}
+		bookIDs = append(bookIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return out, err
+	}
+
+	for _, oldID := range bookIDs {
+		res, err := tx.Exec(`
+			INSERT INTO books (course_id, title, author, numChapters, location)
+			SELECT ?, title, author, numChapters, location FROM books WHERE id = ?
+		`, newID, oldID)
+		if err != nil {
+			return out, err
+		}
+		bookID, err := res.LastInsertId()
+		if err != nil {
+			return out, err
+		}
+		out.Books++
+
+		res, err = tx.Exec(`
+			INSERT INTO chapters (book_id, chapter_num, deadline)
+			SELECT ?, chapter_num, `+deadline+` FROM chapters WHERE book_id = ? ORDER BY id
+		`, bookID, oldID)
+		if err != nil {
+			return out, err
+		}
+		n, _ := res.RowsAffected()
+		out.Chapters += n
+	}
+
+	res, err = tx.Exec(`
+		INSERT INTO articles (course_id, title, author, location, deadline)
+		SELECT ?, title, author, location, `+deadline+` FROM articles WHERE course_id = ? ORDER BY id
+	`, newID, sourceID)
+	if err != nil {
+		return out, err
+	}
+	out.Articles, _ = res.RowsAffected()
+
+	res, err = tx.Exec(`
+		INSERT INTO assignments (course_id, title, description, deadline)
+		SELECT ?, title, description, `+deadline+` FROM assignments WHERE course_id = ? ORDER BY id
+	`, newID, sourceID)
+	if err != nil {
+		return out, err
+	}
+	out.Assignments, _ = res.RowsAffected()
+
+	if terr == nil {
+		if out.ClearedDeadlines, err = clearDeadlinesOutside(tx, to, newID); err != nil {
+			return out, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return out, err
+	}
+	return out, publishCloned(db, newID)
+}
+
+// deadlineTables are the copied items that carry a deadline, with the query
+// selecting a course's items.
+var deadlineTables = []struct{ table, where string }{
+	{"chapters", "book_id IN (SELECT id FROM books WHERE course_id = ?)"},
+	{"articles", "course_id = ?"},
+	{"assignments", "course_id = ?"},
+}
+
+// clearDeadlinesOutside clears the deadlines of course courseID that t would
+// not accept, and returns how many it cleared.
+func clearDeadlinesOutside(tx *sql.Tx, t term.Term, courseID int64) (int64, error) {
+	var cleared int64
+	for _, dt := range deadlineTables {
+		rows, err := tx.Query(`SELECT id, deadline FROM `+dt.table+` WHERE deadline IS NOT NULL AND `+dt.where, courseID)
+		if err != nil {
+			return 0, err
+		}
+		var bad []int64
+		for rows.Next() {
+			var id, dl int64
+			if err := rows.Scan(&id, &dl); err != nil {
+				rows.Close()
+				return 0, err
+			}
+			if t.CheckDeadline(dl) != nil {
+				bad = append(bad, id)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+		for _, id := range bad {
+			if _, err := tx.Exec(`UPDATE `+dt.table+` SET deadline = NULL WHERE id = ?`, id); err != nil {
+				return 0, err
+			}
+		}
+		cleared += int64(len(bad))
+	}
+	return cleared, nil
+}
+
+// publishCloned announces the items of a freshly cloned course, the same way
+// adding them one by one would.
+func publishCloned(db *sql.DB, courseID int64) error {
+	books, err := book.ListBooksByCourse(db, courseID)
+	if err != nil {
+		return err
+	}
+	for _, b := range books {
+		events.Publish(events.Event{Type: events.TypeCreated, Kind: events.KindBook, ItemID: b.ID, CourseID: courseID, Item: b})
+	}
+
+	rows, err := db.Query(`SELECT id FROM articles WHERE course_id = ? ORDER BY id`, courseID)
+	if err != nil {
+		return err
+	}
+	var articleIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		articleIDs = append(articleIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, id := range articleIDs {
+		a, err := article.GetArticle(db, id)
+		if err != nil {
+			return err
+		}
+		events.Publish(events.Event{Type: events.TypeCreated, Kind: events.KindArticle, ItemID: a.ID, CourseID: courseID, Item: a})
+	}
+
+	assignments, err := assignment.ListAssignmentsByCourse(db, courseID)
+	if err != nil {
+		return err
+	}
+	for _, a := range assignments {
+		events.Publish(events.Event{Type: events.TypeCreated, Kind: events.KindAssignment, ItemID: a.ID, CourseID: courseID, Item: a})
+	}
+	return nil
+}
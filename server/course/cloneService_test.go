@@ -0,0 +1,133 @@
+package course
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"example.com/sqlite-server/events"
+	"example.com/sqlite-server/store"
+	"example.com/sqlite-server/term"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func exec(t *testing.T, db *sql.DB, query string, args ...any) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+func epoch(t *testing.T, s string) int64 {
+	t.Helper()
+	at, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return at.Unix()
+}
+
+func TestCloneCourseShiftsDeadlinesIntoTerm(t *testing.T) {
+	db := openTestDB(t)
+	exec(t, db, `INSERT INTO users (id, email, password) VALUES ('u1', 'a@x.io', '')`)
+	exec(t, db, `INSERT INTO universities (id, name, status) VALUES ('uni', 'Uni', 'approved')`)
+	for _, tm := range []term.Term{
+		{UniversityID: "uni", Year: 2025, Term: 1, Name: "Spring 2025", StartsOn: "2025-01-06", EndsOn: "2025-03-28"},
+		{UniversityID: "uni", Year: 2026, Term: 1, Name: "Spring 2026", StartsOn: "2026-01-05", EndsOn: "2026-03-27",
+			Breaks: []term.Break{{Name: "Reading week", StartsOn: "2026-02-16", EndsOn: "2026-02-20"}}},
+	} {
+		if _, err := term.CreateTerm(db, tm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exec(t, db, `INSERT INTO courses (id, university_id, year, term, code, name) VALUES (1, 'uni', 2025, 1, 'CS101', 'Intro')`)
+	exec(t, db, `INSERT INTO books (id, course_id, title, author, numChapters) VALUES (1, 1, 'Book', 'A', 1)`)
+	// Lands on 2026-03-29, after the target term.
+	exec(t, db, `INSERT INTO chapters (book_id, chapter_num, deadline) VALUES (1, 1, ?)`, epoch(t, "2025-03-30T12:00:00Z"))
+	// Lands on 2026-01-09.
+	exec(t, db, `INSERT INTO articles (course_id, title, author, deadline) VALUES (1, 'Article', 'B', ?)`, epoch(t, "2025-01-10T12:00:00Z"))
+	// Lands on 2026-02-16, in the reading week.
+	exec(t, db, `INSERT INTO assignments (course_id, title, deadline) VALUES (1, 'Essay', ?)`, epoch(t, "2025-02-17T12:00:00Z"))
+
+	sub, _, _ := events.Default.Subscribe(0)
+	defer sub.Close()
+
+	res, err := CloneCourse(db, 1, CloneOptions{Year: 2026, Term: 1, ShiftDeadlines: true}, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ShiftedDays != 364 || res.Books != 1 || res.Chapters != 1 || res.Articles != 1 || res.Assignments != 1 {
+		t.Fatalf("result = %+v", res)
+	}
+	if res.ClearedDeadlines != 2 {
+		t.Fatalf("cleared %d deadlines, want 2", res.ClearedDeadlines)
+	}
+
+	newID := res.Course.ID
+	var articleDL sql.NullInt64
+	if err := db.QueryRow(`SELECT deadline FROM articles WHERE course_id = ?`, newID).Scan(&articleDL); err != nil {
+		t.Fatal(err)
+	}
+	if !articleDL.Valid || articleDL.Int64 != epoch(t, "2026-01-09T12:00:00Z") {
+		t.Fatalf("article deadline = %v", articleDL)
+	}
+	var kept int
+	if err := db.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM chapters c JOIN books b ON b.id = c.book_id WHERE b.course_id = ? AND c.deadline IS NOT NULL)
+		     + (SELECT COUNT(*) FROM assignments WHERE course_id = ? AND deadline IS NOT NULL)
+	`, newID, newID).Scan(&kept); err != nil {
+		t.Fatal(err)
+	}
+	if kept != 0 {
+		t.Fatalf("%d deadlines outside the term were kept", kept)
+	}
+
+	created := map[string]int{}
+	for len(sub.C()) > 0 {
+		e := <-sub.C()
+		if e.Type != events.TypeCreated || e.CourseID != newID || e.Item == nil {
+			t.Fatalf("unexpected event %+v", e)
+		}
+		created[e.Kind]++
+	}
+	if created[events.KindBook] != 1 || created[events.KindArticle] != 1 || created[events.KindAssignment] != 1 {
+		t.Fatalf("created events = %v", created)
+	}
+}
+
+func TestCloneCourseWithoutTermsShiftsByMonths(t *testing.T) {
+	db := openTestDB(t)
+	exec(t, db, `INSERT INTO users (id, email, password) VALUES ('u1', 'a@x.io', '')`)
+	exec(t, db, `INSERT INTO universities (id, name, status) VALUES ('uni', 'Uni', 'approved')`)
+	exec(t, db, `INSERT INTO courses (id, university_id, year, term, code, name) VALUES (1, 'uni', 2025, 3, 'CS101', 'Intro')`)
+	exec(t, db, `INSERT INTO articles (course_id, title, author, deadline) VALUES (1, 'Article', 'B', ?)`, epoch(t, "2025-09-15T09:00:00Z"))
+
+	res, err := CloneCourse(db, 1, CloneOptions{Year: 2026, Term: 1, ShiftDeadlines: true}, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ShiftedMonths != 6 || res.ClearedDeadlines != 0 {
+		t.Fatalf("result = %+v", res)
+	}
+	var dl int64
+	if err := db.QueryRow(`SELECT deadline FROM articles WHERE course_id = ?`, res.Course.ID).Scan(&dl); err != nil {
+		t.Fatal(err)
+	}
+	if dl != epoch(t, "2026-03-15T09:00:00Z") {
+		t.Fatalf("deadline = %s", time.Unix(dl, 0).UTC())
+	}
+
+	if _, err := CloneCourse(db, 1, CloneOptions{Year: 2026, Term: 1}, "u1"); err != ErrCourseExists {
+		t.Fatalf("second clone: %v", err)
+	}
+}
@@ -3,19 +3,24 @@ package course
 import (
 	"database/sql"
 	"encoding/json"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"example.com/sqlite-server/enrollment"
+	"example.com/sqlite-server/invite"
 	"example.com/sqlite-server/membership"
 	"example.com/sqlite-server/session"
 	"example.com/sqlite-server/util"
-	"example.com/sqlite-server/enrollment"
 )
 
 // RegisterCourseRoutes wires the course endpoints.
-func RegisterCourseRoutes(mux *http.ServeMux, db *sql.DB) {
+func RegisterCourseRoutes(mux util.Router, db *sql.DB) {
 	// My courses + create (both auth)
 	mux.HandleFunc("/courses", session.RequireAuth(db, coursesHandler(db)))
+	mux.HandleFunc("/courses/", session.RequireAuth(db, courseItemDispatcher(db)))
 
 	// Catalog (member-only view)
 	mux.HandleFunc("/course-catalog", session.RequireAuth(db, courseCatalogHandler(db)))
@@ -24,89 +29,117 @@ func RegisterCourseRoutes(mux *http.ServeMux, db *sql.DB) {
 // Dispatcher for /courses (auth already applied by middleware).
 
 func coursesHandler(db *sql.DB) http.HandlerFunc {
-  return func(w http.ResponseWriter, r *http.Request) {
-    switch r.Method {
-    case http.MethodGet:
-      getMyCoursesForUniversityHandler(db)(w, r)
-    case http.MethodPost:
-      postCourseHandler(db)(w, r)
-    case http.MethodDelete:
-      deleteCourseHandler(db)(w, r)
-    default:
-      http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-    }
-  }
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			getMyCoursesForUniversityHandler(db)(w, r)
+		case http.MethodPost:
+			postCourseHandler(db)(w, r)
+		case http.MethodDelete:
+			deleteCourseHandler(db)(w, r)
+		default:
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
 }
 
-// GET /courses?universityId=UUID
+// includeArchived reports whether the list request asked for ?include=archived.
+func includeArchived(r *http.Request) bool {
+	for _, v := range strings.Split(r.URL.Query().Get("include"), ",") {
+		if strings.TrimSpace(v) == "archived" {
+			return true
+		}
+	}
+	return false
+}
+
+// GET /courses?universityId=UUID[&include=archived]
 // Returns ONLY the caller's enrolled courses for the given university.
 // Authorization: user must be a member of the university (defense-in-depth).
 func getMyCoursesForUniversityHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid, ok := session.UserIDFromCtx(r.Context())
 		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 		uniID := strings.TrimSpace(r.URL.Query().Get("universityId"))
 		if uniID == "" {
-			http.Error(w, "universityId is required", http.StatusBadRequest)
+			util.HTTPError(w, "universityId is required", http.StatusBadRequest)
 			return
 		}
 
 		// Membership gate (enrollment already implies membership, but keep this for clarity)
 		isMember, err := membership.IsMember(db, uid, uniID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		if !isMember {
-			http.Error(w, "forbidden", http.StatusForbidden)
+			util.HTTPError(w, "forbidden", http.StatusForbidden)
 			return
 		}
 
-		list, err := ListMyCoursesByUniversity(db, uid, uniID)
+		list, err := ListMyCoursesByUniversity(db, uid, uniID, includeArchived(r))
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		util.WriteJSON(w, list, http.StatusOK)
 	}
 }
 
-// GET /course-catalog?universityId=UUID
-// Returns ALL courses for the university (not just the caller’s enrollments).
+// GET /course-catalog?universityId=UUID[&year=2025][&term=1][&include=archived]
+//
+//	[&sort=recent|code|name][&limit=N][&cursor=...]
+//
+// Returns a page of ALL courses for the university (not just the caller’s enrollments).
 func courseCatalogHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 		uid, ok := session.UserIDFromCtx(r.Context())
 		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 		uniID := strings.TrimSpace(r.URL.Query().Get("universityId"))
 		if uniID == "" {
-			http.Error(w, "universityId is required", http.StatusBadRequest)
+			util.HTTPError(w, "universityId is required", http.StatusBadRequest)
 			return
 		}
 
 		// Must be a member of the university to view the catalog
 		isMember, err := membership.IsMember(db, uid, uniID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		if !isMember {
-			http.Error(w, "forbidden", http.StatusForbidden)
+			util.HTTPError(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		pq, err := util.ParsePageQuery(r, catalogSorts...)
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		f := CatalogFilter{IncludeArchived: includeArchived(r)}
+		if f.Year, err = util.OptionalInt64Query(r, "year"); err != nil {
+			util.HTTPError(w, "invalid year", http.StatusBadRequest)
+			return
+		}
+		if f.Term, err = util.OptionalInt64Query(r, "term"); err != nil {
+			util.HTTPError(w, "invalid term", http.StatusBadRequest)
 			return
 		}
 
-		list, err := ListCoursesByUniversity(db, uniID)
+		list, err := ListCoursesByUniversity(db, uniID, f, pq)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		util.WriteJSON(w, list, http.StatusOK)
@@ -127,7 +160,7 @@ func postCourseHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		uid, ok := session.UserIDFromCtx(r.Context())
 		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 
@@ -135,7 +168,7 @@ func postCourseHandler(db *sql.DB) http.HandlerFunc {
 		dec := json.NewDecoder(r.Body)
 		dec.DisallowUnknownFields()
 		if err := dec.Decode(&p); err != nil {
-			http.Error(w, "bad request", http.StatusBadRequest)
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
 			return
 		}
 
@@ -143,114 +176,286 @@ func postCourseHandler(db *sql.DB) http.HandlerFunc {
 		p.Code = strings.TrimSpace(p.Code)
 		p.Name = strings.TrimSpace(p.Name)
 		if p.UniversityID == "" || p.Year <= 0 || p.Term < 1 || p.Term > 4 || p.Code == "" || p.Name == "" {
-			http.Error(w, "invalid input", http.StatusBadRequest)
+			util.HTTPError(w, "invalid input", http.StatusBadRequest)
 			return
 		}
 
 		isMember, err := membership.IsMember(db, uid, p.UniversityID)
 		if err != nil {
-			http.Error(w, "internal error", http.StatusInternalServerError)
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		if !isMember {
-			http.Error(w, "forbidden", http.StatusForbidden)
+			util.HTTPError(w, "forbidden", http.StatusForbidden)
 			return
 		}
 
-		c, err := AddCourse(db, p.UniversityID, p.Year, p.Term, p.Code, p.Name)
+		c, err := AddCourse(db, p.UniversityID, p.Year, p.Term, p.Code, p.Name, uid)
 		if err != nil {
-			lc := strings.ToLower(err.Error())
-			switch {
-			case strings.Contains(lc, "university not found"):
-				http.Error(w, "university not found", http.StatusBadRequest)
-				return
-			case strings.Contains(lc, "course already exists"):
-				http.Error(w, "course already exists", http.StatusConflict)
-				return
-			case strings.Contains(lc, "invalid input"):
-				http.Error(w, "invalid input", http.StatusBadRequest)
-				return
-			default:
-				http.Error(w, "internal error", http.StatusInternalServerError)
-				return
-			}
+			util.WriteError(w, err)
+			return
 		}
 
 		util.WriteJSON(w, c, http.StatusCreated)
 	}
 }
 
-
 // DELETE /courses
 // Body: { "courseId": number }
 // Auth: caller must be a MEMBER of the university owning the course.
 // Only succeeds if there are NO books, NO articles, NO assignments.
 func deleteCourseHandler(db *sql.DB) http.HandlerFunc {
-  type payload struct {
-    CourseID int64 `json:"courseId"`
-  }
-  return func(w http.ResponseWriter, r *http.Request) {
-    var p payload
-    dec := json.NewDecoder(r.Body)
-    dec.DisallowUnknownFields()
-    if err := dec.Decode(&p); err != nil {
-      http.Error(w, "bad request", http.StatusBadRequest)
-      return
-    }
-    if p.CourseID <= 0 {
-      http.Error(w, "invalid input", http.StatusBadRequest)
-      return
-    }
-
-    // Membership gate: must belong to the university that owns the course.
-    uid, ok := session.UserIDFromCtx(r.Context())
-    if !ok {
-      http.Error(w, "unauthorized", http.StatusUnauthorized)
-      return
-    }
-    uniID, err := enrollment.CourseUniversity(db, p.CourseID)
-    if err != nil {
-      if err == sql.ErrNoRows {
-        http.Error(w, "not found", http.StatusNotFound)
-        return
-      }
-      http.Error(w, "internal error", http.StatusInternalServerError)
-      return
-    }
-    isMember, err := membership.IsMember(db, uid, uniID)
-    if err != nil {
-      http.Error(w, "internal error", http.StatusInternalServerError)
-      return
-    }
-    if !isMember {
-      http.Error(w, "forbidden", http.StatusForbidden)
-      return
-    }
-
-    // Delegate to service layer.
-    deleted, derr := DeleteCourseIfEmpty(db, p.CourseID)
-    if derr != nil {
-      lc := strings.ToLower(derr.Error())
-      switch {
-      case derr == sql.ErrNoRows:
-        http.Error(w, "not found", http.StatusNotFound)
-      case strings.Contains(lc, "invalid input"):
-        http.Error(w, "invalid input", http.StatusBadRequest)
-      case strings.Contains(lc, "has books"):
-        http.Error(w, "conflict: course has books", http.StatusConflict)
-      case strings.Contains(lc, "has articles"):
-        http.Error(w, "conflict: course has articles", http.StatusConflict)
-      case strings.Contains(lc, "has assignments"):
-        http.Error(w, "conflict: course has assignments", http.StatusConflict)
-      default:
-        http.Error(w, "internal error", http.StatusInternalServerError)
-      }
-      return
-    }
-    if !deleted {
-      http.Error(w, "not found", http.StatusNotFound)
-      return
-    }
-    w.WriteHeader(http.StatusNoContent)
-  }
+	type payload struct {
+		CourseID int64 `json:"courseId"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var p payload
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil {
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if p.CourseID <= 0 {
+			util.HTTPError(w, "invalid input", http.StatusBadRequest)
+			return
+		}
+
+		// Membership gate: must belong to the university that owns the course.
+		uid, ok := session.UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		uniID, err := enrollment.CourseUniversity(db, p.CourseID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				util.HTTPError(w, "not found", http.StatusNotFound)
+				return
+			}
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		isMember, err := membership.IsMember(db, uid, uniID)
+		if err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if !isMember {
+			util.HTTPError(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		// Delegate to service layer.
+		deleted, derr := DeleteCourseIfEmpty(db, p.CourseID)
+		if derr != nil {
+			util.WriteError(w, derr)
+			return
+		}
+		if !deleted {
+			util.HTTPError(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Dispatcher for /courses/current and /courses/{id}/(clone|archive|restore).
+func courseItemDispatcher(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/courses/"), "/")
+		if len(parts) == 1 && parts[0] == "current" {
+			if r.Method != http.MethodGet {
+				util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			currentCoursesHandler(db)(w, r)
+			return
+		}
+		if len(parts) != 2 {
+			util.HTTPError(w, "not found", http.StatusNotFound)
+			return
+		}
+		id, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil || id <= 0 {
+			util.HTTPError(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		var h http.HandlerFunc
+		switch parts[1] {
+		case "clone":
+			h = cloneCourseHandler(db, id)
+		case "archive":
+			h = archiveCourseHandler(db, id, true)
+		case "restore":
+			h = archiveCourseHandler(db, id, false)
+		default:
+			util.HTTPError(w, "not found", http.StatusNotFound)
+			return
+		}
+		if r.Method != http.MethodPost {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// POST /courses/{id}/clone
+// Body: { "year": 2026, "term": 1, "code": "CS101", "name": "Intro to CS", "shiftDeadlines": true }
+// code and name default to the source's. Requires: caller is a member of the course's university
+// and a curator of, or enrolled in, the source course.
+func cloneCourseHandler(db *sql.DB, courseID int64) http.HandlerFunc {
+	type payload struct {
+		Year           int64  `json:"year"`
+		Term           int64  `json:"term"`
+		Code           string `json:"code"`
+		Name           string `json:"name"`
+		ShiftDeadlines bool   `json:"shiftDeadlines"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := session.UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var p payload
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil {
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if p.Year <= 0 || p.Term < 1 || p.Term > 4 {
+			util.HTTPError(w, "invalid input", http.StatusBadRequest)
+			return
+		}
+
+		uniID, err := enrollment.CourseUniversity(db, courseID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				util.HTTPError(w, "not found", http.StatusNotFound)
+				return
+			}
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		isMember, err := membership.IsMember(db, uid, uniID)
+		if err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if !isMember {
+			util.HTTPError(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		// Only people who can already see the materials may copy them: the
+		// course's curators and its students. Otherwise cloning would get round
+		// an invite-only or approval course's join policy.
+		allowed, err := invite.IsCourseCurator(db, uid, courseID)
+		if err == nil && !allowed {
+			allowed, err = enrollment.UserEnrolledInCourse(db, uid, courseID)
+		}
+		if err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			util.HTTPError(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		res, err := CloneCourse(db, courseID, CloneOptions{
+			Year: p.Year, Term: p.Term, Code: p.Code, Name: p.Name, ShiftDeadlines: p.ShiftDeadlines,
+		}, uid)
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		util.WriteJSON(w, res, http.StatusCreated)
+	}
+}
+
+// GET /courses/current
+// My enrolled, unarchived courses whose term is running today, grouped by term
+// (across universities).
+func currentCoursesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := session.UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		groups, err := ListCurrentCourses(db, uid, time.Now())
+		if err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		util.WriteJSON(w, groups, http.StatusOK)
+	}
+}
+
+// POST /courses/{id}/archive, POST /courses/{id}/restore
+// Body (optional): { "scope": "me" | "course" }  (default "me")
+// "me" archives the course for the caller only (must be enrolled); "course"
+// archives it for everyone (course curators).
+func archiveCourseHandler(db *sql.DB, courseID int64, archived bool) http.HandlerFunc {
+	type payload struct {
+		Scope string `json:"scope"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := session.UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var p payload
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil && err != io.EOF {
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		switch p.Scope {
+		case "", "me":
+			if err := SetEnrollmentArchived(db, uid, courseID, archived); err != nil {
+				if err == sql.ErrNoRows {
+					util.HTTPError(w, "not enrolled", http.StatusNotFound)
+					return
+				}
+				util.HTTPError(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+		case "course":
+			curator, err := invite.IsCourseCurator(db, uid, courseID)
+			if err == sql.ErrNoRows {
+				util.HTTPError(w, "not found", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				util.HTTPError(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+			if !curator {
+				util.HTTPError(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			if err := SetCourseArchived(db, courseID, archived); err != nil {
+				if err == sql.ErrNoRows {
+					util.HTTPError(w, "not found", http.StatusNotFound)
+					return
+				}
+				util.HTTPError(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+		default:
+			util.HTTPError(w, "scope must be me or course", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
 }
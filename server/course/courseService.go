@@ -2,8 +2,22 @@ package course
 
 import (
 	"database/sql"
-	"errors"
+	"net/http"
 	"strings"
+	"time"
+
+	"example.com/sqlite-server/store"
+	"example.com/sqlite-server/term"
+	"example.com/sqlite-server/util"
+)
+
+// Errors returned by this package.
+var (
+	ErrUniversityNotFound   = util.NewError(http.StatusBadRequest, "university_not_found", "university not found")
+	ErrCourseExists         = util.NewError(http.StatusConflict, "course_exists", "course already exists")
+	ErrCourseHasBooks       = util.NewError(http.StatusConflict, "course_has_books", "course has books")
+	ErrCourseHasArticles    = util.NewError(http.StatusConflict, "course_has_articles", "course has articles")
+	ErrCourseHasAssignments = util.NewError(http.StatusConflict, "course_has_assignments", "course has assignments")
 )
 
 type Course struct {
@@ -13,35 +27,42 @@ type Course struct {
 	Term         int64  `json:"term"` // 1..4
 	Code         string `json:"code"`
 	Name         string `json:"name"`
+	JoinPolicy   string `json:"joinPolicy"`
+	ArchivedAt   *int64 `json:"archivedAt,omitempty"`     // archived for everyone
+	ArchivedByMe *int64 `json:"archivedByMeAt,omitempty"` // archived by the caller (my lists only)
 }
 
-// AddCourse inserts a new course for a university.
-// Validates input, ensures university exists, and maps UNIQUE to a friendly error.
-func AddCourse(db *sql.DB, universityID string, year, term int64, code, name string) (Course, error) {
+// AddCourse inserts a new course for a university, created by createdBy (who can
+// then curate it). Validates input, ensures university exists, and maps UNIQUE to
+// a friendly error.
+func AddCourse(db *sql.DB, universityID string, year, termNum int64, code, name, createdBy string) (Course, error) {
 	code = strings.TrimSpace(code)
 	name = strings.TrimSpace(name)
 	universityID = strings.TrimSpace(universityID)
 
-	if universityID == "" || year <= 0 || term < 1 || term > 4 || code == "" || name == "" {
-		return Course{}, errors.New("invalid input")
+	if universityID == "" || year <= 0 || termNum < 1 || termNum > 4 || code == "" || name == "" {
+		return Course{}, util.ErrInvalidInput
 	}
 
 	// Ensure the university exists.
 	var exists string
 	if err := db.QueryRow(`SELECT id FROM universities WHERE id = ?`, universityID).Scan(&exists); err != nil {
 		if err == sql.ErrNoRows {
-			return Course{}, errors.New("university not found")
+			return Course{}, ErrUniversityNotFound
 		}
 		return Course{}, err
 	}
+	if err := term.CheckCourseTerm(db, universityID, year, termNum); err != nil {
+		return Course{}, err
+	}
 
 	res, err := db.Exec(`
-		INSERT INTO courses (university_id, year, term, code, name)
-		VALUES (?, ?, ?, ?, ?)
-	`, universityID, year, term, code, name)
+		INSERT INTO courses (university_id, year, term, code, name, created_by)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, universityID, year, termNum, code, name, createdBy)
 	if err != nil {
-		if strings.Contains(strings.ToLower(err.Error()), "unique") {
-			return Course{}, errors.New("course already exists")
+		if store.IsUniqueViolation(err) {
+			return Course{}, ErrCourseExists
 		}
 		return Course{}, err
 	}
@@ -53,17 +74,18 @@ func AddCourse(db *sql.DB, universityID string, year, term int64, code, name str
 
 	var c Course
 	if err := db.QueryRow(`
-		SELECT id, university_id, year, term, code, name
+		SELECT id, university_id, year, term, code, name, join_policy, archived_at
 		  FROM courses
 		 WHERE id = ?
-	`, id).Scan(&c.ID, &c.UniversityID, &c.Year, &c.Term, &c.Code, &c.Name); err != nil {
+	`, id).Scan(&c.ID, &c.UniversityID, &c.Year, &c.Term, &c.Code, &c.Name, &c.JoinPolicy, &c.ArchivedAt); err != nil {
 		return Course{}, err
 	}
 	return c, nil
 }
 
 // ListMyCoursesByUniversity returns the caller's enrolled courses for a given university.
-func ListMyCoursesByUniversity(db *sql.DB, userID, universityID string) ([]Course, error) {
+// Courses archived for everyone or by the caller are left out unless includeArchived.
+func ListMyCoursesByUniversity(db *sql.DB, userID, universityID string, includeArchived bool) ([]Course, error) {
 	userID = strings.TrimSpace(userID)
 	universityID = strings.TrimSpace(universityID)
 	if userID == "" || universityID == "" {
@@ -71,13 +93,14 @@ func ListMyCoursesByUniversity(db *sql.DB, userID, universityID string) ([]Cours
 	}
 
 	rows, err := db.Query(`
-		SELECT c.id, c.university_id, c.year, c.term, c.code, c.name
+		SELECT c.id, c.university_id, c.year, c.term, c.code, c.name, c.join_policy, c.archived_at, uc.archived_at
 		  FROM user_courses uc
 		  JOIN courses c ON c.id = uc.course_id
 		 WHERE uc.user_id = ?
 		   AND c.university_id = ?
+		   AND (? OR (c.archived_at IS NULL AND uc.archived_at IS NULL))
 		 ORDER BY c.year DESC, c.term DESC, c.code ASC
-	`, userID, universityID)
+	`, userID, universityID, includeArchived)
 	if err != nil {
 		return nil, err
 	}
@@ -86,7 +109,8 @@ func ListMyCoursesByUniversity(db *sql.DB, userID, universityID string) ([]Cours
 	out := make([]Course, 0, 32)
 	for rows.Next() {
 		var c Course
-		if err := rows.Scan(&c.ID, &c.UniversityID, &c.Year, &c.Term, &c.Code, &c.Name); err != nil {
+		if err := rows.Scan(&c.ID, &c.UniversityID, &c.Year, &c.Term, &c.Code, &c.Name, &c.JoinPolicy,
+			&c.ArchivedAt, &c.ArchivedByMe); err != nil {
 			return nil, err
 		}
 		out = append(out, c)
@@ -94,41 +118,134 @@ func ListMyCoursesByUniversity(db *sql.DB, userID, universityID string) ([]Cours
 	return out, rows.Err()
 }
 
-// ListCoursesByUniversity returns all courses for a given university (catalog view).
-func ListCoursesByUniversity(db *sql.DB, universityID string) ([]Course, error) {
+// CatalogFilter narrows ListCoursesByUniversity; nil fields match everything.
+type CatalogFilter struct {
+	Year            *int64
+	Term            *int64
+	IncludeArchived bool
+}
+
+// catalogSorts are the orders GET /course-catalog accepts; the first is the default.
+// "recent" is newest term first, then by code.
+var catalogSorts = []util.Sort{
+	{Name: "recent", Keys: []string{"-(year * 4 + term)", "code", "id"}},
+	{Name: "code", Keys: []string{"code", "id"}},
+	{Name: "name", Keys: []string{"name", "id"}},
+}
+
+// ListCoursesByUniversity returns a page of a university's courses (catalog view).
+// Archived courses are left out unless f.IncludeArchived.
+func ListCoursesByUniversity(db *sql.DB, universityID string, f CatalogFilter, q util.PageQuery) (util.Page[Course], error) {
 	universityID = strings.TrimSpace(universityID)
 	if universityID == "" {
-		return []Course{}, nil
+		return util.NewPage[Course](nil, q, nil), nil
 	}
 
+	after, args := q.After()
 	rows, err := db.Query(`
-		SELECT id, university_id, year, term, code, name
+		SELECT id, university_id, year, term, code, name, join_policy, archived_at
 		  FROM courses
 		 WHERE university_id = ?
-		 ORDER BY year DESC, term DESC, code ASC
-	`, universityID)
+		   AND (? OR archived_at IS NULL)
+		   AND (? IS NULL OR year = ?)
+		   AND (? IS NULL OR term = ?)
+		   AND `+after+`
+		 ORDER BY `+q.OrderBy()+`
+		 LIMIT ?
+	`, append(append([]any{universityID, f.IncludeArchived, f.Year, f.Year, f.Term, f.Term}, args...), q.FetchLimit())...)
 	if err != nil {
-		return nil, err
+		return util.Page[Course]{}, err
 	}
 	defer rows.Close()
 
-	out := make([]Course, 0, 32)
+	out := make([]Course, 0, q.FetchLimit())
 	for rows.Next() {
 		var c Course
-		if err := rows.Scan(&c.ID, &c.UniversityID, &c.Year, &c.Term, &c.Code, &c.Name); err != nil {
-			return nil, err
+		if err := rows.Scan(&c.ID, &c.UniversityID, &c.Year, &c.Term, &c.Code, &c.Name, &c.JoinPolicy, &c.ArchivedAt); err != nil {
+			return util.Page[Course]{}, err
 		}
 		out = append(out, c)
 	}
-	return out, rows.Err()
+	if err := rows.Err(); err != nil {
+		return util.Page[Course]{}, err
+	}
+	return util.NewPage(out, q, func(c Course) []any {
+		switch q.Sort.Name {
+		case "code":
+			return []any{c.Code, c.ID}
+		case "name":
+			return []any{c.Name, c.ID}
+		}
+		return []any{-(c.Year*4 + c.Term), c.Code, c.ID}
+	}), nil
+}
+
+// TermCourses is a term with the caller's courses in it.
+type TermCourses struct {
+	Term    term.Term `json:"term"`
+	Courses []Course  `json:"courses"`
 }
 
+// ListCurrentCourses returns userID's enrolled courses whose term contains now,
+// grouped by term in start order. Archived courses (for everyone or by userID)
+// are left out.
+func ListCurrentCourses(db *sql.DB, userID string, now time.Time) ([]TermCourses, error) {
+	// Prefilter on dates a day either side (terms use local dates); Contains decides.
+	rows, err := db.Query(`
+		SELECT t.id, c.id, c.university_id, c.year, c.term, c.code, c.name, c.join_policy, c.archived_at
+		  FROM user_courses uc
+		  JOIN courses c ON c.id = uc.course_id
+		  JOIN terms t ON t.university_id = c.university_id AND t.year = c.year AND t.term = c.term
+		 WHERE uc.user_id = ?
+		   AND c.archived_at IS NULL AND uc.archived_at IS NULL
+		   AND t.starts_on <= ? AND t.ends_on >= ?
+		 ORDER BY t.starts_on ASC, t.id ASC, c.code ASC
+	`, userID, now.AddDate(0, 0, 1).UTC().Format("2006-01-02"), now.AddDate(0, 0, -1).UTC().Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	type row struct {
+		termID int64
+		c      Course
+	}
+	var found []row
+	for rows.Next() {
+		var x row
+		if err := rows.Scan(&x.termID, &x.c.ID, &x.c.UniversityID, &x.c.Year, &x.c.Term, &x.c.Code, &x.c.Name,
+			&x.c.JoinPolicy, &x.c.ArchivedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		found = append(found, x)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([]TermCourses, 0, 4)
+	for _, x := range found {
+		if n := len(out); n > 0 && out[n-1].Term.ID == x.termID {
+			out[n-1].Courses = append(out[n-1].Courses, x.c)
+			continue
+		}
+		t, err := term.GetTerm(db, x.termID)
+		if err != nil {
+			return nil, err
+		}
+		if !t.Contains(now) {
+			continue
+		}
+		out = append(out, TermCourses{Term: t, Courses: []Course{x.c}})
+	}
+	return out, nil
+}
 
 // DeleteCourseIfEmpty deletes the course only if it exists AND has no books, articles, or assignments.
 // Returns (false, sql.ErrNoRows) if course doesn't exist.
 func DeleteCourseIfEmpty(db *sql.DB, courseID int64) (bool, error) {
 	if courseID <= 0 {
-		return false, errors.New("invalid input")
+		return false, util.ErrInvalidInput
 	}
 
 	// Ensure course exists.
@@ -147,21 +264,21 @@ func DeleteCourseIfEmpty(db *sql.DB, courseID int64) (bool, error) {
 		return false, err
 	}
 	if cnt > 0 {
-		return false, errors.New("course has books")
+		return false, ErrCourseHasBooks
 	}
 
 	if err := db.QueryRow(`SELECT COUNT(1) FROM articles WHERE course_id = ?`, courseID).Scan(&cnt); err != nil {
 		return false, err
 	}
 	if cnt > 0 {
-		return false, errors.New("course has articles")
+		return false, ErrCourseHasArticles
 	}
 
 	if err := db.QueryRow(`SELECT COUNT(1) FROM assignments WHERE course_id = ?`, courseID).Scan(&cnt); err != nil {
 		return false, err
 	}
 	if cnt > 0 {
-		return false, errors.New("course has assignments")
+		return false, ErrCourseHasAssignments
 	}
 
 	// Safe to delete.
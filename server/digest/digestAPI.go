@@ -0,0 +1,121 @@
+package digest
+
+import (
+	"database/sql"
+	"encoding/json"
+	"html"
+	"net/http"
+	"strings"
+
+	"example.com/sqlite-server/mailer"
+	"example.com/sqlite-server/session"
+	"example.com/sqlite-server/util"
+)
+
+func RegisterDigestRoutes(mux util.Router, db *sql.DB) {
+	mux.HandleFunc("/digest/settings", session.RequireAuth(db, settingsHandler(db)))
+	mux.HandleFunc("/digest/unsubscribe", unsubscribeHandler(db))
+}
+
+// settingsResult adds whether the server can send email at all.
+type settingsResult struct {
+	Settings
+	MailEnabled bool `json:"mailEnabled"`
+}
+
+// GET /digest/settings
+// PUT /digest/settings
+// Body: { "frequency": "off" | "daily" | "weekly", "hour": 8, "weekday": 1, "timezone": "Europe/Amsterdam" }
+// Omitted fields besides frequency keep their current values.
+func settingsHandler(db *sql.DB) http.HandlerFunc {
+	type payload struct {
+		Frequency string  `json:"frequency"`
+		Hour      *int    `json:"hour"`
+		Weekday   *int    `json:"weekday"`
+		Timezone  *string `json:"timezone"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := session.UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			s, err := GetSettings(db, uid)
+			if err != nil {
+				util.WriteError(w, err)
+				return
+			}
+			util.WriteJSON(w, settingsResult{Settings: s, MailEnabled: mailer.Default != nil}, http.StatusOK)
+
+		case http.MethodPut:
+			var p payload
+			dec := json.NewDecoder(r.Body)
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&p); err != nil {
+				util.HTTPError(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			s, err := GetSettings(db, uid)
+			if err != nil {
+				util.WriteError(w, err)
+				return
+			}
+			s.Frequency = p.Frequency
+			if p.Hour != nil {
+				s.Hour = *p.Hour
+			}
+			if p.Weekday != nil {
+				s.Weekday = *p.Weekday
+			}
+			if p.Timezone != nil {
+				s.Timezone = strings.TrimSpace(*p.Timezone)
+			}
+			s, err = SaveSettings(db, uid, s)
+			if err != nil {
+				util.WriteError(w, err)
+				return
+			}
+			util.WriteJSON(w, settingsResult{Settings: s, MailEnabled: mailer.Default != nil}, http.StatusOK)
+
+		default:
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// GET /digest/unsubscribe?token=...   (no auth: the link in each digest)
+// Shows a confirmation button, so link scanners don't unsubscribe anyone.
+// POST /digest/unsubscribe?token=...  (the button, and one-click unsubscribe
+// from mail clients per RFC 8058)
+func unsubscribeHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = w.Write([]byte(`<!doctype html><meta charset="utf-8"><title>Unsubscribe</title>` +
+				`<form method="post" action="?token=` + html.EscapeString(token) + `">` +
+				`<p>Stop sending me deadline digests?</p><button type="submit">Unsubscribe</button></form>`))
+
+		case http.MethodPost:
+			err := Unsubscribe(db, token)
+			if err == sql.ErrNoRows {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`<!doctype html><meta charset="utf-8"><title>Unsubscribe</title>` +
+					`<p>This link is not valid. You can change digest settings in the app.</p>`))
+				return
+			}
+			if err != nil {
+				util.WriteError(w, err)
+				return
+			}
+			_, _ = w.Write([]byte(`<!doctype html><meta charset="utf-8"><title>Unsubscribe</title>` +
+				`<p>You won't get deadline digests anymore. You can turn them back on in the app.</p>`))
+
+		default:
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
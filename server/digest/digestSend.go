@@ -0,0 +1,161 @@
+package digest
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"example.com/sqlite-server/mailer"
+)
+
+// slot is the most recent scheduled send time at or before now.
+func (s Settings) slot(now time.Time, loc *time.Location) time.Time {
+	local := now.In(loc)
+	t := time.Date(local.Year(), local.Month(), local.Day(), s.Hour, 0, 0, 0, loc)
+	if t.After(now) {
+		t = t.AddDate(0, 0, -1)
+	}
+	if s.Frequency == Weekly {
+		for int(t.Weekday()) != s.Weekday {
+			t = t.AddDate(0, 0, -1)
+		}
+	}
+	return t
+}
+
+type recipient struct {
+	userID, email, token string // token: this digest's unsubscribe token
+	settings             Settings
+	loc                  *time.Location
+}
+
+// SendDue sends the digests whose slot has passed since they were last
+// handled. A user with nothing outstanding gets no email, but the slot still
+// counts as handled; a failed send is retried on the next run. Returns the
+// number of emails sent.
+func SendDue(db *sql.DB, m mailer.Mailer, now time.Time) (int, error) {
+	rows, err := db.Query(`
+		SELECT s.user_id, u.email, s.frequency, s.hour, s.weekday, s.timezone,
+		       COALESCE(s.last_sent_at, 0)
+		  FROM digest_settings s
+		  JOIN users u ON u.id = s.user_id
+		 WHERE s.frequency <> 'off' AND u.disabled_at IS NULL
+	`)
+	if err != nil {
+		return 0, err
+	}
+	var due []recipient
+	for rows.Next() {
+		var r recipient
+		var last int64
+		if err := rows.Scan(&r.userID, &r.email, &r.settings.Frequency, &r.settings.Hour,
+			&r.settings.Weekday, &r.settings.Timezone, &last); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if r.loc, err = time.LoadLocation(r.settings.Timezone); err != nil {
+			r.loc = time.UTC
+		}
+		if r.settings.slot(now, r.loc).Unix() > last {
+			due = append(due, r)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, r := range due {
+		ok, err := send(db, m, r, now)
+		if err != nil {
+			log.Printf("digest for %s: %v", r.email, err)
+			continue
+		}
+		if ok {
+			sent++
+		}
+		if _, err := db.Exec(`UPDATE digest_settings SET last_sent_at = ? WHERE user_id = ?`, now.Unix(), r.userID); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// SendNow sends userID's digest right away, whatever their settings (the
+// daily horizon is used while they're off). It reports false when there was
+// nothing to send.
+func SendNow(db *sql.DB, m mailer.Mailer, userID string, now time.Time) (bool, error) {
+	s, err := GetSettings(db, userID)
+	if err != nil {
+		return false, err
+	}
+	if s.Frequency == Off {
+		s.Frequency = Daily
+	}
+	r := recipient{userID: userID, settings: s, loc: time.UTC}
+	if loc, err := time.LoadLocation(s.Timezone); err == nil {
+		r.loc = loc
+	}
+	if err := db.QueryRow(`SELECT email FROM users WHERE id = ?`, userID).Scan(&r.email); err != nil {
+		return false, err
+	}
+	return send(db, m, r, now)
+}
+
+func send(db *sql.DB, m mailer.Mailer, r recipient, now time.Time) (bool, error) {
+	d, err := Build(db, r.userID, r.settings.Frequency, now)
+	if err != nil {
+		return false, err
+	}
+	if d.Empty() {
+		return false, nil
+	}
+	if r.token, err = NewUnsubscribeToken(db, r.userID); err != nil {
+		return false, err
+	}
+	msg := render(d, r)
+	if err := m.Send(msg); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func render(d Digest, r recipient) mailer.Message {
+	days := int(d.Horizon / (24 * time.Hour))
+	var parts []string
+	if n := len(d.Overdue); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d overdue", n))
+	}
+	if n := len(d.Upcoming); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d due in the next %d days", n, days))
+	}
+
+	var b strings.Builder
+	list := func(title string, items []Item) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "%s\n\n", title)
+		for _, it := range items {
+			fmt.Fprintf(&b, "  %s  %s\n", it.Deadline.In(r.loc).Format("Mon 2 Jan 15:04"), it.Summary)
+		}
+		b.WriteString("\n")
+	}
+	list("Overdue", d.Overdue)
+	list(fmt.Sprintf("Due in the next %d days", days), d.Upcoming)
+	fmt.Fprintf(&b, "Times are in %s. Open the app: %s/\n", r.loc, AppURL)
+
+	msg := mailer.Message{To: r.email, Subject: "Your deadlines: " + strings.Join(parts, ", "), Headers: map[string]string{}}
+	if r.token != "" {
+		link := AppURL + "/api/digest/unsubscribe?token=" + url.QueryEscape(r.token)
+		fmt.Fprintf(&b, "\nYou get this %s because you asked for it. To stop: %s\n", r.settings.Frequency, link)
+		msg.Headers["List-Unsubscribe"] = "<" + link + ">"
+		msg.Headers["List-Unsubscribe-Post"] = "List-Unsubscribe=One-Click"
+	}
+	msg.Text = b.String()
+	return msg
+}
@@ -0,0 +1,44 @@
+package digest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlot(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, berlin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	for _, tc := range []struct {
+		name string
+		s    Settings
+		now  string
+		want string
+	}{
+		{"daily, after the hour", Settings{Frequency: Daily, Hour: 8}, "2026-03-11 09:30", "2026-03-11 08:00"},
+		{"daily, on the hour", Settings{Frequency: Daily, Hour: 8}, "2026-03-11 08:00", "2026-03-11 08:00"},
+		{"daily, before the hour", Settings{Frequency: Daily, Hour: 8}, "2026-03-11 07:59", "2026-03-10 08:00"},
+		{"daily, across a month", Settings{Frequency: Daily, Hour: 23}, "2026-03-01 06:00", "2026-02-28 23:00"},
+		{"weekly, same day after", Settings{Frequency: Weekly, Hour: 8, Weekday: 3}, "2026-03-11 10:00", "2026-03-11 08:00"},
+		{"weekly, same day before", Settings{Frequency: Weekly, Hour: 8, Weekday: 3}, "2026-03-11 07:00", "2026-03-04 08:00"},
+		{"weekly, later in the week", Settings{Frequency: Weekly, Hour: 8, Weekday: 1}, "2026-03-14 12:00", "2026-03-09 08:00"},
+		{"daily, after spring forward", Settings{Frequency: Daily, Hour: 8}, "2026-03-29 09:00", "2026-03-29 08:00"},
+		{"daily, before spring forward", Settings{Frequency: Daily, Hour: 8}, "2026-03-29 07:00", "2026-03-28 08:00"},
+	} {
+		now := at(tc.now)
+		// The caller's clock is in UTC; the slot is in the user's zone.
+		got := tc.s.slot(now.UTC(), berlin)
+		if want := at(tc.want); !got.Equal(want) || got.Location() != berlin {
+			t.Errorf("%s: slot(%s) = %s, want %s", tc.name, tc.now, got, want)
+		}
+	}
+}
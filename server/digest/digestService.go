@@ -0,0 +1,202 @@
+// Package digest emails users a daily or weekly summary of their overdue and
+// upcoming deadlines, read from calendar_index. Digests are opt-in.
+package digest
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"example.com/sqlite-server/util"
+)
+
+var (
+	ErrInvalidFrequency = util.NewError(http.StatusBadRequest, "invalid_frequency", "frequency must be off, daily or weekly")
+	ErrInvalidSchedule  = util.NewError(http.StatusBadRequest, "invalid_schedule", "hour must be 0-23 and weekday 0-6")
+	ErrInvalidTimezone  = util.NewError(http.StatusBadRequest, "invalid_timezone", "invalid timezone")
+)
+
+// Frequencies.
+const (
+	Off    = "off"
+	Daily  = "daily"
+	Weekly = "weekly"
+)
+
+// AppURL is where links in digests point; serve sets it from APP_URL.
+var AppURL = "http://localhost:8080"
+
+// Settings are a user's digest preferences. Digests go out at Hour (and on
+// Weekday, for weekly ones) in Timezone.
+type Settings struct {
+	Frequency  string `json:"frequency" enum:"off|daily|weekly"`
+	Hour       int    `json:"hour" doc:"0-23"`
+	Weekday    int    `json:"weekday" doc:"weekly digests: 0 = Sunday ... 6 = Saturday"`
+	Timezone   string `json:"timezone"`
+	LastSentAt *int64 `json:"last_sent_at,omitempty"`
+}
+
+// defaults are the settings of a user who never saved any.
+var defaults = Settings{Frequency: Off, Hour: 8, Weekday: 1, Timezone: "UTC"}
+
+// GetSettings returns userID's settings (the defaults if none were saved).
+func GetSettings(db *sql.DB, userID string) (Settings, error) {
+	s := defaults
+	var last sql.NullInt64
+	err := db.QueryRow(`
+		SELECT frequency, hour, weekday, timezone, last_sent_at FROM digest_settings WHERE user_id = ?
+	`, userID).Scan(&s.Frequency, &s.Hour, &s.Weekday, &s.Timezone, &last)
+	if err == sql.ErrNoRows {
+		return defaults, nil
+	}
+	if err != nil {
+		return Settings{}, err
+	}
+	if last.Valid {
+		s.LastSentAt = &last.Int64
+	}
+	return s, nil
+}
+
+// SaveSettings validates and stores s. Digests are counted from now, so
+// switching them on at 9:00 with an 8:00 slot sends the first one tomorrow.
+// Errors: ErrInvalidFrequency, ErrInvalidSchedule, ErrInvalidTimezone.
+func SaveSettings(db *sql.DB, userID string, s Settings) (Settings, error) {
+	switch s.Frequency {
+	case Off, Daily, Weekly:
+	default:
+		return Settings{}, ErrInvalidFrequency
+	}
+	if s.Hour < 0 || s.Hour > 23 || s.Weekday < 0 || s.Weekday > 6 {
+		return Settings{}, ErrInvalidSchedule
+	}
+	if s.Timezone == "" {
+		s.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return Settings{}, ErrInvalidTimezone
+	}
+
+	now := time.Now().Unix()
+	s.LastSentAt = &now
+	if _, err := db.Exec(`
+		INSERT INTO digest_settings (user_id, frequency, hour, weekday, timezone, last_sent_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			frequency = excluded.frequency, hour = excluded.hour, weekday = excluded.weekday,
+			timezone = excluded.timezone, last_sent_at = excluded.last_sent_at
+	`, userID, s.Frequency, s.Hour, s.Weekday, s.Timezone, now); err != nil {
+		return Settings{}, err
+	}
+	return s, nil
+}
+
+// KeepUnsubscribeTokens is how long the unsubscribe link in a digest works.
+const KeepUnsubscribeTokens = 365 * 24 * time.Hour
+
+// NewUnsubscribeToken mints the unsubscribe token for one digest to userID.
+// The token is returned once; only its hash is kept.
+func NewUnsubscribeToken(db *sql.DB, userID string) (string, error) {
+	buf := make([]byte, 18)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	if _, err := db.Exec(`
+		INSERT INTO digest_unsubscribe_tokens (token_hash, user_id) VALUES (?, ?)
+	`, util.HashToken(token), userID); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Unsubscribe turns digests off for the owner of token. sql.ErrNoRows if the
+// token is unknown.
+func Unsubscribe(db *sql.DB, token string) error {
+	var userID string
+	if err := db.QueryRow(`
+		SELECT user_id FROM digest_unsubscribe_tokens WHERE token_hash = ?
+	`, util.HashToken(strings.TrimSpace(token))).Scan(&userID); err != nil {
+		return err
+	}
+	_, err := db.Exec(`UPDATE digest_settings SET frequency = 'off' WHERE user_id = ?`, userID)
+	return err
+}
+
+// PruneUnsubscribeTokens drops the tokens minted before cutoff.
+func PruneUnsubscribeTokens(db *sql.DB, cutoff time.Time) (int64, error) {
+	res, err := db.Exec(`DELETE FROM digest_unsubscribe_tokens WHERE created_at < ?`, cutoff.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Item is one deadline in a digest.
+type Item struct {
+	Kind     string
+	SourceID int64
+	Summary  string
+	Deadline time.Time
+}
+
+// Digest is what a user has outstanding.
+type Digest struct {
+	Overdue  []Item
+	Upcoming []Item
+	Horizon  time.Duration // how far ahead Upcoming looks
+}
+
+func (d Digest) Empty() bool { return len(d.Overdue) == 0 && len(d.Upcoming) == 0 }
+
+// overdueWindow bounds how far back overdue items are listed; older ones are
+// presumably abandoned.
+const overdueWindow = 14 * 24 * time.Hour
+
+// horizon is how far ahead a digest looks: until a little past the next one.
+func horizon(frequency string) time.Duration {
+	if frequency == Weekly {
+		return 14 * 24 * time.Hour
+	}
+	return 3 * 24 * time.Hour
+}
+
+// Build collects userID's incomplete items due within the horizon or overdue.
+// calendar_index doesn't follow progress, so completion is read from progress.
+func Build(db *sql.DB, userID string, frequency string, now time.Time) (Digest, error) {
+	d := Digest{Horizon: horizon(frequency)}
+	rows, err := db.Query(`
+		SELECT c.kind, c.source_id, c.summary, c.deadline_epoch
+		  FROM calendar_index c
+		 WHERE c.user_id = ? AND c.cancelled_at IS NULL
+		   AND c.deadline_epoch >= ? AND c.deadline_epoch < ?
+		   AND NOT EXISTS (
+		         SELECT 1 FROM progress p
+		          WHERE p.user_id = c.user_id AND p.completed = 1
+		            AND CASE c.kind WHEN 'chapter' THEN p.chapter_id
+		                            WHEN 'article' THEN p.article_id
+		                            WHEN 'assignment' THEN p.assignment_id END = c.source_id)
+		 ORDER BY c.deadline_epoch, c.kind, c.source_id
+	`, userID, now.Add(-overdueWindow).Unix(), now.Add(d.Horizon).Unix())
+	if err != nil {
+		return Digest{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var it Item
+		var deadline int64
+		if err := rows.Scan(&it.Kind, &it.SourceID, &it.Summary, &deadline); err != nil {
+			return Digest{}, err
+		}
+		it.Deadline = time.Unix(deadline, 0)
+		if it.Deadline.Before(now) {
+			d.Overdue = append(d.Overdue, it)
+		} else {
+			d.Upcoming = append(d.Upcoming, it)
+		}
+	}
+	return d, rows.Err()
+}
@@ -0,0 +1,54 @@
+package digest
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"example.com/sqlite-server/store"
+)
+
+func TestUnsubscribe(t *testing.T) {
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`INSERT INTO users (id, email, password) VALUES ('u1', 'a@x.io', '')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SaveSettings(db, "u1", Settings{Frequency: Daily, Hour: 8}); err != nil {
+		t.Fatal(err)
+	}
+	older, err := NewUnsubscribeToken(db, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewUnsubscribeToken(db, "u1"); err != nil {
+		t.Fatal(err)
+	}
+	var stored int
+	if err := db.QueryRow(`SELECT COUNT(1) FROM digest_unsubscribe_tokens WHERE token_hash = ?`, older).Scan(&stored); err != nil || stored != 0 {
+		t.Errorf("token stored in plaintext (%d, %v)", stored, err)
+	}
+
+	// The link in an older digest still works.
+	if err := Unsubscribe(db, older+"\n"); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := GetSettings(db, "u1"); err != nil || s.Frequency != Off {
+		t.Errorf("after unsubscribe: %+v, %v", s, err)
+	}
+	if err := Unsubscribe(db, "nope"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("unknown token: %v, want sql.ErrNoRows", err)
+	}
+
+	if n, err := PruneUnsubscribeTokens(db, time.Now().Add(time.Minute)); err != nil || n != 2 {
+		t.Errorf("prune = %d, %v; want 2", n, err)
+	}
+	if err := Unsubscribe(db, older); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("pruned token: %v, want sql.ErrNoRows", err)
+	}
+}
@@ -0,0 +1,155 @@
+// Package events is an in-process bus of course changes. Services publish
+// after a change is committed; GET /events streams them to the users enrolled
+// in the course.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	TypeCreated  = "created"
+	TypeDeleted  = "deleted"
+	TypeDeadline = "deadline"
+	TypeProgress = "progress"
+)
+
+// Item kinds.
+const (
+	KindBook       = "book"
+	KindChapter    = "chapter"
+	KindArticle    = "article"
+	KindAssignment = "assignment"
+)
+
+// Event is one change in a course.
+type Event struct {
+	ID        int64  `json:"id"`
+	Type      string `json:"type" enum:"created|deleted|deadline|progress"`
+	Kind      string `json:"kind" enum:"book|chapter|article|assignment"`
+	ItemID    int64  `json:"itemId"`
+	CourseID  int64  `json:"courseId"`
+	Deadline  *int64 `json:"deadline,omitempty" doc:"deadline events; absent when cleared"`
+	Completed *bool  `json:"completed,omitempty" doc:"progress events"`
+	Item      any    `json:"item,omitempty" doc:"created events: the new item"`
+	At        int64  `json:"at"`
+
+	// UserID limits the event to one user: progress is personal, so it only
+	// reaches the user's other tabs and devices.
+	UserID string `json:"-"`
+}
+
+// Bus fans events out to subscribers and keeps the most recent ones so a
+// reconnecting client can catch up.
+type Bus struct {
+	mu      sync.Mutex
+	last    int64
+	history []Event // oldest first
+	keep    int
+	subs    map[*Subscription]struct{}
+}
+
+// NewBus returns a bus remembering the last keep events. IDs start at the
+// current time in microseconds, so IDs from before a restart are older than
+// anything the new bus has and are recognised as unrecoverable.
+func NewBus(keep int) *Bus {
+	return &Bus{
+		last: time.Now().UnixMicro(),
+		keep: keep,
+		subs: map[*Subscription]struct{}{},
+	}
+}
+
+// Default is the bus the services publish to.
+var Default = NewBus(1024)
+
+// Publish sends e on the Default bus.
+func Publish(e Event) { Default.Publish(e) }
+
+// subscriberBuffer is how far a subscriber may fall behind before it is
+// dropped (and reconnects with Last-Event-ID).
+const subscriberBuffer = 64
+
+// Publish assigns e its ID and delivers it. It never blocks on a slow
+// subscriber: one whose buffer is full is closed instead.
+func (b *Bus) Publish(e Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.last++
+	e.ID = b.last
+	if e.At == 0 {
+		e.At = time.Now().Unix()
+	}
+	b.history = append(b.history, e)
+	if len(b.history) > b.keep {
+		b.history = b.history[len(b.history)-b.keep:]
+	}
+	for s := range b.subs {
+		select {
+		case s.c <- e:
+		default:
+			delete(b.subs, s)
+			close(s.c)
+		}
+	}
+	return e
+}
+
+// Subscription receives events until it is closed.
+type Subscription struct {
+	bus *Bus
+	c   chan Event
+}
+
+// C delivers events in order. It is closed when the subscriber falls too far
+// behind.
+func (s *Subscription) C() <-chan Event { return s.c }
+
+// Close stops delivery. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if _, ok := s.bus.subs[s]; ok {
+		delete(s.bus.subs, s)
+		close(s.c)
+	}
+}
+
+// CloseSubscriptions ends every current subscription, so event streams
+// finish (and their clients reconnect elsewhere) when the server shuts down.
+func (b *Bus) CloseSubscriptions() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		delete(b.subs, s)
+		close(s.c)
+	}
+}
+
+// Subscribe starts a subscription. With lastID > 0 it also returns the kept
+// events published after lastID; complete is false when some of those are no
+// longer kept (or lastID is not one of this bus's), and the caller has to
+// resynchronise some other way.
+func (b *Bus) Subscribe(lastID int64) (sub *Subscription, backlog []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub = &Subscription{bus: b, c: make(chan Event, subscriberBuffer)}
+	b.subs[sub] = struct{}{}
+
+	if lastID <= 0 {
+		return sub, nil, true
+	}
+	if lastID > b.last || lastID < b.last-int64(len(b.history)) {
+		return sub, nil, false
+	}
+	for _, e := range b.history {
+		if e.ID > lastID {
+			backlog = append(backlog, e)
+		}
+	}
+	return sub, backlog, true
+}
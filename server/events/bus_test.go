@@ -0,0 +1,65 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlowSubscriberDoesNotBlockPublish(t *testing.T) {
+	bus := NewBus(16)
+	slow, _, _ := bus.Subscribe(0) // never reads
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10*subscriberBuffer; i++ {
+			bus.Publish(Event{Type: TypeCreated, CourseID: 1})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Publish blocked on a subscriber that does not read")
+	}
+
+	// The slow subscriber keeps what fit in its buffer and is then dropped.
+	n := 0
+	for range slow.C() {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("slow subscriber got %d events, want %d", n, subscriberBuffer)
+	}
+	if len(bus.subs) != 0 {
+		t.Errorf("%d subscriptions left", len(bus.subs))
+	}
+	slow.Close() // already dropped; must not panic
+}
+
+func TestSubscribeReplaysMissedEvents(t *testing.T) {
+	bus := NewBus(3)
+	var ids []int64
+	for i := 0; i < 5; i++ {
+		ids = append(ids, bus.Publish(Event{Type: TypeCreated, CourseID: 1}).ID)
+	}
+
+	sub, backlog, complete := bus.Subscribe(ids[2])
+	defer sub.Close()
+	if !complete || len(backlog) != 2 || backlog[0].ID != ids[3] || backlog[1].ID != ids[4] {
+		t.Fatalf("after %d: backlog %+v, complete %v", ids[2], backlog, complete)
+	}
+
+	// ids[0] has been forgotten: the caller has to resynchronise.
+	old, backlog, complete := bus.Subscribe(ids[0])
+	defer old.Close()
+	if complete || len(backlog) != 0 {
+		t.Fatalf("after %d: backlog %+v, complete %v", ids[0], backlog, complete)
+	}
+	// So has anything from before a restart.
+	time.Sleep(time.Millisecond)
+	again, _, complete := NewBus(3).Subscribe(ids[4])
+	defer again.Close()
+	if complete {
+		t.Fatal("an ID from another bus was accepted")
+	}
+}
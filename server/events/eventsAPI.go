@@ -0,0 +1,156 @@
+package events
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"example.com/sqlite-server/session"
+	"example.com/sqlite-server/util"
+)
+
+func RegisterEventRoutes(mux util.Router, db *sql.DB) {
+	mux.HandleFunc("/events", session.RequireAuth(db, streamHandler(db, Default)))
+}
+
+// keepAlive is how often an idle stream gets a comment line, so proxies keep
+// it open; the caller's enrollments are re-read at the same pace.
+const keepAlive = 25 * time.Second
+
+// GET /events
+//
+// A text/event-stream of changes in the caller's courses. Each change is a
+// message whose data is an Event and whose id can be sent back as
+// Last-Event-ID (browsers do this on reconnect; ?lastEventId= works too) to
+// receive what was missed. If that is no longer possible the stream starts
+// with a "reset" event and the client should reload its data.
+func streamHandler(db *sql.DB, bus *Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		userID, ok := session.UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var lastID int64
+		if v := r.Header.Get("Last-Event-ID"); v != "" {
+			lastID, _ = strconv.ParseInt(v, 10, 64)
+		} else if v := r.URL.Query().Get("lastEventId"); v != "" {
+			lastID, _ = strconv.ParseInt(v, 10, 64)
+		}
+
+		courses, err := enrolledCourses(db, userID)
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+
+		// Subscribe before writing anything so nothing published meanwhile
+		// is lost.
+		sub, backlog, complete := bus.Subscribe(lastID)
+		defer sub.Close()
+
+		rc := http.NewResponseController(w)
+		_ = rc.SetWriteDeadline(time.Time{}) // the stream outlives any server write timeout
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no") // nginx: don't buffer
+		w.WriteHeader(http.StatusOK)
+
+		fmt.Fprintf(w, "retry: 5000\n\n")
+		if !complete {
+			fmt.Fprintf(w, "event: reset\ndata: {}\n\n")
+		}
+		for _, e := range backlog {
+			if visible(db, e, userID, courses) {
+				writeEvent(w, e)
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		ticker := time.NewTicker(keepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case e, ok := <-sub.C():
+				if !ok {
+					return // fell behind; the client reconnects and catches up
+				}
+				if !visible(db, e, userID, courses) {
+					continue
+				}
+				writeEvent(w, e)
+			case <-ticker.C:
+				if c, err := enrolledCourses(db, userID); err == nil {
+					courses = c
+				} else {
+					log.Printf("events: enrollments for %s: %v", userID, err)
+				}
+				fmt.Fprintf(w, ": keep-alive\n\n")
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, e Event) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("events: encoding event %d: %v", e.ID, err)
+		return
+	}
+	fmt.Fprintf(w, "id: %d\ndata: %s\n\n", e.ID, data)
+}
+
+// visible reports whether e goes to userID. courses caches which courses
+// the user is enrolled in; one it hasn't seen yet (just joined, say) is
+// looked up.
+func visible(db *sql.DB, e Event, userID string, courses map[int64]bool) bool {
+	if e.UserID != "" && e.UserID != userID {
+		return false
+	}
+	enrolled, known := courses[e.CourseID]
+	if !known {
+		var one int
+		err := db.QueryRow(`SELECT 1 FROM user_courses WHERE user_id = ? AND course_id = ?`, userID, e.CourseID).Scan(&one)
+		if err != nil && err != sql.ErrNoRows {
+			log.Printf("events: enrollment of %s in %d: %v", userID, e.CourseID, err)
+			return false
+		}
+		enrolled = err == nil
+		courses[e.CourseID] = enrolled
+	}
+	return enrolled
+}
+
+func enrolledCourses(db *sql.DB, userID string) (map[int64]bool, error) {
+	rows, err := db.Query(`SELECT course_id FROM user_courses WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	courses := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		courses[id] = true
+	}
+	return courses, rows.Err()
+}
@@ -0,0 +1,190 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/sqlite-server/session"
+	"example.com/sqlite-server/store"
+)
+
+// streamFixture serves the event stream of its own bus. Users u1 and u2 are
+// enrolled in course 1; only u2 in course 2.
+type streamFixture struct {
+	db      *sql.DB
+	bus     *Bus
+	srv     *httptest.Server
+	running sync.WaitGroup // handlers still streaming
+}
+
+func newStreamFixture(t *testing.T) *streamFixture {
+	t.Helper()
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, q := range []string{
+		`INSERT INTO users (id, email, password) VALUES ('u1', 'a@x.io', ''), ('u2', 'b@x.io', '')`,
+		`INSERT INTO universities (id, name) VALUES ('uni', 'Uni')`,
+		`INSERT INTO courses (id, university_id, year, term, code, name) VALUES
+		   (1, 'uni', 2026, 1, 'A', 'A'), (2, 'uni', 2026, 1, 'B', 'B'), (3, 'uni', 2026, 1, 'C', 'C')`,
+		`INSERT INTO user_courses (user_id, course_id) VALUES ('u1', 1), ('u2', 1), ('u2', 2)`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+
+	f := &streamFixture{db: db, bus: NewBus(16)}
+	h := session.RequireAuth(db, streamHandler(db, f.bus))
+	f.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.running.Add(1)
+		defer f.running.Done()
+		h(w, r)
+	}))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+// open starts a stream as userID and returns its events.
+func (f *streamFixture) open(t *testing.T, ctx context.Context, userID string) <-chan Event {
+	t.Helper()
+	s, err := session.CreateSession(f.db, userID, false, session.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, f.srv.URL+"/events", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: s.ID})
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /events as %s: %s", userID, resp.Status)
+	}
+
+	out := make(chan Event, 16)
+	go func() {
+		defer resp.Body.Close()
+		defer close(out)
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			data, ok := strings.CutPrefix(sc.Text(), "data: ")
+			if !ok || data == "{}" {
+				continue
+			}
+			var e Event
+			if json.Unmarshal([]byte(data), &e) == nil {
+				out <- e
+			}
+		}
+	}()
+	return out
+}
+
+// waitFor polls cond for a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for " + what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (f *streamFixture) subscribers() int {
+	f.bus.mu.Lock()
+	defer f.bus.mu.Unlock()
+	return len(f.bus.subs)
+}
+
+func TestStreamOnlyCarriesVisibleEvents(t *testing.T) {
+	f := newStreamFixture(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	u1 := f.open(t, ctx, "u1")
+	u2 := f.open(t, ctx, "u2")
+	waitFor(t, "both subscriptions", func() bool { return f.subscribers() == 2 })
+
+	completed := true
+	published := []Event{
+		{Type: TypeCreated, Kind: KindBook, ItemID: 10, CourseID: 2},                                          // u2 only
+		{Type: TypeProgress, Kind: KindArticle, ItemID: 11, CourseID: 1, Completed: &completed, UserID: "u2"}, // u2's own
+		{Type: TypeCreated, Kind: KindArticle, ItemID: 12, CourseID: 1},                                       // both
+		{Type: TypeCreated, Kind: KindBook, ItemID: 13, CourseID: 3},                                          // nobody
+		{Type: TypeDeleted, Kind: KindArticle, ItemID: 14, CourseID: 1},                                       // both; last
+	}
+	for _, e := range published {
+		f.bus.Publish(e)
+	}
+
+	for _, c := range []struct {
+		user  string
+		in    <-chan Event
+		items []int64
+	}{
+		{"u1", u1, []int64{12, 14}},
+		{"u2", u2, []int64{10, 11, 12, 14}},
+	} {
+		var got []int64
+		for e := range c.in {
+			got = append(got, e.ItemID)
+			if e.ItemID == 14 {
+				break
+			}
+		}
+		if len(got) != len(c.items) {
+			t.Errorf("%s got items %v, want %v", c.user, got, c.items)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.items[i] {
+				t.Errorf("%s got items %v, want %v", c.user, got, c.items)
+				break
+			}
+		}
+	}
+}
+
+func TestStreamEndsWhenClientDisconnects(t *testing.T) {
+	f := newStreamFixture(t)
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := f.open(t, ctx, "u1")
+	waitFor(t, "the subscription", func() bool { return f.subscribers() == 1 })
+	f.bus.Publish(Event{Type: TypeCreated, Kind: KindBook, ItemID: 1, CourseID: 1})
+	if e := <-events; e.ItemID != 1 {
+		t.Fatalf("got %+v", e)
+	}
+
+	cancel()
+	for range events {
+	}
+	handlersDone := make(chan struct{})
+	go func() { f.running.Wait(); close(handlersDone) }()
+	select {
+	case <-handlersDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the handler kept streaming after the client went away")
+	}
+	if n := f.subscribers(); n != 0 {
+		t.Fatalf("%d subscriptions left after disconnect", n)
+	}
+
+	f.srv.CloseClientConnections()
+	http.DefaultClient.CloseIdleConnections()
+	waitFor(t, "goroutines to exit", func() bool { return runtime.NumGoroutine() <= before })
+}
@@ -5,6 +5,7 @@ go 1.25.1
 require (
 	github.com/google/uuid v1.6.0
 	golang.org/x/crypto v0.42.0
+	golang.org/x/text v0.29.0
 	modernc.org/sqlite v1.39.0
 )
 
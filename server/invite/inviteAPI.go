@@ -0,0 +1,394 @@
+package invite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/sqlite-server/session"
+	"example.com/sqlite-server/util"
+)
+
+func RegisterInviteRoutes(mux util.Router, db *sql.DB) {
+	mux.HandleFunc("/invites", session.RequireAuth(db, invitesHandler(db)))
+	mux.HandleFunc("/invites/accept", session.RequireAuth(db, acceptInviteHandler(db)))
+	mux.HandleFunc("/join-policy", session.RequireAuth(db, joinPolicyHandler(db)))
+	mux.HandleFunc("/join-requests", session.RequireAuth(db, joinRequestsHandler(db)))
+	mux.HandleFunc("/join-requests/approve", session.RequireAuth(db, decideRequestHandler(db, true)))
+	mux.HandleFunc("/join-requests/reject", session.RequireAuth(db, decideRequestHandler(db, false)))
+}
+
+// targetFromQuery reads ?universityId= or ?courseId=.
+func targetFromQuery(r *http.Request) (Target, error) {
+	q := r.URL.Query()
+	var cid int64
+	if s := strings.TrimSpace(q.Get("courseId")); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return Target{}, err
+		}
+		cid = n
+	}
+	return NewTarget(strings.TrimSpace(q.Get("universityId")), cid)
+}
+
+// requireCurator writes the error response and returns false unless uid curates t.
+func requireCurator(w http.ResponseWriter, db *sql.DB, uid string, t Target) bool {
+	ok, err := CanCurate(db, uid, t)
+	if err == sql.ErrNoRows {
+		util.HTTPError(w, "course not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		util.HTTPError(w, "internal error", http.StatusInternalServerError)
+		return false
+	}
+	if !ok {
+		util.HTTPError(w, "forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func invitesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			listInvitesHandler(db)(w, r)
+		case http.MethodPost:
+			createInviteHandler(db)(w, r)
+		case http.MethodDelete:
+			revokeInviteHandler(db)(w, r)
+		default:
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// GET /invites?universityId=... | ?courseId=...  (curators)
+func listInvitesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, _ := session.UserIDFromCtx(r.Context())
+		t, err := targetFromQuery(r)
+		if err != nil {
+			util.WriteError(w, ErrInvalidTarget)
+			return
+		}
+		if !requireCurator(w, db, uid, t) {
+			return
+		}
+		list, err := ListInvites(db, t)
+		if err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		util.WriteJSON(w, list, http.StatusOK)
+	}
+}
+
+// POST /invites  (curators)
+// Body: { "universityId": "..." | "courseId": 1, "expiresInDays": 7, "maxUses": 30 }
+// Both limits are optional; the code is only returned here.
+func createInviteHandler(db *sql.DB) http.HandlerFunc {
+	type payload struct {
+		UniversityID  string `json:"universityId"`
+		CourseID      int64  `json:"courseId"`
+		ExpiresInDays int    `json:"expiresInDays"`
+		MaxUses       int64  `json:"maxUses"`
+	}
+	type response struct {
+		Invite
+		Code string `json:"code"`
+		Link string `json:"link"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, _ := session.UserIDFromCtx(r.Context())
+
+		var p payload
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil {
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		t, err := NewTarget(strings.TrimSpace(p.UniversityID), p.CourseID)
+		if err != nil {
+			util.WriteError(w, ErrInvalidTarget)
+			return
+		}
+		if p.ExpiresInDays < 0 || p.ExpiresInDays > 365 || p.MaxUses < 0 {
+			util.HTTPError(w, "invalid limits", http.StatusBadRequest)
+			return
+		}
+		if !requireCurator(w, db, uid, t) {
+			return
+		}
+
+		inv, code, err := CreateInvite(db, uid, t, time.Duration(p.ExpiresInDays)*24*time.Hour, p.MaxUses)
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		util.WriteJSON(w, response{Invite: inv, Code: code, Link: "/#/join/" + code}, http.StatusCreated)
+	}
+}
+
+// DELETE /invites  (curators)
+// Body: { "inviteId": "..." }
+func revokeInviteHandler(db *sql.DB) http.HandlerFunc {
+	type payload struct {
+		InviteID string `json:"inviteId"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, _ := session.UserIDFromCtx(r.Context())
+
+		var p payload
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil || strings.TrimSpace(p.InviteID) == "" {
+			util.HTTPError(w, "inviteId is required", http.StatusBadRequest)
+			return
+		}
+		t, err := InviteTarget(db, p.InviteID)
+		if err == sql.ErrNoRows {
+			util.HTTPError(w, "invite not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if !requireCurator(w, db, uid, t) {
+			return
+		}
+		if err := RevokeInvite(db, p.InviteID); err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// POST /invites/accept
+// Body: { "code": "..." }
+func acceptInviteHandler(db *sql.DB) http.HandlerFunc {
+	type payload struct {
+		Code string `json:"code"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		uid, _ := session.UserIDFromCtx(r.Context())
+
+		var p payload
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil || strings.TrimSpace(p.Code) == "" {
+			util.HTTPError(w, "code is required", http.StatusBadRequest)
+			return
+		}
+
+		out, err := AcceptInvite(db, uid, p.Code)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				util.WriteProblem(w, http.StatusNotFound, "invalid_invite", "invalid invite")
+				return
+			}
+			util.WriteError(w, err)
+			return
+		}
+		util.WriteJSON(w, out, http.StatusOK)
+	}
+}
+
+// PATCH /join-policy  (curators)
+// Body: { "universityId": "..." | "courseId": 1, "joinPolicy": "open" | "invite" | "approval" }
+func joinPolicyHandler(db *sql.DB) http.HandlerFunc {
+	type payload struct {
+		UniversityID string `json:"universityId"`
+		CourseID     int64  `json:"courseId"`
+		JoinPolicy   string `json:"joinPolicy"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		uid, _ := session.UserIDFromCtx(r.Context())
+
+		var p payload
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil {
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		t, err := NewTarget(strings.TrimSpace(p.UniversityID), p.CourseID)
+		if err != nil {
+			util.WriteError(w, ErrInvalidTarget)
+			return
+		}
+		if !ValidPolicy(p.JoinPolicy) {
+			util.WriteError(w, ErrInvalidPolicy)
+			return
+		}
+		if !requireCurator(w, db, uid, t) {
+			return
+		}
+		if err := SetPolicy(db, t, p.JoinPolicy); err != nil {
+			if err == sql.ErrNoRows {
+				util.HTTPError(w, "not found", http.StatusNotFound)
+				return
+			}
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		util.WriteJSON(w, map[string]string{"joinPolicy": p.JoinPolicy}, http.StatusOK)
+	}
+}
+
+func joinRequestsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			listRequestsHandler(db)(w, r)
+		case http.MethodDelete:
+			cancelRequestHandler(db)(w, r)
+		default:
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// GET /join-requests                       (my own requests)
+// GET /join-requests?universityId=...|courseId=...[&status=pending]  (curators)
+func listRequestsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, _ := session.UserIDFromCtx(r.Context())
+		q := r.URL.Query()
+
+		if q.Get("universityId") == "" && q.Get("courseId") == "" {
+			list, err := ListMyRequests(db, uid)
+			if err != nil {
+				util.HTTPError(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+			util.WriteJSON(w, list, http.StatusOK)
+			return
+		}
+
+		t, err := targetFromQuery(r)
+		if err != nil {
+			util.WriteError(w, ErrInvalidTarget)
+			return
+		}
+		status := q.Get("status")
+		if status == "" {
+			status = "pending"
+		}
+		if status != "pending" && status != "approved" && status != "rejected" {
+			util.HTTPError(w, "invalid status", http.StatusBadRequest)
+			return
+		}
+		if !requireCurator(w, db, uid, t) {
+			return
+		}
+		list, err := ListRequests(db, t, status)
+		if err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		util.WriteJSON(w, list, http.StatusOK)
+	}
+}
+
+// DELETE /join-requests  (withdraw my pending request)
+// Body: { "requestId": 1 }
+func cancelRequestHandler(db *sql.DB) http.HandlerFunc {
+	type payload struct {
+		RequestID int64 `json:"requestId"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, _ := session.UserIDFromCtx(r.Context())
+
+		var p payload
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil || p.RequestID <= 0 {
+			util.HTTPError(w, "requestId is required", http.StatusBadRequest)
+			return
+		}
+		if err := CancelRequest(db, uid, p.RequestID); err != nil {
+			if err == sql.ErrNoRows {
+				util.HTTPError(w, "request not found", http.StatusNotFound)
+				return
+			}
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// POST /join-requests/approve, /join-requests/reject  (curators)
+// Body: { "requestId": 1 }
+func decideRequestHandler(db *sql.DB, approve bool) http.HandlerFunc {
+	type payload struct {
+		RequestID int64 `json:"requestId"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		uid, _ := session.UserIDFromCtx(r.Context())
+
+		var p payload
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil || p.RequestID <= 0 {
+			util.HTTPError(w, "requestId is required", http.StatusBadRequest)
+			return
+		}
+
+		t, err := RequestTarget(db, p.RequestID)
+		if err == sql.ErrNoRows {
+			util.HTTPError(w, "request not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if !requireCurator(w, db, uid, t) {
+			return
+		}
+
+		if err := DecideRequest(db, p.RequestID, uid, approve); err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// RespondByPolicy answers a join attempt (POST /user-universities, /user-courses)
+// that policy doesn't let through directly: "approval" files a request and
+// replies 202, "invite" replies 403.
+func RespondByPolicy(w http.ResponseWriter, db *sql.DB, userID, policy string, t Target) {
+	if policy != PolicyApproval {
+		util.WriteProblem(w, http.StatusForbidden, "invite_required", "invite required")
+		return
+	}
+	req, _, err := RequestJoin(db, userID, t)
+	if err != nil {
+		util.WriteError(w, err)
+		return
+	}
+	util.WriteJSON(w, map[string]any{"status": "pending", "requestId": req.ID}, http.StatusAccepted)
+}
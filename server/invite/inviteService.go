@@ -0,0 +1,226 @@
+package invite
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"example.com/sqlite-server/store"
+	"example.com/sqlite-server/util"
+)
+
+// Invite is the curator-facing view of an invite (the code is only shown at creation).
+type Invite struct {
+	ID           string  `json:"id"`
+	UniversityID *string `json:"universityId,omitempty"`
+	CourseID     *int64  `json:"courseId,omitempty"`
+	CreatedAt    int64   `json:"created_at"`
+	ExpiresAt    *int64  `json:"expires_at,omitempty"`
+	MaxUses      *int64  `json:"maxUses,omitempty"`
+	Uses         int64   `json:"uses"`
+	Revoked      bool    `json:"revoked"`
+}
+
+// Accepted reports what accepting an invite joined.
+type Accepted struct {
+	UniversityID string `json:"universityId"`
+	CourseID     *int64 `json:"courseId,omitempty"`
+}
+
+// CreateInvite mints an invite code for t. ttl <= 0 means no expiry and
+// maxUses <= 0 means unlimited. The code is returned once; only its hash is kept.
+// Errors: sql.ErrNoRows (no such target), ErrUniversityNotApproved.
+func CreateInvite(db *sql.DB, createdBy string, t Target, ttl time.Duration, maxUses int64) (Invite, string, error) {
+	if !t.isCourse() {
+		var status string
+		if err := db.QueryRow(`SELECT status FROM universities WHERE id = ?`, t.UniversityID).Scan(&status); err != nil {
+			return Invite{}, "", err
+		}
+		if status != "approved" {
+			return Invite{}, "", ErrUniversityNotApproved
+		}
+	}
+
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return Invite{}, "", err
+	}
+	code := base64.RawURLEncoding.EncodeToString(buf)
+
+	inv := Invite{ID: uuid.NewString(), CreatedAt: time.Now().Unix()}
+	if t.isCourse() {
+		inv.CourseID = &t.CourseID
+	} else {
+		inv.UniversityID = &t.UniversityID
+	}
+	if ttl > 0 {
+		exp := time.Now().Add(ttl).Unix()
+		inv.ExpiresAt = &exp
+	}
+	if maxUses > 0 {
+		inv.MaxUses = &maxUses
+	}
+
+	uni, course := t.args()
+	if _, err := db.Exec(`
+		INSERT INTO invites (id, code_hash, university_id, course_id, created_by, created_at, expires_at, max_uses)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, inv.ID, util.HashToken(code), uni, course, createdBy, inv.CreatedAt, inv.ExpiresAt, inv.MaxUses); err != nil {
+		if store.IsForeignKeyViolation(err) {
+			return Invite{}, "", sql.ErrNoRows
+		}
+		return Invite{}, "", err
+	}
+	return inv, code, nil
+}
+
+// ListInvites returns the invites for t, newest first.
+func ListInvites(db *sql.DB, t Target) ([]Invite, error) {
+	uni, course := t.args()
+	rows, err := db.Query(`
+		SELECT id, university_id, course_id, created_at, expires_at, max_uses, uses, revoked_at IS NOT NULL
+		  FROM invites
+		 WHERE university_id IS ? AND course_id IS ?
+		 ORDER BY created_at DESC, id ASC
+	`, uni, course)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Invite, 0, 8)
+	for rows.Next() {
+		var inv Invite
+		var uniID sql.NullString
+		var courseID, exp, max sql.NullInt64
+		if err := rows.Scan(&inv.ID, &uniID, &courseID, &inv.CreatedAt, &exp, &max, &inv.Uses, &inv.Revoked); err != nil {
+			return nil, err
+		}
+		if uniID.Valid {
+			inv.UniversityID = &uniID.String
+		}
+		if courseID.Valid {
+			inv.CourseID = &courseID.Int64
+		}
+		if exp.Valid {
+			inv.ExpiresAt = &exp.Int64
+		}
+		if max.Valid {
+			inv.MaxUses = &max.Int64
+		}
+		out = append(out, inv)
+	}
+	return out, rows.Err()
+}
+
+// InviteTarget returns what an invite is for (for authorization). sql.ErrNoRows if missing.
+func InviteTarget(db *sql.DB, inviteID string) (Target, error) {
+	var uniID sql.NullString
+	var courseID sql.NullInt64
+	if err := db.QueryRow(`
+		SELECT university_id, course_id FROM invites WHERE id = ?
+	`, inviteID).Scan(&uniID, &courseID); err != nil {
+		return Target{}, err
+	}
+	return Target{UniversityID: uniID.String, CourseID: courseID.Int64}, nil
+}
+
+// RevokeInvite stops an invite from being used. sql.ErrNoRows if missing.
+func RevokeInvite(db *sql.DB, inviteID string) error {
+	res, err := db.Exec(`
+		UPDATE invites SET revoked_at = COALESCE(revoked_at, strftime('%s','now')) WHERE id = ?
+	`, inviteID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// AcceptInvite joins userID to the invite's university or course, counting one use
+// (re-accepting when already joined doesn't use it up). Pending join requests for
+// the same target are marked approved.
+// Errors: sql.ErrNoRows (unknown or revoked code), ErrInviteExpired, ErrInviteUsedUp,
+// ErrUniversityNotApproved.
+func AcceptInvite(db *sql.DB, userID, code string) (Accepted, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return Accepted{}, err
+	}
+	defer tx.Rollback()
+
+	var id string
+	var uniID sql.NullString
+	var courseID, exp, max sql.NullInt64
+	var uses int64
+	var revoked bool
+	err = tx.QueryRow(`
+		SELECT id, university_id, course_id, expires_at, max_uses, uses, revoked_at IS NOT NULL
+		  FROM invites
+		 WHERE code_hash = ?
+	`, util.HashToken(strings.TrimSpace(code))).Scan(&id, &uniID, &courseID, &exp, &max, &uses, &revoked)
+	if err != nil {
+		return Accepted{}, err
+	}
+	if revoked {
+		return Accepted{}, sql.ErrNoRows
+	}
+	if exp.Valid && time.Now().Unix() >= exp.Int64 {
+		return Accepted{}, ErrInviteExpired
+	}
+
+	t := Target{UniversityID: uniID.String, CourseID: courseID.Int64}
+	out := Accepted{UniversityID: uniID.String}
+	if t.isCourse() {
+		if err := tx.QueryRow(`SELECT university_id FROM courses WHERE id = ?`, t.CourseID).Scan(&out.UniversityID); err != nil {
+			return Accepted{}, err
+		}
+		out.CourseID = &t.CourseID
+	}
+
+	var status string
+	if err := tx.QueryRow(`SELECT status FROM universities WHERE id = ?`, out.UniversityID).Scan(&status); err != nil {
+		return Accepted{}, err
+	}
+	if status != "approved" {
+		return Accepted{}, ErrUniversityNotApproved
+	}
+
+	var already int
+	if t.isCourse() {
+		err = tx.QueryRow(`SELECT COUNT(1) FROM user_courses WHERE user_id = ? AND course_id = ?`, userID, t.CourseID).Scan(&already)
+	} else {
+		err = tx.QueryRow(`SELECT COUNT(1) FROM user_universities WHERE user_id = ? AND university_id = ?`, userID, t.UniversityID).Scan(&already)
+	}
+	if err != nil {
+		return Accepted{}, err
+	}
+	if already > 0 {
+		return out, nil
+	}
+	if max.Valid && uses >= max.Int64 {
+		return Accepted{}, ErrInviteUsedUp
+	}
+
+	if _, err := tx.Exec(`UPDATE invites SET uses = uses + 1 WHERE id = ?`, id); err != nil {
+		return Accepted{}, err
+	}
+	if err := grant(tx, userID, t); err != nil {
+		return Accepted{}, err
+	}
+	uni, course := t.args()
+	if _, err := tx.Exec(`
+		UPDATE join_requests
+		   SET status = 'approved', decided_at = strftime('%s','now')
+		 WHERE user_id = ? AND status = 'pending' AND university_id IS ? AND course_id IS ?
+	`, userID, uni, course); err != nil {
+		return Accepted{}, err
+	}
+	return out, tx.Commit()
+}
@@ -0,0 +1,80 @@
+package invite
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"example.com/sqlite-server/store"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`
+		INSERT INTO users (id, email, password) VALUES ('owner', 'o@x.io', ''), ('u1', 'a@x.io', ''), ('u2', 'b@x.io', '');
+		INSERT INTO universities (id, name, status) VALUES ('uni', 'Uni', 'approved');
+	`); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestAcceptInvite(t *testing.T) {
+	db := openTestDB(t)
+	_, code, err := CreateInvite(db, "owner", Target{UniversityID: "uni"}, time.Hour, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := AcceptInvite(db, "u1", " "+code+"\n")
+	if err != nil || got.UniversityID != "uni" || got.CourseID != nil {
+		t.Fatalf("accept = %+v, %v", got, err)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(1) FROM user_universities WHERE user_id = 'u1' AND university_id = 'uni'`).Scan(&n); err != nil || n != 1 {
+		t.Errorf("u1 memberships = %d, %v; want 1", n, err)
+	}
+	// Accepting again as a member doesn't use the invite up.
+	if _, err := AcceptInvite(db, "u1", code); err != nil {
+		t.Errorf("re-accept: %v", err)
+	}
+	if _, err := AcceptInvite(db, "u2", code); !errors.Is(err, ErrInviteUsedUp) {
+		t.Errorf("second user: %v, want ErrInviteUsedUp", err)
+	}
+}
+
+func TestAcceptInviteExpired(t *testing.T) {
+	db := openTestDB(t)
+	inv, code, err := CreateInvite(db, "owner", Target{UniversityID: "uni"}, time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE invites SET expires_at = ? WHERE id = ?`, time.Now().Add(-time.Minute).Unix(), inv.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AcceptInvite(db, "u1", code); !errors.Is(err, ErrInviteExpired) {
+		t.Errorf("accept: %v, want ErrInviteExpired", err)
+	}
+}
+
+func TestAcceptInviteRevokedOrUnknown(t *testing.T) {
+	db := openTestDB(t)
+	inv, code, err := CreateInvite(db, "owner", Target{UniversityID: "uni"}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RevokeInvite(db, inv.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []string{code, "not-a-code"} {
+		if _, err := AcceptInvite(db, "u1", c); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("accept %q: %v, want sql.ErrNoRows", c, err)
+		}
+	}
+}
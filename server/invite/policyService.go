@@ -0,0 +1,183 @@
+package invite
+
+import (
+	"database/sql"
+	"net/http"
+
+	"example.com/sqlite-server/util"
+)
+
+// Join policies for universities and courses.
+const (
+	PolicyOpen     = "open"     // anyone (any member, for courses) can join
+	PolicyInvite   = "invite"   // only with an invite code
+	PolicyApproval = "approval" // joining creates a request a curator approves
+)
+
+// ValidPolicy reports whether p is a known join policy.
+func ValidPolicy(p string) bool {
+	return p == PolicyOpen || p == PolicyInvite || p == PolicyApproval
+}
+
+// Errors returned by this package.
+var (
+	ErrInvalidTarget         = util.NewError(http.StatusBadRequest, "invalid_target", "exactly one of universityId or courseId is required")
+	ErrInvalidPolicy         = util.NewError(http.StatusBadRequest, "invalid_policy", "joinPolicy must be open, invite or approval")
+	ErrUniversityNotApproved = util.NewError(http.StatusConflict, "university_not_approved", "university is awaiting approval")
+	ErrInviteExpired         = util.NewError(http.StatusGone, "invite_expired", "invite expired")
+	ErrInviteUsedUp          = util.NewError(http.StatusGone, "invite_used_up", "invite used up")
+	ErrNotPending            = util.NewError(http.StatusConflict, "request_decided", "request already decided")
+)
+
+// Target is what an invite or join request is for: exactly one of a
+// university or a course.
+type Target struct {
+	UniversityID string
+	CourseID     int64
+}
+
+// NewTarget validates that exactly one of universityID/courseID is set.
+func NewTarget(universityID string, courseID int64) (Target, error) {
+	if (universityID == "") == (courseID <= 0) {
+		return Target{}, ErrInvalidTarget
+	}
+	return Target{UniversityID: universityID, CourseID: courseID}, nil
+}
+
+func (t Target) isCourse() bool { return t.CourseID > 0 }
+
+// args returns the (university_id, course_id) column values.
+func (t Target) args() (any, any) {
+	if t.isCourse() {
+		return nil, t.CourseID
+	}
+	return t.UniversityID, nil
+}
+
+func isAdmin(db *sql.DB, userID string) (bool, error) {
+	var x int
+	err := db.QueryRow(`SELECT 1 FROM admins WHERE user_id = ?`, userID).Scan(&x)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// IsUniversityCurator reports whether userID manages universityID: an owner or
+// curator member, or an admin.
+func IsUniversityCurator(db *sql.DB, userID, universityID string) (bool, error) {
+	var x int
+	err := db.QueryRow(`
+		SELECT 1 FROM user_universities
+		 WHERE user_id = ? AND university_id = ? AND role IN ('owner','curator')
+	`, userID, universityID).Scan(&x)
+	if err == nil {
+		return true, nil
+	}
+	if err != sql.ErrNoRows {
+		return false, err
+	}
+	return isAdmin(db, userID)
+}
+
+// IsUniversityOwner reports whether userID owns universityID (or is an admin);
+// only owners appoint curators.
+func IsUniversityOwner(db *sql.DB, userID, universityID string) (bool, error) {
+	var x int
+	err := db.QueryRow(`
+		SELECT 1 FROM user_universities
+		 WHERE user_id = ? AND university_id = ? AND role = 'owner'
+	`, userID, universityID).Scan(&x)
+	if err == nil {
+		return true, nil
+	}
+	if err != sql.ErrNoRows {
+		return false, err
+	}
+	return isAdmin(db, userID)
+}
+
+// IsCourseCurator reports whether userID manages courseID: its creator, or a
+// curator of its university. Returns sql.ErrNoRows if the course doesn't exist.
+func IsCourseCurator(db *sql.DB, userID string, courseID int64) (bool, error) {
+	var uniID string
+	var createdBy sql.NullString
+	err := db.QueryRow(`
+		SELECT university_id, created_by FROM courses WHERE id = ?
+	`, courseID).Scan(&uniID, &createdBy)
+	if err != nil {
+		return false, err
+	}
+	if createdBy.Valid && createdBy.String == userID {
+		return true, nil
+	}
+	return IsUniversityCurator(db, userID, uniID)
+}
+
+// CanCurate checks curator rights for either kind of target.
+func CanCurate(db *sql.DB, userID string, t Target) (bool, error) {
+	if t.isCourse() {
+		return IsCourseCurator(db, userID, t.CourseID)
+	}
+	return IsUniversityCurator(db, userID, t.UniversityID)
+}
+
+// UniversityPolicy returns a university's join policy. sql.ErrNoRows if missing.
+func UniversityPolicy(db *sql.DB, universityID string) (string, error) {
+	var p string
+	err := db.QueryRow(`SELECT join_policy FROM universities WHERE id = ?`, universityID).Scan(&p)
+	return p, err
+}
+
+// CoursePolicy returns a course's join policy. sql.ErrNoRows if missing.
+func CoursePolicy(db *sql.DB, courseID int64) (string, error) {
+	var p string
+	err := db.QueryRow(`SELECT join_policy FROM courses WHERE id = ?`, courseID).Scan(&p)
+	return p, err
+}
+
+// SetPolicy changes the join policy of a university or course.
+// Errors: ErrInvalidPolicy, sql.ErrNoRows.
+func SetPolicy(db *sql.DB, t Target, policy string) error {
+	if !ValidPolicy(policy) {
+		return ErrInvalidPolicy
+	}
+	var res sql.Result
+	var err error
+	if t.isCourse() {
+		res, err = db.Exec(`UPDATE courses SET join_policy = ? WHERE id = ?`, policy, t.CourseID)
+	} else {
+		res, err = db.Exec(`UPDATE universities SET join_policy = ? WHERE id = ?`, policy, t.UniversityID)
+	}
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// grant adds userID to the target inside tx. A course grant also adds the
+// course's university membership, since enrollment requires it.
+func grant(tx *sql.Tx, userID string, t Target) error {
+	uniID := t.UniversityID
+	if t.isCourse() {
+		if err := tx.QueryRow(`SELECT university_id FROM courses WHERE id = ?`, t.CourseID).Scan(&uniID); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`
+		INSERT OR IGNORE INTO user_universities (user_id, university_id, role) VALUES (?, ?, 'member')
+	`, userID, uniID); err != nil {
+		return err
+	}
+	if t.isCourse() {
+		if _, err := tx.Exec(`
+			INSERT OR IGNORE INTO user_courses (user_id, course_id) VALUES (?, ?)
+		`, userID, t.CourseID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
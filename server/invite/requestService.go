@@ -0,0 +1,166 @@
+package invite
+
+import (
+	"database/sql"
+)
+
+// JoinRequest is a pending (or decided) request to join an approval-required
+// university or course.
+type JoinRequest struct {
+	ID           int64   `json:"id"`
+	UserID       string  `json:"userId"`
+	UserEmail    string  `json:"userEmail,omitempty"`
+	UniversityID *string `json:"universityId,omitempty"`
+	CourseID     *int64  `json:"courseId,omitempty"`
+	Status       string  `json:"status"`
+	CreatedAt    int64   `json:"created_at"`
+	DecidedAt    *int64  `json:"decided_at,omitempty"`
+}
+
+// RequestJoin records a pending request (idempotent: an existing pending request
+// is returned with created=false). Errors: ErrUniversityNotApproved.
+func RequestJoin(db *sql.DB, userID string, t Target) (JoinRequest, bool, error) {
+	if !t.isCourse() {
+		var status string
+		if err := db.QueryRow(`SELECT status FROM universities WHERE id = ?`, t.UniversityID).Scan(&status); err != nil {
+			return JoinRequest{}, false, err
+		}
+		if status != "approved" {
+			return JoinRequest{}, false, ErrUniversityNotApproved
+		}
+	}
+
+	uni, course := t.args()
+	res, err := db.Exec(`
+		INSERT OR IGNORE INTO join_requests (user_id, university_id, course_id) VALUES (?, ?, ?)
+	`, userID, uni, course)
+	if err != nil {
+		return JoinRequest{}, false, err
+	}
+	created := false
+	if n, _ := res.RowsAffected(); n > 0 {
+		created = true
+	}
+
+	reqs, err := queryRequests(db, `
+		WHERE jr.user_id = ? AND jr.status = 'pending' AND jr.university_id IS ? AND jr.course_id IS ?
+	`, userID, uni, course)
+	if err != nil {
+		return JoinRequest{}, false, err
+	}
+	if len(reqs) == 0 {
+		return JoinRequest{}, false, sql.ErrNoRows
+	}
+	return reqs[0], created, nil
+}
+
+// ListRequests returns requests for t with the given status, oldest first.
+func ListRequests(db *sql.DB, t Target, status string) ([]JoinRequest, error) {
+	uni, course := t.args()
+	return queryRequests(db, `
+		WHERE jr.university_id IS ? AND jr.course_id IS ? AND jr.status = ?
+	`, uni, course, status)
+}
+
+// ListMyRequests returns userID's own requests, oldest first.
+func ListMyRequests(db *sql.DB, userID string) ([]JoinRequest, error) {
+	return queryRequests(db, `WHERE jr.user_id = ?`, userID)
+}
+
+func queryRequests(db *sql.DB, where string, args ...any) ([]JoinRequest, error) {
+	rows, err := db.Query(`
+		SELECT jr.id, jr.user_id, COALESCE(u.email, ''), jr.university_id, jr.course_id,
+		       jr.status, jr.created_at, jr.decided_at
+		  FROM join_requests jr
+		  LEFT JOIN users u ON u.id = jr.user_id
+		`+where+`
+		 ORDER BY jr.created_at ASC, jr.id ASC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]JoinRequest, 0, 8)
+	for rows.Next() {
+		var r JoinRequest
+		var uniID sql.NullString
+		var courseID, decided sql.NullInt64
+		if err := rows.Scan(&r.ID, &r.UserID, &r.UserEmail, &uniID, &courseID, &r.Status, &r.CreatedAt, &decided); err != nil {
+			return nil, err
+		}
+		if uniID.Valid {
+			r.UniversityID = &uniID.String
+		}
+		if courseID.Valid {
+			r.CourseID = &courseID.Int64
+		}
+		if decided.Valid {
+			r.DecidedAt = &decided.Int64
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// RequestTarget returns what a request is for (for authorization). sql.ErrNoRows if missing.
+func RequestTarget(db *sql.DB, requestID int64) (Target, error) {
+	var uniID sql.NullString
+	var courseID sql.NullInt64
+	err := db.QueryRow(`
+		SELECT university_id, course_id FROM join_requests WHERE id = ?
+	`, requestID).Scan(&uniID, &courseID)
+	return Target{UniversityID: uniID.String, CourseID: courseID.Int64}, err
+}
+
+// DecideRequest approves (joining the user) or rejects a pending request.
+// Errors: sql.ErrNoRows, ErrNotPending.
+func DecideRequest(db *sql.DB, requestID int64, deciderID string, approve bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var uniID sql.NullString
+	var courseID sql.NullInt64
+	var userID, status string
+	if err := tx.QueryRow(`
+		SELECT university_id, course_id, user_id, status FROM join_requests WHERE id = ?
+	`, requestID).Scan(&uniID, &courseID, &userID, &status); err != nil {
+		return err
+	}
+	if status != "pending" {
+		return ErrNotPending
+	}
+
+	newStatus := "rejected"
+	if approve {
+		newStatus = "approved"
+		if err := grant(tx, userID, Target{UniversityID: uniID.String, CourseID: courseID.Int64}); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`
+		UPDATE join_requests
+		   SET status = ?, decided_by = ?, decided_at = strftime('%s','now')
+		 WHERE id = ?
+	`, newStatus, deciderID, requestID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CancelRequest withdraws userID's own pending request. sql.ErrNoRows if there is none.
+func CancelRequest(db *sql.DB, userID string, requestID int64) error {
+	res, err := db.Exec(`
+		DELETE FROM join_requests WHERE id = ? AND user_id = ? AND status = 'pending'
+	`, requestID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package invite
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestJoinRequests(t *testing.T) {
+	db := openTestDB(t)
+	uni := Target{UniversityID: "uni"}
+
+	req, created, err := RequestJoin(db, "u1", uni)
+	if err != nil || !created || req.Status != "pending" {
+		t.Fatalf("request = %+v, %v, %v", req, created, err)
+	}
+	if again, created, err := RequestJoin(db, "u1", uni); err != nil || created || again.ID != req.ID {
+		t.Fatalf("repeated request = %+v, %v, %v; want the pending one", again, created, err)
+	}
+
+	if err := DecideRequest(db, req.ID, "owner", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := DecideRequest(db, req.ID, "owner", false); !errors.Is(err, ErrNotPending) {
+		t.Errorf("deciding twice: %v", err)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(1) FROM user_universities WHERE user_id = 'u1' AND university_id = 'uni'`).Scan(&n); err != nil || n != 1 {
+		t.Errorf("u1 memberships = %d, %v; want 1", n, err)
+	}
+
+	other, _, err := RequestJoin(db, "u2", uni)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CancelRequest(db, "u1", other.ID); err != sql.ErrNoRows {
+		t.Errorf("cancelling someone else's request: %v", err)
+	}
+	if err := CancelRequest(db, "u2", other.ID); err != nil {
+		t.Errorf("cancel: %v", err)
+	}
+	if mine, err := ListMyRequests(db, "u2"); err != nil || len(mine) != 0 {
+		t.Errorf("u2's requests after cancelling = %+v, %v", mine, err)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+
+	"example.com/sqlite-server/digest"
+	"example.com/sqlite-server/mailer"
+	"example.com/sqlite-server/push"
+	"example.com/sqlite-server/term"
+	"example.com/sqlite-server/webhook"
+)
+
+// runJobs runs the server's periodic housekeeping until ctx is done, then
+// waits for jobs under way. Each job runs once at startup and then on its
+// interval.
+func runJobs(ctx context.Context, db *sql.DB) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	wg.Go(every(ctx, time.Hour, func() {
+		n, err := term.ArchiveEndedTerms(db, time.Now())
+		if err != nil {
+			log.Printf("archive ended terms: %v", err)
+			return
+		}
+		if n > 0 {
+			log.Printf("archived %d courses from ended terms", n)
+		}
+	}))
+	wg.Go(every(ctx, 24*time.Hour, func() {
+		if _, err := webhook.PruneDeliveries(db, time.Now().Add(-webhook.KeepDeliveries)); err != nil {
+			log.Printf("prune webhook deliveries: %v", err)
+		}
+		if _, err := digest.PruneUnsubscribeTokens(db, time.Now().Add(-digest.KeepUnsubscribeTokens)); err != nil {
+			log.Printf("prune digest unsubscribe tokens: %v", err)
+		}
+	}))
+	if mailer.Default == nil {
+		log.Printf("no mailer configured (SMTP_ADDR or MAIL_DIR); deadline digests are off")
+	} else {
+		wg.Go(every(ctx, 15*time.Minute, func() {
+			n, err := digest.SendDue(db, mailer.Default, time.Now())
+			if err != nil {
+				log.Printf("send digests: %v", err)
+			}
+			if n > 0 {
+				log.Printf("sent %d deadline digests", n)
+			}
+		}))
+	}
+	if push.Default == nil {
+		log.Printf("no VAPID_PRIVATE_KEY set; push notifications are off")
+	} else {
+		wg.Go(every(ctx, 5*time.Minute, func() {
+			n, err := push.SendDue(ctx, db, push.Default, time.Now())
+			if err != nil {
+				log.Printf("send push notifications: %v", err)
+			}
+			if n > 0 {
+				log.Printf("sent %d push notifications", n)
+			}
+		}))
+		wg.Go(every(ctx, 24*time.Hour, func() {
+			if _, err := push.PruneSent(db, time.Now()); err != nil {
+				log.Printf("prune push notifications: %v", err)
+			}
+		}))
+	}
+}
+
+// every returns a loop running job now and then on interval until ctx is
+// done.
+func every(ctx context.Context, interval time.Duration, job func()) func() {
+	return func() {
+		job()
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				job()
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Package mailer sends plain-text email, over SMTP or, for local testing,
+// into a directory of .eml files.
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Message is one email to one recipient.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	Headers map[string]string // extra headers, e.g. List-Unsubscribe
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Config picks a mailer: Dir (MAIL_DIR) writes files there, SMTPAddr
+// (SMTP_ADDR, host:port, with SMTP_USERNAME and SMTP_PASSWORD if the server
+// wants them) sends mail. From (MAIL_FROM) is the sender for both.
+type Config struct {
+	Dir          string
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+	From         string
+}
+
+// Mailer returns the configured mailer, or nil when neither Dir nor SMTPAddr
+// is set.
+func (c Config) Mailer() Mailer {
+	from := c.From
+	if from == "" {
+		from = "reading@localhost"
+	}
+	if c.Dir != "" {
+		return FileSink{Dir: c.Dir, From: from}
+	}
+	if c.SMTPAddr != "" {
+		return SMTP{Addr: c.SMTPAddr, Username: c.SMTPUsername, Password: c.SMTPPassword, From: from}
+	}
+	return nil
+}
+
+// Default is the mailer digests go out with; serve sets it from the mail
+// settings. Nil: no mail is sent.
+var Default Mailer
+
+// SMTP sends through a submission server. The connection is upgraded with
+// STARTTLS when the server offers it, and credentials are only sent over TLS
+// (or to localhost).
+type SMTP struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m SMTP) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return fmt.Errorf("SMTP_ADDR: %w", err)
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	return smtp.SendMail(m.Addr, auth, address(m.From), []string{msg.To}, msg.bytes(m.From))
+}
+
+// FileSink writes each message to Dir as <time>-<recipient>.eml.
+type FileSink struct {
+	Dir  string
+	From string
+}
+
+func (m FileSink) Send(msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), strings.NewReplacer("/", "_", "@", "_at_").Replace(msg.To))
+	return os.WriteFile(filepath.Join(m.Dir, name), msg.bytes(m.From), 0o644)
+}
+
+// bytes renders the message as RFC 5322 text with a quoted-printable body.
+func (msg Message) bytes(from string) []byte {
+	var b bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&b, "%s: %s\r\n", k, v) }
+	header("From", from)
+	header("To", msg.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID(from))
+	keys := make([]string, 0, len(msg.Headers))
+	for k := range msg.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		header(k, msg.Headers[k])
+	}
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	b.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(&b)
+	_, _ = qp.Write([]byte(strings.ReplaceAll(msg.Text, "\n", "\r\n")))
+	_ = qp.Close()
+	return b.Bytes()
+}
+
+// address is the bare address of a From like "Reading <noreply@example.com>".
+func address(from string) string {
+	if a, err := mail.ParseAddress(from); err == nil {
+		return a.Address
+	}
+	return from
+}
+
+func messageID(from string) string {
+	buf := make([]byte, 12)
+	_, _ = rand.Read(buf)
+	domain := "localhost"
+	if _, d, ok := strings.Cut(address(from), "@"); ok && d != "" {
+		domain = d
+	}
+	return "<" + hex.EncodeToString(buf) + "@" + domain + ">"
+}
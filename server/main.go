@@ -1,31 +1,64 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"time"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 
-	"example.com/sqlite-server/store"
+	"example.com/sqlite-server/events"
 	"example.com/sqlite-server/middleware"
+	"example.com/sqlite-server/store"
+	"example.com/sqlite-server/webhook"
 )
 
 func main() {
-	// 1. Database setup
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		dbPath = "data.db" // default for local dev
+	// Subcommands (admin grant, migrate, ...) share the database setup below.
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "serve" {
+		args = args[1:]
+	} else if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		os.Exit(runCommand(args))
+	}
+
+	cfg, err := loadConfig(args)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := serve(cfg); err != nil {
+		log.Fatal(err)
 	}
+}
+
+// serve runs the HTTP server and background jobs until SIGINT or SIGTERM,
+// then gives in-flight requests cfg.ShutdownTimeout to finish.
+func serve(cfg Config) error {
+	cfg.apply()
 
-	dsn := "file:" + dbPath + "?_pragma=busy_timeout(5000)"
-	db, err := store.OpenDB(dsn)
+	// 1. Database setup
+	db, err := openDatabaseAt(cfg.DBPath)
 	if err != nil {
-		log.Fatal("failed to open database:", err)
+		return err
 	}
 	defer db.Close()
 
-	if err := store.EnsureSchema(db); err != nil { log.Fatal(err) }
-	if err := store.EnsureCalendar(db); err != nil { log.Fatal(err) }
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var background sync.WaitGroup
+	background.Go(func() { runJobs(ctx, db) })
+	background.Go(func() { webhook.Run(ctx, db, events.Default) })
 
 	// 2. API routes
 	apiMux := http.NewServeMux()
@@ -34,20 +67,102 @@ func main() {
 	// 3. Top-level mux
 	mux := http.NewServeMux()
 
-	// Mount API under /api with CORS middleware
-	mux.Handle("/api/", http.StripPrefix("/api", middleware.WithCORS(apiMux)))
+	// Mount API under /api with CORS + CSRF middleware
+	mux.Handle("/api/", apiHandler(apiMux))
 
-	// Serve static client (if present)
-	fs := http.FileServer(http.Dir("./client"))
-	mux.Handle("/", fs)
+	// Serve static client (if configured)
+	if cfg.StaticDir != "" {
+		mux.Handle("/", http.FileServer(http.Dir(cfg.StaticDir)))
+	}
 
 	// 4. HTTP server configuration
 	srv := &http.Server{
-		Addr:              ":8080",
+		Addr:              cfg.Addr,
 		Handler:           mux,
-		ReadHeaderTimeout: 5 * time.Second,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		TLSConfig:         &tls.Config{MinVersion: tls.VersionTLS12},
+	}
+	// Event streams never finish on their own; end them so clients
+	// reconnect to whichever server comes next.
+	srv.RegisterOnShutdown(events.Default.CloseSubscriptions)
+
+	served := make(chan error, 1)
+	go func() {
+		if cfg.TLSCert != "" {
+			log.Printf("listening on https://%s", displayAddr(cfg.Addr))
+			served <- srv.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+		} else {
+			log.Printf("listening on http://%s", displayAddr(cfg.Addr))
+			served <- srv.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-served:
+		stop()
+		background.Wait()
+		return err
+	case <-ctx.Done():
 	}
 
-	log.Println("listening on http://localhost:8080")
-	log.Fatal(srv.ListenAndServe())
+	// 5. Graceful shutdown: stop accepting, drain, then stop the jobs.
+	stop() // a second signal kills the process
+	log.Printf("shutting down; draining requests for up to %s", cfg.ShutdownTimeout)
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(drainCtx); err != nil {
+		log.Printf("drain incomplete (%v); closing remaining connections", err)
+		srv.Close()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-drainCtx.Done():
+		log.Printf("background jobs still running; closing the database anyway")
+	}
+	log.Printf("stopped")
+	return nil
+}
+
+// displayAddr turns a listen address like ":8080" into something to show.
+func displayAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "localhost" + addr
+	}
+	return addr
+}
+
+// apiHandler serves the API routes with the prefix /api stripped, behind the
+// CORS and CSRF middleware.
+func apiHandler(apiMux *http.ServeMux) http.Handler {
+	return http.StripPrefix("/api", middleware.WithCORS(middleware.WithCSRF(apiMux)))
+}
+
+// openDatabase loads the settings from the environment and CONFIG_FILE,
+// applies them, and opens DB_PATH (default data.db) with the schema brought
+// up to date.
+func openDatabase() (*sql.DB, error) {
+	cfg, err := loadConfig(nil)
+	if err != nil {
+		return nil, err
+	}
+	cfg.apply()
+	return openDatabaseAt(cfg.DBPath)
+}
+
+// openDatabaseAt opens the SQLite file at dbPath and brings the schema up to date.
+func openDatabaseAt(dbPath string) (*sql.DB, error) {
+	db, err := store.Open(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, nil
 }
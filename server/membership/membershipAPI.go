@@ -1,128 +1,206 @@
 package membership
 
 import (
-  "database/sql"
-  "encoding/json"
-  "net/http"
-  "strings"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
 
-  "example.com/sqlite-server/session"
-  "example.com/sqlite-server/util"
+	"example.com/sqlite-server/invite"
+	"example.com/sqlite-server/session"
+	"example.com/sqlite-server/util"
 )
 
-func RegisterMembershipRoutes(mux *http.ServeMux, db *sql.DB) {
-  mux.HandleFunc("/user-universities", userUniversitiesHandler(db))
+func RegisterMembershipRoutes(mux util.Router, db *sql.DB) {
+	mux.HandleFunc("/user-universities", userUniversitiesHandler(db))
+	mux.HandleFunc("/user-universities/role", session.RequireAuth(db, setRoleHandler(db)))
 }
 
 // Dispatcher
 func userUniversitiesHandler(db *sql.DB) http.HandlerFunc {
-  return func(w http.ResponseWriter, r *http.Request) {
-    switch r.Method {
-    case http.MethodGet:
-      session.RequireAuth(db, getUserUniversitiesHandler(db)).ServeHTTP(w, r)
-    case http.MethodPost:
-      session.RequireAuth(db, postUserUniversityHandler(db)).ServeHTTP(w, r)
-    case http.MethodDelete:
-      session.RequireAuth(db, deleteUserUniversityHandler(db)).ServeHTTP(w, r)
-    default:
-      http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-    }
-  }
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			session.RequireAuth(db, getUserUniversitiesHandler(db)).ServeHTTP(w, r)
+		case http.MethodPost:
+			session.RequireAuth(db, postUserUniversityHandler(db)).ServeHTTP(w, r)
+		case http.MethodDelete:
+			session.RequireAuth(db, deleteUserUniversityHandler(db)).ServeHTTP(w, r)
+		default:
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
 }
 
 // GET /user-universities  (list my memberships)
 func getUserUniversitiesHandler(db *sql.DB) http.HandlerFunc {
-  return func(w http.ResponseWriter, r *http.Request) {
-    userID, ok := session.UserIDFromCtx(r.Context())
-    if !ok {
-      http.Error(w, "unauthorized", http.StatusUnauthorized)
-      return
-    }
-    list, err := ListMemberships(db, userID)
-    if err != nil {
-      http.Error(w, "internal error", http.StatusInternalServerError)
-      return
-    }
-    util.WriteJSON(w, list, http.StatusOK)
-  }
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := session.UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		list, err := ListMemberships(db, userID)
+		if err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		util.WriteJSON(w, list, http.StatusOK)
+	}
 }
 
 // POST /user-universities  (subscribe me to a university)
 func postUserUniversityHandler(db *sql.DB) http.HandlerFunc {
-  type payload struct {
-    UniversityID string `json:"universityId"`
-  }
-  return func(w http.ResponseWriter, r *http.Request) {
-    userID, ok := session.UserIDFromCtx(r.Context())
-    if !ok {
-      http.Error(w, "unauthorized", http.StatusUnauthorized)
-      return
-    }
-
-    var p payload
-    dec := json.NewDecoder(r.Body)
-    dec.DisallowUnknownFields()
-    if err := dec.Decode(&p); err != nil {
-      http.Error(w, "bad request", http.StatusBadRequest)
-      return
-    }
-
-    uniID := strings.TrimSpace(p.UniversityID)
-    if uniID == "" {
-      http.Error(w, "universityId is required", http.StatusBadRequest)
-      return
-    }
-
-    created, m, err := AddMembership(db, userID, uniID)
-    if err != nil {
-      if err == sql.ErrNoRows {
-        http.Error(w, "university not found", http.StatusBadRequest)
-        return
-      }
-      http.Error(w, "internal error", http.StatusInternalServerError)
-      return
-    }
-
-    if created {
-      util.WriteJSON(w, m, http.StatusCreated)
-      return
-    }
-    util.WriteJSON(w, m, http.StatusOK) // idempotent re-subscribe
-  }
+	type payload struct {
+		UniversityID string `json:"universityId"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := session.UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var p payload
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil {
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		uniID := strings.TrimSpace(p.UniversityID)
+		if uniID == "" {
+			util.HTTPError(w, "universityId is required", http.StatusBadRequest)
+			return
+		}
+
+		// Join policy: curators (and admins) always get in; otherwise "open" joins,
+		// "approval" files a request and "invite" needs POST /invites/accept.
+		if already, err := IsMember(db, userID, uniID); err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		} else if !already {
+			policy, err := invite.UniversityPolicy(db, uniID)
+			if err == sql.ErrNoRows {
+				util.HTTPError(w, "university not found", http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				util.HTTPError(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+			curator, err := invite.IsUniversityCurator(db, userID, uniID)
+			if err != nil {
+				util.HTTPError(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+			if !curator && policy != invite.PolicyOpen {
+				invite.RespondByPolicy(w, db, userID, policy, invite.Target{UniversityID: uniID})
+				return
+			}
+		}
+
+		created, m, err := AddMembership(db, userID, uniID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				util.HTTPError(w, "university not found", http.StatusBadRequest)
+				return
+			}
+			util.WriteError(w, err)
+			return
+		}
+
+		if created {
+			util.WriteJSON(w, m, http.StatusCreated)
+			return
+		}
+		util.WriteJSON(w, m, http.StatusOK) // idempotent re-subscribe
+	}
 }
 
 // DELETE /user-universities  (unsubscribe me from a university)
 // Idempotent: always returns 204 No Content if input is valid.
 func deleteUserUniversityHandler(db *sql.DB) http.HandlerFunc {
-  type payload struct {
-    UniversityID string `json:"universityId"`
-  }
-  return func(w http.ResponseWriter, r *http.Request) {
-    userID, ok := session.UserIDFromCtx(r.Context())
-    if !ok {
-      http.Error(w, "unauthorized", http.StatusUnauthorized)
-      return
-    }
-
-    var p payload
-    dec := json.NewDecoder(r.Body)
-    dec.DisallowUnknownFields()
-    if err := dec.Decode(&p); err != nil {
-      http.Error(w, "bad request", http.StatusBadRequest)
-      return
-    }
-    uniID := strings.TrimSpace(p.UniversityID)
-    if uniID == "" {
-      http.Error(w, "universityId is required", http.StatusBadRequest)
-      return
-    }
-
-    // Remove regardless of current state (idempotent)
-    _, err := RemoveMembership(db, userID, uniID)
-    if err != nil {
-      http.Error(w, "internal error", http.StatusInternalServerError)
-      return
-    }
-    w.WriteHeader(http.StatusNoContent)
-  }
+	type payload struct {
+		UniversityID string `json:"universityId"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := session.UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var p payload
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil {
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		uniID := strings.TrimSpace(p.UniversityID)
+		if uniID == "" {
+			util.HTTPError(w, "universityId is required", http.StatusBadRequest)
+			return
+		}
+
+		// Remove regardless of current state (idempotent)
+		_, err := RemoveMembership(db, userID, uniID)
+		if err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// PATCH /user-universities/role  (owners and admins)
+// Body: { "universityId": "...", "userId": "...", "role": "member" | "curator" }
+func setRoleHandler(db *sql.DB) http.HandlerFunc {
+	type payload struct {
+		UniversityID string `json:"universityId"`
+		UserID       string `json:"userId"`
+		Role         string `json:"role"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		callerID, _ := session.UserIDFromCtx(r.Context())
+
+		var p payload
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil {
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		uniID := strings.TrimSpace(p.UniversityID)
+		if uniID == "" || strings.TrimSpace(p.UserID) == "" {
+			util.HTTPError(w, "universityId and userId are required", http.StatusBadRequest)
+			return
+		}
+
+		owner, err := invite.IsUniversityOwner(db, callerID, uniID)
+		if err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if !owner {
+			util.HTTPError(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		if err := SetRole(db, uniID, strings.TrimSpace(p.UserID), p.Role); err != nil {
+			if err == sql.ErrNoRows {
+				util.HTTPError(w, "not a member", http.StatusNotFound)
+				return
+			}
+			util.WriteError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
 }
@@ -1,76 +1,91 @@
 package membership
 
-import "database/sql"
+import (
+	"database/sql"
+	"net/http"
+
+	"example.com/sqlite-server/util"
+)
+
+// Errors returned by this package.
+var (
+	ErrUniversityNotApproved = util.NewError(http.StatusConflict, "university_not_approved", "university is awaiting approval")
+	ErrInvalidRole           = util.NewError(http.StatusBadRequest, "invalid_role", "role must be member or curator")
+	ErrOwnerRole             = util.NewError(http.StatusConflict, "owner_role", "the owner's role can't be changed")
+)
 
 type Membership struct {
-  UserID       string `json:"userId"`
-  UniversityID string `json:"universityId"`
-  Role         string `json:"role"`
+	UserID       string `json:"userId"`
+	UniversityID string `json:"universityId"`
+	Role         string `json:"role"`
 }
 
 type MembershipView struct {
-  UniversityID string `json:"universityId"`
-  Name         string `json:"name"`
-  Role         string `json:"role"`
-  CreatedAt    int64  `json:"created_at"`
+	UniversityID string `json:"universityId"`
+	Name         string `json:"name"`
+	Role         string `json:"role"`
+	CreatedAt    int64  `json:"created_at"`
 }
 
-// AddMembership subscribes user to a university (idempotent).
+// AddMembership subscribes user to an approved university (idempotent).
 // Returns (created, Membership, error).
 func AddMembership(db *sql.DB, userID, universityID string) (bool, Membership, error) {
-  // Ensure the university exists
-  var exists string
-  if err := db.QueryRow(`SELECT id FROM universities WHERE id = ?`, universityID).Scan(&exists); err != nil {
-    if err == sql.ErrNoRows {
-      return false, Membership{}, sql.ErrNoRows
-    }
-    return false, Membership{}, err
-  }
+	// Ensure the university exists and has been approved
+	var status string
+	if err := db.QueryRow(`SELECT status FROM universities WHERE id = ?`, universityID).Scan(&status); err != nil {
+		if err == sql.ErrNoRows {
+			return false, Membership{}, sql.ErrNoRows
+		}
+		return false, Membership{}, err
+	}
+	if status != "approved" {
+		return false, Membership{}, ErrUniversityNotApproved
+	}
 
-  // Insert or ignore to be idempotent
-  res, err := db.Exec(`
+	// Insert or ignore to be idempotent
+	res, err := db.Exec(`
     INSERT OR IGNORE INTO user_universities (user_id, university_id, role)
     VALUES (?, ?, 'member')
   `, userID, universityID)
-  if err != nil {
-    return false, Membership{}, err
-  }
+	if err != nil {
+		return false, Membership{}, err
+	}
 
-  created := false
-  if n, _ := res.RowsAffected(); n > 0 {
-    created = true
-  }
+	created := false
+	if n, _ := res.RowsAffected(); n > 0 {
+		created = true
+	}
 
-  // Read final role
-  var role string
-  if err := db.QueryRow(`
+	// Read final role
+	var role string
+	if err := db.QueryRow(`
     SELECT role
       FROM user_universities
      WHERE user_id = ? AND university_id = ?
   `, userID, universityID).Scan(&role); err != nil {
-    return created, Membership{}, err
-  }
+		return created, Membership{}, err
+	}
 
-  return created, Membership{UserID: userID, UniversityID: universityID, Role: role}, nil
+	return created, Membership{UserID: userID, UniversityID: universityID, Role: role}, nil
 }
 
 // RemoveMembership unsubscribes user from a university.
 // Returns (deleted, error). Idempotent: deleted=false if nothing to remove.
 func RemoveMembership(db *sql.DB, userID, universityID string) (bool, error) {
-  res, err := db.Exec(`
+	res, err := db.Exec(`
     DELETE FROM user_universities
      WHERE user_id = ? AND university_id = ?
   `, userID, universityID)
-  if err != nil {
-    return false, err
-  }
-  n, _ := res.RowsAffected()
-  return n > 0, nil
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
 }
 
 // ListMemberships returns all universities the user is a member of.
 func ListMemberships(db *sql.DB, userID string) ([]MembershipView, error) {
-  rows, err := db.Query(`
+	rows, err := db.Query(`
     SELECT u.id, u.name, uu.role, u.created_at
       FROM universities u
       JOIN user_universities uu
@@ -78,23 +93,22 @@ func ListMemberships(db *sql.DB, userID string) ([]MembershipView, error) {
      WHERE uu.user_id = ?
      ORDER BY u.name ASC
   `, userID)
-  if err != nil {
-    return nil, err
-  }
-  defer rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-  out := make([]MembershipView, 0, 16)
-  for rows.Next() {
-    var mv MembershipView
-    if err := rows.Scan(&mv.UniversityID, &mv.Name, &mv.Role, &mv.CreatedAt); err != nil {
-      return nil, err
-    }
-    out = append(out, mv)
-  }
-  return out, rows.Err()
+	out := make([]MembershipView, 0, 16)
+	for rows.Next() {
+		var mv MembershipView
+		if err := rows.Scan(&mv.UniversityID, &mv.Name, &mv.Role, &mv.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, mv)
+	}
+	return out, rows.Err()
 }
 
-
 // IsMember reports whether userID is a member of universityID.
 func IsMember(db *sql.DB, userID, universityID string) (bool, error) {
 	var x int
@@ -112,3 +126,24 @@ func IsMember(db *sql.DB, userID, universityID string) (bool, error) {
 	}
 	return true, nil
 }
+
+// SetRole makes a member a curator or a plain member again. Owners keep their role.
+// Errors: ErrInvalidRole, ErrOwnerRole, sql.ErrNoRows (not a member).
+func SetRole(db *sql.DB, universityID, userID, role string) error {
+	if role != "member" && role != "curator" {
+		return ErrInvalidRole
+	}
+	var current string
+	if err := db.QueryRow(`
+		SELECT role FROM user_universities WHERE user_id = ? AND university_id = ?
+	`, userID, universityID).Scan(&current); err != nil {
+		return err
+	}
+	if current == "owner" {
+		return ErrOwnerRole
+	}
+	_, err := db.Exec(`
+		UPDATE user_universities SET role = ? WHERE user_id = ? AND university_id = ?
+	`, role, userID, universityID)
+	return err
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithCSRF(t *testing.T) {
+	saved := AllowedOrigins
+	defer func() { AllowedOrigins = saved }()
+	AllowedOrigins = map[string]struct{}{"https://app.example.com": {}}
+
+	h := WithCSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	session := &http.Cookie{Name: "session", Value: "tok"}
+	csrf := &http.Cookie{Name: CSRFCookieName, Value: "abc"}
+
+	cases := []struct {
+		name    string
+		method  string
+		cookies []*http.Cookie
+		headers map[string]string
+		want    int
+	}{
+		{"safe method", http.MethodGet, []*http.Cookie{session}, nil, http.StatusNoContent},
+		{"no session cookie", http.MethodPost, nil, map[string]string{"Origin": "https://evil.example"}, http.StatusNoContent},
+		{"bearer token", http.MethodPost, []*http.Cookie{session}, map[string]string{"Authorization": "Bearer rdg_x", "Origin": "https://evil.example"}, http.StatusNoContent},
+		{"same origin fetch", http.MethodPost, []*http.Cookie{session}, map[string]string{"Sec-Fetch-Site": "same-origin"}, http.StatusNoContent},
+		{"cross site fetch", http.MethodPost, []*http.Cookie{session}, map[string]string{"Sec-Fetch-Site": "cross-site"}, http.StatusForbidden},
+		{"own host", http.MethodDelete, []*http.Cookie{session}, map[string]string{"Origin": "http://example.com"}, http.StatusNoContent},
+		{"allowed origin", http.MethodPatch, []*http.Cookie{session}, map[string]string{"Origin": "https://app.example.com"}, http.StatusNoContent},
+		{"other origin", http.MethodPost, []*http.Cookie{session}, map[string]string{"Origin": "https://evil.example"}, http.StatusForbidden},
+		{"null origin", http.MethodPost, []*http.Cookie{session}, map[string]string{"Origin": "null"}, http.StatusForbidden},
+		{"no signals", http.MethodPost, []*http.Cookie{session}, nil, http.StatusForbidden},
+		{"double submit", http.MethodPost, []*http.Cookie{session, csrf}, map[string]string{CSRFHeaderName: "abc"}, http.StatusNoContent},
+		{"double submit mismatch", http.MethodPost, []*http.Cookie{session, csrf}, map[string]string{CSRFHeaderName: "abd"}, http.StatusForbidden},
+		{"header without cookie", http.MethodPost, []*http.Cookie{session}, map[string]string{CSRFHeaderName: "abc"}, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, "http://example.com/courses", nil)
+		for _, ck := range c.cookies {
+			r.AddCookie(ck)
+		}
+		for k, v := range c.headers {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != c.want {
+			t.Errorf("%s: %d, want %d", c.name, w.Code, c.want)
+		}
+	}
+
+	// A wildcard CORS setting never vouches for an origin.
+	AllowedOrigins = map[string]struct{}{"*": {}}
+	r := httptest.NewRequest(http.MethodPost, "http://example.com/courses", nil)
+	r.AddCookie(session)
+	r.Header.Set("Origin", "https://evil.example")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("wildcard origin: %d", w.Code)
+	}
+}
+
+func TestCSRFTokenHandlerReusesCookie(t *testing.T) {
+	w := httptest.NewRecorder()
+	CSRFTokenHandler(w, httptest.NewRequest(http.MethodGet, "/csrf", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != CSRFCookieName || cookies[0].Value == "" || cookies[0].HttpOnly {
+		t.Fatalf("cookies = %+v", cookies)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/csrf", nil)
+	r.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	CSRFTokenHandler(w, r)
+	if len(w.Result().Cookies()) != 0 {
+		t.Fatal("a new cookie was set although one was sent")
+	}
+}
@@ -47,7 +47,7 @@ func WithCORS(next http.Handler) http.Handler {
 				w.Header().Set("Access-Control-Allow-Origin", reqOrigin)
 			}
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-CSRF-Token")
 		}
 
 		if r.Method == http.MethodOptions {
@@ -0,0 +1,475 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// GoClient generates the operations of a Go client package from the spec.
+// Request and response types that live in importable packages are used as
+// they are; types declared in package main (handler-local bodies) are copied
+// under exported names. The generated methods call helpers the package
+// provides by hand:
+//
+//	func (c *Client) do(ctx context.Context, method, path string, query url.Values, body any) (int, []byte, error)
+//	func decode(data []byte, v any) error
+//	func setString(q url.Values, key, v string)
+//	func setInt(q url.Values, key string, v int64)
+//	func setBool(q url.Values, key string, v *bool)
+//
+// Operations whose only success is a redirect are browser flows, and event
+// streams don't fit a request/response method; both are skipped.
+func (s *Spec) GoClient(pkg, generator string) ([]byte, error) {
+	g := &goGen{
+		imports: map[string]bool{"context": true, "net/http": true},
+		local:   map[reflect.Type]string{},
+	}
+	var methods bytes.Buffer
+	for _, o := range s.ops {
+		g.operation(&methods, o)
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by %s; DO NOT EDIT.\n\n", generator)
+	fmt.Fprintf(&out, "package %s\n\n", pkg)
+	imports := make([]string, 0, len(g.imports))
+	for p := range g.imports {
+		imports = append(imports, p)
+	}
+	// Standard library first, then everything else, as goimports groups them.
+	sort.Slice(imports, func(i, j int) bool {
+		if a, b := stdlib(imports[i]), stdlib(imports[j]); a != b {
+			return a
+		}
+		return imports[i] < imports[j]
+	})
+	out.WriteString("import (\n")
+	for i, p := range imports {
+		if i > 0 && stdlib(imports[i-1]) && !stdlib(p) {
+			out.WriteString("\n")
+		}
+		fmt.Fprintf(&out, "\t%q\n", p)
+	}
+	out.WriteString(")\n\n")
+	for _, d := range g.decls {
+		out.WriteString(d)
+		out.WriteString("\n")
+	}
+	out.Write(methods.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return out.Bytes(), fmt.Errorf("openapi: generated client does not parse: %v", err)
+	}
+	return src, nil
+}
+
+// stdlib reports whether an import path is in the standard library, whose
+// first element never contains a dot.
+func stdlib(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".")
+}
+
+type goGen struct {
+	imports map[string]bool
+	local   map[reflect.Type]string // main-package types copied into the client
+	decls   []string
+}
+
+func (g *goGen) operation(w *bytes.Buffer, o *Op) {
+	var ok []result
+	for _, r := range o.results {
+		if r.status >= 200 && r.status < 300 {
+			ok = append(ok, r)
+		}
+	}
+	if len(ok) == 0 || ok[0].mediaType == "text/event-stream" {
+		return
+	}
+	name := exportName(o.ID())
+
+	// Arguments.
+	args := []string{"ctx context.Context"}
+	var pathExpr []string
+	parts := pathParam.Split(o.Path, -1)
+	params := pathParam.FindAllStringSubmatch(o.Path, -1)
+	for i, part := range parts {
+		if part != "" {
+			pathExpr = append(pathExpr, fmt.Sprintf("%q", part))
+		}
+		if i == len(params) {
+			break
+		}
+		p := params[i][1]
+		if o.param("path", p).Schema.Type == "integer" {
+			g.imports["strconv"] = true
+			args = append(args, p+" int64")
+			pathExpr = append(pathExpr, "strconv.FormatInt("+p+", 10)")
+		} else {
+			g.imports["net/url"] = true
+			args = append(args, p+" string")
+			pathExpr = append(pathExpr, "url.PathEscape("+p+")")
+		}
+	}
+	query := "nil"
+	if qs := o.queryParams(); len(qs) > 0 {
+		g.imports["net/url"] = true
+		params := name + "Params"
+		g.paramsDecl(params, o, qs)
+		args = append(args, "p "+params)
+		query = "q"
+	}
+	body := "nil"
+	if o.body != nil {
+		args = append(args, "body "+g.typeName(o.body))
+		body = "body"
+	}
+
+	// Results.
+	var types []reflect.Type
+	text := false
+	for _, r := range ok {
+		switch {
+		case r.typ != nil:
+			if !slices.Contains(types, r.typ) {
+				types = append(types, r.typ)
+			}
+		case r.mediaType != "":
+			text = true
+		}
+	}
+	var ret, zero string
+	switch {
+	case len(types) > 1:
+		ret = "*" + name + "Result"
+		zero = "nil"
+		g.unionDecl(name+"Result", o, ok)
+	case len(types) == 1:
+		ret, zero = g.resultType(types[0])
+	case text:
+		ret, zero = "[]byte", "nil"
+	}
+
+	fmt.Fprintf(w, "// %s calls %s %s: %s.\n", name, o.Method, o.Path, sentence(o.op.Summary))
+	if o.op.Description != "" {
+		fmt.Fprintf(w, "//\n// %s\n", o.op.Description)
+	}
+	if o.op.Security != nil && len(*o.op.Security) == 1 {
+		for scheme := range (*o.op.Security)[0] {
+			fmt.Fprintf(w, "//\n// Only %s authentication is accepted.\n", scheme)
+		}
+	}
+	if ret == "" {
+		fmt.Fprintf(w, "func (c *Client) %s(%s) error {\n", name, strings.Join(args, ", "))
+	} else {
+		fmt.Fprintf(w, "func (c *Client) %s(%s) (%s, error) {\n", name, strings.Join(args, ", "), ret)
+	}
+	if query == "q" {
+		w.WriteString("\tq := p.values()\n")
+	}
+	call := fmt.Sprintf("c.do(ctx, http.Method%s, %s, %s, %s)", methodName(o.Method), strings.Join(pathExpr, " + "), query, body)
+	switch {
+	case ret == "":
+		fmt.Fprintf(w, "\t_, _, err := %s\n\treturn err\n", call)
+	case len(types) > 1:
+		fmt.Fprintf(w, "\tstatus, data, err := %s\n\tif err != nil {\n\t\treturn nil, err\n\t}\n", call)
+		fmt.Fprintf(w, "\tout := &%sResult{Status: status}\n\tswitch status {\n", name)
+		for _, t := range types {
+			var statuses []string
+			for _, r := range ok {
+				if r.typ == t {
+					statuses = append(statuses, fmt.Sprint(r.status))
+				}
+			}
+			field := g.fieldName(t)
+			fmt.Fprintf(w, "\tcase %s:\n\t\tout.%s = new(%s)\n\t\terr = decode(data, out.%s)\n", strings.Join(statuses, ", "), field, g.typeName(t), field)
+		}
+		w.WriteString("\t}\n\treturn out, err\n")
+	case len(types) == 1:
+		fmt.Fprintf(w, "\t_, data, err := %s\n\tif err != nil {\n\t\treturn %s, err\n\t}\n", call, zero)
+		if strings.HasPrefix(ret, "*") {
+			fmt.Fprintf(w, "\tout := new(%s)\n\treturn out, decode(data, out)\n", ret[1:])
+		} else {
+			fmt.Fprintf(w, "\tvar out %s\n\treturn out, decode(data, &out)\n", ret)
+		}
+	default: // text
+		fmt.Fprintf(w, "\t_, data, err := %s\n\treturn data, err\n", call)
+	}
+	w.WriteString("}\n\n")
+}
+
+// resultType is how a method returns a decoded t: structs by pointer, slices
+// and maps as they are.
+func (g *goGen) resultType(t reflect.Type) (ret, zero string) {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Map:
+		return g.typeName(t), "nil"
+	default:
+		return "*" + g.typeName(t), "nil"
+	}
+}
+
+func (o *Op) param(in, name string) Parameter {
+	for _, p := range o.op.Parameters {
+		if p.In == in && p.Name == name {
+			return p
+		}
+	}
+	panic("openapi: " + o.Path + " has no " + in + " parameter " + name)
+}
+
+func (o *Op) queryParams() []Parameter {
+	var qs []Parameter
+	for _, p := range o.op.Parameters {
+		if p.In == "query" {
+			qs = append(qs, p)
+		}
+	}
+	return qs
+}
+
+// paramsDecl declares the struct holding an operation's query parameters.
+// Zero values are left out of the query; booleans are pointers so false can
+// be sent.
+func (g *goGen) paramsDecl(name string, o *Op, qs []Parameter) {
+	var d, values strings.Builder
+	fmt.Fprintf(&d, "// %s holds the query parameters of %s. Zero values are not sent.\n", name, exportName(o.ID()))
+	fmt.Fprintf(&d, "type %s struct {\n", name)
+	for _, p := range qs {
+		field := exportName(p.Name)
+		var comment []string
+		if p.Required {
+			comment = append(comment, "required")
+		}
+		if p.Description != "" {
+			comment = append(comment, p.Description)
+		}
+		if len(p.Schema.Enum) > 0 {
+			var vs []string
+			for _, v := range p.Schema.Enum {
+				vs = append(vs, fmt.Sprint(v))
+			}
+			comment = append(comment, "one of "+strings.Join(vs, ", "))
+		}
+		typ, set := "string", "setString"
+		switch p.Schema.Type {
+		case "integer":
+			typ, set = "int64", "setInt"
+		case "boolean":
+			typ, set = "*bool", "setBool"
+		}
+		fmt.Fprintf(&d, "\t%s %s", field, typ)
+		if len(comment) > 0 {
+			fmt.Fprintf(&d, " // %s", strings.Join(comment, "; "))
+		}
+		d.WriteString("\n")
+		fmt.Fprintf(&values, "\t%s(q, %q, p.%s)\n", set, p.Name, field)
+	}
+	d.WriteString("}\n\n")
+	fmt.Fprintf(&d, "func (p %s) values() url.Values {\n\tq := url.Values{}\n%s\treturn q\n}\n", name, values.String())
+	g.decls = append(g.decls, d.String())
+}
+
+// unionDecl declares the result of an operation whose success responses have
+// different bodies; the field for the received status is set.
+func (g *goGen) unionDecl(name string, o *Op, ok []result) {
+	var d strings.Builder
+	fmt.Fprintf(&d, "// %s is the result of %s; the field matching Status is set.\n", name, exportName(o.ID()))
+	fmt.Fprintf(&d, "type %s struct {\n\tStatus int\n", name)
+	var seen []reflect.Type
+	for _, r := range ok {
+		if r.typ == nil || slices.Contains(seen, r.typ) {
+			continue
+		}
+		seen = append(seen, r.typ)
+		var statuses []string
+		for _, r2 := range ok {
+			if r2.typ == r.typ {
+				statuses = append(statuses, fmt.Sprint(r2.status))
+			}
+		}
+		fmt.Fprintf(&d, "\t%s *%s // %s\n", g.fieldName(r.typ), g.typeName(r.typ), strings.Join(statuses, ", "))
+	}
+	d.WriteString("}\n")
+	g.decls = append(g.decls, d.String())
+}
+
+// fieldName names a union field after its type: *enrollment.Enrollment is
+// Enrollment, PendingResult is Pending.
+func (g *goGen) fieldName(t reflect.Type) string {
+	n := g.typeName(t)
+	n = n[strings.LastIndex(n, ".")+1:]
+	if trimmed := strings.TrimSuffix(n, "Result"); trimmed != "" {
+		n = trimmed
+	}
+	return n
+}
+
+// typeName renders t as Go source in the client package, importing its
+// package or copying it there.
+func (g *goGen) typeName(t reflect.Type) string {
+	if t == rawMessageType {
+		g.imports["encoding/json"] = true
+		return "json.RawMessage"
+	}
+	if t.Name() != "" && t.PkgPath() != "" {
+		if t.PkgPath() == "main" {
+			return g.copyType(t)
+		}
+		return g.qualified(t.PkgPath(), t.Name())
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return "*" + g.typeName(t.Elem())
+	case reflect.Slice:
+		return "[]" + g.typeName(t.Elem())
+	case reflect.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), g.typeName(t.Elem()))
+	case reflect.Map:
+		return "map[" + g.typeName(t.Key()) + "]" + g.typeName(t.Elem())
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return "any"
+		}
+	case reflect.Struct:
+		return "struct {\n" + g.fields(t) + "}"
+	}
+	return t.String()
+}
+
+// qualified renders pkgPath.name, including generic instances such as
+// util.Page[book.Book].
+func (g *goGen) qualified(pkgPath, name string) string {
+	g.imports[pkgPath] = true
+	base, args, generic := strings.Cut(name, "[")
+	out := path.Base(pkgPath) + "." + base
+	if !generic {
+		return out
+	}
+	var rendered []string
+	for _, a := range strings.Split(strings.TrimSuffix(args, "]"), ",") {
+		dot := strings.LastIndex(a, ".")
+		if dot < 0 {
+			rendered = append(rendered, a)
+			continue
+		}
+		rendered = append(rendered, g.qualified(a[:dot], a[dot+1:]))
+	}
+	return out + "[" + strings.Join(rendered, ", ") + "]"
+}
+
+// copyType declares an exported copy of a package main type.
+func (g *goGen) copyType(t reflect.Type) string {
+	if name, ok := g.local[t]; ok {
+		return name
+	}
+	name := exportName(t.Name())
+	g.local[t] = name
+	if t.Kind() != reflect.Struct {
+		g.decls = append(g.decls, fmt.Sprintf("type %s %s\n", name, g.typeName(underlying(t))))
+		return name
+	}
+	g.decls = append(g.decls, fmt.Sprintf("type %s struct {\n%s}\n", name, g.fields(t)))
+	return name
+}
+
+func underlying(t reflect.Type) reflect.Type {
+	switch t.Kind() {
+	case reflect.Slice:
+		return reflect.SliceOf(t.Elem())
+	case reflect.Map:
+		return reflect.MapOf(t.Key(), t.Elem())
+	default:
+		return t
+	}
+}
+
+// fields renders a struct's fields with their json and doc tags.
+func (g *goGen) fields(t reflect.Type) string {
+	var b strings.Builder
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		var tags []string
+		for _, key := range []string{"json", "doc", "enum"} {
+			if v, ok := f.Tag.Lookup(key); ok {
+				tags = append(tags, fmt.Sprintf("%s:%q", key, v))
+			}
+		}
+		tag := ""
+		if len(tags) > 0 {
+			tag = " `" + strings.Join(tags, " ") + "`"
+		}
+		if f.Anonymous {
+			fmt.Fprintf(&b, "\t%s%s\n", g.typeName(f.Type), tag)
+		} else {
+			fmt.Fprintf(&b, "\t%s %s%s\n", f.Name, g.typeName(f.Type), tag)
+		}
+	}
+	return b.String()
+}
+
+var initialisms = []string{"TOTP", "CSRF", "OIDC", "URL", "ID"}
+
+// exportName turns an operation id, parameter or type name into an exported
+// Go identifier: courseId is CourseID, loginTOTP is LoginTOTP. Only whole
+// camel-case words are initialisms, so identity stays Identity.
+func exportName(s string) string {
+	var b strings.Builder
+	for _, w := range camelWords(s) {
+		i := slices.IndexFunc(initialisms, func(in string) bool { return strings.EqualFold(in, w) })
+		if i >= 0 {
+			b.WriteString(initialisms[i])
+			continue
+		}
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	return b.String()
+}
+
+// camelWords splits s before each upper-case letter that starts a word:
+// "courseId" is course, Id; "loginTOTPCode" is login, TOTP, Code.
+func camelWords(s string) []string {
+	r := []rune(s)
+	var words []string
+	start := 0
+	for i := 1; i < len(r); i++ {
+		if !unicode.IsUpper(r[i]) {
+			continue
+		}
+		if !unicode.IsUpper(r[i-1]) || (i+1 < len(r) && unicode.IsLower(r[i+1])) {
+			words = append(words, string(r[start:i]))
+			start = i
+		}
+	}
+	if start < len(r) {
+		words = append(words, string(r[start:]))
+	}
+	return words
+}
+
+// sentence lower-cases a summary's first letter unless it starts an acronym.
+func sentence(s string) string {
+	r := []rune(s)
+	if len(r) > 1 && unicode.IsUpper(r[1]) {
+		return s
+	}
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+func methodName(m string) string {
+	return string(m[0]) + strings.ToLower(m[1:])
+}
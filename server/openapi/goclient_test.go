@@ -0,0 +1,35 @@
+package openapi
+
+import "testing"
+
+func TestExportName(t *testing.T) {
+	for in, want := range map[string]string{
+		"courseId":       "CourseID",
+		"identityId":     "IdentityID",
+		"unlinkIdentity": "UnlinkIdentity",
+		"id":             "ID",
+		"loginTOTP":      "LoginTOTP",
+		"oidcLogin":      "OIDCLogin",
+		"webhookUrl":     "WebhookURL",
+		"urls":           "Urls",
+		"getCSRFToken":   "GetCSRFToken",
+		"idleSince":      "IdleSince",
+	} {
+		if got := exportName(in); got != want {
+			t.Errorf("exportName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestStdlib(t *testing.T) {
+	for path, want := range map[string]bool{
+		"context":                        true,
+		"net/http":                       true,
+		"example.com/sqlite-server/util": false,
+		"github.com/google/uuid":         false,
+	} {
+		if got := stdlib(path); got != want {
+			t.Errorf("stdlib(%q) = %v", path, got)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package openapi
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+var rawMessageType = reflect.TypeFor[json.RawMessage]()
+
+// schemaOf returns the schema for values of t as encoding/json writes them.
+// Exported named structs become components and are referenced; anonymous and
+// unexported ones are inlined.
+func (s *Spec) schemaOf(t reflect.Type) *Schema {
+	if t == rawMessageType {
+		return &Schema{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(s.schemaOf(t.Elem()))
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schemaOf(t.Elem())}
+	case reflect.Struct:
+		name := s.componentName(t)
+		if name == "" {
+			return s.structSchema(t)
+		}
+		if _, ok := s.doc.Components.Schemas[name]; !ok {
+			s.doc.Components.Schemas[name] = &Schema{} // placeholder for recursive types
+			s.doc.Components.Schemas[name] = s.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default: // interfaces: anything goes
+		return &Schema{}
+	}
+}
+
+func nullable(sch *Schema) *Schema {
+	if sch.Ref != "" {
+		return &Schema{AllOf: []*Schema{sch}, Nullable: true}
+	}
+	sch.Nullable = true
+	return sch
+}
+
+// componentName names the component for t, or "" to inline it. Generic
+// instances are named after their arguments: util.Page[book.Book] is
+// "BookPage". Names taken by another type get their package as a prefix.
+func (s *Spec) componentName(t reflect.Type) string {
+	name := t.Name()
+	if name == "" || !unicode.IsUpper(rune(name[0])) {
+		return ""
+	}
+	if base, args, ok := strings.Cut(name, "["); ok {
+		name = ""
+		for _, a := range strings.Split(strings.TrimSuffix(args, "]"), ",") {
+			name += a[strings.LastIndex(a, ".")+1:]
+		}
+		name += base
+	}
+	if prev, ok := s.types[name]; ok && prev != t {
+		pkg := path.Base(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	s.types[name] = t
+	return name
+}
+
+func (s *Spec) structSchema(t reflect.Type) *Schema {
+	sch := &Schema{Type: "object", Properties: map[string]*Schema{}, AdditionalProperties: false}
+	s.addFields(sch, t)
+	return sch
+}
+
+// addFields adds t's JSON fields to sch, flattening embedded structs.
+func (s *Spec) addFields(sch *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				s.addFields(sch, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		omitempty := strings.Contains(opts, "omitempty")
+
+		var fs *Schema
+		if omitempty && ft.Kind() == reflect.Pointer {
+			fs = s.schemaOf(ft.Elem()) // omitted when nil, never null
+		} else {
+			fs = s.schemaOf(ft)
+		}
+		if desc := f.Tag.Get("doc"); desc != "" {
+			fs = describe(fs, desc)
+		}
+		if enum := f.Tag.Get("enum"); enum != "" {
+			for _, v := range strings.Split(enum, "|") {
+				fs.Enum = append(fs.Enum, v)
+			}
+		}
+		sch.Properties[name] = fs
+		if !omitempty {
+			sch.Required = append(sch.Required, name)
+		}
+	}
+}
+
+func describe(sch *Schema, desc string) *Schema {
+	if sch.Ref != "" {
+		return &Schema{AllOf: []*Schema{sch}, Description: desc}
+	}
+	sch.Description = desc
+	return sch
+}
@@ -0,0 +1,321 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Spec collects operations and builds the document from them.
+type Spec struct {
+	doc    Document
+	types  map[string]reflect.Type
+	ops    []*Op
+	tag    string
+	errors *Schema
+}
+
+// New returns an empty spec. errorBody is the type of error responses; every
+// error status an operation declares is documented with it.
+func New(title, version, description string, errorBody any) *Spec {
+	s := &Spec{
+		doc: Document{
+			OpenAPI: "3.0.3",
+			Info:    Info{Title: title, Version: version, Description: description},
+			Paths:   map[string]PathItem{},
+			Components: Components{
+				Schemas:         map[string]*Schema{},
+				SecuritySchemes: map[string]SecurityScheme{},
+			},
+		},
+		types: map[string]reflect.Type{},
+	}
+	s.errors = s.schemaOf(reflect.TypeOf(errorBody))
+	return s
+}
+
+// Server adds a base URL that paths are relative to.
+func (s *Spec) Server(url string) {
+	s.doc.Servers = append(s.doc.Servers, Server{URL: url})
+}
+
+// Security adds a security scheme. Operations not marked Public accept any of
+// the schemes added with Security.
+func (s *Spec) Security(name string, scheme SecurityScheme) {
+	s.doc.Components.SecuritySchemes[name] = scheme
+	s.doc.Security = append(s.doc.Security, SecurityRequirement{name: {}})
+}
+
+// Tag starts a group: operations added after it are tagged with name.
+func (s *Spec) Tag(name string) {
+	s.doc.Tags = append(s.doc.Tags, Tag{Name: name})
+	s.tag = name
+}
+
+// Model adds v's type to the components without an operation using it, for
+// payloads the document can't otherwise describe (event stream messages).
+func (s *Spec) Model(v any) {
+	s.schemaOf(reflect.TypeOf(v))
+}
+
+// Op adds an operation. route is "METHOD /path", with {name} path parameters.
+func (s *Spec) Op(id, route, summary string) *Op {
+	method, p, ok := strings.Cut(route, " ")
+	if !ok {
+		panic("openapi: route must be \"METHOD /path\": " + route)
+	}
+	o := &Op{
+		spec:   s,
+		Method: method,
+		Path:   p,
+		op: &Operation{
+			OperationID: id,
+			Summary:     summary,
+			Responses:   map[string]*Response{},
+		},
+		match: pathPattern(p),
+	}
+	if s.tag != "" {
+		o.op.Tags = []string{s.tag}
+	}
+	for _, m := range pathParam.FindAllStringSubmatch(p, -1) {
+		o.op.Parameters = append(o.op.Parameters, Parameter{
+			Name: m[1], In: "path", Required: true, Schema: &Schema{Type: "string"},
+		})
+	}
+	item := s.doc.Paths[p]
+	if item == nil {
+		item = PathItem{}
+		s.doc.Paths[p] = item
+	}
+	item[strings.ToLower(method)] = o.op
+	s.ops = append(s.ops, o)
+	return o
+}
+
+// Ops returns the operations in the order they were added.
+func (s *Spec) Ops() []*Op { return s.ops }
+
+// Document returns the built document.
+func (s *Spec) Document() *Document { return &s.doc }
+
+// JSON returns the document, indented.
+func (s *Spec) JSON() []byte {
+	b, err := json.MarshalIndent(s.doc, "", "  ")
+	if err != nil {
+		panic(err) // the document only holds plain values
+	}
+	return append(b, '\n')
+}
+
+// Find returns the operation for method and path (relative to the server URL).
+func (s *Spec) Find(method, path string) *Op {
+	for _, o := range s.ops {
+		if o.Method == method && o.match.MatchString(path) {
+			return o
+		}
+	}
+	return nil
+}
+
+var pathParam = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+func pathPattern(p string) *regexp.Regexp {
+	parts := pathParam.Split(p, -1)
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, "[^/]+") + "$")
+}
+
+// Op is one operation being described.
+type Op struct {
+	spec   *Spec
+	Method string
+	Path   string
+	op     *Operation
+	match  *regexp.Regexp
+
+	// The Go types behind the document, for GoClient.
+	body    reflect.Type
+	results []result
+}
+
+// result is one documented success response: a JSON body of type typ, a
+// non-JSON body of mediaType, or (both zero) no body.
+type result struct {
+	status    int
+	typ       reflect.Type
+	mediaType string
+}
+
+// ID returns the operation id.
+func (o *Op) ID() string { return o.op.OperationID }
+
+// Describe sets the longer description.
+func (o *Op) Describe(text string) *Op {
+	o.op.Description = text
+	return o
+}
+
+// Public marks the operation as needing no authentication.
+func (o *Op) Public() *Op {
+	o.op.Security = &[]SecurityRequirement{}
+	return o
+}
+
+// Only restricts the operation to the named security scheme.
+func (o *Op) Only(scheme string) *Op {
+	o.op.Security = &[]SecurityRequirement{{scheme: {}}}
+	return o
+}
+
+// PathParam sets the type of a path parameter ("integer" or "string").
+func (o *Op) PathParam(name, typ, desc string) *Op {
+	for i := range o.op.Parameters {
+		if p := &o.op.Parameters[i]; p.In == "path" && p.Name == name {
+			p.Schema = &Schema{Type: typ}
+			p.Description = desc
+			return o
+		}
+	}
+	panic("openapi: " + o.Path + " has no path parameter " + name)
+}
+
+// Query adds a query parameter of type typ ("string", "integer", "boolean").
+func (o *Op) Query(name, typ string, required bool, desc string) *Op {
+	o.op.Parameters = append(o.op.Parameters, Parameter{
+		Name: name, In: "query", Required: required, Description: desc, Schema: &Schema{Type: typ},
+	})
+	return o
+}
+
+// QueryEnum adds a string query parameter limited to values.
+func (o *Op) QueryEnum(name string, required bool, desc string, values ...string) *Op {
+	sch := &Schema{Type: "string"}
+	for _, v := range values {
+		sch.Enum = append(sch.Enum, v)
+	}
+	o.op.Parameters = append(o.op.Parameters, Parameter{
+		Name: name, In: "query", Required: required, Description: desc, Schema: sch,
+	})
+	return o
+}
+
+// Body documents a required JSON request body shaped like v.
+func (o *Op) Body(v any) *Op {
+	o.body = reflect.TypeOf(v)
+	o.op.RequestBody = &RequestBody{
+		Required: true,
+		Content:  map[string]MediaType{"application/json": {Schema: o.spec.schemaOf(reflect.TypeOf(v))}},
+	}
+	return o
+}
+
+// OptionalBody documents a JSON request body that may be omitted.
+func (o *Op) OptionalBody(v any) *Op {
+	o.Body(v)
+	o.op.RequestBody.Required = false
+	return o
+}
+
+// Returns documents a JSON response shaped like v.
+func (o *Op) Returns(status int, v any) *Op {
+	o.results = append(o.results, result{status: status, typ: reflect.TypeOf(v)})
+	return o.respond(status, "application/json", o.spec.schemaOf(reflect.TypeOf(v)))
+}
+
+// ReturnsText documents a non-JSON response of the given media type.
+func (o *Op) ReturnsText(status int, mediaType string) *Op {
+	o.results = append(o.results, result{status: status, mediaType: mediaType})
+	return o.respond(status, mediaType, &Schema{Type: "string"})
+}
+
+// Empty documents responses without a body (204, 302, 304, ...).
+func (o *Op) Empty(statuses ...int) *Op {
+	for _, st := range statuses {
+		o.op.Responses[strconv.Itoa(st)] = &Response{Description: http.StatusText(st)}
+		o.results = append(o.results, result{status: st})
+	}
+	return o
+}
+
+// Errors documents error responses.
+func (o *Op) Errors(statuses ...int) *Op {
+	for _, st := range statuses {
+		o.respond(st, "application/problem+json", o.spec.errors)
+	}
+	return o
+}
+
+func (o *Op) respond(status int, mediaType string, sch *Schema) *Op {
+	key := strconv.Itoa(status)
+	r := o.op.Responses[key]
+	if r == nil {
+		r = &Response{Description: http.StatusText(status), Content: map[string]MediaType{}}
+		o.op.Responses[key] = r
+	}
+	if r.Content == nil {
+		r.Content = map[string]MediaType{}
+	}
+	r.Content[mediaType] = MediaType{Schema: sch}
+	return o
+}
+
+// SamplePath is Path with every parameter replaced by "1".
+func (o *Op) SamplePath() string {
+	return pathParam.ReplaceAllString(o.Path, "1")
+}
+
+// CheckRequest validates a JSON request body against the operation.
+func (o *Op) CheckRequest(body []byte) error {
+	rb := o.op.RequestBody
+	if rb == nil {
+		if len(strings.TrimSpace(string(body))) > 0 {
+			return fmt.Errorf("%s %s takes no request body", o.Method, o.Path)
+		}
+		return nil
+	}
+	if len(body) == 0 {
+		if rb.Required {
+			return fmt.Errorf("%s %s: request body is required", o.Method, o.Path)
+		}
+		return nil
+	}
+	return o.spec.validateJSON(rb.Content["application/json"].Schema, body)
+}
+
+// CheckResponse validates a response against the operation: the status must
+// be documented and the body must match its media type and schema.
+func (o *Op) CheckResponse(status int, contentType string, body []byte) error {
+	r := o.op.Responses[strconv.Itoa(status)]
+	if r == nil {
+		return fmt.Errorf("status %d is not documented", status)
+	}
+	if len(r.Content) == 0 {
+		if len(body) > 0 {
+			return fmt.Errorf("status %d is documented without a body, got %q", status, truncate(body))
+		}
+		return nil
+	}
+	mt, _, _ := strings.Cut(contentType, ";")
+	media, ok := r.Content[strings.TrimSpace(mt)]
+	if !ok {
+		return fmt.Errorf("status %d: undocumented content type %q", status, contentType)
+	}
+	if !strings.HasSuffix(mt, "json") {
+		return nil
+	}
+	return o.spec.validateJSON(media.Schema, body)
+}
+
+func truncate(b []byte) string {
+	if len(b) > 80 {
+		return string(b[:80]) + "..."
+	}
+	return string(b)
+}
@@ -0,0 +1,131 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+func (s *Spec) validateJSON(sch *Schema, body []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("invalid JSON: %v", err)
+	}
+	return s.validate(sch, v, "$")
+}
+
+// validate checks a decoded JSON value (numbers as json.Number) against sch.
+func (s *Spec) validate(sch *Schema, v any, at string) error {
+	if sch.Ref != "" {
+		name := strings.TrimPrefix(sch.Ref, "#/components/schemas/")
+		ref, ok := s.doc.Components.Schemas[name]
+		if !ok {
+			return fmt.Errorf("%s: unknown schema %s", at, sch.Ref)
+		}
+		return s.validate(ref, v, at)
+	}
+	if v == nil {
+		if sch.Nullable || (sch.Type == "" && len(sch.AllOf) == 0) {
+			return nil
+		}
+		return fmt.Errorf("%s: null is not allowed", at)
+	}
+	for _, sub := range sch.AllOf {
+		if err := s.validate(sub, v, at); err != nil {
+			return err
+		}
+	}
+	if len(sch.Enum) > 0 && !slices.Contains(sch.Enum, v) {
+		return fmt.Errorf("%s: %v is not one of %v", at, v, sch.Enum)
+	}
+
+	switch sch.Type {
+	case "":
+		return nil
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return typeError(at, sch.Type, v)
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return typeError(at, sch.Type, v)
+		}
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return typeError(at, sch.Type, v)
+		}
+		if _, err := n.Int64(); err != nil {
+			return typeError(at, sch.Type, v)
+		}
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			return typeError(at, sch.Type, v)
+		}
+	case "array":
+		a, ok := v.([]any)
+		if !ok {
+			return typeError(at, sch.Type, v)
+		}
+		for i, item := range a {
+			if err := s.validate(sch.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		m, ok := v.(map[string]any)
+		if !ok {
+			return typeError(at, sch.Type, v)
+		}
+		for _, name := range sch.Required {
+			if _, ok := m[name]; !ok {
+				return fmt.Errorf("%s: missing %q", at, name)
+			}
+		}
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			sub, ok := sch.Properties[k]
+			if !ok {
+				switch ap := sch.AdditionalProperties.(type) {
+				case *Schema:
+					sub = ap
+				case bool:
+					if !ap {
+						return fmt.Errorf("%s: unexpected property %q", at, k)
+					}
+					continue
+				default:
+					continue
+				}
+			}
+			if err := s.validate(sub, m[k], at+"."+k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func typeError(at, want string, v any) error {
+	got := "object"
+	switch v.(type) {
+	case bool:
+		got = "boolean"
+	case string:
+		got = "string"
+	case json.Number:
+		got = "number"
+	case []any:
+		got = "array"
+	}
+	return fmt.Errorf("%s: expected %s, got %s", at, want, got)
+}
@@ -0,0 +1,206 @@
+package push
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"example.com/sqlite-server/session"
+	"example.com/sqlite-server/util"
+)
+
+func RegisterPushRoutes(mux util.Router, db *sql.DB) {
+	mux.HandleFunc("/push/settings", session.RequireAuth(db, settingsHandler(db)))
+	mux.HandleFunc("/push/subscriptions", session.RequireAuth(db, subscriptionsHandler(db)))
+	mux.HandleFunc("/push/test", session.RequireAuth(db, testHandler(db)))
+}
+
+// settingsResult adds what a browser needs to subscribe.
+type settingsResult struct {
+	Settings
+	Enabled   bool   `json:"enabled"`
+	PublicKey string `json:"publicKey,omitempty" doc:"the VAPID key, for PushManager.subscribe's applicationServerKey"`
+}
+
+func result(s Settings) settingsResult {
+	r := settingsResult{Settings: s, Enabled: Default != nil}
+	if Default != nil {
+		r.PublicKey = Default.PublicKey
+	}
+	return r
+}
+
+// GET /push/settings
+// PUT /push/settings
+// Body: { "leadHours": 24, "chapters": true, "articles": true, "assignments": false }
+// Omitted fields keep their current values.
+func settingsHandler(db *sql.DB) http.HandlerFunc {
+	type payload struct {
+		LeadHours   *int  `json:"leadHours"`
+		Chapters    *bool `json:"chapters"`
+		Articles    *bool `json:"articles"`
+		Assignments *bool `json:"assignments"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, _ := session.UserIDFromCtx(r.Context())
+		switch r.Method {
+		case http.MethodGet:
+			s, err := GetSettings(db, uid)
+			if err != nil {
+				util.WriteError(w, err)
+				return
+			}
+			util.WriteJSON(w, result(s), http.StatusOK)
+
+		case http.MethodPut:
+			var p payload
+			dec := json.NewDecoder(r.Body)
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&p); err != nil {
+				util.HTTPError(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			s, err := GetSettings(db, uid)
+			if err != nil {
+				util.WriteError(w, err)
+				return
+			}
+			if p.LeadHours != nil {
+				s.LeadHours = *p.LeadHours
+			}
+			if p.Chapters != nil {
+				s.Chapters = *p.Chapters
+			}
+			if p.Articles != nil {
+				s.Articles = *p.Articles
+			}
+			if p.Assignments != nil {
+				s.Assignments = *p.Assignments
+			}
+			s, err = SaveSettings(db, uid, s)
+			if err != nil {
+				util.WriteError(w, err)
+				return
+			}
+			util.WriteJSON(w, result(s), http.StatusOK)
+
+		default:
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func subscriptionsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			listSubscriptionsHandler(db)(w, r)
+		case http.MethodPost:
+			subscribeHandler(db)(w, r)
+		case http.MethodDelete:
+			unsubscribeHandler(db)(w, r)
+		default:
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// GET /push/subscriptions
+func listSubscriptionsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, _ := session.UserIDFromCtx(r.Context())
+		list, err := ListSubscriptions(db, uid)
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		util.WriteJSON(w, list, http.StatusOK)
+	}
+}
+
+// POST /push/subscriptions
+// Body: the browser's PushSubscription as JSON:
+// { "endpoint": "https://...", "expirationTime": null, "keys": { "p256dh": "...", "auth": "..." } }
+func subscribeHandler(db *sql.DB) http.HandlerFunc {
+	type payload struct {
+		Endpoint       string `json:"endpoint"`
+		ExpirationTime *int64 `json:"expirationTime"`
+		Keys           struct {
+			P256dh string `json:"p256dh"`
+			Auth   string `json:"auth"`
+		} `json:"keys"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, _ := session.UserIDFromCtx(r.Context())
+
+		var p payload
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil || p.Endpoint == "" || p.Keys.P256dh == "" || p.Keys.Auth == "" {
+			util.HTTPError(w, "endpoint and keys are required", http.StatusBadRequest)
+			return
+		}
+		s, err := Subscribe(db, uid, p.Endpoint, p.Keys.P256dh, p.Keys.Auth, r.UserAgent())
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		util.WriteJSON(w, s, http.StatusCreated)
+	}
+}
+
+// DELETE /push/subscriptions
+// Body: { "endpoint": "https://..." }
+func unsubscribeHandler(db *sql.DB) http.HandlerFunc {
+	type payload struct {
+		Endpoint string `json:"endpoint"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, _ := session.UserIDFromCtx(r.Context())
+
+		var p payload
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil || p.Endpoint == "" {
+			util.HTTPError(w, "endpoint is required", http.StatusBadRequest)
+			return
+		}
+		err := Unsubscribe(db, uid, p.Endpoint)
+		if err == sql.ErrNoRows {
+			util.HTTPError(w, "subscription not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// POST /push/test
+// Sends a test notification to the user's browsers.
+func testHandler(db *sql.DB) http.HandlerFunc {
+	type response struct {
+		Sent int `json:"sent"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if Default == nil {
+			util.HTTPError(w, "push notifications are not configured", http.StatusServiceUnavailable)
+			return
+		}
+		uid, _ := session.UserIDFromCtx(r.Context())
+		n, err := SendTest(r.Context(), db, Default, uid)
+		if err != nil && n == 0 {
+			log.Printf("push test to %s: %v", uid, err)
+			util.HTTPError(w, "the push service did not accept the notification", http.StatusBadGateway)
+			return
+		}
+		util.WriteJSON(w, response{Sent: n}, http.StatusOK)
+	}
+}
@@ -0,0 +1,176 @@
+package push
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var b64 = base64.RawURLEncoding
+
+// decodeKey accepts the URL-safe base64 browsers use, padded or not (and the
+// standard alphabet, which some libraries emit).
+func decodeKey(s string) ([]byte, error) {
+	s = strings.TrimRight(strings.TrimSpace(s), "=")
+	s = strings.NewReplacer("+", "-", "/", "_").Replace(s)
+	return b64.DecodeString(s)
+}
+
+// recordSize is the aes128gcm record size; payloads fit in one record.
+const recordSize = 4096
+
+// encrypt seals plaintext for a browser per RFC 8291 (aes128gcm content
+// coding): an ECDH agreement between an ephemeral key and the browser's
+// p256dh key, mixed with its auth secret, keys AES-128-GCM. The result is
+// the content-coding header (salt, record size, ephemeral public key)
+// followed by the single record.
+func encrypt(plaintext, uaPublic, authSecret []byte, asPrivate *ecdh.PrivateKey, salt []byte) ([]byte, error) {
+	ua, err := ecdh.P256().NewPublicKey(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := asPrivate.ECDH(ua)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asPrivate.PublicKey().Bytes()
+
+	keyInfo := append([]byte("WebPush: info\x00"), uaPublic...)
+	keyInfo = append(keyInfo, asPublic...)
+	ikm, err := hkdf.Key(sha256.New, shared, authSecret, string(keyInfo), 32)
+	if err != nil {
+		return nil, err
+	}
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// A 0x02 delimiter marks the last (only) record; no padding.
+	record := append(append([]byte{}, plaintext...), 0x02)
+	if len(record)+gcm.Overhead() > recordSize {
+		return nil, fmt.Errorf("push payload too large (%d bytes)", len(plaintext))
+	}
+
+	out := make([]byte, 0, 16+4+1+len(asPublic)+len(record)+gcm.Overhead())
+	out = append(out, salt...)
+	out = binary.BigEndian.AppendUint32(out, recordSize)
+	out = append(out, byte(len(asPublic)))
+	out = append(out, asPublic...)
+	return gcm.Seal(out, nonce, record, nil), nil
+}
+
+// seal encrypts plaintext for a subscription with a fresh key and salt.
+func seal(plaintext []byte, s subscription) ([]byte, error) {
+	uaPublic, err := decodeKey(s.p256dh)
+	if err != nil {
+		return nil, err
+	}
+	authSecret, err := decodeKey(s.auth)
+	if err != nil {
+		return nil, err
+	}
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return encrypt(plaintext, uaPublic, authSecret, asPrivate, salt)
+}
+
+// VAPID identifies this server to push services (RFC 8292).
+type VAPID struct {
+	key       *ecdsa.PrivateKey
+	PublicKey string // URL-safe base64 of the uncompressed point; browsers need it to subscribe
+	Subject   string // a mailto: or https: contact for the push service's operators
+}
+
+// NewVAPID loads a key pair from the URL-safe base64 private scalar.
+func NewVAPID(privateKey, subject string) (*VAPID, error) {
+	raw, err := decodeKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("VAPID_PRIVATE_KEY: %w", err)
+	}
+	key, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), raw)
+	if err != nil {
+		return nil, fmt.Errorf("VAPID_PRIVATE_KEY: %w", err)
+	}
+	pub, err := key.PublicKey.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return &VAPID{key: key, PublicKey: b64.EncodeToString(pub), Subject: subject}, nil
+}
+
+// GenerateVAPID returns a new private key, encoded for VAPID_PRIVATE_KEY.
+func GenerateVAPID() (string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	raw, err := key.Bytes()
+	if err != nil {
+		return "", err
+	}
+	return b64.EncodeToString(raw), nil
+}
+
+// authorization is the Authorization header for a request to endpoint: an
+// ES256 JWT for the endpoint's origin, valid for 12 hours, and the public key.
+func (v *VAPID) authorization(endpoint string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	header := b64.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, err := json.Marshal(map[string]any{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(12 * time.Hour).Unix(),
+		"sub": v.Subject,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + b64.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, v.key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	// JWS wants the fixed-size r || s, not ASN.1.
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return "vapid t=" + unsigned + "." + b64.EncodeToString(sig) + ", k=" + v.PublicKey, nil
+}
@@ -0,0 +1,152 @@
+package push
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// decrypt opens an aes128gcm body as the browser holding uaPrivate would
+// (RFC 8291 section 3.4, RFC 8188).
+func decrypt(body []byte, uaPrivate *ecdh.PrivateKey, authSecret []byte) ([]byte, error) {
+	if len(body) < 21 {
+		return nil, errors.New("short header")
+	}
+	salt, rs, idLen := body[:16], binary.BigEndian.Uint32(body[16:20]), int(body[20])
+	if len(body) < 21+idLen {
+		return nil, errors.New("short key id")
+	}
+	asPublic, record := body[21:21+idLen], body[21+idLen:]
+	if uint32(len(record)) > rs {
+		return nil, errors.New("more than one record")
+	}
+
+	as, err := ecdh.P256().NewPublicKey(asPublic)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := uaPrivate.ECDH(as)
+	if err != nil {
+		return nil, err
+	}
+	keyInfo := append([]byte("WebPush: info\x00"), uaPrivate.PublicKey().Bytes()...)
+	keyInfo = append(keyInfo, asPublic...)
+	ikm, err := hkdf.Key(sha256.New, shared, authSecret, string(keyInfo), 32)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, nonce, record, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Strip padding back to the last-record delimiter.
+	plain = bytes.TrimRight(plain, "\x00")
+	if len(plain) == 0 || plain[len(plain)-1] != 0x02 {
+		return nil, errors.New("missing last-record delimiter")
+	}
+	return plain[:len(plain)-1], nil
+}
+
+func mustDecode(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := decodeKey(s)
+	if err != nil {
+		t.Fatalf("%s: %v", s, err)
+	}
+	return b
+}
+
+// TestEncryptRFC8291 checks encrypt against the example in RFC 8291 section 5.
+func TestEncryptRFC8291(t *testing.T) {
+	plaintext := []byte("When I grow up, I want to be a watermelon")
+	asPrivate, err := ecdh.P256().NewPrivateKey(mustDecode(t, "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uaPrivate, err := ecdh.P256().NewPrivateKey(mustDecode(t, "q1dXpw3UpT5VOmu_cf_v6ih07Aems3njxI-JWgLcM94"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uaPublic := mustDecode(t, "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4")
+	if !bytes.Equal(uaPrivate.PublicKey().Bytes(), uaPublic) {
+		t.Fatal("test vector keys do not match")
+	}
+	authSecret := mustDecode(t, "BTBZMqHH6r4Tts7J_aSIgg")
+	salt := mustDecode(t, "DGv6ra1nlYgDCS1FRnbzlw")
+	want := mustDecode(t, "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN")
+
+	got, err := encrypt(plaintext, uaPublic, authSecret, asPrivate, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("encrypt =\n%s\nwant\n%s", b64.EncodeToString(got), b64.EncodeToString(want))
+	}
+	back, err := decrypt(got, uaPrivate, authSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(back, plaintext) {
+		t.Fatalf("decrypt = %q", back)
+	}
+}
+
+func TestSealRoundTrip(t *testing.T) {
+	uaPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authSecret := make([]byte, 16)
+	rand.Read(authSecret)
+	s := subscription{
+		p256dh: b64.EncodeToString(uaPrivate.PublicKey().Bytes()),
+		auth:   b64.EncodeToString(authSecret) + "==", // padded, as some browsers send it
+	}
+
+	msg := []byte(`{"title":"Due in 2 hours"}`)
+	a, err := seal(msg, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := seal(msg, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(a, b) {
+		t.Fatal("two seals of the same message are identical; key or salt reused")
+	}
+	back, err := decrypt(a, uaPrivate, authSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(back, msg) {
+		t.Fatalf("decrypt = %q", back)
+	}
+
+	if _, err := seal(make([]byte, recordSize), s); err == nil {
+		t.Fatal("a payload larger than one record was sealed")
+	}
+}
@@ -0,0 +1,242 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/sqlite-server/util"
+)
+
+const sendTimeout = 10 * time.Second
+
+// client posts to push services; see util.PublicClient.
+var client = util.PublicClient(sendTimeout, func() bool { return AllowPrivate })
+
+// Notification is the JSON payload the service worker shows.
+type Notification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Tag   string `json:"tag"` // replaces an earlier notification for the same item
+	URL   string `json:"url"` // opened when the notification is clicked
+}
+
+// errGone means the push service dropped the subscription (404 or 410).
+var errGone = errors.New("subscription expired")
+
+// post sends one encrypted notification. ttl is how long the push service
+// should keep it for an offline browser.
+func post(ctx context.Context, v *VAPID, s subscription, payload []byte, ttl time.Duration, now time.Time) error {
+	body, err := seal(payload, s)
+	if err != nil {
+		return err
+	}
+	auth, err := v.authorization(s.endpoint, now)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(ttl/time.Second)))
+	req.Header.Set("Urgency", "normal")
+
+	res, err := client.Do(req)
+	if err != nil {
+		if errors.Is(err, util.ErrPrivateAddress) {
+			return util.ErrPrivateAddress
+		}
+		return err
+	}
+	defer res.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(res.Body, 200))
+	switch {
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone:
+		return errGone
+	case res.StatusCode < 200 || res.StatusCode > 299:
+		msg := res.Status
+		if t := strings.TrimSpace(string(snippet)); t != "" {
+			msg += ": " + t
+		}
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// sendToUser posts payload to each of userID's subscriptions, deleting the
+// ones the push service says are gone. It returns how many accepted it and
+// the last other failure.
+func sendToUser(ctx context.Context, db *sql.DB, v *VAPID, userID string, payload []byte, ttl time.Duration, now time.Time) (int, error) {
+	rows, err := db.Query(`SELECT id, endpoint, p256dh, auth FROM push_subscriptions WHERE user_id = ?`, userID)
+	if err != nil {
+		return 0, err
+	}
+	var subs []subscription
+	for rows.Next() {
+		var s subscription
+		if err := rows.Scan(&s.id, &s.endpoint, &s.p256dh, &s.auth); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		subs = append(subs, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	var lastErr error
+	for _, s := range subs {
+		err := post(ctx, v, s, payload, ttl, now)
+		switch {
+		case err == nil:
+			sent++
+		case errors.Is(err, errGone):
+			if _, err := db.Exec(`DELETE FROM push_subscriptions WHERE id = ?`, s.id); err != nil {
+				return sent, err
+			}
+		default:
+			lastErr = err
+		}
+	}
+	return sent, lastErr
+}
+
+// dueItem is a deadline within someone's lead time.
+type dueItem struct {
+	userID, kind, summary string
+	sourceID, deadline    int64
+}
+
+// SendDue notifies users of deadlines within their lead time that they
+// haven't completed and weren't notified of yet. An item counts as notified
+// once a browser accepted it or the user has no browsers left; otherwise it
+// is retried on the next run, until the deadline passes. It stops early once
+// ctx is done. Returns the number of notifications sent.
+func SendDue(ctx context.Context, db *sql.DB, v *VAPID, now time.Time) (int, error) {
+	rows, err := db.Query(`
+		SELECT c.user_id, c.kind, c.source_id, c.summary, c.deadline_epoch
+		  FROM calendar_index c
+		  LEFT JOIN push_settings s ON s.user_id = c.user_id
+		 WHERE c.cancelled_at IS NULL
+		   AND c.deadline_epoch > ? AND c.deadline_epoch <= ? + COALESCE(s.lead_hours, 24) * 3600
+		   AND EXISTS (SELECT 1 FROM push_subscriptions ps WHERE ps.user_id = c.user_id)
+		   AND CASE c.kind WHEN 'chapter' THEN COALESCE(s.chapters, 1)
+		                   WHEN 'article' THEN COALESCE(s.articles, 1)
+		                   WHEN 'assignment' THEN COALESCE(s.assignments, 1) END = 1
+		   AND NOT EXISTS (
+		         SELECT 1 FROM push_sent n
+		          WHERE n.user_id = c.user_id AND n.kind = c.kind
+		            AND n.source_id = c.source_id AND n.deadline_epoch = c.deadline_epoch)
+		   AND NOT EXISTS (
+		         SELECT 1 FROM progress p
+		          WHERE p.user_id = c.user_id AND p.completed = 1
+		            AND CASE c.kind WHEN 'chapter' THEN p.chapter_id
+		                            WHEN 'article' THEN p.article_id
+		                            WHEN 'assignment' THEN p.assignment_id END = c.source_id)
+		 ORDER BY c.user_id, c.deadline_epoch
+	`, now.Unix(), now.Unix())
+	if err != nil {
+		return 0, err
+	}
+	var due []dueItem
+	for rows.Next() {
+		var d dueItem
+		if err := rows.Scan(&d.userID, &d.kind, &d.sourceID, &d.summary, &d.deadline); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, d := range due {
+		if ctx.Err() != nil {
+			return sent, nil // shutting down; the rest go out next time
+		}
+		deadline := time.Unix(d.deadline, 0)
+		payload, err := json.Marshal(Notification{
+			Title: dueIn(deadline.Sub(now)),
+			Body:  d.summary,
+			Tag:   fmt.Sprintf("%s:%d", d.kind, d.sourceID),
+			URL:   "/",
+		})
+		if err != nil {
+			return sent, err
+		}
+		n, err := sendToUser(ctx, db, v, d.userID, payload, deadline.Sub(now), now)
+		sent += n
+		if err != nil && n == 0 {
+			log.Printf("push %s %d to %s: %v", d.kind, d.sourceID, d.userID, err)
+			var left int
+			if qerr := db.QueryRow(`SELECT COUNT(*) FROM push_subscriptions WHERE user_id = ?`, d.userID).Scan(&left); qerr != nil {
+				return sent, qerr
+			}
+			if left > 0 {
+				continue
+			}
+		}
+		if _, err := db.Exec(`
+			INSERT OR IGNORE INTO push_sent (user_id, kind, source_id, deadline_epoch) VALUES (?, ?, ?, ?)
+		`, d.userID, d.kind, d.sourceID, d.deadline); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// dueIn is a notification title for a deadline d from now.
+func dueIn(d time.Duration) string {
+	switch h := int(d.Round(time.Hour) / time.Hour); {
+	case d < time.Hour:
+		return "Due in less than an hour"
+	case h == 1:
+		return "Due in 1 hour"
+	case h < 48:
+		return fmt.Sprintf("Due in %d hours", h)
+	default:
+		return fmt.Sprintf("Due in %d days", h/24)
+	}
+}
+
+// SendTest sends a test notification to userID's browsers and returns how
+// many accepted it.
+func SendTest(ctx context.Context, db *sql.DB, v *VAPID, userID string) (int, error) {
+	payload, err := json.Marshal(Notification{
+		Title: "Notifications are on",
+		Body:  "You'll be reminded here before your deadlines.",
+		Tag:   "test",
+		URL:   "/",
+	})
+	if err != nil {
+		return 0, err
+	}
+	return sendToUser(ctx, db, v, userID, payload, time.Hour, time.Now())
+}
+
+// PruneSent forgets notifications for deadlines before cutoff.
+func PruneSent(db *sql.DB, cutoff time.Time) (int64, error) {
+	res, err := db.Exec(`DELETE FROM push_sent WHERE deadline_epoch < ?`, cutoff.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
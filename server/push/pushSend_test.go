@@ -0,0 +1,155 @@
+package push
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/sqlite-server/store"
+)
+
+// pushService records what reaches a fake push service; /gone answers 410.
+type pushService struct {
+	mu     sync.Mutex
+	bodies [][]byte
+	gone   int
+}
+
+func (p *pushService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if r.URL.Path == "/gone" {
+		p.gone++
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+	p.bodies = append(p.bodies, body)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func TestSendDueNotifiesOnceAndDropsGoneSubscriptions(t *testing.T) {
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	svc := &pushService{}
+	srv := httptest.NewServer(svc)
+	defer srv.Close()
+	AllowPrivate = true
+	defer func() { AllowPrivate = false }()
+
+	key, err := GenerateVAPID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewVAPID(key, "mailto:test@localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	deadline := now.Add(2 * time.Hour)
+	for _, q := range []string{
+		`INSERT INTO users (id, email, password) VALUES ('u1', 'a@x.io', '')`,
+		`INSERT INTO universities (id, name) VALUES ('uni', 'Uni')`,
+		`INSERT INTO courses (id, university_id, year, term, code, name) VALUES (1, 'uni', 2026, 1, 'CS101', 'Intro')`,
+		`INSERT INTO user_courses (user_id, course_id) VALUES ('u1', 1)`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO assignments (course_id, title, deadline) VALUES (1, 'Essay', ?)`, deadline.Unix()); err != nil {
+		t.Fatal(err)
+	}
+	// Far outside the default 24 hour lead time.
+	if _, err := db.Exec(`INSERT INTO assignments (course_id, title, deadline) VALUES (1, 'Project', ?)`, now.Add(72*time.Hour).Unix()); err != nil {
+		t.Fatal(err)
+	}
+
+	uaPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authSecret := make([]byte, 16)
+	rand.Read(authSecret)
+	p256dh, auth := b64.EncodeToString(uaPrivate.PublicKey().Bytes()), b64.EncodeToString(authSecret)
+	for _, path := range []string{"/live", "/gone"} {
+		if _, err := Subscribe(db, "u1", srv.URL+path, p256dh, auth, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := SendDue(context.Background(), db, v, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || len(svc.bodies) != 1 || svc.gone != 1 {
+		t.Fatalf("sent %d, service got %d (+%d gone)", n, len(svc.bodies), svc.gone)
+	}
+	subs, err := ListSubscriptions(db, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 1 || subs[0].Endpoint != srv.URL+"/live" {
+		t.Fatalf("subscriptions left: %+v", subs)
+	}
+
+	plain, err := decrypt(svc.bodies[0], uaPrivate, authSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var note Notification
+	if err := json.Unmarshal(plain, &note); err != nil {
+		t.Fatal(err)
+	}
+	if note.Title != "Due in 2 hours" {
+		t.Errorf("title = %q", note.Title)
+	}
+
+	// A later run has nothing new to say.
+	if n, err := SendDue(context.Background(), db, v, now.Add(time.Minute)); err != nil || n != 0 {
+		t.Fatalf("second run sent %d (%v)", n, err)
+	}
+	if len(svc.bodies) != 1 {
+		t.Fatalf("service got %d notifications, want 1", len(svc.bodies))
+	}
+
+	// Moving the deadline makes it news again.
+	if _, err := db.Exec(`UPDATE assignments SET deadline = ? WHERE title = 'Essay'`, deadline.Add(time.Hour).Unix()); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := SendDue(context.Background(), db, v, now.Add(time.Minute)); err != nil || n != 1 {
+		t.Fatalf("after moving the deadline sent %d (%v)", n, err)
+	}
+
+	// Only notifications for deadlines before the cutoff are forgotten.
+	pruned, err := PruneSent(db, deadline.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 1 || count(t, db, `SELECT COUNT(*) FROM push_sent`) != 1 {
+		t.Fatalf("pruned %d", pruned)
+	}
+}
+
+func count(t *testing.T, db *sql.DB, query string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(query).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
@@ -0,0 +1,163 @@
+// Package push sends Web Push notifications (VAPID, RFC 8030/8291/8292) to
+// users' browsers a configurable number of hours before their deadlines, read
+// from calendar_index.
+package push
+
+import (
+	"crypto/ecdh"
+	"database/sql"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"example.com/sqlite-server/util"
+)
+
+var (
+	ErrInvalidSubscription = util.NewError(http.StatusBadRequest, "invalid_subscription", "endpoint must be an https URL, with the browser's p256dh and auth keys")
+	ErrPrivateEndpoint     = util.NewError(http.StatusBadRequest, "private_endpoint", "endpoint must be a public address")
+	ErrInvalidLeadTime     = util.NewError(http.StatusBadRequest, "invalid_lead_time", "leadHours must be 1-168")
+)
+
+// AllowPrivate lets subscriptions use plain http and loopback or
+// private-network endpoints; serve sets it from PUSH_ALLOW_PRIVATE. Real push
+// services never need it; local test receivers do.
+var AllowPrivate bool
+
+// Default is the server's VAPID identity; serve sets it from
+// VAPID_PRIVATE_KEY and VAPID_SUBJECT. Nil: push is off.
+var Default *VAPID
+
+// Subscription is one browser registered for notifications.
+type Subscription struct {
+	ID        int64  `json:"id"`
+	Endpoint  string `json:"endpoint"`
+	UserAgent string `json:"userAgent,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// subscription is what sending needs.
+type subscription struct {
+	id                     int64
+	endpoint, p256dh, auth string
+}
+
+// checkEndpoint validates a push service URL. The dialer enforces the same
+// address rule on every send.
+func checkEndpoint(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" || u.User != nil || (u.Scheme != "https" && !(AllowPrivate && u.Scheme == "http")) {
+		return "", ErrInvalidSubscription
+	}
+	if !AllowPrivate && util.PrivateHost(u.Hostname()) {
+		return "", ErrPrivateEndpoint
+	}
+	return u.String(), nil
+}
+
+// Subscribe registers a browser for userID, as given by the browser's
+// PushSubscription. An endpoint registered before (say, by whoever used the
+// browser last) moves to userID.
+// Errors: ErrInvalidSubscription, ErrPrivateEndpoint.
+func Subscribe(db *sql.DB, userID, endpoint, p256dh, auth, userAgent string) (Subscription, error) {
+	endpoint, err := checkEndpoint(endpoint)
+	if err != nil {
+		return Subscription{}, err
+	}
+	if key, err := decodeKey(p256dh); err != nil {
+		return Subscription{}, ErrInvalidSubscription
+	} else if _, err := ecdh.P256().NewPublicKey(key); err != nil {
+		return Subscription{}, ErrInvalidSubscription
+	}
+	if secret, err := decodeKey(auth); err != nil || len(secret) != 16 {
+		return Subscription{}, ErrInvalidSubscription
+	}
+	userAgent = util.Truncate(userAgent, 200)
+
+	s := Subscription{Endpoint: endpoint, UserAgent: userAgent}
+	err = db.QueryRow(`
+		INSERT INTO push_subscriptions (user_id, endpoint, p256dh, auth, user_agent)
+		VALUES (?, ?, ?, ?, NULLIF(?, ''))
+		ON CONFLICT (endpoint) DO UPDATE SET
+			user_id = excluded.user_id, p256dh = excluded.p256dh, auth = excluded.auth,
+			user_agent = excluded.user_agent
+		RETURNING id, created_at
+	`, userID, endpoint, strings.TrimSpace(p256dh), strings.TrimSpace(auth), userAgent).Scan(&s.ID, &s.CreatedAt)
+	return s, err
+}
+
+// Unsubscribe removes userID's subscription for endpoint. sql.ErrNoRows if
+// there is none.
+func Unsubscribe(db *sql.DB, userID, endpoint string) error {
+	res, err := db.Exec(`DELETE FROM push_subscriptions WHERE user_id = ? AND endpoint = ?`, userID, strings.TrimSpace(endpoint))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListSubscriptions returns userID's browsers, newest first.
+func ListSubscriptions(db *sql.DB, userID string) ([]Subscription, error) {
+	rows, err := db.Query(`
+		SELECT id, endpoint, COALESCE(user_agent, ''), created_at
+		  FROM push_subscriptions WHERE user_id = ? ORDER BY id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []Subscription{}
+	for rows.Next() {
+		var s Subscription
+		if err := rows.Scan(&s.ID, &s.Endpoint, &s.UserAgent, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+// Settings are a user's notification preferences: how long before a
+// deadline to notify, and for which kinds of item.
+type Settings struct {
+	LeadHours   int  `json:"leadHours" doc:"1-168"`
+	Chapters    bool `json:"chapters"`
+	Articles    bool `json:"articles"`
+	Assignments bool `json:"assignments"`
+}
+
+// defaults are the settings of a user who never saved any.
+var defaults = Settings{LeadHours: 24, Chapters: true, Articles: true, Assignments: true}
+
+// GetSettings returns userID's settings (the defaults if none were saved).
+func GetSettings(db *sql.DB, userID string) (Settings, error) {
+	var s Settings
+	err := db.QueryRow(`
+		SELECT lead_hours, chapters, articles, assignments FROM push_settings WHERE user_id = ?
+	`, userID).Scan(&s.LeadHours, &s.Chapters, &s.Articles, &s.Assignments)
+	if err == sql.ErrNoRows {
+		return defaults, nil
+	}
+	return s, err
+}
+
+// SaveSettings validates and stores s. Errors: ErrInvalidLeadTime.
+func SaveSettings(db *sql.DB, userID string, s Settings) (Settings, error) {
+	if s.LeadHours < 1 || s.LeadHours > 168 {
+		return Settings{}, ErrInvalidLeadTime
+	}
+	_, err := db.Exec(`
+		INSERT INTO push_settings (user_id, lead_hours, chapters, articles, assignments)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			lead_hours = excluded.lead_hours, chapters = excluded.chapters,
+			articles = excluded.articles, assignments = excluded.assignments
+	`, userID, s.LeadHours, s.Chapters, s.Articles, s.Assignments)
+	if err != nil {
+		return Settings{}, err
+	}
+	return s, nil
+}
@@ -5,41 +5,62 @@ import (
 	"log"
 	"net/http"
 
+	"example.com/sqlite-server/article"
+	"example.com/sqlite-server/assignment"
 	"example.com/sqlite-server/auth"
-	"example.com/sqlite-server/university"
-  "example.com/sqlite-server/membership"
-  "example.com/sqlite-server/enrollment"
-  "example.com/sqlite-server/course"
 	"example.com/sqlite-server/book"
 	"example.com/sqlite-server/chapter"
-	"example.com/sqlite-server/article"
-	"example.com/sqlite-server/assignment"
+	"example.com/sqlite-server/course"
+	"example.com/sqlite-server/enrollment"
+	"example.com/sqlite-server/invite"
+	"example.com/sqlite-server/membership"
+	"example.com/sqlite-server/session"
+	"example.com/sqlite-server/term"
+	"example.com/sqlite-server/university"
 
 	"example.com/sqlite-server/calendar"
+	"example.com/sqlite-server/digest"
+	"example.com/sqlite-server/events"
+	"example.com/sqlite-server/push"
+	"example.com/sqlite-server/search"
+	"example.com/sqlite-server/webhook"
 
 	"example.com/sqlite-server/admin"
+	"example.com/sqlite-server/middleware"
+	"example.com/sqlite-server/util"
 )
 
 // -----------------------------------------------------------
 // Router setup
 // -----------------------------------------------------------
 
-func registerRoutes(mux *http.ServeMux, db *sql.DB) {
+func registerRoutes(mux util.Router, db *sql.DB) {
 	mux.HandleFunc("/", rootHandler)
+	mux.HandleFunc("/csrf", middleware.CSRFTokenHandler)
+	mux.HandleFunc("/openapi.json", openapiHandler)
 
 	auth.RegisterAuthRoutes(mux, db)
+	session.RegisterSessionRoutes(mux, db)
+	session.RegisterAPITokenRoutes(mux, db)
 	university.RegisterUniversityRoutes(mux, db)
-  membership.RegisterMembershipRoutes(mux, db)
-  enrollment.RegisterEnrollmentRoutes(mux, db)
+	membership.RegisterMembershipRoutes(mux, db)
+	enrollment.RegisterEnrollmentRoutes(mux, db)
 	course.RegisterCourseRoutes(mux, db)
+	invite.RegisterInviteRoutes(mux, db)
+	term.RegisterTermRoutes(mux, db)
 	book.RegisterBookRoutes(mux, db)
 	chapter.RegisterChapterRoutes(mux, db)
 	article.RegisterArticleRoutes(mux, db)
 	assignment.RegisterAssignmentRoutes(mux, db)
 
 	calendar.RegisterCalendarRoutes(mux, db)
+	search.RegisterSearchRoutes(mux, db)
+	digest.RegisterDigestRoutes(mux, db)
+	push.RegisterPushRoutes(mux, db)
+	events.RegisterEventRoutes(mux, db)
+	webhook.RegisterWebhookRoutes(mux, db)
 
-	admin.RegisterAdminRoutes(mux,db)
+	admin.RegisterAdminRoutes(mux, db)
 }
 
 // -----------------------------------------------------------
@@ -48,7 +69,7 @@ func registerRoutes(mux *http.ServeMux, db *sql.DB) {
 
 func rootHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
@@ -0,0 +1,64 @@
+package search
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"example.com/sqlite-server/session"
+	"example.com/sqlite-server/util"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+func RegisterSearchRoutes(mux util.Router, db *sql.DB) {
+	mux.HandleFunc("/search", session.RequireAuth(db, searchHandler(db)))
+}
+
+// GET /search?q=text[&kind=course|book|article|assignment][&limit=N][&include=archived]
+func searchHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		uid, ok := session.UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		qs := r.URL.Query()
+		q := Query{
+			Text:  qs.Get("q"),
+			Kind:  strings.TrimSpace(qs.Get("kind")),
+			Limit: defaultLimit,
+		}
+		for _, v := range strings.Split(qs.Get("include"), ",") {
+			q.IncludeArchived = q.IncludeArchived || strings.TrimSpace(v) == "archived"
+		}
+		if !ValidKind(q.Kind) {
+			util.HTTPError(w, "invalid kind", http.StatusBadRequest)
+			return
+		}
+		if s := qs.Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 {
+				util.HTTPError(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			q.Limit = min(n, maxLimit)
+		}
+
+		hits, err := Search(db, uid, q)
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		util.WriteJSON(w, hits, http.StatusOK)
+	}
+}
@@ -0,0 +1,115 @@
+package search
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"example.com/sqlite-server/util"
+)
+
+// Result kinds, as stored in search_index.kind.
+const (
+	KindCourse     = "course"
+	KindBook       = "book"
+	KindArticle    = "article"
+	KindAssignment = "assignment"
+)
+
+// ErrEmptyQuery is returned when the query has no words to search for.
+var ErrEmptyQuery = util.NewError(http.StatusBadRequest, "empty_query", "q is required")
+
+// Hit is one search result. Snippet is the matching part of the title or
+// detail with matches wrapped in [ and ].
+type Hit struct {
+	Kind         string `json:"kind"`
+	ID           int64  `json:"id"`
+	CourseID     int64  `json:"courseId"`
+	CourseCode   string `json:"courseCode"`
+	UniversityID string `json:"universityId"`
+	Title        string `json:"title"`
+	Detail       string `json:"detail,omitempty"`
+	Snippet      string `json:"snippet"`
+}
+
+// Query holds the parameters of Search.
+type Query struct {
+	Text            string
+	Kind            string // "" = all kinds
+	Limit           int
+	IncludeArchived bool
+}
+
+// ValidKind reports whether k is a result kind ("" means any).
+func ValidKind(k string) bool {
+	switch k {
+	case "", KindCourse, KindBook, KindArticle, KindAssignment:
+		return true
+	}
+	return false
+}
+
+// matchExpr turns free text into an FTS5 query: every word must match, and the
+// last one may be a prefix (search-as-you-type). Words are quoted, so FTS
+// syntax in user input is matched literally. Returns "" if there are no words.
+func matchExpr(text string) string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > 16 {
+		words = words[:16]
+	}
+	for i, w := range words {
+		words[i] = `"` + w + `"`
+	}
+	if len(words) > 0 {
+		words[len(words)-1] += "*"
+	}
+	return strings.Join(words, " ")
+}
+
+// Search returns the best matches for q.Text visible to userID: courses of
+// universities they belong to, and books, articles and assignments of courses
+// they are enrolled in. Archived courses (for everyone or by the user) and
+// their materials are left out unless q.IncludeArchived.
+// Errors: ErrEmptyQuery.
+func Search(db *sql.DB, userID string, q Query) ([]Hit, error) {
+	expr := matchExpr(q.Text)
+	if expr == "" {
+		return nil, ErrEmptyQuery
+	}
+
+	rows, err := db.Query(`
+		SELECT s.kind, s.source_id, c.id, c.code, c.university_id, s.title, s.detail,
+		       snippet(search_index, -1, '[', ']', '…', 12)
+		  FROM search_index s
+		  JOIN courses c ON c.id = s.course_id
+		  LEFT JOIN user_courses uc ON uc.course_id = c.id AND uc.user_id = ?
+		 WHERE search_index MATCH ?
+		   AND (? = '' OR s.kind = ?)
+		   AND CASE WHEN s.kind = 'course'
+		            THEN EXISTS (SELECT 1 FROM user_universities m
+		                          WHERE m.user_id = ? AND m.university_id = c.university_id)
+		            ELSE uc.user_id IS NOT NULL
+		       END
+		   AND (? OR (c.archived_at IS NULL AND uc.archived_at IS NULL))
+		 ORDER BY bm25(search_index, 10.0, 1.0), s.rowid
+		 LIMIT ?
+	`, userID, expr, q.Kind, q.Kind, userID, q.IncludeArchived, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Hit, 0, q.Limit)
+	for rows.Next() {
+		var h Hit
+		if err := rows.Scan(&h.Kind, &h.ID, &h.CourseID, &h.CourseCode, &h.UniversityID,
+			&h.Title, &h.Detail, &h.Snippet); err != nil {
+			return nil, err
+		}
+		out = append(out, h)
+	}
+	return out, rows.Err()
+}
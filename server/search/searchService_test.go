@@ -0,0 +1,111 @@
+package search
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"example.com/sqlite-server/store"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, q := range []string{
+		`INSERT INTO users (id, email, password) VALUES ('u1', 'a@x.io', '')`,
+		`INSERT INTO universities (id, name) VALUES ('a', 'Uni A'), ('b', 'Uni B')`,
+		`INSERT INTO user_universities (user_id, university_id) VALUES ('u1', 'a')`,
+		`INSERT INTO courses (id, university_id, year, term, code, name) VALUES
+		   (1, 'a', 2026, 1, 'CS101', 'Algorithms'),
+		   (2, 'a', 2026, 1, 'CS102', 'Algorithms II'),
+		   (3, 'b', 2026, 1, 'CS103', 'Algorithms III')`,
+		`INSERT INTO user_courses (user_id, course_id) VALUES ('u1', 1)`,
+		`INSERT INTO books (course_id, title, author) VALUES (1, 'Introduction to Algorithms', 'Cormen'), (2, 'Algorithm Design', 'Kleinberg'), (3, 'The Algorithm Design Manual', 'Skiena')`,
+		`INSERT INTO articles (course_id, title, author) VALUES (1, 'Dynamic programming', 'Bellman')`,
+		`INSERT INTO assignments (course_id, title, description) VALUES (1, 'Problem set 1', 'Sorting algorithms')`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+	return db
+}
+
+func hits(t *testing.T, db *sql.DB, q Query) []string {
+	t.Helper()
+	if q.Limit == 0 {
+		q.Limit = 20
+	}
+	list, err := Search(db, "u1", q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, h := range list {
+		out = append(out, h.Kind+":"+h.Title)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestSearchOnlyFindsVisibleItems(t *testing.T) {
+	db := openTestDB(t)
+
+	// Courses of the user's universities, materials of enrolled courses only.
+	got := strings.Join(hits(t, db, Query{Text: "algo"}), ", ")
+	want := "assignment:Problem set 1, book:Introduction to Algorithms, course:Algorithms, course:Algorithms II"
+	if got != want {
+		t.Fatalf("hits:\n  %s\nwant\n  %s", got, want)
+	}
+
+	if got := hits(t, db, Query{Text: "algo", Kind: KindBook}); len(got) != 1 {
+		t.Fatalf("books: %v", got)
+	}
+	if got := hits(t, db, Query{Text: "dynamic prog"}); len(got) != 1 || got[0] != "article:Dynamic programming" {
+		t.Fatalf("two words: %v", got)
+	}
+	// FTS syntax is matched literally rather than failing.
+	if got := hits(t, db, Query{Text: `algo* OR NEAR("x"`}); len(got) != 0 {
+		t.Fatalf("FTS syntax: %v", got)
+	}
+	if _, err := Search(db, "u1", Query{Text: " -- ", Limit: 5}); !errors.Is(err, ErrEmptyQuery) {
+		t.Fatalf("no words: %v", err)
+	}
+
+	// Archived courses drop out unless asked for.
+	if _, err := db.Exec(`UPDATE user_courses SET archived_at = 1 WHERE course_id = 1`); err != nil {
+		t.Fatal(err)
+	}
+	if got := hits(t, db, Query{Text: "cormen"}); len(got) != 0 {
+		t.Fatalf("archived enrollment: %v", got)
+	}
+	if got := hits(t, db, Query{Text: "cormen", IncludeArchived: true}); len(got) != 1 {
+		t.Fatalf("archived enrollment, included: %v", got)
+	}
+}
+
+func TestSearchIndexFollowsEdits(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := db.Exec(`UPDATE books SET title = 'Concrete Mathematics' WHERE course_id = 1`); err != nil {
+		t.Fatal(err)
+	}
+	if got := hits(t, db, Query{Text: "concrete"}); len(got) != 1 {
+		t.Fatalf("renamed book: %v", got)
+	}
+	if got := hits(t, db, Query{Text: "introduction"}); len(got) != 0 {
+		t.Fatalf("old title still found: %v", got)
+	}
+	if _, err := db.Exec(`DELETE FROM articles`); err != nil {
+		t.Fatal(err)
+	}
+	if got := hits(t, db, Query{Text: "bellman"}); len(got) != 0 {
+		t.Fatalf("deleted article still found: %v", got)
+	}
+}
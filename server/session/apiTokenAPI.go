@@ -0,0 +1,216 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"example.com/sqlite-server/util"
+)
+
+const maxTokenDays = 365
+
+// RegisterAPITokenRoutes wires the personal access token endpoints.
+// Managing tokens requires a browser session; a token can't mint or revoke tokens.
+func RegisterAPITokenRoutes(mux util.Router, db *sql.DB) {
+	mux.HandleFunc("/tokens", RequireAuth(db, RequireCookieSession(apiTokensHandler(db))))
+}
+
+// readBearer extracts the token from "Authorization: Bearer <token>". ok is
+// true whenever the Bearer scheme is used, even with an empty token: such a
+// request is authenticated by the token or not at all.
+func readBearer(r *http.Request) (tok string, ok bool) {
+	scheme, tok, _ := strings.Cut(strings.TrimSpace(r.Header.Get("Authorization")), " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(tok), true
+}
+
+// requireToken authenticates a bearer token and enforces its scopes before calling next.
+func requireToken(db *sql.DB, bearer string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t, err := lookupAPIToken(db, bearer)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !scopeAllows(t.Scopes, r) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope"`)
+			util.HTTPError(w, "forbidden: insufficient token scope", http.StatusForbidden)
+			return
+		}
+		_ = touchAPIToken(db, t) // best effort
+
+		ctx := context.WithValue(r.Context(), ctxUserID, t.UserID)
+		ctx = context.WithValue(ctx, ctxTokenScopes, t.Scopes)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// scopeAllows maps a request onto the scope it needs:
+//   - /admin/*                   → admin
+//   - GET/HEAD                   → read
+//   - PATCH /{kind}/{id}/progress → progress:write
+//
+// Anything else (creating/deleting material, deadlines, memberships) is cookie-only.
+func scopeAllows(scopes []string, r *http.Request) bool {
+	has := func(want string) bool {
+		for _, s := range scopes {
+			if s == want {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/admin/"):
+		return has(ScopeAdmin)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return has(ScopeRead)
+	case r.Method == http.MethodPatch && strings.HasSuffix(r.URL.Path, "/progress"):
+		return has(ScopeProgressWrite)
+	default:
+		return false
+	}
+}
+
+// ViaToken reports whether the request was authenticated with a personal access token.
+func ViaToken(ctx context.Context) bool {
+	_, ok := ctx.Value(ctxTokenScopes).([]string)
+	return ok
+}
+
+// RequireCookieSession, inside RequireAuth, rejects token-authenticated requests.
+func RequireCookieSession(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ViaToken(r.Context()) {
+			util.HTTPError(w, "forbidden: requires a browser session", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// Dispatcher for /tokens
+func apiTokensHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			getAPITokensHandler(db)(w, r)
+		case http.MethodPost:
+			postAPITokenHandler(db)(w, r)
+		case http.MethodDelete:
+			deleteAPITokenHandler(db)(w, r)
+		default:
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// GET /tokens
+// Lists the caller's tokens (metadata only; secrets are shown once at creation).
+func getAPITokensHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		list, err := ListAPITokens(db, uid)
+		if err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		util.WriteJSON(w, list, http.StatusOK)
+	}
+}
+
+// POST /tokens
+// Body: { "name": "bulk progress script", "scopes": ["read","progress:write"], "expiresInDays": 90 }
+// expiresInDays defaults to 90 (max 365). The admin scope is only granted to admins.
+// Returns: 201 Created with the token metadata plus "token" (the secret, shown once).
+func postAPITokenHandler(db *sql.DB) http.HandlerFunc {
+	type payload struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays *int64   `json:"expiresInDays,omitempty"`
+	}
+	type resp struct {
+		APIToken
+		Token string `json:"token"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var p payload
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil {
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		days := int64(90)
+		if p.ExpiresInDays != nil {
+			days = *p.ExpiresInDays
+		}
+		if days < 1 || days > maxTokenDays {
+			util.HTTPError(w, "expiresInDays must be between 1 and 365", http.StatusBadRequest)
+			return
+		}
+
+		t, secret, err := CreateAPIToken(db, uid, p.Name, p.Scopes, time.Duration(days)*24*time.Hour)
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		util.WriteJSON(w, resp{APIToken: t, Token: secret}, http.StatusCreated)
+	}
+}
+
+// DELETE /tokens
+// Body: { "tokenId": "uuid" }
+// 204 if revoked; 404 if the caller has no such token.
+func deleteAPITokenHandler(db *sql.DB) http.HandlerFunc {
+	type payload struct {
+		TokenID string `json:"tokenId"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var p payload
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil {
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		id := strings.TrimSpace(p.TokenID)
+		if id == "" {
+			util.HTTPError(w, "tokenId is required", http.StatusBadRequest)
+			return
+		}
+
+		if err := RevokeAPIToken(db, uid, id); err != nil {
+			if err == sql.ErrNoRows {
+				util.HTTPError(w, "not found", http.StatusNotFound)
+				return
+			}
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,207 @@
+package session
+
+import (
+	"database/sql"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"example.com/sqlite-server/util"
+)
+
+// Token scopes.
+const (
+	ScopeRead          = "read"           // any GET
+	ScopeProgressWrite = "progress:write" // PATCH .../{id}/progress
+	ScopeAdmin         = "admin"          // /admin/* (admins only)
+)
+
+// tokenPrefix makes personal tokens recognizable in logs and secret scanners.
+const tokenPrefix = "rdg_"
+
+var validScopes = map[string]bool{
+	ScopeRead:          true,
+	ScopeProgressWrite: true,
+	ScopeAdmin:         true,
+}
+
+// APIToken is the user-facing shape of a personal access token (never includes the secret).
+type APIToken struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  int64    `json:"created_at"`
+	ExpiresAt  *int64   `json:"expires_at,omitempty"`
+	LastUsedAt *int64   `json:"last_used_at,omitempty"`
+}
+
+// Errors returned by CreateAPIToken.
+var (
+	ErrInvalidScope     = util.NewError(http.StatusBadRequest, "invalid_scope", "invalid scope")
+	ErrAdminScopeDenied = util.NewError(http.StatusForbidden, "admin_scope_denied", "admin scope requires admin")
+)
+
+// NormalizeScopes validates, de-duplicates and sorts a scope list.
+func NormalizeScopes(in []string) ([]string, error) {
+	seen := make(map[string]bool, len(in))
+	out := make([]string, 0, len(in))
+	for _, s := range in {
+		s = strings.TrimSpace(s)
+		if !validScopes[s] {
+			return nil, ErrInvalidScope
+		}
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	if len(out) == 0 {
+		return nil, ErrInvalidScope
+	}
+	sort.Strings(out) // stable order for storage/display
+	return out, nil
+}
+
+// CreateAPIToken mints a token for userID. The secret is returned once; only its hash is stored.
+// ttl <= 0 means the token never expires. The admin scope requires the user to be an admin.
+func CreateAPIToken(db *sql.DB, userID, name string, scopes []string, ttl time.Duration) (APIToken, string, error) {
+	name = strings.TrimSpace(name)
+	if userID == "" || name == "" || len(name) > 100 {
+		return APIToken{}, "", util.ErrInvalidInput
+	}
+	scopes, err := NormalizeScopes(scopes)
+	if err != nil {
+		return APIToken{}, "", err
+	}
+	for _, s := range scopes {
+		if s != ScopeAdmin {
+			continue
+		}
+		var x int
+		err := db.QueryRow(`SELECT 1 FROM admins WHERE user_id = ?`, userID).Scan(&x)
+		if err == sql.ErrNoRows {
+			return APIToken{}, "", ErrAdminScopeDenied
+		}
+		if err != nil {
+			return APIToken{}, "", err
+		}
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return APIToken{}, "", err
+	}
+	secret = tokenPrefix + secret
+
+	t := APIToken{
+		ID:        uuid.NewString(),
+		Name:      name,
+		Scopes:    scopes,
+		CreatedAt: time.Now().Unix(),
+	}
+	if ttl > 0 {
+		exp := time.Now().Add(ttl).Unix()
+		t.ExpiresAt = &exp
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO api_tokens (id, user_id, name, token_hash, scopes, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, t.ID, userID, t.Name, util.HashToken(secret), strings.Join(scopes, " "), t.CreatedAt, t.ExpiresAt)
+	if err != nil {
+		return APIToken{}, "", err
+	}
+	return t, secret, nil
+}
+
+// ListAPITokens returns the user's tokens (including expired ones), newest first.
+func ListAPITokens(db *sql.DB, userID string) ([]APIToken, error) {
+	rows, err := db.Query(`
+		SELECT id, name, scopes, created_at, expires_at, last_used_at
+		  FROM api_tokens
+		 WHERE user_id = ?
+		 ORDER BY created_at DESC, id ASC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]APIToken, 0, 8)
+	for rows.Next() {
+		var t APIToken
+		var scopes string
+		var exp, used sql.NullInt64
+		if err := rows.Scan(&t.ID, &t.Name, &scopes, &t.CreatedAt, &exp, &used); err != nil {
+			return nil, err
+		}
+		t.Scopes = strings.Fields(scopes)
+		if exp.Valid {
+			v := exp.Int64
+			t.ExpiresAt = &v
+		}
+		if used.Valid {
+			v := used.Int64
+			t.LastUsedAt = &v
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// RevokeAPIToken deletes one of the user's tokens. Returns sql.ErrNoRows if not found.
+func RevokeAPIToken(db *sql.DB, userID, tokenID string) error {
+	res, err := db.Exec(`DELETE FROM api_tokens WHERE user_id = ? AND id = ?`, userID, tokenID)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// tokenAuth is what a valid bearer token resolves to.
+type tokenAuth struct {
+	ID       string
+	UserID   string
+	Scopes   []string
+	LastUsed int64
+}
+
+// lookupAPIToken resolves a bearer secret to its owner and scopes.
+// Returns sql.ErrNoRows for unknown or expired tokens, or if the owner is disabled.
+func lookupAPIToken(db *sql.DB, secret string) (tokenAuth, error) {
+	var t tokenAuth
+	var scopes string
+	var exp, used sql.NullInt64
+	err := db.QueryRow(`
+		SELECT t.id, t.user_id, t.scopes, t.expires_at, t.last_used_at
+		  FROM api_tokens t
+		  JOIN users u ON u.id = t.user_id
+		 WHERE t.token_hash = ? AND u.disabled_at IS NULL
+	`, util.HashToken(secret)).Scan(&t.ID, &t.UserID, &scopes, &exp, &used)
+	if err != nil {
+		return tokenAuth{}, err
+	}
+	if exp.Valid && time.Now().Unix() >= exp.Int64 {
+		return tokenAuth{}, sql.ErrNoRows
+	}
+	t.Scopes = strings.Fields(scopes)
+	t.LastUsed = used.Int64
+	return t, nil
+}
+
+// touchAPIToken records token use, at most once per lastSeenResolution.
+func touchAPIToken(db *sql.DB, t tokenAuth) error {
+	now := time.Now().Unix()
+	if now-t.LastUsed < lastSeenResolution {
+		return nil
+	}
+	_, err := db.Exec(`UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, now, t.ID)
+	return err
+}
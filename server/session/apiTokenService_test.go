@@ -0,0 +1,98 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNormalizeScopes(t *testing.T) {
+	got, err := NormalizeScopes([]string{" read", "progress:write", "read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != ScopeProgressWrite || got[1] != ScopeRead {
+		t.Fatalf("scopes = %v", got)
+	}
+	for _, bad := range [][]string{nil, {"write"}, {"read", ""}} {
+		if _, err := NormalizeScopes(bad); err != ErrInvalidScope {
+			t.Errorf("%q: %v", bad, err)
+		}
+	}
+}
+
+func TestAPITokenScopes(t *testing.T) {
+	db := openTestDB(t)
+	if _, _, err := CreateAPIToken(db, "u1", "ops", []string{ScopeAdmin}, 0); err != ErrAdminScopeDenied {
+		t.Fatalf("admin scope for a non-admin: %v", err)
+	}
+	reader, readSecret, err := CreateAPIToken(db, "u1", "reader", []string{ScopeRead}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, writeSecret, err := CreateAPIToken(db, "u1", "tracker", []string{ScopeRead, ScopeProgressWrite}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, expiredSecret, err := CreateAPIToken(db, "u1", "old", []string{ScopeRead}, time.Nanosecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second) // expiry has second resolution
+
+	h := RequireAuth(db, func(w http.ResponseWriter, r *http.Request) {
+		if uid, _ := UserIDFromCtx(r.Context()); uid != "u1" || !ViaToken(r.Context()) {
+			t.Errorf("%s %s ran as %q", r.Method, r.URL.Path, uid)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	for _, c := range []struct {
+		secret, method, path string
+		want                 int
+	}{
+		{readSecret, http.MethodGet, "/books", http.StatusNoContent},
+		{readSecret, http.MethodPatch, "/articles/1/progress", http.StatusForbidden},
+		{writeSecret, http.MethodPatch, "/articles/1/progress", http.StatusNoContent},
+		{writeSecret, http.MethodPost, "/books", http.StatusForbidden},
+		{writeSecret, http.MethodGet, "/admin/users", http.StatusForbidden},
+		{expiredSecret, http.MethodGet, "/books", http.StatusUnauthorized},
+		{"rdg_unknown", http.MethodGet, "/books", http.StatusUnauthorized},
+	} {
+		r := httptest.NewRequest(c.method, c.path, nil)
+		r.Header.Set("Authorization", "Bearer "+c.secret)
+		w := httptest.NewRecorder()
+		h(w, r)
+		if w.Code != c.want {
+			t.Errorf("%s %s with %.8s…: %d, want %d", c.method, c.path, c.secret, w.Code, c.want)
+		}
+	}
+
+	// A bad token is not rescued by a valid session cookie.
+	s := newSession(t, db, "u1", false, "")
+	r := httptest.NewRequest(http.MethodGet, "/books", nil)
+	r.Header.Set("Authorization", "Bearer rdg_unknown")
+	r.AddCookie(&http.Cookie{Name: "session", Value: s.ID})
+	w := httptest.NewRecorder()
+	h(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("bad token with a cookie: %d", w.Code)
+	}
+
+	if err := RevokeAPIToken(db, "u2", reader.ID); err == nil {
+		t.Fatal("revoked someone else's token")
+	}
+	if err := RevokeAPIToken(db, "u1", reader.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lookupAPIToken(db, readSecret); err == nil {
+		t.Fatal("revoked token still works")
+	}
+	list, err := ListAPITokens(db, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("listed %d tokens, want 2", len(list))
+	}
+}
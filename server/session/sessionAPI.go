@@ -1,60 +1,213 @@
 package session
 
 import (
-  "context"
-  "database/sql"
-  "net/http"
-  "time"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"example.com/sqlite-server/util"
 )
 
 // private context key type
 type ctxKey int
 
-const ctxUserID ctxKey = iota
+const (
+	ctxUserID ctxKey = iota
+	ctxSessionKey
+	ctxTokenScopes
+)
+
+// RegisterSessionRoutes wires the "active sessions" endpoints.
+func RegisterSessionRoutes(mux util.Router, db *sql.DB) {
+	mux.HandleFunc("/sessions", RequireAuth(db, sessionsHandler(db)))
+	mux.HandleFunc("/sessions/others", RequireAuth(db, revokeOtherSessionsHandler(db)))
+}
 
-// ReadSessionID is exported so other packages can reuse it if needed.
+// ReadSessionID returns the session cookie's value. Requests that use a bearer
+// token have none: the cookie never stands in for a bad or missing token, and
+// the CSRF check relies on that.
 func ReadSessionID(r *http.Request) string {
-  if c, err := r.Cookie("__Host-session"); err == nil && c.Value != "" {
-    return c.Value
-  }
-  if c, err := r.Cookie("session"); err == nil && c.Value != "" {
-    return c.Value
-  }
-  return ""
+	if _, ok := readBearer(r); ok {
+		return ""
+	}
+	if c, err := r.Cookie("__Host-session"); err == nil && c.Value != "" {
+		return c.Value
+	}
+	if c, err := r.Cookie("session"); err == nil && c.Value != "" {
+		return c.Value
+	}
+	return ""
+}
+
+// ClientInfoFromRequest extracts the metadata stored with a session.
+func ClientInfoFromRequest(r *http.Request) ClientInfo {
+	return ClientInfo{UserAgent: util.Truncate(r.UserAgent(), 512), IP: util.ClientIP(r)}
 }
 
 // RequireAuth is middleware that validates the session cookie,
 // loads the session, and injects userID into the request context.
+// Sessions past half of their TTL are renewed and the cookie reissued.
+// A personal access token in "Authorization: Bearer" is accepted instead of
+// the cookie, limited to what its scopes allow.
 func RequireAuth(db *sql.DB, next http.HandlerFunc) http.HandlerFunc {
-  return func(w http.ResponseWriter, r *http.Request) {
-    sid := ReadSessionID(r)
-    if sid == "" {
-      http.Error(w, "unauthorized", http.StatusUnauthorized)
-      return
-    }
-
-    // Use same-package functions from sessionService.go
-    sess, err := GetSessionByID(db, sid)
-    if err != nil {
-      http.Error(w, "unauthorized", http.StatusUnauthorized)
-      return
-    }
-
-    now := time.Now().Unix()
-    if now >= sess.ExpiresAt {
-      _ = DeleteSessionByID(db, sid) // cleanup if expired
-      http.Error(w, "unauthorized", http.StatusUnauthorized)
-      return
-    }
-
-    ctx := context.WithValue(r.Context(), ctxUserID, sess.UserID)
-    next.ServeHTTP(w, r.WithContext(ctx))
-  }
+	return func(w http.ResponseWriter, r *http.Request) {
+		if bearer, ok := readBearer(r); ok {
+			requireToken(db, bearer, next)(w, r)
+			return
+		}
+
+		sid := ReadSessionID(r)
+		if sid == "" {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		// Use same-package functions from sessionService.go
+		sess, err := GetSessionByID(db, sid)
+		if err != nil {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		now := time.Now().Unix()
+		if now >= sess.ExpiresAt {
+			_ = DeleteSessionByID(db, sid) // cleanup if expired
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		// Sliding expiration: active users keep their session (up to MaxLifetime).
+		if renewed, ok, err := RenewSession(db, sess); err == nil && ok {
+			sess = renewed
+			SetCookie(w, sess)
+		}
+
+		_ = TouchSession(db, sess, ClientInfoFromRequest(r)) // best effort
+
+		ctx := context.WithValue(r.Context(), ctxUserID, sess.UserID)
+		ctx = context.WithValue(ctx, ctxSessionKey, sess.Key)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
 }
 
 // UserIDFromCtx extracts the userID set by RequireAuth.
 func UserIDFromCtx(ctx context.Context) (string, bool) {
-  v := ctx.Value(ctxUserID)
-  s, ok := v.(string)
-  return s, ok && s != ""
+	v := ctx.Value(ctxUserID)
+	s, ok := v.(string)
+	return s, ok && s != ""
+}
+
+// SessionKeyFromCtx extracts the current session's stored key (hashed ID) set by RequireAuth.
+func SessionKeyFromCtx(ctx context.Context) (string, bool) {
+	v := ctx.Value(ctxSessionKey)
+	s, ok := v.(string)
+	return s, ok && s != ""
+}
+
+// Dispatcher for /sessions
+func sessionsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			getSessionsHandler(db)(w, r)
+		case http.MethodDelete:
+			deleteSessionHandler(db)(w, r)
+		default:
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// GET /sessions
+// Returns the caller's active sessions; the one making the request has "current": true.
+func getSessionsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		key, _ := SessionKeyFromCtx(r.Context())
+
+		list, err := ListUserSessions(db, uid, key)
+		if err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		util.WriteJSON(w, list, http.StatusOK)
+	}
+}
+
+// DELETE /sessions
+// Body: { "sessionId": "handle" }  (the "id" from GET /sessions)
+// 204 if revoked; 404 if the caller has no such session.
+func deleteSessionHandler(db *sql.DB) http.HandlerFunc {
+	type payload struct {
+		SessionID string `json:"sessionId"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var p payload
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil {
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		handle := strings.TrimSpace(p.SessionID)
+		if handle == "" {
+			util.HTTPError(w, "sessionId is required", http.StatusBadRequest)
+			return
+		}
+
+		if err := RevokeUserSession(db, uid, handle); err != nil {
+			if err == sql.ErrNoRows {
+				util.HTTPError(w, "not found", http.StatusNotFound)
+				return
+			}
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DELETE /sessions/others
+// Revokes every session of the caller except the current one.
+// Returns: 200 OK with { "revoked": n }
+func revokeOtherSessionsHandler(db *sql.DB) http.HandlerFunc {
+	type resp struct {
+		Revoked int64 `json:"revoked"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		uid, ok := UserIDFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		key, ok := SessionKeyFromCtx(r.Context())
+		if !ok {
+			util.HTTPError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		n, err := RevokeOtherSessions(db, uid, key)
+		if err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		util.WriteJSON(w, resp{Revoked: n}, http.StatusOK)
+	}
 }
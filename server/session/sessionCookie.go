@@ -0,0 +1,86 @@
+package session
+
+import (
+	"net/http"
+	"time"
+
+	"example.com/sqlite-server/util"
+)
+
+// Lifetimes controls how long sessions live.
+//
+//   - TTL: idle lifetime of a normal session; renewed on use once past half-life.
+//   - RememberTTL: same, for sessions created with "remember me".
+//   - MaxLifetime: absolute cap from creation; sliding renewal never goes past it.
+type Lifetimes struct {
+	TTL         time.Duration
+	RememberTTL time.Duration
+	MaxLifetime time.Duration
+}
+
+// Settings are the lifetimes in use: these defaults, replaced from the server
+// config (SESSION_TTL, SESSION_REMEMBER_TTL, SESSION_MAX_LIFETIME) at startup.
+var Settings = Lifetimes{
+	TTL:         7 * 24 * time.Hour,
+	RememberTTL: 30 * 24 * time.Hour,
+	MaxLifetime: 90 * 24 * time.Hour,
+}
+
+// ttlFor returns the sliding window for a session.
+func (l Lifetimes) ttlFor(remember bool) time.Duration {
+	if remember {
+		return l.RememberTTL
+	}
+	return l.TTL
+}
+
+// expiryFrom computes the expiry for a session created at createdAt and renewed at now.
+func (l Lifetimes) expiryFrom(createdAt, now int64, remember bool) int64 {
+	exp := now + int64(l.ttlFor(remember)/time.Second)
+	if l.MaxLifetime > 0 {
+		if hard := createdAt + int64(l.MaxLifetime/time.Second); exp > hard {
+			exp = hard
+		}
+	}
+	return exp
+}
+
+// cookieName picks the __Host- prefixed name for Secure cookies (which it
+// requires).
+func cookieName() string {
+	if util.SecureCookies {
+		return "__Host-session"
+	}
+	return "session"
+}
+
+// SetCookie writes the session cookie. It persists until the session expires,
+// which is further out for remember-me sessions.
+func SetCookie(w http.ResponseWriter, s Session) {
+	c := &http.Cookie{
+		Name:     cookieName(),
+		Value:    s.ID,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: util.CookieSameSite,
+		Secure:   util.SecureCookies,
+		Expires:  time.Unix(s.ExpiresAt, 0),
+	}
+	http.SetCookie(w, c)
+}
+
+// ClearCookies expires both possible cookie names (dev/prod).
+func ClearCookies(w http.ResponseWriter) {
+	for _, name := range []string{"session", "__Host-session"} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			Expires:  time.Unix(0, 0),
+			MaxAge:   -1,
+			HttpOnly: true,
+			SameSite: util.CookieSameSite,
+			Secure:   util.SecureCookies,
+		})
+	}
+}
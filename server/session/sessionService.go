@@ -4,49 +4,98 @@ import (
 	"crypto/rand"
 	"database/sql"
 	"encoding/base64"
+	"net/http"
 	"time"
+
+	"github.com/google/uuid"
+
+	"example.com/sqlite-server/util"
 )
 
+// ErrAccountDisabled is returned when a disabled account tries to sign in.
+var ErrAccountDisabled = util.NewError(http.StatusForbidden, "account_disabled", "account disabled")
+
+// Session is a login. ID is the bearer token held in the cookie; only its
+// hash (Key) is stored, as sessions.id.
 type Session struct {
 	ID        string
+	Key       string
+	Handle    string
 	UserID    string
 	CreatedAt int64
 	ExpiresAt int64
+	LastSeen  int64
+	Remember  bool
+}
+
+// ClientInfo is the request metadata recorded alongside a session.
+type ClientInfo struct {
+	UserAgent string
+	IP        string
 }
 
-// CreateSession inserts a new session for userID with the given TTL.
-func CreateSession(db *sql.DB, userID string, ttl time.Duration) (Session, error) {
+// SessionView is the user-facing shape of a session (never includes the token).
+type SessionView struct {
+	ID        string `json:"id"`
+	CreatedAt int64  `json:"created_at"`
+	ExpiresAt int64  `json:"expires_at"`
+	LastSeen  int64  `json:"last_seen"`
+	UserAgent string `json:"userAgent,omitempty"`
+	IP        string `json:"ip,omitempty"`
+	Current   bool   `json:"current"`
+}
+
+// lastSeenResolution limits how often RequireAuth writes last_seen_at.
+const lastSeenResolution = 60 // seconds
+
+// CreateSession inserts a new session for userID. Its lifetime comes from Settings:
+// RememberTTL when remember is set, TTL otherwise.
+func CreateSession(db *sql.DB, userID string, remember bool, info ClientInfo) (Session, error) {
 	token, err := randomToken(32) // 256-bit
 	if err != nil {
 		return Session{}, err
 	}
+	handle := uuid.NewString()
 	now := time.Now().Unix()
-	exp := time.Now().Add(ttl).Unix()
+	exp := Settings.expiryFrom(now, now, remember)
 
-	_, err = db.Exec(`
-		INSERT INTO sessions (id, user_id, created_at, expires_at)
-		VALUES (?, ?, ?, ?)
-	`, token, userID, now, exp)
+	key := util.HashToken(token)
+
+	// Disabled accounts never get a session, whichever way they signed in.
+	res, err := db.Exec(`
+		INSERT INTO sessions (id, handle, user_id, created_at, expires_at, last_seen_at, user_agent, ip, remember)
+		SELECT ?, ?, id, ?, ?, ?, ?, ?, ?
+		  FROM users
+		 WHERE id = ? AND disabled_at IS NULL
+	`, key, handle, now, exp, now, nullIfEmpty(info.UserAgent), nullIfEmpty(info.IP), remember, userID)
 	if err != nil {
 		return Session{}, err
 	}
-	return Session{ID: token, UserID: userID, CreatedAt: now, ExpiresAt: exp}, nil
+	if n, _ := res.RowsAffected(); n == 0 {
+		return Session{}, ErrAccountDisabled
+	}
+	return Session{ID: token, Key: key, Handle: handle, UserID: userID, CreatedAt: now, ExpiresAt: exp, LastSeen: now, Remember: remember}, nil
 }
 
-// GetSessionByID loads a session regardless of expiry.
+// GetSessionByID loads a session by its bearer token, regardless of expiry.
 // (Expiry is enforced by callers/middleware.)
 func GetSessionByID(db *sql.DB, id string) (Session, error) {
-	var s Session
+	s := Session{ID: id}
+	var handle sql.NullString
+	var lastSeen sql.NullInt64
 	err := db.QueryRow(`
-		SELECT id, user_id, created_at, expires_at
+		SELECT id, handle, user_id, created_at, expires_at, last_seen_at, remember
 		FROM sessions
 		WHERE id = ?;
-	`, id).Scan(&s.ID, &s.UserID, &s.CreatedAt, &s.ExpiresAt)
+	`, util.HashToken(id)).Scan(&s.Key, &handle, &s.UserID, &s.CreatedAt, &s.ExpiresAt, &lastSeen, &s.Remember)
+	s.Handle = handle.String
+	s.LastSeen = lastSeen.Int64
 	return s, err
 }
 
+// DeleteSessionByID removes the session for the given bearer token.
 func DeleteSessionByID(db *sql.DB, id string) error {
-	_, err := db.Exec(`DELETE FROM sessions WHERE id = ?`, id)
+	_, err := db.Exec(`DELETE FROM sessions WHERE id = ?`, util.HashToken(id))
 	return err
 }
 
@@ -56,6 +105,98 @@ func DeleteExpiredSessions(db *sql.DB) error {
 	return err
 }
 
+// RenewSession slides the expiry forward once the session is past half of its TTL,
+// never beyond Settings.MaxLifetime from creation. Returns the (possibly updated)
+// session and whether it was renewed, so callers know to reissue the cookie.
+func RenewSession(db *sql.DB, s Session) (Session, bool, error) {
+	now := time.Now().Unix()
+	half := int64(Settings.ttlFor(s.Remember)/time.Second) / 2
+	if s.ExpiresAt-now > half {
+		return s, false, nil
+	}
+	exp := Settings.expiryFrom(s.CreatedAt, now, s.Remember)
+	if exp <= s.ExpiresAt {
+		return s, false, nil // already at the absolute cap
+	}
+	if _, err := db.Exec(`UPDATE sessions SET expires_at = ? WHERE id = ?`, exp, s.Key); err != nil {
+		return s, false, err
+	}
+	s.ExpiresAt = exp
+	return s, true, nil
+}
+
+// TouchSession records activity on a session. Writes are skipped when the
+// stored last_seen_at is recent enough, so busy clients don't cause a write per request.
+func TouchSession(db *sql.DB, s Session, info ClientInfo) error {
+	now := time.Now().Unix()
+	if now-s.LastSeen < lastSeenResolution {
+		return nil
+	}
+	_, err := db.Exec(`
+		UPDATE sessions
+		   SET last_seen_at = ?,
+		       user_agent = COALESCE(?, user_agent),
+		       ip = COALESCE(?, ip)
+		 WHERE id = ?
+	`, now, nullIfEmpty(info.UserAgent), nullIfEmpty(info.IP), s.Key)
+	return err
+}
+
+// ListUserSessions returns the user's unexpired sessions, most recently used first.
+// currentKey is the caller's own session key, used to flag it in the result.
+func ListUserSessions(db *sql.DB, userID, currentKey string) ([]SessionView, error) {
+	rows, err := db.Query(`
+		SELECT id, handle, created_at, expires_at,
+		       COALESCE(last_seen_at, created_at),
+		       COALESCE(user_agent, ''), COALESCE(ip, '')
+		  FROM sessions
+		 WHERE user_id = ?
+		   AND expires_at > strftime('%s','now')
+		 ORDER BY COALESCE(last_seen_at, created_at) DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]SessionView, 0, 8)
+	for rows.Next() {
+		var key string
+		var v SessionView
+		if err := rows.Scan(&key, &v.ID, &v.CreatedAt, &v.ExpiresAt, &v.LastSeen, &v.UserAgent, &v.IP); err != nil {
+			return nil, err
+		}
+		v.Current = key == currentKey
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// RevokeUserSession deletes one of the user's sessions by its public handle.
+// Returns sql.ErrNoRows if the user has no such session.
+func RevokeUserSession(db *sql.DB, userID, handle string) error {
+	res, err := db.Exec(`DELETE FROM sessions WHERE user_id = ? AND handle = ?`, userID, handle)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RevokeOtherSessions deletes all of the user's sessions except keepKey ("log out everywhere else").
+// Returns the number of sessions removed.
+func RevokeOtherSessions(db *sql.DB, userID, keepKey string) (int64, error) {
+	res, err := db.Exec(`DELETE FROM sessions WHERE user_id = ? AND id <> ?`, userID, keepKey)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
+
 func randomToken(n int) (string, error) {
 	buf := make([]byte, n)
 	if _, err := rand.Read(buf); err != nil {
@@ -64,3 +205,10 @@ func randomToken(n int) (string, error) {
 	// URL-safe, no padding
 	return base64.RawURLEncoding.EncodeToString(buf), nil
 }
+
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,171 @@
+package session
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"example.com/sqlite-server/store"
+	"example.com/sqlite-server/util"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`INSERT INTO users (id, email, password) VALUES ('u1', 'a@x.io', ''), ('u2', 'b@x.io', '')`); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func newSession(t *testing.T, db *sql.DB, userID string, remember bool, ua string) Session {
+	t.Helper()
+	s, err := CreateSession(db, userID, remember, ClientInfo{UserAgent: ua, IP: "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestListAndRevokeSessions(t *testing.T) {
+	db := openTestDB(t)
+	laptop := newSession(t, db, "u1", false, "laptop")
+	phone := newSession(t, db, "u1", false, "phone")
+	tablet := newSession(t, db, "u1", false, "tablet")
+	other := newSession(t, db, "u2", false, "other")
+
+	list, err := ListUserSessions(db, "u1", laptop.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("listed %d sessions, want 3", len(list))
+	}
+	for _, v := range list {
+		if v.ID == laptop.ID || v.ID == laptop.Key {
+			t.Fatal("listing exposes the session token")
+		}
+		if v.Current != (v.ID == laptop.Handle) {
+			t.Errorf("session %s (%s): current = %v", v.ID, v.UserAgent, v.Current)
+		}
+		if v.IP != "192.0.2.1" {
+			t.Errorf("session %s: ip = %q", v.ID, v.IP)
+		}
+	}
+
+	if err := RevokeUserSession(db, "u1", other.Handle); err != sql.ErrNoRows {
+		t.Fatalf("revoking someone else's session: %v", err)
+	}
+	if err := RevokeUserSession(db, "u1", phone.Handle); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetSessionByID(db, phone.ID); err != sql.ErrNoRows {
+		t.Fatalf("revoked session still loads: %v", err)
+	}
+
+	n, err := RevokeOtherSessions(db, "u1", laptop.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("revoked %d other sessions, want 1 (the tablet)", n)
+	}
+	for _, s := range []Session{laptop, other} {
+		if _, err := GetSessionByID(db, s.ID); err != nil {
+			t.Errorf("session %s was revoked: %v", s.Handle, err)
+		}
+	}
+	if _, err := GetSessionByID(db, tablet.ID); err != sql.ErrNoRows {
+		t.Fatalf("tablet session still loads: %v", err)
+	}
+}
+
+func TestRenewSessionSlidesUpToMaxLifetime(t *testing.T) {
+	saved := Settings
+	defer func() { Settings = saved }()
+	Settings = Lifetimes{TTL: time.Hour, RememberTTL: 10 * time.Hour, MaxLifetime: 24 * time.Hour}
+
+	db := openTestDB(t)
+	now := time.Now().Unix()
+	s := newSession(t, db, "u1", false, "")
+	if s.ExpiresAt < now+3600 || s.ExpiresAt > now+3601 {
+		t.Fatalf("expires in %ds, want an hour", s.ExpiresAt-now)
+	}
+	if r := newSession(t, db, "u1", true, ""); r.ExpiresAt < now+36000 {
+		t.Fatalf("remembered session expires in %ds, want 10 hours", r.ExpiresAt-now)
+	}
+
+	// Fresh sessions are left alone.
+	if _, renewed, err := RenewSession(db, s); err != nil || renewed {
+		t.Fatalf("fresh session renewed = %v (%v)", renewed, err)
+	}
+
+	// Past half-life the window slides forward.
+	s.ExpiresAt = now + 600
+	s, renewed, err := RenewSession(db, s)
+	if err != nil || !renewed {
+		t.Fatalf("renewed = %v (%v)", renewed, err)
+	}
+	stored, err := GetSessionByID(db, s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ExpiresAt != s.ExpiresAt || s.ExpiresAt < now+3600 {
+		t.Fatalf("expires at %d (stored %d), want an hour from now", s.ExpiresAt, stored.ExpiresAt)
+	}
+
+	// ...but never past MaxLifetime from creation.
+	s.CreatedAt = now - 23*3600 - 1800
+	s.ExpiresAt = now + 600
+	s, renewed, err = RenewSession(db, s)
+	if err != nil || !renewed {
+		t.Fatalf("renewed = %v (%v)", renewed, err)
+	}
+	if want := s.CreatedAt + 24*3600; s.ExpiresAt != want {
+		t.Fatalf("expires at %d, want the cap %d", s.ExpiresAt, want)
+	}
+	if _, renewed, _ := RenewSession(db, s); renewed {
+		t.Fatal("renewed past the cap")
+	}
+}
+
+func TestSessionsAreStoredHashed(t *testing.T) {
+	db := openTestDB(t)
+	s := newSession(t, db, "u1", false, "")
+
+	var stored string
+	if err := db.QueryRow(`SELECT id FROM sessions WHERE user_id = 'u1'`).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored == s.ID || stored != util.HashToken(s.ID) || stored != s.Key {
+		t.Fatalf("stored id %q for token %q", stored, s.ID)
+	}
+	if _, err := GetSessionByID(db, s.ID); err != nil {
+		t.Fatalf("lookup by token: %v", err)
+	}
+	// The stored value is not itself a working token.
+	if _, err := GetSessionByID(db, stored); err != sql.ErrNoRows {
+		t.Fatalf("lookup by stored hash: %v", err)
+	}
+	if err := DeleteSessionByID(db, s.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetSessionByID(db, s.ID); err != sql.ErrNoRows {
+		t.Fatalf("deleted session still loads: %v", err)
+	}
+}
+
+func TestDisabledUsersGetNoSession(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := db.Exec(`UPDATE users SET disabled_at = 1 WHERE id = 'u2'`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CreateSession(db, "u2", false, ClientInfo{}); err != ErrAccountDisabled {
+		t.Fatalf("CreateSession for a disabled user: %v", err)
+	}
+}
@@ -390,3 +390,106 @@ func EnsureCalendarTriggers(db execer) error {
 	}
 	return nil
 }
+
+// courseItemsMatch selects calendar_index rows for items of course %[1]s.
+const courseItemsMatch = `(
+		(kind = 'assignment' AND source_id IN (SELECT id FROM assignments WHERE course_id = %[1]s)) OR
+		(kind = 'article' AND source_id IN (SELECT id FROM articles WHERE course_id = %[1]s)) OR
+		(kind = 'chapter' AND source_id IN (
+			SELECT c.id FROM chapters c JOIN books b ON b.id = c.book_id WHERE b.course_id = %[1]s))
+	)`
+
+// entryArchived is true when calendar_index row NEW belongs to a course that is
+// archived, for everyone or by the row's user.
+const entryArchived = `EXISTS (
+		SELECT 1 FROM courses co
+		  LEFT JOIN user_courses uc ON uc.course_id = co.id AND uc.user_id = NEW.user_id
+		 WHERE co.id = CASE NEW.kind
+		         WHEN 'assignment' THEN (SELECT course_id FROM assignments WHERE id = NEW.source_id)
+		         WHEN 'article' THEN (SELECT course_id FROM articles WHERE id = NEW.source_id)
+		         WHEN 'chapter' THEN (SELECT b.course_id FROM chapters c JOIN books b ON b.id = c.book_id
+		                               WHERE c.id = NEW.source_id) END
+		   AND (co.archived_at IS NOT NULL OR uc.archived_at IS NOT NULL))`
+
+// ensureCalendarArchiveTriggers installs triggers that cancel a course's calendar
+// entries when it is archived (for everyone, or by one user) and revive them on
+// restore, unless the other kind of archive still applies. Entries that the
+// material and enrolment triggers add or revive while a course is archived
+// are cancelled straight away. They need user_courses.archived_at, so they are
+// created by a migration.
+func ensureCalendarArchiveTriggers(db execer) error {
+	course := fmt.Sprintf(courseItemsMatch, "NEW.id")
+	enrolment := fmt.Sprintf(courseItemsMatch, "NEW.course_id")
+	_, err := db.Exec(`
+	CREATE TRIGGER IF NOT EXISTS cal_archived_ins
+	AFTER INSERT ON calendar_index
+	WHEN NEW.cancelled_at IS NULL AND ` + entryArchived + `
+	BEGIN
+		UPDATE calendar_index SET cancelled_at = strftime('%s','now') WHERE uid = NEW.uid;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS cal_archived_revive
+	AFTER UPDATE OF cancelled_at ON calendar_index
+	WHEN NEW.cancelled_at IS NULL AND ` + entryArchived + `
+	BEGIN
+		UPDATE calendar_index SET cancelled_at = strftime('%s','now') WHERE uid = NEW.uid;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS cal_course_archive
+	AFTER UPDATE OF archived_at ON courses
+	WHEN OLD.archived_at IS NULL AND NEW.archived_at IS NOT NULL
+	BEGIN
+		UPDATE calendar_index
+		SET cancelled_at = strftime('%s','now'),
+		    last_modified_epoch = strftime('%s','now'),
+		    seq = seq + 1
+		WHERE cancelled_at IS NULL
+		  AND ` + course + `;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS cal_course_restore
+	AFTER UPDATE OF archived_at ON courses
+	WHEN OLD.archived_at IS NOT NULL AND NEW.archived_at IS NULL
+	BEGIN
+		UPDATE calendar_index
+		SET cancelled_at = NULL,
+		    last_modified_epoch = strftime('%s','now'),
+		    seq = seq + 1
+		WHERE cancelled_at IS NOT NULL
+		  AND user_id IN (SELECT user_id FROM user_courses
+		                   WHERE course_id = NEW.id AND archived_at IS NULL)
+		  AND ` + course + `;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS cal_uc_archive
+	AFTER UPDATE OF archived_at ON user_courses
+	WHEN OLD.archived_at IS NULL AND NEW.archived_at IS NOT NULL
+	BEGIN
+		UPDATE calendar_index
+		SET cancelled_at = strftime('%s','now'),
+		    last_modified_epoch = strftime('%s','now'),
+		    seq = seq + 1
+		WHERE cancelled_at IS NULL
+		  AND user_id = NEW.user_id
+		  AND ` + enrolment + `;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS cal_uc_restore
+	AFTER UPDATE OF archived_at ON user_courses
+	WHEN OLD.archived_at IS NOT NULL AND NEW.archived_at IS NULL
+	  AND (SELECT archived_at FROM courses WHERE id = NEW.course_id) IS NULL
+	BEGIN
+		UPDATE calendar_index
+		SET cancelled_at = NULL,
+		    last_modified_epoch = strftime('%s','now'),
+		    seq = seq + 1
+		WHERE cancelled_at IS NOT NULL
+		  AND user_id = NEW.user_id
+		  AND ` + enrolment + `;
+	END;
+	`)
+	if err != nil {
+		return fmt.Errorf("ensure calendar archive triggers: %w", err)
+	}
+	return nil
+}
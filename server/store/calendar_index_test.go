@@ -0,0 +1,68 @@
+package store
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchivedCourseCalendarEntries(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	exec := func(query string, args ...any) {
+		t.Helper()
+		if _, err := db.Exec(query, args...); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	live := func(kind string, id int64) bool {
+		t.Helper()
+		var cancelled sql.NullInt64
+		if err := db.QueryRow(`
+			SELECT cancelled_at FROM calendar_index WHERE user_id = 'u1' AND kind = ? AND source_id = ?
+		`, kind, id).Scan(&cancelled); err != nil {
+			t.Fatalf("%s %d: %v", kind, id, err)
+		}
+		return !cancelled.Valid
+	}
+
+	exec(`INSERT INTO users (id, email, password) VALUES ('u1', 'a@x.io', '')`)
+	exec(`INSERT INTO universities (id, name) VALUES ('uni', 'Uni')`)
+	exec(`INSERT INTO courses (id, university_id, year, term, code, name) VALUES (1, 'uni', 2026, 1, 'CS1', 'Intro')`)
+	exec(`INSERT INTO user_courses (user_id, course_id) VALUES ('u1', 1)`)
+
+	// Archived for everyone: new material stays off the calendar until restored.
+	exec(`UPDATE courses SET archived_at = 1 WHERE id = 1`)
+	exec(`INSERT INTO assignments (id, course_id, title, deadline) VALUES (10, 1, 'Essay', 1800000000)`)
+	exec(`INSERT INTO books (id, course_id, title, author) VALUES (20, 1, 'SICP', 'Abelson')`)
+	exec(`INSERT INTO chapters (id, book_id, chapter_num, deadline) VALUES (21, 20, 1, 1800000000)`)
+	if live("assignment", 10) || live("chapter", 21) {
+		t.Error("material added to an archived course is on the calendar")
+	}
+	exec(`UPDATE courses SET archived_at = NULL WHERE id = 1`)
+	if !live("assignment", 10) || !live("chapter", 21) {
+		t.Error("restoring the course didn't bring its new material back")
+	}
+
+	// Archived by the user only.
+	exec(`UPDATE user_courses SET archived_at = 1 WHERE user_id = 'u1' AND course_id = 1`)
+	exec(`INSERT INTO articles (id, course_id, title, author, deadline) VALUES (30, 1, 'Paper', 'Turing', 1800000000)`)
+	if live("article", 30) {
+		t.Error("material added to a course the user archived is on their calendar")
+	}
+	exec(`UPDATE user_courses SET archived_at = NULL WHERE user_id = 'u1' AND course_id = 1`)
+	if !live("article", 30) {
+		t.Error("restoring the enrolment didn't bring the article back")
+	}
+
+	// Enrolling in an archived course.
+	exec(`DELETE FROM user_courses`)
+	exec(`UPDATE courses SET archived_at = 1 WHERE id = 1`)
+	exec(`INSERT INTO user_courses (user_id, course_id) VALUES ('u1', 1)`)
+	if live("assignment", 10) {
+		t.Error("enrolling in an archived course revived its entries")
+	}
+}
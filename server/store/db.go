@@ -1,24 +1,39 @@
 package store
 
 import (
-  "database/sql"
+	"database/sql"
 
-  _ "modernc.org/sqlite"
+	_ "modernc.org/sqlite"
 )
 
 func OpenDB(dsn string) (*sql.DB, error) {
-  db, err := sql.Open("sqlite", dsn)
-  if err != nil {
-    return nil, err
-  }
-  // tiny app: keep connections low
-  db.SetMaxOpenConns(1) // only 1 server should be active at a time
-  db.SetMaxIdleConns(1)
-  return db, nil
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// tiny app: keep connections low
+	db.SetMaxOpenConns(1) // only 1 server should be active at a time
+	db.SetMaxIdleConns(1)
+	return db, nil
+}
+
+// Open opens the SQLite file at path and brings the schema up to date.
+func Open(path string) (*sql.DB, error) {
+	db, err := OpenDB("file:" + path + "?_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	for _, ensure := range []func(*sql.DB) error{EnsureSchema, EnsureCalendar, EnsureSearch, EnsureMigrations} {
+		if err := ensure(db); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return db, nil
 }
 
 func EnsureSchema(db *sql.DB) error {
-  _, err := db.Exec(`
+	_, err := db.Exec(`
     PRAGMA foreign_keys = ON;
 
     -- Core identities
@@ -26,7 +41,8 @@ func EnsureSchema(db *sql.DB) error {
       id TEXT PRIMARY KEY,
       email TEXT NOT NULL UNIQUE,
       password TEXT NOT NULL,
-      created_at INTEGER NOT NULL DEFAULT (strftime('%s','now'))
+      created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+      disabled_at INTEGER -- set by an admin; blocks sign-in and tokens
     );
 
 		CREATE TABLE IF NOT EXISTS admins (
@@ -37,13 +53,22 @@ func EnsureSchema(db *sql.DB) error {
     CREATE TABLE IF NOT EXISTS universities (
       id TEXT PRIMARY KEY,
       name TEXT NOT NULL UNIQUE,
-      created_at INTEGER NOT NULL DEFAULT (strftime('%s','now'))
+      created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+      status TEXT NOT NULL DEFAULT 'approved'
+        CHECK (status IN ('pending','approved','rejected')),
+      created_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+      name_key TEXT,      -- util.FoldName(name), for duplicate detection
+      reviewed_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+      reviewed_at INTEGER,
+      review_note TEXT,
+      join_policy TEXT NOT NULL DEFAULT 'open'
+        CHECK (join_policy IN ('open','invite','approval'))
     );
 
     CREATE TABLE IF NOT EXISTS user_universities (
       user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
       university_id TEXT NOT NULL REFERENCES universities(id) ON DELETE CASCADE,
-      role TEXT NOT NULL DEFAULT 'member',
+      role TEXT NOT NULL DEFAULT 'member', -- member | curator | owner
       PRIMARY KEY (user_id, university_id)
     );
 
@@ -55,12 +80,17 @@ func EnsureSchema(db *sql.DB) error {
       term INTEGER NOT NULL,
       code TEXT NOT NULL,
       name TEXT NOT NULL,
+      created_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+      join_policy TEXT NOT NULL DEFAULT 'open'
+        CHECK (join_policy IN ('open','invite','approval')),
+      archived_at INTEGER, -- archived for everyone (by a curator, or when its term ends)
       UNIQUE (university_id, year, term, code)
     );
 
     CREATE TABLE IF NOT EXISTS user_courses (
       user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
       course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+      archived_at INTEGER, -- archived by this user only
       PRIMARY KEY (user_id, course_id)
     );
 
@@ -121,9 +151,208 @@ func EnsureSchema(db *sql.DB) error {
       id TEXT PRIMARY KEY,
       user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
       created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+      expires_at INTEGER NOT NULL,
+      handle TEXT,        -- public identifier; id is the SHA-256 of the bearer token
+      last_seen_at INTEGER,
+      user_agent TEXT,
+      ip TEXT,
+      remember INTEGER NOT NULL DEFAULT 0
+    );
+
+    -- External (OIDC) identities linked to users
+    CREATE TABLE IF NOT EXISTS user_identities (
+      id INTEGER PRIMARY KEY AUTOINCREMENT,
+      user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+      issuer TEXT NOT NULL,
+      subject TEXT NOT NULL,
+      email TEXT,
+      created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+      last_login_at INTEGER,
+      UNIQUE (issuer, subject)
+    );
+
+    -- In-flight OIDC logins (one-time; state is stored hashed)
+    CREATE TABLE IF NOT EXISTS oidc_states (
+      state TEXT PRIMARY KEY,
+      code_verifier TEXT NOT NULL,
+      nonce TEXT NOT NULL,
+      return_to TEXT NOT NULL,
+      link_user_id TEXT REFERENCES users(id) ON DELETE CASCADE,
+      expires_at INTEGER NOT NULL
+    );
+
+    -- TOTP second factor (enabled once the first code is confirmed)
+    CREATE TABLE IF NOT EXISTS user_totp (
+      user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+      secret TEXT NOT NULL,        -- base32
+      enabled INTEGER NOT NULL DEFAULT 0 CHECK (enabled IN (0,1)),
+      last_step INTEGER NOT NULL DEFAULT 0, -- last accepted time step (replay guard)
+      created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+      failed_attempts INTEGER NOT NULL DEFAULT 0, -- wrong login codes in a row
+      locked_until INTEGER -- no login challenges until then
+    );
+
+    -- One-time recovery codes; only the hash is stored
+    CREATE TABLE IF NOT EXISTS totp_recovery_codes (
+      user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+      code_hash TEXT NOT NULL,
+      used_at INTEGER,
+      PRIMARY KEY (user_id, code_hash)
+    );
+
+    -- Password verified, second factor pending (token stored hashed)
+    CREATE TABLE IF NOT EXISTS mfa_challenges (
+      token TEXT PRIMARY KEY,
+      user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+      remember INTEGER NOT NULL DEFAULT 0,
+      attempts INTEGER NOT NULL DEFAULT 0,
       expires_at INTEGER NOT NULL
     );
 
+    -- Invite codes for a university or a course (only the hash is stored)
+    CREATE TABLE IF NOT EXISTS invites (
+      id TEXT PRIMARY KEY,
+      code_hash TEXT NOT NULL UNIQUE,
+      university_id TEXT REFERENCES universities(id) ON DELETE CASCADE,
+      course_id INTEGER REFERENCES courses(id) ON DELETE CASCADE,
+      created_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+      created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+      expires_at INTEGER,
+      max_uses INTEGER,
+      uses INTEGER NOT NULL DEFAULT 0,
+      revoked_at INTEGER,
+      CHECK ((university_id IS NULL) <> (course_id IS NULL))
+    );
+
+    -- Requests to join approval-required universities/courses
+    CREATE TABLE IF NOT EXISTS join_requests (
+      id INTEGER PRIMARY KEY AUTOINCREMENT,
+      user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+      university_id TEXT REFERENCES universities(id) ON DELETE CASCADE,
+      course_id INTEGER REFERENCES courses(id) ON DELETE CASCADE,
+      status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending','approved','rejected')),
+      created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+      decided_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+      decided_at INTEGER,
+      CHECK ((university_id IS NULL) <> (course_id IS NULL))
+    );
+
+    -- Personal access tokens (Authorization: Bearer); only the hash is stored
+    CREATE TABLE IF NOT EXISTS api_tokens (
+      id TEXT PRIMARY KEY,
+      user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+      name TEXT NOT NULL,
+      token_hash TEXT NOT NULL UNIQUE,
+      scopes TEXT NOT NULL,
+      created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+      expires_at INTEGER,
+      last_used_at INTEGER
+    );
+
+    -- Academic terms; courses belong to the term with the same (university_id, year, term).
+    -- Dates are calendar days (YYYY-MM-DD, end inclusive) in the term's timezone.
+    CREATE TABLE IF NOT EXISTS terms (
+      id INTEGER PRIMARY KEY AUTOINCREMENT,
+      university_id TEXT NOT NULL REFERENCES universities(id) ON DELETE CASCADE,
+      year INTEGER NOT NULL,
+      term INTEGER NOT NULL CHECK (term BETWEEN 1 AND 4),
+      name TEXT NOT NULL,
+      starts_on TEXT NOT NULL,
+      ends_on TEXT NOT NULL,
+      timezone TEXT NOT NULL DEFAULT 'UTC',
+      archived_at INTEGER, -- set once by the auto-archiver
+      CHECK (starts_on <= ends_on),
+      UNIQUE (university_id, year, term)
+    );
+
+    -- Reading weeks and holidays within a term (no deadlines fall on these days)
+    CREATE TABLE IF NOT EXISTS term_breaks (
+      id INTEGER PRIMARY KEY AUTOINCREMENT,
+      term_id INTEGER NOT NULL REFERENCES terms(id) ON DELETE CASCADE,
+      name TEXT NOT NULL,
+      starts_on TEXT NOT NULL,
+      ends_on TEXT NOT NULL,
+      CHECK (starts_on <= ends_on)
+    );
+
+    -- Outgoing webhooks per course (curators). The secret signs deliveries,
+    -- so unlike tokens it is kept as is.
+    CREATE TABLE IF NOT EXISTS webhooks (
+      id INTEGER PRIMARY KEY AUTOINCREMENT,
+      course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+      url TEXT NOT NULL,
+      secret TEXT NOT NULL,
+      created_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+      created_at INTEGER NOT NULL DEFAULT (strftime('%s','now'))
+    );
+
+    -- One row per event per webhook: the delivery log and the retry queue
+    CREATE TABLE IF NOT EXISTS webhook_deliveries (
+      id INTEGER PRIMARY KEY AUTOINCREMENT,
+      webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+      event TEXT NOT NULL,      -- created | deleted | deadline | ping
+      payload TEXT NOT NULL,    -- JSON, exactly as sent
+      status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending','delivered','failed')),
+      attempts INTEGER NOT NULL DEFAULT 0,
+      next_attempt_at INTEGER,  -- pending only; NULL while a ping is in flight
+      response_status INTEGER,  -- of the last attempt
+      error TEXT,               -- of the last attempt
+      created_at INTEGER NOT NULL DEFAULT (strftime('%s','now')),
+      delivered_at INTEGER
+    );
+
+    -- Deadline digest emails (opt-in).
+    CREATE TABLE IF NOT EXISTS digest_settings (
+      user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+      frequency TEXT NOT NULL DEFAULT 'off'
+        CHECK (frequency IN ('off','daily','weekly')),
+      hour INTEGER NOT NULL DEFAULT 8 CHECK (hour BETWEEN 0 AND 23),
+      weekday INTEGER NOT NULL DEFAULT 1 CHECK (weekday BETWEEN 0 AND 6), -- weekly; 0 = Sunday
+      timezone TEXT NOT NULL DEFAULT 'UTC',
+      last_sent_at INTEGER -- when the last scheduled digest was handled
+    );
+
+    -- Each digest carries its own unsubscribe token, so links in older emails
+    -- keep working. Only the hash is kept.
+    CREATE TABLE IF NOT EXISTS digest_unsubscribe_tokens (
+      token_hash TEXT PRIMARY KEY,
+      user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+      created_at INTEGER NOT NULL DEFAULT (strftime('%s','now'))
+    );
+
+    -- Web Push subscriptions, one per browser. endpoint is the push service
+    -- URL; p256dh and auth are the browser's keys for encrypting payloads.
+    CREATE TABLE IF NOT EXISTS push_subscriptions (
+      id INTEGER PRIMARY KEY AUTOINCREMENT,
+      user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+      endpoint TEXT NOT NULL UNIQUE,
+      p256dh TEXT NOT NULL,
+      auth TEXT NOT NULL,
+      user_agent TEXT,
+      created_at INTEGER NOT NULL DEFAULT (strftime('%s','now'))
+    );
+
+    -- Push notification preferences; users without a row get the defaults.
+    CREATE TABLE IF NOT EXISTS push_settings (
+      user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+      lead_hours INTEGER NOT NULL DEFAULT 24 CHECK (lead_hours BETWEEN 1 AND 168),
+      chapters INTEGER NOT NULL DEFAULT 1 CHECK (chapters IN (0,1)),
+      articles INTEGER NOT NULL DEFAULT 1 CHECK (articles IN (0,1)),
+      assignments INTEGER NOT NULL DEFAULT 1 CHECK (assignments IN (0,1))
+    );
+
+    -- Deadlines already notified. Keyed on the deadline too, so a moved
+    -- deadline is notified again.
+    CREATE TABLE IF NOT EXISTS push_sent (
+      user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+      kind TEXT NOT NULL,
+      source_id INTEGER NOT NULL,
+      deadline_epoch INTEGER NOT NULL,
+      PRIMARY KEY (user_id, kind, source_id, deadline_epoch)
+    );
+
     -- Indexes
     CREATE INDEX IF NOT EXISTS idx_user_universities_university
       ON user_universities(university_id);
@@ -137,6 +366,9 @@ func EnsureSchema(db *sql.DB) error {
     CREATE INDEX IF NOT EXISTS idx_user_courses_course
       ON user_courses(course_id);
 
+    CREATE INDEX IF NOT EXISTS idx_digest_unsubscribe_tokens_user
+      ON digest_unsubscribe_tokens(user_id);
+
     CREATE INDEX IF NOT EXISTS idx_books_course
       ON books(course_id);
 
@@ -178,6 +410,46 @@ func EnsureSchema(db *sql.DB) error {
 
     CREATE INDEX IF NOT EXISTS idx_sessions_expires
       ON sessions(expires_at);
+
+    CREATE INDEX IF NOT EXISTS idx_api_tokens_user
+      ON api_tokens(user_id);
+
+    CREATE INDEX IF NOT EXISTS idx_user_identities_user
+      ON user_identities(user_id);
+
+    CREATE INDEX IF NOT EXISTS idx_invites_university
+      ON invites(university_id) WHERE university_id IS NOT NULL;
+
+    CREATE INDEX IF NOT EXISTS idx_invites_course
+      ON invites(course_id) WHERE course_id IS NOT NULL;
+
+    CREATE INDEX IF NOT EXISTS idx_terms_ends_on
+      ON terms(ends_on);
+
+    CREATE INDEX IF NOT EXISTS idx_term_breaks_term
+      ON term_breaks(term_id);
+
+    CREATE INDEX IF NOT EXISTS idx_webhooks_course
+      ON webhooks(course_id);
+
+    CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook
+      ON webhook_deliveries(webhook_id, id);
+
+    CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due
+      ON webhook_deliveries(next_attempt_at)
+      WHERE status = 'pending';
+
+    CREATE INDEX IF NOT EXISTS idx_push_subscriptions_user
+      ON push_subscriptions(user_id);
+
+    -- At most one pending request per user and target
+    CREATE UNIQUE INDEX IF NOT EXISTS idx_join_requests_pending_university
+      ON join_requests(user_id, university_id)
+      WHERE status = 'pending' AND university_id IS NOT NULL;
+
+    CREATE UNIQUE INDEX IF NOT EXISTS idx_join_requests_pending_course
+      ON join_requests(user_id, course_id)
+      WHERE status = 'pending' AND course_id IS NOT NULL;
   `)
-  return err
+	return err
 }
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestConstraintErrors(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`INSERT INTO users (id, email, password) VALUES ('u1', 'a@example.com', 'x')`); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Exec(`INSERT INTO users (id, email, password) VALUES ('u2', 'a@example.com', 'x')`)
+	if !IsUniqueViolation(err) || IsForeignKeyViolation(err) {
+		t.Fatalf("duplicate email: %v", err)
+	}
+	_, err = db.Exec(`INSERT INTO users (id, email, password) VALUES ('u1', 'b@example.com', 'x')`)
+	if !IsUniqueViolation(fmt.Errorf("wrapped: %w", err)) {
+		t.Fatalf("duplicate primary key: %v", err)
+	}
+	_, err = db.Exec(`INSERT INTO sessions (id, user_id, expires_at) VALUES ('s1', 'nobody', 0)`)
+	if !IsForeignKeyViolation(err) || IsUniqueViolation(err) {
+		t.Fatalf("missing user: %v", err)
+	}
+
+	// Messages that merely mention a constraint are not constraint errors.
+	if IsUniqueViolation(errors.New("unique")) || IsForeignKeyViolation(errors.New("foreign key")) {
+		t.Fatal("plain errors classified as constraint failures")
+	}
+}
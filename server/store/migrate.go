@@ -0,0 +1,262 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"example.com/sqlite-server/util"
+)
+
+// migration is a one-shot schema change that CREATE ... IF NOT EXISTS can't express
+// (new columns on existing tables, backfills). Each runs once and is recorded by name.
+type migration struct {
+	name string
+	run  func(tx *sql.Tx) error
+}
+
+var migrations = []migration{
+	{name: "0001_session_metadata", run: migrateSessionMetadata},
+	{name: "0002_session_remember", run: migrateSessionRemember},
+	{name: "0003_hash_tokens", run: migrateHashTokens},
+	{name: "0004_user_disabled", run: migrateUserDisabled},
+	{name: "0005_university_moderation", run: migrateUniversityModeration},
+	{name: "0006_join_policies", run: migrateJoinPolicies},
+	{name: "0007_course_archived", run: migrateCourseArchived},
+	{name: "0008_enrollment_archived", run: migrateEnrollmentArchived},
+	{name: "0009_search_backfill", run: migrateSearchBackfill},
+}
+
+// EnsureMigrations applies any migrations not yet recorded in schema_migrations.
+// Call after EnsureSchema and EnsureCalendar so the base tables exist.
+func EnsureMigrations(db *sql.DB) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			name TEXT PRIMARY KEY,
+			applied_at INTEGER NOT NULL DEFAULT (strftime('%s','now'))
+		);
+	`); err != nil {
+		return fmt.Errorf("ensure schema_migrations: %w", err)
+	}
+
+	for _, m := range migrations {
+		var x int
+		err := db.QueryRow(`SELECT 1 FROM schema_migrations WHERE name = ?`, m.name).Scan(&x)
+		if err == nil {
+			continue
+		}
+		if err != sql.ErrNoRows {
+			return fmt.Errorf("check migration %s: %w", m.name, err)
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("begin migration %s: %w", m.name, err)
+		}
+		if err := m.run(tx); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("migration %s: %w", m.name, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (name) VALUES (?)`, m.name); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("record migration %s: %w", m.name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit migration %s: %w", m.name, err)
+		}
+		log.Printf("applied migration %s", m.name)
+	}
+	return nil
+}
+
+// addColumnIfMissing runs ALTER TABLE ... ADD COLUMN unless the column already exists
+// (fresh databases may already have it from EnsureSchema).
+func addColumnIfMissing(tx *sql.Tx, table, column, decl string) error {
+	rows, err := tx.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + decl)
+	return err
+}
+
+// Sessions gain a public handle (so the raw token never leaves the cookie)
+// plus client metadata for the "active sessions" view.
+func migrateSessionMetadata(tx *sql.Tx) error {
+	for _, c := range []struct{ name, decl string }{
+		{"handle", "TEXT"},
+		{"last_seen_at", "INTEGER"},
+		{"user_agent", "TEXT"},
+		{"ip", "TEXT"},
+	} {
+		if err := addColumnIfMissing(tx, "sessions", c.name, c.decl); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`
+		UPDATE sessions
+		   SET handle = lower(hex(randomblob(16)))
+		 WHERE handle IS NULL;
+
+		UPDATE sessions
+		   SET last_seen_at = created_at
+		 WHERE last_seen_at IS NULL;
+
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_handle
+		  ON sessions(handle);
+	`); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Remember-me sessions get the longer TTL when they slide forward.
+func migrateSessionRemember(tx *sql.Tx) error {
+	return addColumnIfMissing(tx, "sessions", "remember", "INTEGER NOT NULL DEFAULT 0")
+}
+
+// Sessions and calendar tokens used to be stored as the raw bearer value.
+// Rehash them in place so existing cookies and subscribed calendar URLs keep working.
+func migrateHashTokens(tx *sql.Tx) error {
+	for _, t := range []struct{ table, column string }{
+		{"sessions", "id"},
+		{"calendar_tokens", "token"},
+	} {
+		rows, err := tx.Query(`SELECT ` + t.column + ` FROM ` + t.table)
+		if err != nil {
+			return err
+		}
+		var raw []string
+		for rows.Next() {
+			var v string
+			if err := rows.Scan(&v); err != nil {
+				rows.Close()
+				return err
+			}
+			raw = append(raw, v)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, v := range raw {
+			if _, err := tx.Exec(`UPDATE `+t.table+` SET `+t.column+` = ? WHERE `+t.column+` = ?`,
+				util.HashToken(v), v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Admins can disable accounts without deleting them.
+func migrateUserDisabled(tx *sql.Tx) error {
+	return addColumnIfMissing(tx, "users", "disabled_at", "INTEGER")
+}
+
+// Universities gain a moderation status, an owner and a folded name key.
+// Existing universities stay approved and ownerless (admin-managed).
+func migrateUniversityModeration(tx *sql.Tx) error {
+	for _, c := range []struct{ name, decl string }{
+		{"status", "TEXT NOT NULL DEFAULT 'approved' CHECK (status IN ('pending','approved','rejected'))"},
+		{"created_by", "TEXT REFERENCES users(id) ON DELETE SET NULL"},
+		{"name_key", "TEXT"},
+		{"reviewed_by", "TEXT REFERENCES users(id) ON DELETE SET NULL"},
+		{"reviewed_at", "INTEGER"},
+		{"review_note", "TEXT"},
+	} {
+		if err := addColumnIfMissing(tx, "universities", c.name, c.decl); err != nil {
+			return err
+		}
+	}
+
+	rows, err := tx.Query(`SELECT id, name FROM universities WHERE name_key IS NULL`)
+	if err != nil {
+		return err
+	}
+	keys := map[string]string{}
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return err
+		}
+		keys[id] = util.FoldName(name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, key := range keys {
+		if _, err := tx.Exec(`UPDATE universities SET name_key = ? WHERE id = ?`, key, id); err != nil {
+			return err
+		}
+	}
+
+	// Not UNIQUE: pre-existing near-duplicates are left for admins to merge.
+	_, err = tx.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_universities_name_key
+		  ON universities(name_key);
+
+		CREATE INDEX IF NOT EXISTS idx_universities_status
+		  ON universities(status);
+	`)
+	return err
+}
+
+// Universities and courses get a join policy (existing ones stay open), and
+// courses remember their creator, who may curate them.
+func migrateJoinPolicies(tx *sql.Tx) error {
+	policy := "TEXT NOT NULL DEFAULT 'open' CHECK (join_policy IN ('open','invite','approval'))"
+	if err := addColumnIfMissing(tx, "universities", "join_policy", policy); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(tx, "courses", "join_policy", policy); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(tx, "courses", "created_by", "TEXT REFERENCES users(id) ON DELETE SET NULL"); err != nil {
+		return err
+	}
+	// Creators of already-approved universities become their owners.
+	_, err := tx.Exec(`
+		INSERT INTO user_universities (user_id, university_id, role)
+		SELECT created_by, id, 'owner' FROM universities
+		 WHERE status = 'approved' AND created_by IS NOT NULL
+		ON CONFLICT (user_id, university_id) DO UPDATE SET role = 'owner'
+	`)
+	return err
+}
+
+// Courses can be archived once their term is over.
+func migrateCourseArchived(tx *sql.Tx) error {
+	return addColumnIfMissing(tx, "courses", "archived_at", "INTEGER")
+}
+
+// Users can archive courses for themselves; archiving (either kind) cancels the
+// affected calendar entries and restoring brings them back.
+func migrateEnrollmentArchived(tx *sql.Tx) error {
+	if err := addColumnIfMissing(tx, "user_courses", "archived_at", "INTEGER"); err != nil {
+		return err
+	}
+	return ensureCalendarArchiveTriggers(tx)
+}
+
+// Index the courses and materials that predate search_index (whose triggers
+// only see new writes).
+func migrateSearchBackfill(tx *sql.Tx) error {
+	return rebuildSearchIndex(tx)
+}
@@ -0,0 +1,167 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// search_index rowids are derived from the source row so triggers can address
+// entries directly: rowid = source_id*4 + kind code.
+//   0 course, 1 book, 2 article, 3 assignment
+
+// EnsureSearch creates the full-text search index and the triggers that keep it
+// in sync (no-op if present). Existing rows are indexed by a migration.
+func EnsureSearch(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin search tx: %w", err)
+	}
+	if err := EnsureSearchIndex(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := EnsureSearchTriggers(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit search tx: %w", err)
+	}
+	return nil
+}
+
+// EnsureSearchIndex creates the search_index FTS5 table. Matching ignores case
+// and accents.
+func EnsureSearchIndex(db execer) error {
+	_, err := db.Exec(`
+	CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5(
+		kind UNINDEXED,      -- course | book | article | assignment
+		source_id UNINDEXED,
+		course_id UNINDEXED,
+		title,               -- course name, or item title
+		detail,              -- course code, author, or assignment description
+		tokenize = 'unicode61 remove_diacritics 2'
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("ensure search_index: %w", err)
+	}
+	return nil
+}
+
+// EnsureSearchTriggers installs triggers that keep search_index in sync.
+func EnsureSearchTriggers(db execer) error {
+	_, err := db.Exec(`
+	/* ============ COURSES ============ */
+
+	CREATE TRIGGER IF NOT EXISTS search_course_ins
+	AFTER INSERT ON courses
+	BEGIN
+		INSERT INTO search_index (rowid, kind, source_id, course_id, title, detail)
+		VALUES (NEW.id * 4, 'course', NEW.id, NEW.id, NEW.name, NEW.code);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS search_course_upd
+	AFTER UPDATE OF name, code ON courses
+	BEGIN
+		UPDATE search_index SET title = NEW.name, detail = NEW.code WHERE rowid = NEW.id * 4;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS search_course_del
+	AFTER DELETE ON courses
+	BEGIN
+		DELETE FROM search_index WHERE rowid = OLD.id * 4;
+	END;
+
+	/* ============ BOOKS ============ */
+
+	CREATE TRIGGER IF NOT EXISTS search_book_ins
+	AFTER INSERT ON books
+	BEGIN
+		INSERT INTO search_index (rowid, kind, source_id, course_id, title, detail)
+		VALUES (NEW.id * 4 + 1, 'book', NEW.id, NEW.course_id, NEW.title, NEW.author);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS search_book_upd
+	AFTER UPDATE OF title, author, course_id ON books
+	BEGIN
+		UPDATE search_index SET title = NEW.title, detail = NEW.author, course_id = NEW.course_id
+		WHERE rowid = NEW.id * 4 + 1;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS search_book_del
+	AFTER DELETE ON books
+	BEGIN
+		DELETE FROM search_index WHERE rowid = OLD.id * 4 + 1;
+	END;
+
+	/* ============ ARTICLES ============ */
+
+	CREATE TRIGGER IF NOT EXISTS search_article_ins
+	AFTER INSERT ON articles
+	BEGIN
+		INSERT INTO search_index (rowid, kind, source_id, course_id, title, detail)
+		VALUES (NEW.id * 4 + 2, 'article', NEW.id, NEW.course_id, NEW.title, NEW.author);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS search_article_upd
+	AFTER UPDATE OF title, author, course_id ON articles
+	BEGIN
+		UPDATE search_index SET title = NEW.title, detail = NEW.author, course_id = NEW.course_id
+		WHERE rowid = NEW.id * 4 + 2;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS search_article_del
+	AFTER DELETE ON articles
+	BEGIN
+		DELETE FROM search_index WHERE rowid = OLD.id * 4 + 2;
+	END;
+
+	/* ============ ASSIGNMENTS ============ */
+
+	CREATE TRIGGER IF NOT EXISTS search_assignment_ins
+	AFTER INSERT ON assignments
+	BEGIN
+		INSERT INTO search_index (rowid, kind, source_id, course_id, title, detail)
+		VALUES (NEW.id * 4 + 3, 'assignment', NEW.id, NEW.course_id, NEW.title, COALESCE(NEW.description, ''));
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS search_assignment_upd
+	AFTER UPDATE OF title, description, course_id ON assignments
+	BEGIN
+		UPDATE search_index SET title = NEW.title, detail = COALESCE(NEW.description, ''), course_id = NEW.course_id
+		WHERE rowid = NEW.id * 4 + 3;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS search_assignment_del
+	AFTER DELETE ON assignments
+	BEGIN
+		DELETE FROM search_index WHERE rowid = OLD.id * 4 + 3;
+	END;
+	`)
+	if err != nil {
+		return fmt.Errorf("ensure search triggers: %w", err)
+	}
+	return nil
+}
+
+// rebuildSearchIndex re-indexes every course and item from scratch.
+func rebuildSearchIndex(db execer) error {
+	_, err := db.Exec(`
+	DELETE FROM search_index;
+
+	INSERT INTO search_index (rowid, kind, source_id, course_id, title, detail)
+	SELECT id * 4, 'course', id, id, name, code FROM courses;
+
+	INSERT INTO search_index (rowid, kind, source_id, course_id, title, detail)
+	SELECT id * 4 + 1, 'book', id, course_id, title, author FROM books WHERE course_id IS NOT NULL;
+
+	INSERT INTO search_index (rowid, kind, source_id, course_id, title, detail)
+	SELECT id * 4 + 2, 'article', id, course_id, title, author FROM articles WHERE course_id IS NOT NULL;
+
+	INSERT INTO search_index (rowid, kind, source_id, course_id, title, detail)
+	SELECT id * 4 + 3, 'assignment', id, course_id, title, COALESCE(description, '')
+	  FROM assignments WHERE course_id IS NOT NULL;
+	`)
+	return err
+}
@@ -0,0 +1,165 @@
+package term
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"example.com/sqlite-server/invite"
+	"example.com/sqlite-server/session"
+	"example.com/sqlite-server/util"
+)
+
+func RegisterTermRoutes(mux util.Router, db *sql.DB) {
+	mux.HandleFunc("/terms", session.RequireAuth(db, termsHandler(db)))
+	mux.HandleFunc("/terms/", session.RequireAuth(db, termItemHandler(db)))
+}
+
+// termPayload is the body of POST /terms and PUT /terms/{id}.
+type termPayload struct {
+	UniversityID string  `json:"universityId"`
+	Year         int64   `json:"year"`
+	Term         int64   `json:"term"`
+	Name         string  `json:"name"`
+	StartsOn     string  `json:"startsOn"`
+	EndsOn       string  `json:"endsOn"`
+	Timezone     string  `json:"timezone"`
+	Breaks       []Break `json:"breaks"`
+}
+
+func (p termPayload) term() Term {
+	return Term{UniversityID: strings.TrimSpace(p.UniversityID), Year: p.Year, Term: p.Term, Name: p.Name,
+		StartsOn: p.StartsOn, EndsOn: p.EndsOn, Timezone: p.Timezone, Breaks: p.Breaks}
+}
+
+// requireCurator writes 403/500 and returns false unless uid curates universityID.
+func requireCurator(w http.ResponseWriter, db *sql.DB, uid, universityID string) bool {
+	ok, err := invite.IsUniversityCurator(db, uid, universityID)
+	if err != nil {
+		util.HTTPError(w, "internal error", http.StatusInternalServerError)
+		return false
+	}
+	if !ok {
+		util.HTTPError(w, "forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func termsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			listTermsHandler(db)(w, r)
+		case http.MethodPost:
+			createTermHandler(db)(w, r)
+		default:
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// GET /terms?universityId=uuid
+func listTermsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uniID := strings.TrimSpace(r.URL.Query().Get("universityId"))
+		if uniID == "" {
+			util.HTTPError(w, "universityId is required", http.StatusBadRequest)
+			return
+		}
+		list, err := ListTerms(db, uniID)
+		if err != nil {
+			util.HTTPError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		util.WriteJSON(w, list, http.StatusOK)
+	}
+}
+
+// POST /terms  (university curators)
+// Body: { "universityId", "year", "term", "name", "startsOn", "endsOn", "timezone", "breaks": [...] }
+func createTermHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, _ := session.UserIDFromCtx(r.Context())
+
+		var p termPayload
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil {
+			util.HTTPError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		t := p.term()
+		if t.UniversityID == "" {
+			util.HTTPError(w, "universityId is required", http.StatusBadRequest)
+			return
+		}
+		if !requireCurator(w, db, uid, t.UniversityID) {
+			return
+		}
+
+		created, err := CreateTerm(db, t)
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		util.WriteJSON(w, created, http.StatusCreated)
+	}
+}
+
+// GET, PUT, DELETE /terms/{id}
+func termItemHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/terms/"), 10, 64)
+		if err != nil || id <= 0 {
+			util.HTTPError(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		uid, _ := session.UserIDFromCtx(r.Context())
+
+		cur, err := GetTerm(db, id)
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			util.WriteJSON(w, cur, http.StatusOK)
+
+		case http.MethodPut:
+			// Body as for POST; universityId, year and term are ignored.
+			var p termPayload
+			dec := json.NewDecoder(r.Body)
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&p); err != nil {
+				util.HTTPError(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			if !requireCurator(w, db, uid, cur.UniversityID) {
+				return
+			}
+			updated, err := UpdateTerm(db, id, p.term())
+			if err != nil {
+				util.WriteError(w, err)
+				return
+			}
+			util.WriteJSON(w, updated, http.StatusOK)
+
+		case http.MethodDelete:
+			if !requireCurator(w, db, uid, cur.UniversityID) {
+				return
+			}
+			if err := DeleteTerm(db, id); err != nil {
+				util.WriteError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
@@ -0,0 +1,394 @@
+package term
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+	_ "time/tzdata" // term timezones must resolve even without system zoneinfo
+
+	"example.com/sqlite-server/store"
+	"example.com/sqlite-server/util"
+)
+
+const dateLayout = "2006-01-02"
+
+// Errors returned by this package.
+var (
+	ErrInvalidDates        = util.NewError(http.StatusBadRequest, "invalid_dates", "invalid dates")
+	ErrInvalidTimezone     = util.NewError(http.StatusBadRequest, "invalid_timezone", "invalid timezone")
+	ErrBreakOutsideTerm    = util.NewError(http.StatusBadRequest, "break_outside_term", "break outside term")
+	ErrTermExists          = util.NewError(http.StatusConflict, "term_exists", "term already defined")
+	ErrUnknownTerm         = util.NewError(http.StatusBadRequest, "unknown_term", "the university has no such term")
+	ErrDeadlineOutsideTerm = util.NewError(http.StatusBadRequest, "deadline_outside_term", "deadline outside term")
+	ErrDeadlineInBreak     = util.NewError(http.StatusBadRequest, "deadline_in_break", "deadline during a break")
+)
+
+// Break is a reading week or holiday within a term.
+type Break struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	StartsOn string `json:"startsOn"`
+	EndsOn   string `json:"endsOn"`
+}
+
+// Term is one teaching period of a university. Courses belong to the term with
+// the same (universityId, year, term). Dates are inclusive calendar days in Timezone.
+type Term struct {
+	ID           int64   `json:"id"`
+	UniversityID string  `json:"universityId"`
+	Year         int64   `json:"year"`
+	Term         int64   `json:"term"` // 1..4, as courses.term
+	Name         string  `json:"name"`
+	StartsOn     string  `json:"startsOn"`
+	EndsOn       string  `json:"endsOn"`
+	Timezone     string  `json:"timezone"`
+	ArchivedAt   *int64  `json:"archivedAt,omitempty"`
+	Breaks       []Break `json:"breaks"`
+}
+
+// span returns [start, end) for inclusive calendar days startsOn..endsOn in loc.
+func span(startsOn, endsOn string, loc *time.Location) (time.Time, time.Time, error) {
+	s, err := time.ParseInLocation(dateLayout, startsOn, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	e, err := time.ParseInLocation(dateLayout, endsOn, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return s, e.AddDate(0, 0, 1), nil
+}
+
+// Window returns the instants the term starts and ends ([start, end)).
+func (t Term) Window() (time.Time, time.Time) {
+	loc, err := time.LoadLocation(t.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	s, e, _ := span(t.StartsOn, t.EndsOn, loc)
+	return s, e
+}
+
+// Contains reports whether at falls within the term.
+func (t Term) Contains(at time.Time) bool {
+	s, e := t.Window()
+	return !at.Before(s) && at.Before(e)
+}
+
+// BreakAt returns the break at falls in, if any.
+func (t Term) BreakAt(at time.Time) (Break, bool) {
+	loc, err := time.LoadLocation(t.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	for _, b := range t.Breaks {
+		s, e, err := span(b.StartsOn, b.EndsOn, loc)
+		if err == nil && !at.Before(s) && at.Before(e) {
+			return b, true
+		}
+	}
+	return Break{}, false
+}
+
+// validate normalises t and checks its dates, timezone and breaks.
+// Errors: util.ErrInvalidInput, ErrInvalidDates, ErrInvalidTimezone, ErrBreakOutsideTerm.
+func validate(t *Term) error {
+	t.Name = strings.TrimSpace(t.Name)
+	t.Timezone = strings.TrimSpace(t.Timezone)
+	if t.Timezone == "" {
+		t.Timezone = "UTC"
+	}
+	if t.Name == "" || t.Year <= 0 || t.Term < 1 || t.Term > 4 {
+		return util.ErrInvalidInput
+	}
+	loc, err := time.LoadLocation(t.Timezone)
+	if err != nil {
+		return ErrInvalidTimezone
+	}
+	start, end, err := span(t.StartsOn, t.EndsOn, loc)
+	if err != nil || !start.Before(end) {
+		return ErrInvalidDates
+	}
+	for i := range t.Breaks {
+		b := &t.Breaks[i]
+		b.Name = strings.TrimSpace(b.Name)
+		if b.Name == "" {
+			return util.ErrInvalidInput
+		}
+		bs, be, err := span(b.StartsOn, b.EndsOn, loc)
+		if err != nil || !bs.Before(be) {
+			return ErrInvalidDates
+		}
+		if bs.Before(start) || be.After(end) {
+			return ErrBreakOutsideTerm
+		}
+	}
+	return nil
+}
+
+// ListTerms returns a university's terms with their breaks, in date order.
+func ListTerms(db *sql.DB, universityID string) ([]Term, error) {
+	return queryTerms(db, `WHERE university_id = ?`, universityID)
+}
+
+// GetTerm loads one term. sql.ErrNoRows if missing.
+func GetTerm(db *sql.DB, id int64) (Term, error) {
+	list, err := queryTerms(db, `WHERE id = ?`, id)
+	if err != nil {
+		return Term{}, err
+	}
+	if len(list) == 0 {
+		return Term{}, sql.ErrNoRows
+	}
+	return list[0], nil
+}
+
+// TermFor returns the term a course in (universityID, year, term) belongs to.
+// sql.ErrNoRows if the university hasn't defined it.
+func TermFor(db *sql.DB, universityID string, year, term int64) (Term, error) {
+	list, err := queryTerms(db, `WHERE university_id = ? AND year = ? AND term = ?`, universityID, year, term)
+	if err != nil {
+		return Term{}, err
+	}
+	if len(list) == 0 {
+		return Term{}, sql.ErrNoRows
+	}
+	return list[0], nil
+}
+
+func queryTerms(db *sql.DB, where string, args ...any) ([]Term, error) {
+	rows, err := db.Query(`
+		SELECT id, university_id, year, term, name, starts_on, ends_on, timezone, archived_at
+		  FROM terms
+		`+where+`
+		 ORDER BY starts_on ASC, id ASC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Term, 0, 8)
+	for rows.Next() {
+		var t Term
+		var archived sql.NullInt64
+		if err := rows.Scan(&t.ID, &t.UniversityID, &t.Year, &t.Term, &t.Name, &t.StartsOn, &t.EndsOn,
+			&t.Timezone, &archived); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if archived.Valid {
+			t.ArchivedAt = &archived.Int64
+		}
+		t.Breaks = []Break{}
+		out = append(out, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range out {
+		brows, err := db.Query(`
+			SELECT id, name, starts_on, ends_on FROM term_breaks WHERE term_id = ? ORDER BY starts_on, id
+		`, out[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		for brows.Next() {
+			var b Break
+			if err := brows.Scan(&b.ID, &b.Name, &b.StartsOn, &b.EndsOn); err != nil {
+				brows.Close()
+				return nil, err
+			}
+			out[i].Breaks = append(out[i].Breaks, b)
+		}
+		brows.Close()
+		if err := brows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// CreateTerm adds a term (with its breaks) to t.UniversityID.
+// Errors: validation errors, ErrTermExists, sql.ErrNoRows (no such university).
+func CreateTerm(db *sql.DB, t Term) (Term, error) {
+	if err := validate(&t); err != nil {
+		return Term{}, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return Term{}, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		INSERT INTO terms (university_id, year, term, name, starts_on, ends_on, timezone)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, t.UniversityID, t.Year, t.Term, t.Name, t.StartsOn, t.EndsOn, t.Timezone)
+	if err != nil {
+		switch {
+		case store.IsUniqueViolation(err):
+			return Term{}, ErrTermExists
+		case store.IsForeignKeyViolation(err):
+			return Term{}, sql.ErrNoRows
+		}
+		return Term{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return Term{}, err
+	}
+	if err := insertBreaks(tx, id, t.Breaks); err != nil {
+		return Term{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Term{}, err
+	}
+	return GetTerm(db, id)
+}
+
+// UpdateTerm replaces a term's name, dates, timezone and breaks. Year and term
+// number are fixed, since courses are attached by them. sql.ErrNoRows if missing.
+func UpdateTerm(db *sql.DB, id int64, t Term) (Term, error) {
+	cur, err := GetTerm(db, id)
+	if err != nil {
+		return Term{}, err
+	}
+	t.UniversityID, t.Year, t.Term = cur.UniversityID, cur.Year, cur.Term
+	if err := validate(&t); err != nil {
+		return Term{}, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return Term{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE terms SET name = ?, starts_on = ?, ends_on = ?, timezone = ? WHERE id = ?
+	`, t.Name, t.StartsOn, t.EndsOn, t.Timezone, id); err != nil {
+		return Term{}, err
+	}
+	if _, err := tx.Exec(`DELETE FROM term_breaks WHERE term_id = ?`, id); err != nil {
+		return Term{}, err
+	}
+	if err := insertBreaks(tx, id, t.Breaks); err != nil {
+		return Term{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Term{}, err
+	}
+	return GetTerm(db, id)
+}
+
+func insertBreaks(tx *sql.Tx, termID int64, breaks []Break) error {
+	for _, b := range breaks {
+		if _, err := tx.Exec(`
+			INSERT INTO term_breaks (term_id, name, starts_on, ends_on) VALUES (?, ?, ?, ?)
+		`, termID, b.Name, b.StartsOn, b.EndsOn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteTerm removes a term. Its courses keep their year/term but lose the dates.
+// sql.ErrNoRows if missing.
+func DeleteTerm(db *sql.DB, id int64) error {
+	res, err := db.Exec(`DELETE FROM terms WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CheckCourseTerm is used when creating courses: once a university defines terms,
+// courses must be in one of them (ErrUnknownTerm). Universities without terms
+// accept any year/term.
+func CheckCourseTerm(db *sql.DB, universityID string, year, term int64) error {
+	var defined, match int64
+	if err := db.QueryRow(`
+		SELECT COUNT(1), COALESCE(SUM(year = ? AND term = ?), 0) FROM terms WHERE university_id = ?
+	`, year, term, universityID).Scan(&defined, &match); err != nil {
+		return err
+	}
+	if defined > 0 && match == 0 {
+		return ErrUnknownTerm
+	}
+	return nil
+}
+
+// CheckDeadline validates a deadline for a course against its term: it must
+// fall within the term and not in a break. Courses without a defined term accept
+// anything. Errors: ErrDeadlineOutsideTerm, ErrDeadlineInBreak (naming the break).
+func CheckDeadline(db *sql.DB, courseID, deadline int64) error {
+	var uniID string
+	var year, term int64
+	if err := db.QueryRow(`
+		SELECT university_id, year, term FROM courses WHERE id = ?
+	`, courseID).Scan(&uniID, &year, &term); err != nil {
+		return err
+	}
+	t, err := TermFor(db, uniID, year, term)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return t.CheckDeadline(deadline)
+}
+
+// CheckDeadline is CheckDeadline for a course in t.
+func (t Term) CheckDeadline(deadline int64) error {
+	at := time.Unix(deadline, 0)
+	if !t.Contains(at) {
+		return ErrDeadlineOutsideTerm
+	}
+	if b, ok := t.BreakAt(at); ok {
+		return ErrDeadlineInBreak.WithMessage("deadline during " + b.Name)
+	}
+	return nil
+}
+
+// ArchiveAfter is how long after a term ends its courses are archived; serve
+// sets it from TERM_ARCHIVE_AFTER.
+var ArchiveAfter = 14 * 24 * time.Hour
+
+// ArchiveEndedTerms archives terms that ended more than ArchiveAfter ago, along
+// with their courses. Each term is archived once, so a course restored later
+// stays restored. Returns the number of courses archived.
+func ArchiveEndedTerms(db *sql.DB, now time.Time) (int64, error) {
+	// ends_on is a local date; the extra day covers any timezone.
+	cutoff := now.Add(-ArchiveAfter).AddDate(0, 0, -1).UTC().Format(dateLayout)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE courses SET archived_at = ?
+		 WHERE archived_at IS NULL
+		   AND EXISTS (SELECT 1 FROM terms t
+		                WHERE t.university_id = courses.university_id
+		                  AND t.year = courses.year AND t.term = courses.term
+		                  AND t.archived_at IS NULL AND t.ends_on < ?)
+	`, now.Unix(), cutoff)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	if _, err := tx.Exec(`
+		UPDATE terms SET archived_at = ? WHERE archived_at IS NULL AND ends_on < ?
+	`, now.Unix(), cutoff); err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
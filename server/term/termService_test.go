@@ -0,0 +1,153 @@
+package term
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"example.com/sqlite-server/store"
+	"example.com/sqlite-server/util"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`INSERT INTO universities (id, name) VALUES ('uni', 'Uni')`); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func spring(breaks ...Break) Term {
+	return Term{UniversityID: "uni", Year: 2026, Term: 1, Name: " Spring ", StartsOn: "2026-01-12", EndsOn: "2026-05-01",
+		Timezone: "Europe/Zurich", Breaks: breaks}
+}
+
+func TestCreateTermValidates(t *testing.T) {
+	db := openTestDB(t)
+	for _, c := range []struct {
+		name string
+		edit func(*Term)
+		want error
+	}{
+		{"no name", func(t *Term) { t.Name = " " }, util.ErrInvalidInput},
+		{"term 5", func(t *Term) { t.Term = 5 }, util.ErrInvalidInput},
+		{"bad zone", func(t *Term) { t.Timezone = "Mars/Olympus" }, ErrInvalidTimezone},
+		{"bad date", func(t *Term) { t.EndsOn = "2026-02-30" }, ErrInvalidDates},
+		{"ends first", func(t *Term) { t.EndsOn = "2026-01-11" }, ErrInvalidDates},
+		{"break outside", func(t *Term) {
+			t.Breaks = []Break{{Name: "Easter", StartsOn: "2026-04-28", EndsOn: "2026-05-04"}}
+		}, ErrBreakOutsideTerm},
+		{"unknown university", func(t *Term) { t.UniversityID = "nope" }, sql.ErrNoRows},
+	} {
+		tm := spring()
+		c.edit(&tm)
+		if _, err := CreateTerm(db, tm); !errors.Is(err, c.want) {
+			t.Errorf("%s: %v, want %v", c.name, err, c.want)
+		}
+	}
+
+	tm, err := CreateTerm(db, spring(Break{Name: "Easter", StartsOn: "2026-04-03", EndsOn: "2026-04-10"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tm.ID == 0 || tm.Name != "Spring" || len(tm.Breaks) != 1 || tm.Breaks[0].ID == 0 {
+		t.Fatalf("created %+v", tm)
+	}
+	if _, err := CreateTerm(db, spring()); !errors.Is(err, ErrTermExists) {
+		t.Fatalf("second spring term: %v", err)
+	}
+}
+
+func TestCheckDeadline(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := CreateTerm(db, spring(Break{Name: "Easter", StartsOn: "2026-04-03", EndsOn: "2026-04-10"})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`
+		INSERT INTO courses (id, university_id, year, term, code, name) VALUES
+		  (1, 'uni', 2026, 1, 'A', 'A'), (2, 'uni', 2026, 3, 'B', 'B')`); err != nil {
+		t.Fatal(err)
+	}
+	zurich, _ := time.LoadLocation("Europe/Zurich")
+	at := func(s string) int64 {
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, zurich)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v.Unix()
+	}
+
+	for _, c := range []struct {
+		course   int64
+		deadline string
+		want     error
+	}{
+		{1, "2026-01-12 00:00", nil},                    // first day, local midnight
+		{1, "2026-01-11 23:59", ErrDeadlineOutsideTerm}, // the evening before
+		{1, "2026-05-01 23:59", nil},                    // the last day counts in full
+		{1, "2026-05-02 00:00", ErrDeadlineOutsideTerm},
+		{1, "2026-04-06 12:00", ErrDeadlineInBreak},
+		{1, "2026-04-11 09:00", nil},
+		{2, "2030-01-01 00:00", nil}, // no term defined for course 2
+	} {
+		err := CheckDeadline(db, c.course, at(c.deadline))
+		if !errors.Is(err, c.want) {
+			t.Errorf("course %d, %s: %v, want %v", c.course, c.deadline, err, c.want)
+		}
+	}
+	if err := CheckDeadline(db, 1, at("2026-04-06 12:00")); err.Error() != "deadline during Easter" {
+		t.Errorf("break error does not name the break: %q", err)
+	}
+
+	if err := CheckCourseTerm(db, "uni", 2026, 3); !errors.Is(err, ErrUnknownTerm) {
+		t.Errorf("course outside the defined terms: %v", err)
+	}
+	if err := CheckCourseTerm(db, "other", 2026, 3); err != nil {
+		t.Errorf("university without terms: %v", err)
+	}
+}
+
+func TestArchiveEndedTerms(t *testing.T) {
+	db := openTestDB(t)
+	saved := ArchiveAfter
+	defer func() { ArchiveAfter = saved }()
+	ArchiveAfter = 14 * 24 * time.Hour
+
+	if _, err := CreateTerm(db, spring()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO courses (id, university_id, year, term, code, name) VALUES (1, 'uni', 2026, 1, 'A', 'A')`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Term ended 2026-05-01; not yet two weeks on.
+	if n, err := ArchiveEndedTerms(db, time.Date(2026, 5, 10, 0, 0, 0, 0, time.UTC)); err != nil || n != 0 {
+		t.Fatalf("early run archived %d (%v)", n, err)
+	}
+	now := time.Date(2026, 5, 20, 0, 0, 0, 0, time.UTC)
+	if n, err := ArchiveEndedTerms(db, now); err != nil || n != 1 {
+		t.Fatalf("archived %d (%v), want 1", n, err)
+	}
+	tm, err := TermFor(db, "uni", 2026, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tm.ArchivedAt == nil || *tm.ArchivedAt != now.Unix() {
+		t.Fatalf("term archived at %v", tm.ArchivedAt)
+	}
+
+	// A course restored afterwards stays restored.
+	if _, err := db.Exec(`UPDATE courses SET archived_at = NULL WHERE id = 1`); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := ArchiveEndedTerms(db, now.Add(24*time.Hour)); err != nil || n != 0 {
+		t.Fatalf("second run archived %d (%v)", n, err)
+	}
+}
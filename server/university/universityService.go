@@ -2,73 +2,307 @@ package university
 
 import (
 	"database/sql"
-	"errors"
+	"net/http"
 	"strings"
+
+	"example.com/sqlite-server/invite"
+	"example.com/sqlite-server/store"
+	"example.com/sqlite-server/util"
+)
+
+// Moderation states. New universities start pending unless created by an admin.
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+// Errors returned by this package.
+var (
+	ErrInvalidName   = util.NewError(http.StatusBadRequest, "invalid_name", "invalid name")
+	ErrDuplicateName = util.NewError(http.StatusConflict, "duplicate_name", "university name already exists")
+	ErrNameRejected  = util.NewError(http.StatusConflict, "name_rejected", "a university with this name was rejected")
+	ErrInvalidStatus = util.NewError(http.StatusBadRequest, "invalid_status", "invalid status")
+	ErrNotPending    = util.NewError(http.StatusConflict, "not_pending", "university is not pending review")
+	ErrHasCourses    = util.NewError(http.StatusConflict, "university_has_courses", "university has courses")
 )
 
 type University struct {
-  ID        string `json:"id"`
-  Name      string `json:"name"`
-  CreatedAt int64  `json:"created_at"`
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	CreatedAt  int64  `json:"created_at"`
+	Status     string `json:"status"`
+	ReviewNote string `json:"reviewNote,omitempty"`
+	JoinPolicy string `json:"joinPolicy"`
+}
+
+// ReviewItem is a university as seen in the admin moderation queue.
+type ReviewItem struct {
+	University
+	CreatedByEmail string `json:"createdByEmail,omitempty"`
+	ReviewedAt     *int64 `json:"reviewed_at,omitempty"`
+}
+
+func isAdmin(db *sql.DB, userID string) (bool, error) {
+	var x int
+	err := db.QueryRow(`SELECT 1 FROM admins WHERE user_id = ?`, userID).Scan(&x)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
 }
 
-func AddUniversity(db *sql.DB, id, name string) (University, error) {
-  _, err := db.Exec(`
-    INSERT INTO universities (id, name)
-    VALUES (?, ?)
-  `, id, name)
-  if err != nil {
-    return University{}, err
-  }
-
-  var u University
-  err = db.QueryRow(`
-    SELECT id, name, created_at
+// AddUniversity creates a university owned by createdBy. It is approved right
+// away for admins and pending review otherwise. Names are compared with
+// util.FoldName, so "Universität Zürich" duplicates "universitat zurich".
+// Errors: ErrInvalidName, ErrDuplicateName (an existing pending/approved one), ErrNameRejected.
+func AddUniversity(db *sql.DB, id, name, createdBy string) (University, error) {
+	key := util.FoldName(name)
+	if key == "" {
+		return University{}, ErrInvalidName
+	}
+
+	admin, err := isAdmin(db, createdBy)
+	if err != nil {
+		return University{}, err
+	}
+	status := StatusPending
+	if admin {
+		status = StatusApproved
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return University{}, err
+	}
+	defer tx.Rollback()
+
+	var existing string
+	err = tx.QueryRow(`
+    SELECT status FROM universities WHERE name_key = ? OR name = ?
+     ORDER BY status = 'rejected' ASC
+     LIMIT 1
+  `, key, name).Scan(&existing)
+	switch {
+	case err == nil && existing == StatusRejected:
+		return University{}, ErrNameRejected
+	case err == nil:
+		return University{}, ErrDuplicateName
+	case err != sql.ErrNoRows:
+		return University{}, err
+	}
+
+	var reviewedBy any
+	if admin {
+		reviewedBy = createdBy
+	}
+	if _, err := tx.Exec(`
+    INSERT INTO universities (id, name, name_key, status, created_by, reviewed_by, reviewed_at)
+    VALUES (?, ?, ?, ?, ?, ?, CASE WHEN ? IS NULL THEN NULL ELSE strftime('%s','now') END)
+  `, id, name, key, status, createdBy, reviewedBy, reviewedBy); err != nil {
+		if store.IsUniqueViolation(err) {
+			return University{}, ErrDuplicateName
+		}
+		return University{}, err
+	}
+
+	var u University
+	err = tx.QueryRow(`
+    SELECT id, name, created_at, status, join_policy
       FROM universities
      WHERE id = ?
-  `, id).Scan(&u.ID, &u.Name, &u.CreatedAt)
-  if err != nil {
-    return University{}, err
-  }
-  return u, nil
+  `, id).Scan(&u.ID, &u.Name, &u.CreatedAt, &u.Status, &u.JoinPolicy)
+	if err != nil {
+		return University{}, err
+	}
+	if admin {
+		if err := addOwner(tx, id, createdBy); err != nil {
+			return University{}, err
+		}
+	}
+	return u, tx.Commit()
 }
 
-func ListUniversities(db *sql.DB) ([]University, error) {
-  rows, err := db.Query(`
-    SELECT id, name, created_at
+// universitySorts are the orders GET /universities accepts; the first is the default.
+var universitySorts = []util.Sort{
+	{Name: "name", Keys: []string{"name", "id"}},
+	{Name: "created", Keys: []string{"created_at", "id"}},
+}
+
+// ListUniversities returns a page of approved universities (the public
+// directory), optionally only those whose name contains search (compared
+// with util.FoldName).
+func ListUniversities(db *sql.DB, search string, q util.PageQuery) (util.Page[University], error) {
+	after, args := q.After()
+	list, err := queryUniversities(db, `
+    SELECT id, name, created_at, status, COALESCE(review_note, ''), join_policy
       FROM universities
-     ORDER BY name ASC
-  `)
-  if err != nil {
-    return nil, err
-  }
-  defer rows.Close()
-
-  out := make([]University, 0, 64)
-  for rows.Next() {
-    var u University
-    if err := rows.Scan(&u.ID, &u.Name, &u.CreatedAt); err != nil {
-      return nil, err
-    }
-    out = append(out, u)
-  }
-  return out, rows.Err()
+     WHERE status = 'approved'
+       AND (? = '' OR name_key LIKE '%' || ? || '%')
+       AND `+after+`
+     ORDER BY `+q.OrderBy()+`
+     LIMIT ?
+  `, append(append([]any{util.FoldName(search), util.FoldName(search)}, args...), q.FetchLimit())...)
+	if err != nil {
+		return util.Page[University]{}, err
+	}
+	return util.NewPage(list, q, func(u University) []any {
+		if q.Sort.Name == "created" {
+			return []any{u.CreatedAt, u.ID}
+		}
+		return []any{u.Name, u.ID}
+	}), nil
+}
+
+// ListOwnUniversities returns the universities userID created, in any state,
+// so they can follow their submissions.
+func ListOwnUniversities(db *sql.DB, userID string) ([]University, error) {
+	return queryUniversities(db, `
+    SELECT id, name, created_at, status, COALESCE(review_note, ''), join_policy
+      FROM universities
+     WHERE created_by = ?
+     ORDER BY created_at DESC, name ASC
+  `, userID)
+}
+
+func queryUniversities(db *sql.DB, query string, args ...any) ([]University, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]University, 0, 64)
+	for rows.Next() {
+		var u University
+		if err := rows.Scan(&u.ID, &u.Name, &u.CreatedAt, &u.Status, &u.ReviewNote, &u.JoinPolicy); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// ListForReview returns universities in the given status with their creator,
+// oldest first (the moderation queue).
+func ListForReview(db *sql.DB, status string) ([]ReviewItem, error) {
+	switch status {
+	case StatusPending, StatusApproved, StatusRejected:
+	default:
+		return nil, ErrInvalidStatus
+	}
+	rows, err := db.Query(`
+    SELECT un.id, un.name, un.created_at, un.status, COALESCE(un.review_note, ''), un.join_policy,
+           COALESCE(us.email, ''), un.reviewed_at
+      FROM universities un
+      LEFT JOIN users us ON us.id = un.created_by
+     WHERE un.status = ?
+     ORDER BY un.created_at ASC, un.name ASC
+  `, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]ReviewItem, 0, 16)
+	for rows.Next() {
+		var it ReviewItem
+		var reviewed sql.NullInt64
+		if err := rows.Scan(&it.ID, &it.Name, &it.CreatedAt, &it.Status, &it.ReviewNote, &it.JoinPolicy,
+			&it.CreatedByEmail, &reviewed); err != nil {
+			return nil, err
+		}
+		if reviewed.Valid {
+			v := reviewed.Int64
+			it.ReviewedAt = &v
+		}
+		out = append(out, it)
+	}
+	return out, rows.Err()
 }
 
-func DeleteUniversityIfNoCourses(db *sql.DB, id string) (bool, error) {
+// ReviewUniversity approves or rejects a pending university. Approval makes its
+// creator the owner (a member who curates it).
+// Errors: sql.ErrNoRows (no such university), ErrNotPending.
+func ReviewUniversity(db *sql.DB, id, reviewerID string, approve bool, note string) error {
+	status := StatusRejected
+	if approve {
+		status = StatusApproved
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+    UPDATE universities
+       SET status = ?, reviewed_by = ?, reviewed_at = strftime('%s','now'), review_note = ?
+     WHERE id = ? AND status = 'pending'
+  `, status, reviewerID, nullIfEmpty(strings.TrimSpace(note)), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		var x int
+		if err := tx.QueryRow(`SELECT 1 FROM universities WHERE id = ?`, id).Scan(&x); err != nil {
+			return err
+		}
+		return ErrNotPending
+	}
+
+	if approve {
+		var createdBy sql.NullString
+		if err := tx.QueryRow(`SELECT created_by FROM universities WHERE id = ?`, id).Scan(&createdBy); err != nil {
+			return err
+		}
+		if createdBy.Valid {
+			if err := addOwner(tx, id, createdBy.String); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// addOwner makes userID the owner of universityID, upgrading an existing membership.
+func addOwner(tx *sql.Tx, universityID, userID string) error {
+	_, err := tx.Exec(`
+    INSERT INTO user_universities (user_id, university_id, role) VALUES (?, ?, 'owner')
+    ON CONFLICT (user_id, university_id) DO UPDATE SET role = 'owner'
+  `, userID, universityID)
+	return err
+}
+
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// DeleteUniversityIfNoCourses deletes a university on behalf of userID, who must
+// be one of its owners or an admin. Universities with courses can't be deleted.
+// Errors: sql.ErrNoRows, util.ErrInvalidInput, util.ErrForbidden, ErrHasCourses.
+func DeleteUniversityIfNoCourses(db *sql.DB, id, userID string) (bool, error) {
 	id = strings.TrimSpace(id)
 	if id == "" {
-		return false, errors.New("invalid input")
+		return false, util.ErrInvalidInput
 	}
 
-	// Ensure the university exists.
-	var uid string
-	if err := db.QueryRow(`SELECT id FROM universities WHERE id = ?`, id).Scan(&uid); err != nil {
-		if err == sql.ErrNoRows {
-			return false, sql.ErrNoRows
-		}
+	// Ensure the university exists, and that the caller may delete it.
+	var x int
+	if err := db.QueryRow(`SELECT 1 FROM universities WHERE id = ?`, id).Scan(&x); err != nil {
 		return false, err
 	}
+	owner, err := invite.IsUniversityOwner(db, userID, id)
+	if err != nil {
+		return false, err
+	}
+	if !owner {
+		return false, util.ErrForbidden
+	}
 
 	// Check for any courses under it.
 	var cnt int64
@@ -76,7 +310,7 @@ func DeleteUniversityIfNoCourses(db *sql.DB, id string) (bool, error) {
 		return false, err
 	}
 	if cnt > 0 {
-		return false, errors.New("university has courses")
+		return false, ErrHasCourses
 	}
 
 	// Safe to delete.
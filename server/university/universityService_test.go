@@ -0,0 +1,139 @@
+package university
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"example.com/sqlite-server/store"
+	"example.com/sqlite-server/util"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, q := range []string{
+		`INSERT INTO users (id, email, password) VALUES ('u1', 'a@x.io', ''), ('u2', 'b@x.io', ''), ('root', 'root@x.io', '')`,
+		`INSERT INTO admins (user_id) VALUES ('root')`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+	return db
+}
+
+func role(t *testing.T, db *sql.DB, userID, universityID string) string {
+	t.Helper()
+	var r string
+	err := db.QueryRow(`SELECT role FROM user_universities WHERE user_id = ? AND university_id = ?`, userID, universityID).Scan(&r)
+	if err != nil && err != sql.ErrNoRows {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestUniversityModeration(t *testing.T) {
+	db := openTestDB(t)
+	firstPage := util.PageQuery{Sort: universitySorts[0], Limit: 50}
+
+	u, err := AddUniversity(db, "zh", "Universität Zürich", "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Status != StatusPending || role(t, db, "u1", "zh") != "" {
+		t.Fatalf("user submission: %+v, role %q", u, role(t, db, "u1", "zh"))
+	}
+	if _, err := AddUniversity(db, "zh2", "  universitat ZURICH ", "u2"); !errors.Is(err, ErrDuplicateName) {
+		t.Fatalf("folded duplicate: %v", err)
+	}
+	if _, err := AddUniversity(db, "blank", " \t", "u2"); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("blank name: %v", err)
+	}
+	if page, err := ListUniversities(db, "", firstPage); err != nil || len(page.Items) != 0 {
+		t.Fatalf("directory lists pending universities: %+v (%v)", page.Items, err)
+	}
+
+	queue, err := ListForReview(db, StatusPending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue) != 1 || queue[0].ID != "zh" || queue[0].CreatedByEmail != "a@x.io" {
+		t.Fatalf("queue = %+v", queue)
+	}
+
+	if err := ReviewUniversity(db, "zh", "root", true, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReviewUniversity(db, "zh", "root", false, "too late"); !errors.Is(err, ErrNotPending) {
+		t.Fatalf("second review: %v", err)
+	}
+	if err := ReviewUniversity(db, "nope", "root", true, ""); err != sql.ErrNoRows {
+		t.Fatalf("review of a missing university: %v", err)
+	}
+	if role(t, db, "u1", "zh") != "owner" {
+		t.Fatal("approval did not make the creator the owner")
+	}
+	page, err := ListUniversities(db, "zurich", firstPage)
+	if err != nil || len(page.Items) != 1 || page.Items[0].ID != "zh" {
+		t.Fatalf("search after approval: %+v (%v)", page.Items, err)
+	}
+
+	// A rejected name can't be submitted again.
+	if _, err := AddUniversity(db, "spam", "Buy Cheap Watches", "u2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReviewUniversity(db, "spam", "root", false, "spam"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddUniversity(db, "spam2", "buy cheap watches", "u2"); !errors.Is(err, ErrNameRejected) {
+		t.Fatalf("resubmitting a rejected name: %v", err)
+	}
+	own, err := ListOwnUniversities(db, "u2")
+	if err != nil || len(own) != 1 || own[0].Status != StatusRejected || own[0].ReviewNote != "spam" {
+		t.Fatalf("u2's submissions: %+v (%v)", own, err)
+	}
+
+	// Admins skip the queue.
+	a, err := AddUniversity(db, "eth", "ETH", "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Status != StatusApproved || role(t, db, "root", "eth") != "owner" {
+		t.Fatalf("admin submission: %+v, role %q", a, role(t, db, "root", "eth"))
+	}
+}
+
+func TestDeleteUniversityNeedsOwner(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := AddUniversity(db, "zh", "Zurich", "root"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AddUniversity(db, "bs", "Basel", "root"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO user_universities (user_id, university_id, role) VALUES ('u1', 'zh', 'owner'), ('u2', 'zh', 'member')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO courses (university_id, year, term, code, name) VALUES ('bs', 2026, 1, 'X', 'X')`); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := DeleteUniversityIfNoCourses(db, "zh", "u2"); !errors.Is(err, util.ErrForbidden) {
+		t.Fatalf("member deleting: %v", err)
+	}
+	if _, err := DeleteUniversityIfNoCourses(db, "bs", "root"); !errors.Is(err, ErrHasCourses) {
+		t.Fatalf("deleting a university with courses: %v", err)
+	}
+	if ok, err := DeleteUniversityIfNoCourses(db, "zh", "u1"); err != nil || !ok {
+		t.Fatalf("owner deleting: %v, %v", ok, err)
+	}
+	if _, err := DeleteUniversityIfNoCourses(db, "zh", "u1"); err != sql.ErrNoRows {
+		t.Fatalf("deleting twice: %v", err)
+	}
+}
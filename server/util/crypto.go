@@ -0,0 +1,14 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// HashToken returns the hex SHA-256 of a high-entropy bearer secret (session IDs,
+// calendar tokens). Tables store only this digest, so a copy of the database
+// can't be replayed as credentials. Not suitable for passwords (use bcrypt).
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
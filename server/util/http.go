@@ -2,40 +2,151 @@ package util
 
 import (
 	"encoding/json"
-  "net/http"
-  "net/mail"
-  "os"
-  "strconv"
-  "strings"
+	"net"
+	"net/http"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
 )
 
+// Router is what packages register their routes on. *http.ServeMux implements
+// it; the OpenAPI contract test wraps one to record the registered patterns.
+type Router interface {
+	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
+}
+
 func WriteJSON(w http.ResponseWriter, v any, status int) {
-  w.Header().Set("Content-Type", "application/json; charset=utf-8")
-  w.WriteHeader(status)
-  enc := json.NewEncoder(w)
-  enc.SetIndent("", "  ")
-  _ = enc.Encode(v)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
 }
 
 func VerifyEmail(s string) bool {
-  _, err := mail.ParseAddress(s)
-  return err == nil
+	_, err := mail.ParseAddress(s)
+	return err == nil
 }
 
 func IsProd() bool {
-  v := strings.ToLower(strings.TrimSpace(os.Getenv("ENV")))
-  return v == "prod"
+	v := strings.ToLower(strings.TrimSpace(os.Getenv("ENV")))
+	return v == "prod"
 }
 
+// Cookie settings, set from the server config at startup. SecureCookies marks
+// cookies Secure and gives the session cookie the __Host- prefix;
+// CookieSameSite applies to the session and CSRF cookies.
+var (
+	SecureCookies  = IsProd()
+	CookieSameSite = http.SameSiteStrictMode
+)
+
 // ParseInt64Query extracts and parses a positive int64 query parameter.
 func ParseInt64Query(r *http.Request, key string) (int64, error) {
-  v := strings.TrimSpace(r.URL.Query().Get(key))
-  if v == "" {
-    return 0, strconv.ErrSyntax
-  }
-  n, err := strconv.ParseInt(v, 10, 64)
-  if err != nil {
-    return 0, err
-  }
-  return n, nil
+	v := strings.TrimSpace(r.URL.Query().Get(key))
+	if v == "" {
+		return 0, strconv.ErrSyntax
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// OptionalInt64Query parses an optional int64 query parameter; nil if absent.
+func OptionalInt64Query(r *http.Request, key string) (*int64, error) {
+	v := strings.TrimSpace(r.URL.Query().Get(key))
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// OptionalBoolQuery parses an optional true/false query parameter; nil if absent.
+func OptionalBoolQuery(r *http.Request, key string) (*bool, error) {
+	v := strings.TrimSpace(r.URL.Query().Get(key))
+	if v == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// TrustedProxies are the networks of reverse proxies whose X-Forwarded-For
+// header is believed, set from the server config (TRUSTED_PROXIES). Empty by
+// default, so the header, which any client can send, is ignored.
+var TrustedProxies []*net.IPNet
+
+// ParseCIDRs parses a comma-separated list of networks; a bare address is a
+// network of one.
+func ParseCIDRs(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, &net.ParseError{Type: "IP address", Text: s}
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func trustedProxy(ip net.IP) bool {
+	for _, n := range TrustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the client address: the host part of RemoteAddr, unless
+// that is a trusted proxy, in which case X-Forwarded-For is walked from the
+// right (the hop the proxy appended) to the first address that isn't one.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip := net.ParseIP(host); ip == nil || !trustedProxy(ip) {
+		return host
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		ip := net.ParseIP(hop)
+		if ip == nil {
+			break // garbage from the client; stop at the last hop we can vouch for
+		}
+		host = hop
+		if !trustedProxy(ip) {
+			break
+		}
+	}
+	return host
 }
@@ -0,0 +1,47 @@
+package util
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	saved := TrustedProxies
+	defer func() { TrustedProxies = saved }()
+
+	cases := []struct {
+		trusted, remote, xff, want string
+	}{
+		// Without trusted proxies the header is ignored.
+		{"", "203.0.113.7:4000", "198.51.100.1", "203.0.113.7"},
+		// From a trusted proxy, the hop it appended is the client.
+		{"10.0.0.0/8", "10.0.0.2:4000", "198.51.100.1", "198.51.100.1"},
+		// A client can prepend anything; only the proxy's own hop counts.
+		{"10.0.0.0/8", "10.0.0.2:4000", "192.0.2.66, 198.51.100.1", "198.51.100.1"},
+		// Chained trusted proxies are skipped.
+		{"10.0.0.0/8", "10.0.0.2:4000", "198.51.100.1, 10.0.0.9", "198.51.100.1"},
+		// Garbage stops the walk at the last hop that could be vouched for.
+		{"10.0.0.0/8", "10.0.0.2:4000", "198.51.100.1, not-an-ip, 10.0.0.9", "10.0.0.9"},
+		// A direct client claiming to be forwarded is not believed.
+		{"10.0.0.2", "203.0.113.7:4000", "198.51.100.1", "203.0.113.7"},
+	}
+	for _, c := range cases {
+		nets, err := ParseCIDRs(c.trusted)
+		if err != nil {
+			t.Fatalf("%q: %v", c.trusted, err)
+		}
+		TrustedProxies = nets
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = c.remote
+		if c.xff != "" {
+			r.Header.Set("X-Forwarded-For", c.xff)
+		}
+		if got := ClientIP(r); got != c.want {
+			t.Errorf("trusted %q, from %s, X-Forwarded-For %q: %s, want %s", c.trusted, c.remote, c.xff, got, c.want)
+		}
+	}
+
+	if _, err := ParseCIDRs("10.0.0.0/8, nonsense"); err == nil {
+		t.Error("ParseCIDRs accepted nonsense")
+	}
+}
@@ -0,0 +1,67 @@
+package util
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is the dial error of a PublicClient refusing an address.
+var ErrPrivateAddress = errors.New("address is not public")
+
+// cgnat is the shared address space carriers NAT behind (RFC 6598), which
+// net.IP.IsPrivate doesn't cover.
+var cgnat = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// PublicIP reports whether ip is a public unicast address: not loopback,
+// private, carrier-grade NAT, unspecified, link-local or multicast.
+func PublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || cgnat.Contains(ip) || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// PrivateHost reports whether a URL's host is plainly not public: an IP
+// literal that isn't PublicIP, or localhost. Other names are only checked
+// when dialled.
+func PrivateHost(host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return !PublicIP(ip)
+	}
+	return host == "localhost" || strings.HasSuffix(host, ".localhost")
+}
+
+// PublicClient returns a client for calling URLs that users supply. It never
+// follows redirects (a 3xx is the response), bypasses HTTP proxies, and
+// refuses non-public addresses unless allowPrivate reports true, checking the
+// address actually dialled so a name can't resolve its way around the rule.
+func PublicClient(timeout time.Duration, allowPrivate func() bool) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: 5 * time.Second,
+				Control: func(network, address string, _ syscall.RawConn) error {
+					if allowPrivate() {
+						return nil
+					}
+					host, _, err := net.SplitHostPort(address)
+					if err != nil {
+						return err
+					}
+					if ip := net.ParseIP(host); ip == nil || !PublicIP(ip) {
+						return ErrPrivateAddress
+					}
+					return nil
+				},
+			}).DialContext,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}
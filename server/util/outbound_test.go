@@ -0,0 +1,58 @@
+package util
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPrivateHost(t *testing.T) {
+	for host, want := range map[string]bool{
+		"example.com":       false,
+		"93.184.216.34":     false,
+		"2606:4700::1111":   false,
+		"100.63.255.255":    false,
+		"100.128.0.1":       false,
+		"localhost":         true,
+		"app.localhost":     true,
+		"127.0.0.1":         true,
+		"10.1.2.3":          true,
+		"192.168.0.1":       true,
+		"169.254.169.254":   true,
+		"100.64.0.1":        true, // carrier-grade NAT
+		"100.127.255.254":   true,
+		"::1":               true,
+		"::ffff:10.0.0.1":   true,
+		"::ffff:100.64.1.1": true,
+		"fd00::1":           true,
+		"0.0.0.0":           true,
+	} {
+		if got := PrivateHost(host); got != want {
+			t.Errorf("PrivateHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestPublicClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/elsewhere", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	allow := false
+	c := PublicClient(time.Second, func() bool { return allow })
+	if _, err := c.Get(srv.URL); !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("loopback: %v, want ErrPrivateAddress", err)
+	}
+	allow = true
+	res, err := c.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusFound {
+		t.Errorf("status %d, want the redirect itself", res.StatusCode)
+	}
+}
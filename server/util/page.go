@@ -0,0 +1,199 @@
+package util
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Page sizes for list endpoints.
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
+// Errors from ParsePageQuery and ParseItemFilter.
+var (
+	ErrInvalidLimit  = NewError(http.StatusBadRequest, "invalid_limit", "invalid limit")
+	ErrInvalidSort   = NewError(http.StatusBadRequest, "invalid_sort", "invalid sort")
+	ErrInvalidCursor = NewError(http.StatusBadRequest, "invalid_cursor", "invalid cursor")
+	ErrInvalidFilter = NewError(http.StatusBadRequest, "invalid_filter", "invalid filter")
+)
+
+// Page is the envelope every paginated list endpoint returns. NextCursor is
+// set when there are more items; pass it back as ?cursor= to get them.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// Sort is one way a list can be ordered. Keys are the SQL expressions it
+// orders by, most significant first; the last must be unique per row (an id)
+// so positions are unambiguous, and none may be NULL.
+type Sort struct {
+	Name string
+	Keys []string
+}
+
+// PageQuery is a parsed ?limit=&cursor=&sort= request.
+type PageQuery struct {
+	Limit int
+	Sort  Sort
+	Desc  bool
+	after []any // sort key values of the last row already seen
+}
+
+type cursor struct {
+	Sort string `json:"s"`
+	Keys []any  `json:"k"`
+}
+
+// ParsePageQuery reads limit, cursor and sort from r. sort is a name from
+// sorts, prefixed with "-" for descending; it defaults to sorts[0], ascending.
+// A cursor only continues the sort it was issued for.
+// Errors: ErrInvalidLimit, ErrInvalidSort, ErrInvalidCursor.
+func ParsePageQuery(r *http.Request, sorts ...Sort) (PageQuery, error) {
+	qs := r.URL.Query()
+	q := PageQuery{Limit: DefaultPageLimit, Sort: sorts[0]}
+
+	if s := strings.TrimSpace(qs.Get("limit")); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return q, ErrInvalidLimit
+		}
+		q.Limit = min(n, MaxPageLimit)
+	}
+
+	if s := strings.TrimSpace(qs.Get("sort")); s != "" {
+		name, desc := strings.CutPrefix(s, "-")
+		found := false
+		for _, st := range sorts {
+			if st.Name == name {
+				q.Sort, q.Desc, found = st, desc, true
+				break
+			}
+		}
+		if !found {
+			return q, ErrInvalidSort
+		}
+	}
+
+	if s := strings.TrimSpace(qs.Get("cursor")); s != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			return q, ErrInvalidCursor
+		}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		var c cursor
+		if err := dec.Decode(&c); err != nil || c.Sort != q.sortParam() || len(c.Keys) != len(q.Sort.Keys) {
+			return q, ErrInvalidCursor
+		}
+		for i, k := range c.Keys {
+			switch v := k.(type) {
+			case json.Number:
+				n, err := v.Int64()
+				if err != nil {
+					return q, ErrInvalidCursor
+				}
+				c.Keys[i] = n
+			case string:
+			default:
+				return q, ErrInvalidCursor
+			}
+		}
+		q.after = c.Keys
+	}
+	return q, nil
+}
+
+func (q PageQuery) sortParam() string {
+	if q.Desc {
+		return "-" + q.Sort.Name
+	}
+	return q.Sort.Name
+}
+
+// After returns a condition (to AND into the WHERE clause) that skips the rows
+// already returned, and its arguments. It is "1" on the first page.
+func (q PageQuery) After() (string, []any) {
+	if q.after == nil {
+		return "1", nil
+	}
+	op := ">"
+	if q.Desc {
+		op = "<"
+	}
+	marks := strings.TrimSuffix(strings.Repeat("?, ", len(q.after)), ", ")
+	return "(" + strings.Join(q.Sort.Keys, ", ") + ") " + op + " (" + marks + ")", q.after
+}
+
+// OrderBy returns the ORDER BY list for the query's sort.
+func (q PageQuery) OrderBy() string {
+	dir := " ASC"
+	if q.Desc {
+		dir = " DESC"
+	}
+	return strings.Join(q.Sort.Keys, dir+", ") + dir
+}
+
+// FetchLimit is how many rows to select: one more than a page, to tell
+// whether another page follows.
+func (q PageQuery) FetchLimit() int {
+	return q.Limit + 1
+}
+
+// NewPage wraps up to FetchLimit rows. keys returns a row's values for the
+// sort's Keys, in the same order, as selected by the query.
+func NewPage[T any](items []T, q PageQuery, keys func(T) []any) Page[T] {
+	p := Page[T]{Items: items}
+	if p.Items == nil {
+		p.Items = []T{}
+	}
+	if len(items) > q.Limit {
+		p.Items = items[:q.Limit]
+		raw, _ := json.Marshal(cursor{Sort: q.sortParam(), Keys: keys(p.Items[q.Limit-1])})
+		p.NextCursor = base64.RawURLEncoding.EncodeToString(raw)
+	}
+	return p
+}
+
+// ItemFilter narrows the course material lists (books, articles, assignments)
+// for one user; nil fields match everything.
+type ItemFilter struct {
+	Completed   *bool  // done by the user (a book: all of its chapters)
+	HasDeadline *bool  // has a deadline (a book: any chapter)
+	DueBefore   *int64 // deadline (unix seconds) before this
+}
+
+// ParseItemFilter reads ?completed=&hasDeadline=&dueBefore= from r.
+// Errors: ErrInvalidFilter.
+func ParseItemFilter(r *http.Request) (ItemFilter, error) {
+	var f ItemFilter
+	var err error
+	if f.Completed, err = OptionalBoolQuery(r, "completed"); err != nil {
+		return f, ErrInvalidFilter.WithMessage("invalid completed")
+	}
+	if f.HasDeadline, err = OptionalBoolQuery(r, "hasDeadline"); err != nil {
+		return f, ErrInvalidFilter.WithMessage("invalid hasDeadline")
+	}
+	if f.DueBefore, err = OptionalInt64Query(r, "dueBefore"); err != nil {
+		return f, ErrInvalidFilter.WithMessage("invalid dueBefore")
+	}
+	return f, nil
+}
+
+// NoDeadline is the sort key of items without a deadline, so they sort last.
+const NoDeadline = int64(math.MaxInt64)
+
+// DeadlineKey returns the sort key for an optional deadline (see NoDeadline).
+func DeadlineKey(deadline *int64) int64 {
+	if deadline == nil {
+		return NoDeadline
+	}
+	return *deadline
+}
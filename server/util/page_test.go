@@ -0,0 +1,91 @@
+package util
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+var (
+	byName = Sort{Name: "name", Keys: []string{"name", "id"}}
+	byDate = Sort{Name: "date", Keys: []string{"created_at", "id"}}
+)
+
+func pageQuery(t *testing.T, qs url.Values) (PageQuery, error) {
+	t.Helper()
+	return ParsePageQuery(httptest.NewRequest("GET", "/items?"+qs.Encode(), nil), byName, byDate)
+}
+
+type row struct {
+	name string
+	id   int64
+}
+
+func TestPageCursorRoundTrip(t *testing.T) {
+	q, err := pageQuery(t, url.Values{"limit": {"2"}, "sort": {"-name"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cond, args := q.After(); cond != "1" || args != nil {
+		t.Errorf("first page After = %q, %v", cond, args)
+	}
+	if got := q.OrderBy(); got != "name DESC, id DESC" {
+		t.Errorf("OrderBy = %q", got)
+	}
+
+	rows := []row{{"c", 3}, {"b", 2}, {"a", 1}} // FetchLimit rows
+	p := NewPage(rows, q, func(r row) []any { return []any{r.name, r.id} })
+	if len(p.Items) != 2 || p.NextCursor == "" {
+		t.Fatalf("page = %+v", p)
+	}
+
+	q, err = pageQuery(t, url.Values{"limit": {"2"}, "sort": {"-name"}, "cursor": {p.NextCursor}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cond, args := q.After()
+	if cond != "(name, id) < (?, ?)" || !reflect.DeepEqual(args, []any{"b", int64(2)}) {
+		t.Errorf("After = %q, %#v", cond, args)
+	}
+
+	last := NewPage(rows[2:], q, func(r row) []any { return []any{r.name, r.id} })
+	if last.NextCursor != "" {
+		t.Errorf("last page has a cursor")
+	}
+	if empty := NewPage[row](nil, q, nil); empty.Items == nil {
+		t.Errorf("empty page has nil items, want []")
+	}
+}
+
+func TestParsePageQueryErrors(t *testing.T) {
+	q, _ := pageQuery(t, url.Values{"limit": {"1"}})
+	p := NewPage([]row{{"a", 1}, {"b", 2}}, q, func(r row) []any { return []any{r.name, r.id} })
+	enc := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	for _, tc := range []struct {
+		qs   url.Values
+		want error
+	}{
+		{url.Values{"limit": {"0"}}, ErrInvalidLimit},
+		{url.Values{"limit": {"ten"}}, ErrInvalidLimit},
+		{url.Values{"sort": {"size"}}, ErrInvalidSort},
+		{url.Values{"cursor": {"!!"}}, ErrInvalidCursor},
+		{url.Values{"cursor": {p.NextCursor}, "sort": {"-name"}}, ErrInvalidCursor}, // issued for ascending
+		{url.Values{"cursor": {p.NextCursor}, "sort": {"date"}}, ErrInvalidCursor},
+		{url.Values{"cursor": {enc(`{"s":"name","k":["a"]}`)}}, ErrInvalidCursor},
+		{url.Values{"cursor": {enc(`{"s":"name","k":["a",1.5]}`)}}, ErrInvalidCursor},
+		{url.Values{"cursor": {enc(`{"s":"name","k":["a",null]}`)}}, ErrInvalidCursor},
+	} {
+		if _, err := pageQuery(t, tc.qs); !errors.Is(err, tc.want) {
+			t.Errorf("%v: %v, want %v", tc.qs, err, tc.want)
+		}
+	}
+
+	q, err := pageQuery(t, url.Values{"limit": {"5000"}})
+	if err != nil || q.Limit != MaxPageLimit || q.FetchLimit() != MaxPageLimit+1 {
+		t.Errorf("limit=5000: %d, %v", q.Limit, err)
+	}
+}
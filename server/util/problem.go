@@ -0,0 +1,101 @@
+package util
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Error is an error meant for the client: the HTTP status it maps to and a
+// stable machine-readable code. Services return package-level sentinels of
+// this type; callers compare with errors.Is, which matches on Code, so a copy
+// from WithMessage still matches its sentinel.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+// NewError returns an Error, typically for a package-level sentinel.
+func NewError(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Is reports whether target is an *Error with the same code.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithMessage returns a copy of e with a more specific message.
+func (e *Error) WithMessage(msg string) *Error {
+	c := *e
+	c.Message = msg
+	return &c
+}
+
+// Errors shared across packages.
+var (
+	ErrInvalidInput = NewError(http.StatusBadRequest, "invalid_input", "invalid input")
+	ErrForbidden    = NewError(http.StatusForbidden, "forbidden", "forbidden")
+	ErrNotFound     = NewError(http.StatusNotFound, "not_found", "not found")
+)
+
+// Problem is an RFC 7807 error body (application/problem+json). Code is an
+// extension member clients can branch on; Detail is for people.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Code   string `json:"code"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// WriteProblem writes a problem+json response.
+func WriteProblem(w http.ResponseWriter, status int, code, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   code,
+		Detail: detail,
+	})
+}
+
+// HTTPError replaces http.Error for handler-level failures (bad parameters,
+// missing auth, ...): the code is derived from the status, e.g. "bad_request".
+func HTTPError(w http.ResponseWriter, detail string, status int) {
+	WriteProblem(w, status, StatusCode(status), detail)
+}
+
+// StatusCode is the generic problem code for an HTTP status, e.g. "not_found".
+func StatusCode(status int) string {
+	text := strings.ToLower(http.StatusText(status))
+	if text == "" {
+		return "error"
+	}
+	return strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text)
+}
+
+// WriteError maps a service error to a response: an *Error to its status and
+// code, sql.ErrNoRows to 404, anything else to a logged 500.
+func WriteError(w http.ResponseWriter, err error) {
+	var e *Error
+	switch {
+	case errors.As(err, &e):
+		WriteProblem(w, e.Status, e.Code, e.Message)
+	case errors.Is(err, sql.ErrNoRows):
+		WriteProblem(w, http.StatusNotFound, ErrNotFound.Code, ErrNotFound.Message)
+	default:
+		log.Printf("internal error: %v", err)
+		HTTPError(w, "internal error", http.StatusInternalServerError)
+	}
+}
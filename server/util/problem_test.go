@@ -0,0 +1,48 @@
+package util
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteError(t *testing.T) {
+	errTaken := NewError(http.StatusConflict, "name_taken", "name taken")
+	cases := []struct {
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{errTaken, http.StatusConflict, "name_taken", "name taken"},
+		{fmt.Errorf("creating: %w", errTaken.WithMessage("CS101 is taken")), http.StatusConflict, "name_taken", "CS101 is taken"},
+		{sql.ErrNoRows, http.StatusNotFound, "not_found", "not found"},
+		{errors.New("disk on fire"), http.StatusInternalServerError, "internal_server_error", "internal error"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		WriteError(w, c.err)
+		if w.Code != c.status || w.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("%v: %d %s", c.err, w.Code, w.Header().Get("Content-Type"))
+			continue
+		}
+		var p Problem
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatal(err)
+		}
+		if p.Status != c.status || p.Code != c.code || p.Detail != c.detail || p.Title != http.StatusText(c.status) {
+			t.Errorf("%v: %+v", c.err, p)
+		}
+	}
+
+	if !errors.Is(errTaken.WithMessage("other words"), errTaken) {
+		t.Error("a reworded error no longer matches its sentinel")
+	}
+	if StatusCode(http.StatusTeapot) != "im_a_teapot" {
+		t.Errorf("StatusCode(418) = %q", StatusCode(http.StatusTeapot))
+	}
+}
@@ -0,0 +1,54 @@
+package util
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// foldSpecial spells out letters that don't decompose into a base letter
+// plus accents.
+var foldSpecial = map[rune]string{
+	'æ': "ae", 'œ': "oe", 'ø': "o", 'ß': "ss", 'đ': "d", 'ð': "d", 'ħ': "h",
+	'ı': "i", 'ŀ': "l", 'ł': "l", 'ŧ': "t", 'þ': "th",
+}
+
+// FoldName reduces a name to a comparison key: lowercase, accents removed,
+// punctuation dropped and whitespace collapsed. "Universität Zürich" and
+// "universitat  zurich" share a key, as do "M.I.T." and "MIT".
+func FoldName(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range norm.NFD.String(strings.ToLower(s)) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// combining accent, split off its letter by NFD
+		case foldSpecial[r] != "":
+			b.WriteString(foldSpecial[r])
+			space = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+			space = false
+		case unicode.IsSpace(r) || r == '-' || r == '_':
+			if !space && b.Len() > 0 {
+				b.WriteByte(' ')
+				space = true
+			}
+		}
+		// other punctuation (".", "'", ",", ...) is dropped
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// Truncate cuts s to at most max bytes without splitting a UTF-8 character.
+func Truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
@@ -0,0 +1,21 @@
+package util
+
+import "testing"
+
+func TestFoldName(t *testing.T) {
+	for in, want := range map[string]string{
+		"Universität  Zürich":                  "universitat zurich",
+		"Université de Montréal":               "universite de montreal",
+		"Politechnika Łódzka":                  "politechnika lodzka",
+		"Đại học Quốc gia Hà Nội":              "dai hoc quoc gia ha noi",
+		"Ångström-Laboratoriet":                "angstrom laboratoriet",
+		"Universitatea Babeș-Bolyai":           "universitatea babes bolyai",
+		"M.I.T.":                               "mit",
+		"Technische Universität München (TUM)": "technische universitat munchen tum",
+		"Straße":                               "strasse",
+	} {
+		if got := FoldName(in); got != want {
+			t.Errorf("FoldName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
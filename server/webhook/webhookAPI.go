@@ -0,0 +1,225 @@
+package webhook
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"example.com/sqlite-server/invite"
+	"example.com/sqlite-server/session"
+	"example.com/sqlite-server/util"
+)
+
+func RegisterWebhookRoutes(mux util.Router, db *sql.DB) {
+	mux.HandleFunc("/webhooks", session.RequireAuth(db, webhooksHandler(db)))
+	mux.HandleFunc("/webhooks/", session.RequireAuth(db, webhookItemDispatcher(db)))
+}
+
+// requireCurator writes the error response and returns false unless uid
+// curates courseID.
+func requireCurator(w http.ResponseWriter, db *sql.DB, uid string, courseID int64) bool {
+	ok, err := invite.IsCourseCurator(db, uid, courseID)
+	if err == sql.ErrNoRows {
+		util.HTTPError(w, "course not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		util.WriteError(w, err)
+		return false
+	}
+	if !ok {
+		util.HTTPError(w, "forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func webhooksHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			listWebhooksHandler(db)(w, r)
+		case http.MethodPost:
+			createWebhookHandler(db)(w, r)
+		case http.MethodDelete:
+			deleteWebhookHandler(db)(w, r)
+		default:
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// GET /webhooks?courseId=...  (curators)
+func listWebhooksHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, _ := session.UserIDFromCtx(r.Context())
+		courseID, err := util.ParseInt64Query(r, "courseId")
+		if err != nil || courseID <= 0 {
+			util.HTTPError(w, "courseId is required", http.StatusBadRequest)
+			return
+		}
+		if !requireCurator(w, db, uid, courseID) {
+			return
+		}
+		list, err := ListWebhooks(db, courseID)
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		util.WriteJSON(w, list, http.StatusOK)
+	}
+}
+
+// POST /webhooks  (curators)
+// Body: { "courseId": 1, "url": "https://chat.example.com/hooks/..." }
+// The signing secret is only returned here.
+func createWebhookHandler(db *sql.DB) http.HandlerFunc {
+	type payload struct {
+		CourseID int64  `json:"courseId"`
+		URL      string `json:"url"`
+	}
+	type response struct {
+		Webhook
+		Secret string `json:"secret"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, _ := session.UserIDFromCtx(r.Context())
+
+		var p payload
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil || p.CourseID <= 0 || strings.TrimSpace(p.URL) == "" {
+			util.HTTPError(w, "courseId and url are required", http.StatusBadRequest)
+			return
+		}
+		if !requireCurator(w, db, uid, p.CourseID) {
+			return
+		}
+		wh, secret, err := CreateWebhook(db, p.CourseID, uid, p.URL)
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		util.WriteJSON(w, response{Webhook: wh, Secret: secret}, http.StatusCreated)
+	}
+}
+
+// DELETE /webhooks  (curators)
+// Body: { "webhookId": 1 }
+func deleteWebhookHandler(db *sql.DB) http.HandlerFunc {
+	type payload struct {
+		WebhookID int64 `json:"webhookId"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		uid, _ := session.UserIDFromCtx(r.Context())
+
+		var p payload
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil || p.WebhookID <= 0 {
+			util.HTTPError(w, "webhookId is required", http.StatusBadRequest)
+			return
+		}
+		courseID, err := WebhookCourse(db, p.WebhookID)
+		if err == sql.ErrNoRows {
+			util.HTTPError(w, "webhook not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		if !requireCurator(w, db, uid, courseID) {
+			return
+		}
+		if err := DeleteWebhook(db, p.WebhookID); err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Dispatcher for /webhooks/{id}/(deliveries|ping).
+func webhookItemDispatcher(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/webhooks/"), "/")
+		if len(parts) != 2 {
+			util.HTTPError(w, "not found", http.StatusNotFound)
+			return
+		}
+		id, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil || id <= 0 {
+			util.HTTPError(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+
+		var h http.HandlerFunc
+		method := http.MethodGet
+		switch parts[1] {
+		case "deliveries":
+			h = deliveriesHandler(db, id)
+		case "ping":
+			h, method = pingHandler(db, id), http.MethodPost
+		default:
+			util.HTTPError(w, "not found", http.StatusNotFound)
+			return
+		}
+		if r.Method != method {
+			util.HTTPError(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		uid, _ := session.UserIDFromCtx(r.Context())
+		courseID, err := WebhookCourse(db, id)
+		if err == sql.ErrNoRows {
+			util.HTTPError(w, "webhook not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		if !requireCurator(w, db, uid, courseID) {
+			return
+		}
+		h(w, r)
+	}
+}
+
+// GET /webhooks/{id}/deliveries[?limit=50]  (curators)
+// The delivery log, newest first; limit is at most 200.
+func deliveriesHandler(db *sql.DB, webhookID int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 50
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 200 {
+				util.HTTPError(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+		list, err := ListDeliveries(db, webhookID, limit)
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		util.WriteJSON(w, list, http.StatusOK)
+	}
+}
+
+// POST /webhooks/{id}/ping  (curators)
+// Sends a "ping" payload now and returns its delivery, failed or not.
+func pingHandler(db *sql.DB, webhookID int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		d, err := Ping(r.Context(), db, webhookID)
+		if err != nil {
+			util.WriteError(w, err)
+			return
+		}
+		util.WriteJSON(w, d, http.StatusOK)
+	}
+}
@@ -0,0 +1,277 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/sqlite-server/events"
+	"example.com/sqlite-server/util"
+)
+
+// backoff is the wait before each retry; a delivery still failing after the
+// last one is given up on (about 15 hours after the first attempt).
+var backoff = []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 12 * time.Hour}
+
+const (
+	attemptTimeout = 10 * time.Second
+	pollInterval   = 30 * time.Second
+	batchSize      = 20
+)
+
+// client posts deliveries; see util.PublicClient.
+var client = util.PublicClient(attemptTimeout, func() bool { return AllowPrivate })
+
+// wake nudges the delivery loop when something was queued.
+var wake = make(chan struct{}, 1)
+
+func nudge() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run queues the events published on bus and delivers them until ctx is
+// done, finishing the delivery in progress. Deliveries left pending by a
+// previous run are picked up.
+func Run(ctx context.Context, db *sql.DB, bus *events.Bus) {
+	var wg sync.WaitGroup
+	wg.Go(func() { listen(ctx, db, bus) })
+	wg.Go(func() { deliverLoop(ctx, db) })
+	wg.Wait()
+}
+
+func listen(ctx context.Context, db *sql.DB, bus *events.Bus) {
+	var last int64
+	for {
+		sub, backlog, _ := bus.Subscribe(last)
+		for _, e := range backlog {
+			queue(db, e)
+			last = e.ID
+		}
+	receive:
+		for {
+			select {
+			case <-ctx.Done():
+				sub.Close()
+				return
+			case e, ok := <-sub.C():
+				if !ok {
+					break receive
+				}
+				queue(db, e)
+				last = e.ID
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		// Fell behind and was dropped: catch up from the bus's history.
+		log.Printf("webhooks: resubscribing after event %d", last)
+	}
+}
+
+func queue(db *sql.DB, e events.Event) {
+	n, err := Enqueue(db, e)
+	if err != nil {
+		log.Printf("webhooks: queueing event %d: %v", e.ID, err)
+		return
+	}
+	if n > 0 {
+		nudge()
+	}
+}
+
+func deliverLoop(ctx context.Context, db *sql.DB) {
+	t := time.NewTicker(pollInterval)
+	defer t.Stop()
+	for {
+		for n := batchSize; n == batchSize && ctx.Err() == nil; {
+			n = deliverDue(ctx, db)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-t.C:
+		}
+	}
+}
+
+type due struct {
+	id, webhookID int64
+	event         string
+	payload       []byte
+	attempts      int
+	url, secret   string
+}
+
+// deliverDue attempts up to batchSize deliveries whose time has come and
+// returns how many it tried. It stops early once ctx is done; an attempt
+// under way is finished, not cut off.
+func deliverDue(ctx context.Context, db *sql.DB) int {
+	rows, err := db.Query(`
+		SELECT d.id, d.webhook_id, d.event, d.payload, d.attempts, w.url, w.secret
+		  FROM webhook_deliveries d
+		  JOIN webhooks w ON w.id = d.webhook_id
+		 WHERE d.status = 'pending' AND d.next_attempt_at <= ?
+		 ORDER BY d.next_attempt_at, d.id
+		 LIMIT ?
+	`, time.Now().Unix(), batchSize)
+	if err != nil {
+		log.Printf("webhooks: listing due deliveries: %v", err)
+		return 0
+	}
+	var batch []due
+	for rows.Next() {
+		var d due
+		var payload string
+		if err := rows.Scan(&d.id, &d.webhookID, &d.event, &payload, &d.attempts, &d.url, &d.secret); err != nil {
+			rows.Close()
+			log.Printf("webhooks: listing due deliveries: %v", err)
+			return 0
+		}
+		d.payload = []byte(payload)
+		batch = append(batch, d)
+	}
+	rows.Close()
+
+	for i, d := range batch {
+		if ctx.Err() != nil {
+			return i
+		}
+		code, err := post(context.Background(), d)
+		if err := record(db, d, code, err, true); err != nil {
+			log.Printf("webhooks: recording delivery %d: %v", d.id, err)
+		}
+	}
+	return len(batch)
+}
+
+// post makes one attempt. The signature is an HMAC-SHA256, keyed with the
+// webhook's secret, of the timestamp header, a dot, and the body.
+func post(ctx context.Context, d due) (int, error) {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(d.secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(d.payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(d.payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "reading-webhooks/1")
+	req.Header.Set("X-Webhook-Event", d.event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(d.id, 10))
+	req.Header.Set("X-Webhook-Timestamp", ts)
+	req.Header.Set("X-Webhook-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	res, err := client.Do(req)
+	if err != nil {
+		if errors.Is(err, util.ErrPrivateAddress) {
+			return 0, util.ErrPrivateAddress
+		}
+		return 0, err
+	}
+	defer res.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(res.Body, 200))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg := res.Status
+		if s := strings.TrimSpace(string(snippet)); s != "" {
+			msg += ": " + s
+		}
+		return res.StatusCode, errors.New(msg)
+	}
+	return res.StatusCode, nil
+}
+
+// record stores the outcome of an attempt. A failure is rescheduled per
+// backoff when retry is set, and otherwise marks the delivery failed.
+func record(db *sql.DB, d due, code int, attemptErr error, retry bool) error {
+	var status sql.NullInt64
+	if code > 0 {
+		status = sql.NullInt64{Int64: int64(code), Valid: true}
+	}
+	if attemptErr == nil {
+		_, err := db.Exec(`
+			UPDATE webhook_deliveries
+			   SET status = 'delivered', attempts = attempts + 1, response_status = ?, error = NULL,
+			       next_attempt_at = NULL, delivered_at = strftime('%s','now')
+			 WHERE id = ?
+		`, status, d.id)
+		return err
+	}
+
+	msg := attemptErr.Error()
+	var te interface{ Timeout() bool }
+	if errors.As(attemptErr, &te) && te.Timeout() {
+		msg = fmt.Sprintf("no response within %s", attemptTimeout)
+	}
+	if retry && d.attempts < len(backoff) {
+		next := time.Now().Add(backoff[d.attempts]).Unix()
+		_, err := db.Exec(`
+			UPDATE webhook_deliveries
+			   SET attempts = attempts + 1, response_status = ?, error = ?, next_attempt_at = ?
+			 WHERE id = ?
+		`, status, msg, next, d.id)
+		return err
+	}
+	_, err := db.Exec(`
+		UPDATE webhook_deliveries
+		   SET status = 'failed', attempts = attempts + 1, response_status = ?, error = ?, next_attempt_at = NULL
+		 WHERE id = ?
+	`, status, msg, d.id)
+	return err
+}
+
+// Ping sends a "ping" to a webhook right away and returns the logged
+// delivery. Pings aren't retried: the caller sees the outcome and can ping
+// again. sql.ErrNoRows if the webhook doesn't exist.
+func Ping(ctx context.Context, db *sql.DB, webhookID int64) (Delivery, error) {
+	d := due{webhookID: webhookID, event: "ping"}
+	p := Payload{Type: "ping", At: time.Now().Unix()}
+	if err := db.QueryRow(`
+		SELECT w.url, w.secret, c.id, c.code, c.name
+		  FROM webhooks w JOIN courses c ON c.id = w.course_id
+		 WHERE w.id = ?
+	`, webhookID).Scan(&d.url, &d.secret, &p.Course.ID, &p.Course.Code, &p.Course.Name); err != nil {
+		return Delivery{}, err
+	}
+	var err error
+	if d.payload, err = json.Marshal(p); err != nil {
+		return Delivery{}, err
+	}
+	res, err := db.Exec(`
+		INSERT INTO webhook_deliveries (webhook_id, event, payload) VALUES (?, 'ping', ?)
+	`, webhookID, string(d.payload))
+	if err != nil {
+		return Delivery{}, err
+	}
+	d.id, _ = res.LastInsertId()
+
+	code, attemptErr := post(ctx, d)
+	if err := record(db, d, code, attemptErr, false); err != nil {
+		return Delivery{}, err
+	}
+	return scanDelivery(db.QueryRow(`
+		SELECT id, webhook_id, event, status, attempts, response_status, error,
+		       created_at, next_attempt_at, delivered_at, payload
+		  FROM webhook_deliveries
+		 WHERE id = ?
+	`, d.id))
+}
@@ -0,0 +1,124 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"example.com/sqlite-server/store"
+	"example.com/sqlite-server/util"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`
+		INSERT INTO users (id, email, password) VALUES ('u1', 'a@x.io', '');
+		INSERT INTO universities (id, name) VALUES ('uni', 'Uni');
+		INSERT INTO courses (id, university_id, year, term, code, name) VALUES (1, 'uni', 2026, 1, 'CS101', 'Intro');
+	`); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func allowPrivate(t *testing.T, allow bool) {
+	old := AllowPrivate
+	AllowPrivate = allow
+	t.Cleanup(func() { AllowPrivate = old })
+}
+
+func TestPingSignature(t *testing.T) {
+	db := openTestDB(t)
+	allowPrivate(t, true)
+
+	var secret string
+	got := make(chan error, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts := r.Header.Get("X-Webhook-Timestamp")
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(ts + "."))
+		mac.Write(body)
+		want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		sent, err := strconv.ParseInt(ts, 10, 64)
+		switch {
+		case !hmac.Equal([]byte(r.Header.Get("X-Webhook-Signature")), []byte(want)):
+			got <- fmt.Errorf("signature %s, want %s", r.Header.Get("X-Webhook-Signature"), want)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		case err != nil || time.Since(time.Unix(sent, 0)) > time.Minute:
+			got <- fmt.Errorf("timestamp %q", ts)
+		case r.Header.Get("X-Webhook-Event") != "ping":
+			got <- fmt.Errorf("event %q", r.Header.Get("X-Webhook-Event"))
+		default:
+			var p Payload
+			if err := json.Unmarshal(body, &p); err != nil || p.Course.Code != "CS101" {
+				got <- fmt.Errorf("payload %s", body)
+			} else {
+				got <- nil
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	wh, s, err := CreateWebhook(db, 1, "u1", receiver.URL+"/hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret = s
+	d, err := Ping(context.Background(), db, wh.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-got; err != nil {
+		t.Error(err)
+	}
+	if d.Status != "delivered" || d.ResponseStatus == nil || *d.ResponseStatus != http.StatusNoContent {
+		t.Errorf("delivery = %+v", d)
+	}
+}
+
+func TestPingRefusesPrivateAddress(t *testing.T) {
+	db := openTestDB(t)
+	allowPrivate(t, false)
+
+	hit := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { hit = true }))
+	defer receiver.Close()
+
+	if _, _, err := CreateWebhook(db, 1, "u1", receiver.URL); !errors.Is(err, ErrPrivateURL) {
+		t.Errorf("create: %v, want ErrPrivateURL", err)
+	}
+	// Registered while allowed, or a name that later resolves privately: the
+	// dialer still refuses it.
+	res, err := db.Exec(`INSERT INTO webhooks (course_id, url, secret, created_by) VALUES (1, ?, 's', 'u1')`, receiver.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+	d, err := Ping(context.Background(), db, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hit || d.Status != "failed" || d.Error == nil || *d.Error != util.ErrPrivateAddress.Error() {
+		t.Errorf("delivery = %+v (receiver hit: %v)", d, hit)
+	}
+}
@@ -0,0 +1,276 @@
+// Package webhook delivers course events to URLs registered by the course's
+// curators: signed JSON POSTs, retried with backoff, with a log of every
+// delivery.
+package webhook
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"example.com/sqlite-server/events"
+	"example.com/sqlite-server/store"
+	"example.com/sqlite-server/util"
+)
+
+var (
+	ErrInvalidURL      = util.NewError(http.StatusBadRequest, "invalid_url", "url must be an absolute http or https URL")
+	ErrPrivateURL      = util.NewError(http.StatusBadRequest, "private_url", "url must point to a public address")
+	ErrTooManyWebhooks = util.NewError(http.StatusConflict, "too_many_webhooks", "a course can have at most 10 webhooks")
+)
+
+// MaxPerCourse limits the webhooks of one course.
+const MaxPerCourse = 10
+
+// KeepDeliveries is how long finished deliveries stay in the log.
+const KeepDeliveries = 30 * 24 * time.Hour
+
+// AllowPrivate lets webhooks reach loopback and private-network addresses;
+// serve sets it from WEBHOOK_ALLOW_PRIVATE. Off by default: any course
+// creator is a curator, and the server shouldn't POST into its own network
+// for them.
+var AllowPrivate bool
+
+// Webhook is a registered URL (the secret is only shown at creation).
+type Webhook struct {
+	ID         int64   `json:"id"`
+	CourseID   int64   `json:"courseId"`
+	URL        string  `json:"url"`
+	CreatedAt  int64   `json:"created_at"`
+	LastStatus *string `json:"lastStatus,omitempty" enum:"pending|delivered|failed" doc:"of the most recent delivery"`
+}
+
+// Delivery is one event sent (or being sent) to a webhook.
+type Delivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhookId"`
+	Event          string          `json:"event" enum:"created|deleted|deadline|ping"`
+	Status         string          `json:"status" enum:"pending|delivered|failed"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"responseStatus,omitempty" doc:"HTTP status of the last attempt"`
+	Error          *string         `json:"error,omitempty" doc:"why the last attempt failed"`
+	CreatedAt      int64           `json:"created_at"`
+	NextAttemptAt  *int64          `json:"next_attempt_at,omitempty"`
+	DeliveredAt    *int64          `json:"delivered_at,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+// Payload is the JSON body POSTed to a webhook.
+type Payload struct {
+	Type     string `json:"type" enum:"created|deleted|deadline|ping"`
+	Kind     string `json:"kind,omitempty" enum:"book|chapter|article|assignment"`
+	ItemID   int64  `json:"itemId,omitempty"`
+	Course   Course `json:"course"`
+	Deadline *int64 `json:"deadline,omitempty" doc:"deadline events; absent when cleared"`
+	Item     any    `json:"item,omitempty" doc:"created events: the new item"`
+	At       int64  `json:"at"`
+}
+
+// Course identifies the course in a payload.
+type Course struct {
+	ID   int64  `json:"id"`
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// checkURL validates a webhook URL as registered. Names are resolved again
+// on every delivery, where the dialer enforces the same rule.
+func checkURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return "", ErrInvalidURL
+	}
+	if !AllowPrivate && util.PrivateHost(u.Hostname()) {
+		return "", ErrPrivateURL
+	}
+	return u.String(), nil
+}
+
+// CreateWebhook registers rawURL for courseID and returns it with its signing
+// secret, which is not shown again.
+// Errors: ErrInvalidURL, ErrPrivateURL, ErrTooManyWebhooks, sql.ErrNoRows.
+func CreateWebhook(db *sql.DB, courseID int64, createdBy, rawURL string) (Webhook, string, error) {
+	u, err := checkURL(rawURL)
+	if err != nil {
+		return Webhook{}, "", err
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(1) FROM webhooks WHERE course_id = ?`, courseID).Scan(&n); err != nil {
+		return Webhook{}, "", err
+	}
+	if n >= MaxPerCourse {
+		return Webhook{}, "", ErrTooManyWebhooks
+	}
+
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return Webhook{}, "", err
+	}
+	secret := "whsec_" + base64.RawURLEncoding.EncodeToString(buf)
+
+	wh := Webhook{CourseID: courseID, URL: u, CreatedAt: time.Now().Unix()}
+	res, err := db.Exec(`
+		INSERT INTO webhooks (course_id, url, secret, created_by, created_at) VALUES (?, ?, ?, ?, ?)
+	`, courseID, wh.URL, secret, createdBy, wh.CreatedAt)
+	if err != nil {
+		if store.IsForeignKeyViolation(err) {
+			return Webhook{}, "", sql.ErrNoRows
+		}
+		return Webhook{}, "", err
+	}
+	wh.ID, _ = res.LastInsertId()
+	return wh, secret, nil
+}
+
+// ListWebhooks returns a course's webhooks, oldest first.
+func ListWebhooks(db *sql.DB, courseID int64) ([]Webhook, error) {
+	rows, err := db.Query(`
+		SELECT w.id, w.course_id, w.url, w.created_at,
+		       (SELECT d.status FROM webhook_deliveries d WHERE d.webhook_id = w.id ORDER BY d.id DESC LIMIT 1)
+		  FROM webhooks w
+		 WHERE w.course_id = ?
+		 ORDER BY w.id
+	`, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Webhook, 0, 4)
+	for rows.Next() {
+		var wh Webhook
+		var last sql.NullString
+		if err := rows.Scan(&wh.ID, &wh.CourseID, &wh.URL, &wh.CreatedAt, &last); err != nil {
+			return nil, err
+		}
+		if last.Valid {
+			wh.LastStatus = &last.String
+		}
+		out = append(out, wh)
+	}
+	return out, rows.Err()
+}
+
+// WebhookCourse returns the course a webhook belongs to (for authorization).
+// sql.ErrNoRows if missing.
+func WebhookCourse(db *sql.DB, webhookID int64) (int64, error) {
+	var courseID int64
+	err := db.QueryRow(`SELECT course_id FROM webhooks WHERE id = ?`, webhookID).Scan(&courseID)
+	return courseID, err
+}
+
+// DeleteWebhook removes a webhook and its delivery log. sql.ErrNoRows if missing.
+func DeleteWebhook(db *sql.DB, webhookID int64) error {
+	res, err := db.Exec(`DELETE FROM webhooks WHERE id = ?`, webhookID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListDeliveries returns a webhook's most recent deliveries, newest first.
+func ListDeliveries(db *sql.DB, webhookID int64, limit int) ([]Delivery, error) {
+	rows, err := db.Query(`
+		SELECT id, webhook_id, event, status, attempts, response_status, error,
+		       created_at, next_attempt_at, delivered_at, payload
+		  FROM webhook_deliveries
+		 WHERE webhook_id = ?
+		 ORDER BY id DESC
+		 LIMIT ?
+	`, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Delivery, 0, limit)
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func scanDelivery(row interface{ Scan(...any) error }) (Delivery, error) {
+	var d Delivery
+	var code, next, delivered sql.NullInt64
+	var msg sql.NullString
+	var payload string
+	if err := row.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Status, &d.Attempts, &code, &msg,
+		&d.CreatedAt, &next, &delivered, &payload); err != nil {
+		return Delivery{}, err
+	}
+	if code.Valid {
+		c := int(code.Int64)
+		d.ResponseStatus = &c
+	}
+	if msg.Valid {
+		d.Error = &msg.String
+	}
+	if next.Valid {
+		d.NextAttemptAt = &next.Int64
+	}
+	if delivered.Valid {
+		d.DeliveredAt = &delivered.Int64
+	}
+	d.Payload = json.RawMessage(payload)
+	return d, nil
+}
+
+// Enqueue queues e for every webhook of its course. Only changes to the
+// course's materials are sent; progress is personal and never leaves.
+func Enqueue(db *sql.DB, e events.Event) (int, error) {
+	switch e.Type {
+	case events.TypeCreated, events.TypeDeleted, events.TypeDeadline:
+	default:
+		return 0, nil
+	}
+	var hooks int
+	if err := db.QueryRow(`SELECT COUNT(1) FROM webhooks WHERE course_id = ?`, e.CourseID).Scan(&hooks); err != nil || hooks == 0 {
+		return 0, err
+	}
+
+	p := Payload{Type: e.Type, Kind: e.Kind, ItemID: e.ItemID, Deadline: e.Deadline, Item: e.Item, At: e.At}
+	if err := db.QueryRow(`SELECT id, code, name FROM courses WHERE id = ?`, e.CourseID).
+		Scan(&p.Course.ID, &p.Course.Code, &p.Course.Name); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil // the course is gone, and its webhooks with it
+		}
+		return 0, err
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		return 0, err
+	}
+	res, err := db.Exec(`
+		INSERT INTO webhook_deliveries (webhook_id, event, payload, next_attempt_at)
+		SELECT id, ?, ?, strftime('%s','now') FROM webhooks WHERE course_id = ?
+	`, e.Type, string(body), e.CourseID)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// PruneDeliveries drops finished deliveries created before cutoff.
+func PruneDeliveries(db *sql.DB, cutoff time.Time) (int64, error) {
+	res, err := db.Exec(`
+		DELETE FROM webhook_deliveries WHERE status <> 'pending' AND created_at < ?
+	`, cutoff.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
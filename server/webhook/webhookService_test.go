@@ -0,0 +1,93 @@
+package webhook
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"example.com/sqlite-server/events"
+)
+
+func TestCreateWebhookChecksURL(t *testing.T) {
+	db := openTestDB(t)
+	allowPrivate(t, false)
+
+	for _, tc := range []struct {
+		url  string
+		want error
+	}{
+		{"ftp://example.com/hook", ErrInvalidURL},
+		{"/hook", ErrInvalidURL},
+		{"https://user:pw@example.com/hook", ErrInvalidURL},
+		{"http://127.0.0.1:8080/hook", ErrPrivateURL},
+		{"http://10.1.2.3/hook", ErrPrivateURL},
+		{"http://app.localhost/hook", ErrPrivateURL},
+	} {
+		if _, _, err := CreateWebhook(db, 1, "u1", tc.url); !errors.Is(err, tc.want) {
+			t.Errorf("%s: %v, want %v", tc.url, err, tc.want)
+		}
+	}
+	if _, _, err := CreateWebhook(db, 99, "u1", "https://example.com/hook"); err != sql.ErrNoRows {
+		t.Errorf("unknown course: %v, want sql.ErrNoRows", err)
+	}
+
+	wh, secret, err := CreateWebhook(db, 1, "u1", "https://example.com/hook")
+	if err != nil || wh.ID == 0 || !strings.HasPrefix(secret, "whsec_") {
+		t.Fatalf("create = %+v, %q, %v", wh, secret, err)
+	}
+	list, err := ListWebhooks(db, 1)
+	if err != nil || len(list) != 1 || list[0].URL != "https://example.com/hook" || list[0].LastStatus != nil {
+		t.Fatalf("list = %+v, %v", list, err)
+	}
+}
+
+func TestCreateWebhookLimit(t *testing.T) {
+	db := openTestDB(t)
+	for i := 0; i < MaxPerCourse; i++ {
+		if _, _, err := CreateWebhook(db, 1, "u1", fmt.Sprintf("https://example.com/hook/%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := CreateWebhook(db, 1, "u1", "https://example.com/one-more"); !errors.Is(err, ErrTooManyWebhooks) {
+		t.Errorf("create #%d: %v, want ErrTooManyWebhooks", MaxPerCourse+1, err)
+	}
+}
+
+func TestEnqueueSkipsProgress(t *testing.T) {
+	db := openTestDB(t)
+	wh, _, err := CreateWebhook(db, 1, "u1", "https://example.com/hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	progress := events.Event{Type: events.TypeProgress, Kind: events.KindChapter, ItemID: 3, CourseID: 1, UserID: "u1"}
+	if n, err := Enqueue(db, progress); err != nil || n != 0 {
+		t.Errorf("enqueue progress = %d, %v; want nothing queued", n, err)
+	}
+	created := events.Event{Type: events.TypeCreated, Kind: events.KindChapter, ItemID: 3, CourseID: 1}
+	if n, err := Enqueue(db, created); err != nil || n != 1 {
+		t.Fatalf("enqueue created = %d, %v; want 1", n, err)
+	}
+
+	ds, err := ListDeliveries(db, wh.ID, 10)
+	if err != nil || len(ds) != 1 || ds[0].Event != events.TypeCreated || ds[0].Status != "pending" {
+		t.Fatalf("deliveries = %+v, %v", ds, err)
+	}
+	var p Payload
+	if err := json.Unmarshal(ds[0].Payload, &p); err != nil || p.Course.Code != "CS101" || p.ItemID != 3 {
+		t.Errorf("payload %s: %+v, %v", ds[0].Payload, p, err)
+	}
+
+	if err := DeleteWebhook(db, wh.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteWebhook(db, wh.ID); err != sql.ErrNoRows {
+		t.Errorf("delete again: %v, want sql.ErrNoRows", err)
+	}
+	if ds, err := ListDeliveries(db, wh.ID, 10); err != nil || len(ds) != 0 {
+		t.Errorf("deliveries after delete = %+v, %v", ds, err)
+	}
+}